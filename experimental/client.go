@@ -6,16 +6,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	depclient "github.com/stolostron/kubernetes-dependency-watches/client"
+	"golang.org/x/exp/slices"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/yaml"
 
 	"github.com/stolostron/go-template-utils/v4/pkg/templates"
@@ -24,25 +37,170 @@ import (
 func main() {
 	klog.InitFlags(nil)
 
-	var hubKubeConfigPath, clusterName string
+	var opts cliOptions
 
-	flag.StringVar(&hubKubeConfigPath, "hub-kubeconfig", "", "the input kubeconfig to also resolve hub templates")
+	var replayFile string
+
+	var setArgs, valuesFiles stringSliceFlag
+
+	flag.StringVar(&opts.hubKubeConfigPath, "hub-kubeconfig", "", "the input kubeconfig to also resolve hub templates")
+	flag.StringVar(
+		&opts.clusterName,
+		"cluster-name",
+		"",
+		"the cluster name to use as .ManagedClusterName when resolving hub templates",
+	)
+	flag.BoolVar(
+		&opts.dryRun,
+		"dry-run",
+		false,
+		"validate the resolved objects with a server-side dry-run apply, which requires permission to patch them",
+	)
+	flag.BoolVar(&opts.dryRun, "validate-against-cluster", false, "alias of -dry-run")
+	flag.BoolVar(
+		&opts.diff,
+		"diff",
+		false,
+		"print a diff of the live objects and the objects as they would be after the resolved Policy is enforced "+
+			"instead of the resolved Policy, which requires permission to patch them",
+	)
+	flag.BoolVar(
+		&opts.watch,
+		"watch",
+		false,
+		"keep running and print the resolved Policy again whenever an object referenced by the templates changes",
+	)
 	flag.StringVar(
-		&clusterName, "cluster-name", "", "the cluster name to use as .ManagedClusterName when resolving hub templates",
+		&opts.resourcesDir,
+		"resources-dir",
+		"",
+		"a directory of YAML or JSON files of the objects to serve the managed cluster template lookups from instead "+
+			"of a cluster",
+	)
+	flag.StringVar(
+		&opts.recordFile,
+		"record",
+		"",
+		"the YAML file to write the objects fetched by the managed cluster template lookups to, for use with -replay",
+	)
+	flag.StringVar(
+		&replayFile,
+		"replay",
+		"",
+		"the YAML file written by -record to serve the managed cluster template lookups from instead of a cluster",
+	)
+	flag.StringVar(
+		&opts.output,
+		"o",
+		outputYAML,
+		"the output format of yaml, json, or raw, which resolves the input file as a single template that doesn't "+
+			"have to be a Policy and prints a resolved string as is",
+	)
+	flag.StringVar(&opts.output, "output", outputYAML, "alias of -o")
+	flag.StringVar(
+		&opts.outputDir,
+		"output-dir",
+		"",
+		"the directory to write the output of each input file to, with the same path as the input file has relative "+
+			"to its directory argument, instead of printing the output",
+	)
+	flag.BoolVar(
+		&opts.strict,
+		"strict",
+		false,
+		"fail with a list of the objects that the template lookups didn't find, including list lookups with no "+
+			"matching objects",
+	)
+	flag.BoolVar(
+		&opts.trace,
+		"trace",
+		false,
+		"print each lookup of the templates to stderr with the query, whether it was served from a cache or the API, "+
+			"and its duration",
+	)
+	flag.StringVar(
+		&opts.startDelim,
+		"start-delim",
+		"",
+		"the start delimiter of the managed cluster templates instead of {{, such as [[ or <%= for objects that "+
+			"already use {{ }} for another templating system, which requires -stop-delim",
+	)
+	flag.StringVar(
+		&opts.stopDelim, "stop-delim", "", "the stop delimiter of the managed cluster templates instead of }}",
+	)
+	flag.StringVar(&opts.hubStartDelim, "hub-start-delim", "{{hub", "the start delimiter of the hub templates")
+	flag.StringVar(&opts.hubStopDelim, "hub-stop-delim", "hub}}", "the stop delimiter of the hub templates")
+	flag.StringVar(
+		&opts.as,
+		"as",
+		"",
+		"the user to impersonate for the managed cluster template lookups, -dry-run, and -diff, such as the service "+
+			"account of the policy controller, to check that it's allowed to do them",
+	)
+	flag.Var(
+		&opts.asGroups,
+		"as-group",
+		"a group to impersonate with the -as argument, which can be passed multiple times",
+	)
+	flag.Var(
+		&setArgs,
+		"set",
+		"a key=value to set in .Values of the template context, where the key can be a dotted path such as a.b, "+
+			"which can be passed multiple times and overrides -values",
+	)
+	flag.Var(
+		&valuesFiles,
+		"values",
+		"a YAML file of .Values of the template context, which can be passed multiple times with later files "+
+			"overriding earlier ones",
 	)
 	flag.Parse()
 
 	args := flag.Args()
-	if len(args) != 1 {
+
+	repl := len(args) != 0 && args[0] == "repl"
+	if repl {
+		// The arguments of the repl subcommand can also follow it
+		_ = flag.CommandLine.Parse(args[1:])
+
+		if flag.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "The repl subcommand doesn't accept positional arguments")
+			os.Exit(1)
+		}
+	} else if len(args) == 0 {
 		fmt.Fprintln(
-			os.Stderr, "Exactly one positional argument of the YAML file to resolve templates must be provided",
+			os.Stderr,
+			"At least one positional argument of a YAML file, a directory of YAML files, or a glob of YAML files to "+
+				"resolve templates in, or the repl subcommand, must be provided",
 		)
 		os.Exit(1)
 	}
 
-	yamlFile := args[0]
+	var inputs []inputFile
+
+	var err error
+
+	if !repl {
+		inputs, err = expandInputs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to determine the input files: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(inputs) == 0 {
+			fmt.Fprintln(os.Stderr, "No YAML files were found in the positional arguments")
+			os.Exit(1)
+		}
+	}
 
-	if hubKubeConfigPath != "" && clusterName == "" {
+	if opts.watch && (len(inputs) > 1 || opts.outputDir != "") {
+		fmt.Fprintln(
+			os.Stderr, "The -watch argument cannot be used with multiple input files or the -output-dir argument",
+		)
+		os.Exit(1)
+	}
+
+	if opts.hubKubeConfigPath != "" && opts.clusterName == "" {
 		fmt.Fprintln(
 			os.Stderr,
 			"When a hub kubeconfig is provided, you must provide a managed cluster name for hub templates to resolve "+
@@ -51,66 +209,366 @@ func main() {
 		os.Exit(1)
 	}
 
-	processTemplate(yamlFile, hubKubeConfigPath, clusterName)
-}
+	if replayFile != "" {
+		if opts.resourcesDir != "" {
+			fmt.Fprintln(os.Stderr, "The -replay argument cannot be used with the -resources-dir argument")
+			os.Exit(1)
+		}
 
-func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
-	if yamlFile == "" {
-		fmt.Fprintln(os.Stderr, "Please specify an input YAML file using -i")
+		// A recording is just a file of objects to serve
+		opts.resourcesDir = replayFile
+	}
+
+	if opts.resourcesDir != "" && (opts.watch || opts.dryRun || opts.recordFile != "") {
+		fmt.Fprintln(
+			os.Stderr,
+			"The -resources-dir and -replay arguments cannot be used with the -watch, -dry-run, or -record arguments",
+		)
 		os.Exit(1)
 	}
 
-	// #nosec G304 -- Reading in a file is required for the tool to work.
-	yamlBytes, err := os.ReadFile(yamlFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read the file \"%s\": %v\n", yamlFile, err)
+	if opts.resourcesDir != "" && opts.as != "" {
+		fmt.Fprintln(os.Stderr, "The -resources-dir and -replay arguments cannot be used with the -as argument")
 		os.Exit(1)
 	}
 
-	policy := unstructured.Unstructured{}
+	if (opts.startDelim == "") != (opts.stopDelim == "") {
+		fmt.Fprintln(os.Stderr, "The -start-delim and -stop-delim arguments must be set together")
+		os.Exit(1)
+	}
 
-	err = yaml.Unmarshal(yamlBytes, &policy.Object)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to parse the YAML in the file \"%s\": %v\n", yamlFile, err)
+	if opts.hubStartDelim == "" || opts.hubStopDelim == "" {
+		fmt.Fprintln(os.Stderr, "The -hub-start-delim and -hub-stop-delim arguments cannot be empty")
 		os.Exit(1)
 	}
 
-	if policy.GetKind() != "Policy" && policy.GetAPIVersion() != "policy.open-cluster-management.io/v1" {
-		fmt.Fprintf(os.Stderr, "The input YAML file is not a v1 Policy manifest\n")
+	if opts.hubStartDelim == opts.startDelim || (opts.startDelim == "" && opts.hubStartDelim == "{{") {
+		fmt.Fprintln(
+			os.Stderr, "The hub templates must have a different start delimiter than the managed cluster templates",
+		)
 		os.Exit(1)
 	}
 
-	policyTemplates, _, err := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "An invalid policy-templates array was provided: %v\n", err)
+	if len(opts.asGroups) != 0 && opts.as == "" {
+		fmt.Fprintln(os.Stderr, "The -as-group argument requires the -as argument")
 		os.Exit(1)
 	}
 
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	if opts.diff && (opts.resourcesDir != "" || opts.watch || opts.output != outputYAML) {
+		fmt.Fprintln(
+			os.Stderr, "The -diff argument cannot be used with the -resources-dir, -replay, -watch, or -o arguments",
+		)
+		os.Exit(1)
+	}
 
-	kubeConfig, err := clientConfig.ClientConfig()
+	switch opts.output {
+	case outputYAML, outputJSON:
+	case outputRaw:
+		if opts.hubKubeConfigPath != "" || opts.dryRun {
+			fmt.Fprintln(os.Stderr, "The -o raw argument cannot be used with the -hub-kubeconfig or -dry-run arguments")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "The -o argument must be one of yaml, json, or raw but got \"%s\"\n", opts.output)
+		os.Exit(1)
+	}
+
+	opts.values, err = loadValues(valuesFiles, setArgs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to determine the kubeconfig to use: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to load the values: %v\n", err)
 		os.Exit(1)
 	}
 
-	var hubResolver *templates.TemplateResolver
+	if repl {
+		runREPL(opts)
 
-	hubTemplateCtx := struct {
+		return
+	}
+
+	processTemplates(inputs, opts)
+}
+
+// The output formats of the -o argument.
+const (
+	outputYAML = "yaml"
+	outputJSON = "json"
+	outputRaw  = "raw"
+)
+
+// cliOptions are the command-line arguments other than the input file.
+type cliOptions struct {
+	hubKubeConfigPath string
+	clusterName       string
+	// resourcesDir is a directory or file of the objects to serve the managed cluster template lookups from.
+	resourcesDir string
+	recordFile   string
+	output       string
+	outputDir    string
+	// startDelim and stopDelim are the delimiters of the managed cluster templates, which are the default delimiters
+	// when empty.
+	startDelim    string
+	stopDelim     string
+	hubStartDelim string
+	hubStopDelim  string
+	// as and asGroups are the user and groups to impersonate on the managed cluster.
+	as       string
+	asGroups stringSliceFlag
+	// values is .Values of the template context from the -values and -set arguments.
+	values map[string]interface{}
+	dryRun bool
+	diff   bool
+	strict bool
+	trace  bool
+	watch  bool
+}
+
+// policyResolver resolves the hub and managed cluster templates of a Policy.
+type policyResolver struct {
+	resolver          *templates.TemplateResolver
+	resolveOptions    templates.ResolveOptions
+	hubResolver       *templates.TemplateResolver
+	hubResolveOptions templates.ResolveOptions
+	hubTemplateCtx    struct {
 		ManagedClusterName   string
 		ManagedClusterLabels map[string]string
-	}{ManagedClusterName: clusterName}
+		Values               map[string]interface{}
+	}
+	templateCtx struct {
+		Values map[string]interface{}
+	}
+	dryRun bool
+	// differ diffs the resolved Policy against the live objects instead of printing it when -diff is set.
+	differ *liveDiffer
+	// differencesFound is set when differ found differences with the live objects.
+	differencesFound bool
+	// strict causes the objects that the lookups didn't find, which are collected in missingObjects, to fail the
+	// resolution.
+	strict         bool
+	missingObjects []string
+	// recorder records the objects fetched by the managed cluster template lookups when recordFile is set.
+	recorder   *lookupRecorder
+	recordFile string
+	output     string
+	// out is where the output of the input file being resolved is written to.
+	out io.Writer
+}
+
+// readInput returns the content of the input file and the Policy or ConfigurationPolicy in it. The Policy only has a
+// name of the file name in raw output mode since the input file doesn't have to be a Policy.
+func readInput(yamlFile string, opts cliOptions) ([]byte, unstructured.Unstructured) {
+	// #nosec G304 -- Reading in a file is required for the tool to work.
+	yamlBytes, err := os.ReadFile(yamlFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the file \"%s\": %v\n", yamlFile, err)
+		os.Exit(1)
+	}
+
+	policy := unstructured.Unstructured{}
+
+	// In raw output mode, the input file is a single template that doesn't have to be a Policy, so it's not validated
+	if opts.output == outputRaw {
+		policy.SetName(filepath.Base(yamlFile))
+	} else {
+		err = yaml.Unmarshal(yamlBytes, &policy.Object)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse the YAML in the file \"%s\": %v\n", yamlFile, err)
+			os.Exit(1)
+		}
+
+		// A ConfigurationPolicy is resolved as the only policy template of a Policy
+		if !isConfigurationPolicy(policy.Object) {
+			if policy.GetKind() != "Policy" && policy.GetAPIVersion() != "policy.open-cluster-management.io/v1" {
+				fmt.Fprintf(
+					os.Stderr,
+					"The input YAML file \"%s\" is not a v1 Policy or ConfigurationPolicy manifest\n",
+					yamlFile,
+				)
+				os.Exit(1)
+			}
+
+			_, _, err = unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
+			if err != nil {
+				fmt.Fprintf(
+					os.Stderr, "An invalid policy-templates array was provided in \"%s\": %v\n", yamlFile, err,
+				)
+				os.Exit(1)
+			}
+		}
+
+		if opts.hubKubeConfigPath != "" && policy.GetNamespace() == "" {
+			fmt.Fprintf(
+				os.Stderr, "The input Policy manifest \"%s\" must specify a namespace for hub templates\n", yamlFile,
+			)
+			os.Exit(1)
+		}
+	}
+
+	return yamlBytes, policy
+}
+
+func processTemplates(inputs []inputFile, opts cliOptions) {
+	yamlFiles := make([][]byte, len(inputs))
+	policies := make([]unstructured.Unstructured, len(inputs))
+
+	for i, input := range inputs {
+		yamlFiles[i], policies[i] = readInput(input.path, opts)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var watcher *depclient.ObjectIdentifier
+
+	if opts.watch {
+		// The Policy is the watcher of all the objects referenced by its templates. In raw output mode, the name of the
+		// input file is used as the name of the Policy. There is a single input file in watch mode.
+		watcher = &depclient.ObjectIdentifier{
+			Group:     "policy.open-cluster-management.io",
+			Version:   "v1",
+			Kind:      "Policy",
+			Namespace: policies[0].GetNamespace(),
+			Name:      policies[0].GetName(),
+		}
+	}
+
+	// In watch mode, the resolvers use caching and notify on these channels when a watched object changes
+	p, hubEvents, events := newPolicyResolver(ctx, opts, watcher)
+
+	resolveAndPrint := func(i int) bool {
+		// Hub templates can only look up objects in the namespace of the Policy
+		p.hubResolveOptions.LookupNamespace = policies[i].GetNamespace()
+
+		if opts.output == outputRaw {
+			return p.resolveRawAndPrint(yamlFiles[i])
+		}
+
+		return p.resolveAndPrint(policies[i].DeepCopy())
+	}
+
+	if !opts.watch {
+		if !p.resolveAll(inputs, opts, resolveAndPrint) {
+			// Like kubectl diff, an exit code of 1 means that differences were found
+			if opts.diff {
+				os.Exit(2)
+			}
 
-	var hubResolveOptions templates.ResolveOptions
+			os.Exit(1)
+		}
 
-	if hubKubeConfigPath != "" {
-		if policy.GetNamespace() == "" {
-			fmt.Fprintf(os.Stderr, "The input Policy manifest must specify a namespace for hub templates\n")
+		if p.differencesFound {
 			os.Exit(1)
 		}
 
-		hubKubeConfig, err := clientcmd.BuildConfigFromFlags("", hubKubeConfigPath)
+		return
+	}
+
+	resolveAndPrint(0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hubEvents:
+		case <-events:
+		}
+
+		// Coalesce the changes that happened at the same time into a single resolution
+		drainEvents(hubEvents)
+		drainEvents(events)
+
+		fmt.Fprintln(os.Stderr, "A referenced object changed, resolving the templates again")
+
+		// A stream of JSON documents doesn't need a separator, such as for jq
+		if opts.output != outputJSON {
+			//nolint: forbidigo
+			fmt.Println("---")
+		}
+
+		resolveAndPrint(0)
+	}
+}
+
+// resolveAll resolves and prints each input file with resolveAndPrint. The outputs are separated by --- when printed
+// or written to the input's file in the output directory when -output-dir is set. All the input files are resolved
+// even if one fails, and false is returned if any failed.
+func (p *policyResolver) resolveAll(inputs []inputFile, opts cliOptions, resolveAndPrint func(i int) bool) bool {
+	succeeded := true
+
+	for i, input := range inputs {
+		var outputFile *os.File
+
+		if opts.outputDir != "" {
+			var err error
+
+			outputFile, err = createOutputFile(opts.outputDir, input)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to create the output file of \"%s\": %v\n", input.path, err)
+
+				succeeded = false
+
+				continue
+			}
+
+			p.out = outputFile
+		} else if i > 0 && opts.output != outputJSON && !opts.diff {
+			// A stream of JSON documents or diffs doesn't need a separator
+			//nolint: forbidigo
+			fmt.Println("---")
+		}
+
+		ok := resolveAndPrint(i)
+
+		if outputFile != nil {
+			if err := outputFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write the output file of \"%s\": %v\n", input.path, err)
+
+				ok = false
+			}
+
+			// Don't leave an incomplete output file behind
+			if !ok {
+				_ = os.Remove(outputFile.Name())
+			}
+		}
+
+		if !ok {
+			succeeded = false
+
+			if len(inputs) > 1 {
+				fmt.Fprintf(os.Stderr, "Failed to process the file \"%s\"\n", input.path)
+			}
+		}
+	}
+
+	return succeeded
+}
+
+// newPolicyResolver returns a policyResolver with the template resolvers for the arguments. When watcher is set, the
+// resolvers use caching and the returned channels receive an event when an object referenced by the hub and managed
+// cluster templates, respectively, changes. Errors are printed to stderr before exiting.
+func newPolicyResolver(
+	ctx context.Context, opts cliOptions, watcher *depclient.ObjectIdentifier,
+) (p *policyResolver, hubEvents, events <-chan event.GenericEvent) {
+	p = &policyResolver{
+		dryRun: opts.dryRun, recordFile: opts.recordFile, output: opts.output, out: os.Stdout, strict: opts.strict,
+	}
+	p.resolveOptions.StartDelim = opts.startDelim
+	p.resolveOptions.StopDelim = opts.stopDelim
+	p.hubResolveOptions.StartDelim = opts.hubStartDelim
+	p.hubResolveOptions.StopDelim = opts.hubStopDelim
+	p.hubTemplateCtx.ManagedClusterName = opts.clusterName
+	p.hubTemplateCtx.Values = opts.values
+	p.templateCtx.Values = opts.values
+
+	if watcher != nil {
+		p.resolveOptions.Watcher = watcher
+		p.resolveOptions.DisableAutoCacheCleanUp = true
+		p.hubResolveOptions.Watcher = watcher
+		p.hubResolveOptions.DisableAutoCacheCleanUp = true
+	}
+
+	if opts.hubKubeConfigPath != "" {
+		hubKubeConfig, err := clientcmd.BuildConfigFromFlags("", opts.hubKubeConfigPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to load the Hub kubeconfig: %v\n", err)
 			os.Exit(1)
@@ -128,13 +586,13 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 			Resource: "managedclusters",
 		}
 
-		mc, err := dynamicHubClient.Resource(mcGVR).Get(context.TODO(), clusterName, v1.GetOptions{})
+		mc, err := dynamicHubClient.Resource(mcGVR).Get(ctx, opts.clusterName, v1.GetOptions{})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get the ManagedCluster object for %s: %v\n", clusterName, err)
+			fmt.Fprintf(os.Stderr, "Failed to get the ManagedCluster object for %s: %v\n", opts.clusterName, err)
 			os.Exit(1)
 		}
 
-		hubTemplateCtx.ManagedClusterLabels = mc.GetLabels()
+		p.hubTemplateCtx.ManagedClusterLabels = mc.GetLabels()
 
 		hubTemplatesConfig := templates.Config{
 			AdditionalIndentation: 8,
@@ -143,39 +601,470 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 			StopDelim:             "hub}}",
 		}
 
-		hubResolveOptions = templates.ResolveOptions{
-			ClusterScopedAllowList: []templates.ClusterScopedObjectIdentifier{{
-				Group: "cluster.open-cluster-management.io",
-				Kind:  "ManagedCluster",
-				Name:  clusterName,
-			}},
-			LookupNamespace: policy.GetNamespace(),
+		if opts.trace {
+			hubTemplatesConfig.TracerProvider = newLookupTracerProvider(os.Stderr, "hub", opts.watch)
 		}
 
-		hubResolver, err = templates.NewResolver(hubKubeConfig, hubTemplatesConfig)
+		p.hubResolveOptions.ClusterScopedAllowList = []templates.ClusterScopedObjectIdentifier{{
+			Group: "cluster.open-cluster-management.io",
+			Kind:  "ManagedCluster",
+			Name:  opts.clusterName,
+		}}
+
+		p.hubResolver, hubEvents, err = newResolver(ctx, hubKubeConfig, hubTemplatesConfig, opts.watch)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to instantiate the hub template resolver: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	resolver, err := templates.NewResolver(kubeConfig, templates.Config{})
+	managedConfig := templates.Config{
+		Impersonate: rest.ImpersonationConfig{UserName: opts.as, Groups: opts.asGroups},
+	}
+
+	if opts.trace {
+		managedConfig.TracerProvider = newLookupTracerProvider(os.Stderr, "managed", opts.watch)
+	}
+
+	if opts.resourcesDir != "" {
+		objects, err := loadObjects(opts.resourcesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load the objects in \"%s\": %v\n", opts.resourcesDir, err)
+			os.Exit(1)
+		}
+
+		p.resolver, err = templates.NewFakeResolver(objects, managedConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to instantiate the template resolver: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules, &clientcmd.ConfigOverrides{},
+		)
+
+		kubeConfig, err := clientConfig.ClientConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to determine the kubeconfig to use: %v\n", err)
+			os.Exit(1)
+		}
+
+		if opts.diff {
+			differConfig := rest.CopyConfig(kubeConfig)
+			if opts.as != "" {
+				differConfig.Impersonate = managedConfig.Impersonate
+			}
+
+			p.differ, err = newLiveDiffer(differConfig)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to instantiate the client to diff against the cluster: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if opts.recordFile != "" {
+			p.recorder = newLookupRecorder()
+			kubeConfig.Wrap(p.recorder.wrap)
+		}
+
+		p.resolver, events, err = newResolver(ctx, kubeConfig, managedConfig, opts.watch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to instantiate the template resolver: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	return p, hubEvents, events
+}
+
+// newResolver creates a template resolver. When watch is set, the resolver uses caching and the returned channel
+// receives an event when an object referenced by the templates changes.
+func newResolver(
+	ctx context.Context, kubeConfig *rest.Config, config templates.Config, watch bool,
+) (*templates.TemplateResolver, <-chan event.GenericEvent, error) {
+	if !watch {
+		resolver, err := templates.NewResolver(kubeConfig, config)
+
+		return resolver, nil, err
+	}
+
+	resolver, channel, err := templates.NewResolverWithCaching(ctx, kubeConfig, config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to instantiate the template resolver: %v\n", err)
-		os.Exit(1)
+		return nil, nil, err
+	}
+
+	return resolver, channel.Source, nil
+}
+
+// loadObjects returns the Kubernetes objects in the YAML and JSON files in the directory and its subdirectories. A file
+// may contain multiple YAML documents, and the items of List kinds are returned individually.
+func loadObjects(dir string) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		// #nosec G304 -- Reading in the files is required for the tool to work.
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		decoder := k8syaml.NewYAMLOrJSONDecoder(file, 4096)
+
+		for {
+			obj := unstructured.Unstructured{}
+
+			err := decoder.Decode(&obj.Object)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+
+			// Skip empty YAML documents
+			if len(obj.Object) == 0 {
+				continue
+			}
+
+			if !obj.IsList() {
+				objects = append(objects, obj)
+
+				continue
+			}
+
+			err = obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, *item.(*unstructured.Unstructured))
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to parse the list in %s: %w", path, err)
+			}
+		}
+	})
+
+	return objects, err
+}
+
+// drainEvents discards the events that are already queued on the channel.
+func drainEvents(events <-chan event.GenericEvent) {
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
 	}
+}
+
+// resolveAndPrint resolves the templates in the Policy and prints the resolved Policy in the output format, or its diff
+// with the live objects when -diff is set. Errors and dry-run validation failures are printed to stderr, in which case
+// false is returned.
+func (p *policyResolver) resolveAndPrint(policy *unstructured.Unstructured) bool {
+	configurationPolicy := isConfigurationPolicy(policy.Object)
+	if configurationPolicy {
+		policy = wrapInPolicy(policy)
+	}
+
+	p.missingObjects = nil
+
+	dryRunFailures, err := p.resolve(policy)
+
+	if !p.writeRecording() {
+		return false
+	}
+
+	missingObjectsFound := p.printMissingObjects()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		printTemplateErrorContext(err)
+
+		return false
+	}
+
+	if missingObjectsFound {
+		return false
+	}
+
+	if p.differ != nil {
+		diff, err := p.differ.diffPolicy(context.TODO(), policy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to diff the resolved Policy against the cluster: %v\n", err)
+
+			return false
+		}
+
+		p.differencesFound = p.differencesFound || diff != ""
+
+		fmt.Fprint(p.out, diff)
+	} else {
+		output := policy.Object
+		if configurationPolicy {
+			output = unwrapFromPolicy(policy)
+		}
+
+		resolvedPolicy, err := json.Marshal(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The resulting Policy was invalid JSON: %v\n", err)
+
+			return false
+		}
+
+		if !p.print(resolvedPolicy) {
+			return false
+		}
+	}
+
+	if len(dryRunFailures) > 0 {
+		fmt.Fprintln(os.Stderr, "The following objects failed the server-side dry-run validation:")
+
+		for _, failure := range dryRunFailures {
+			fmt.Fprintln(os.Stderr, "- "+failure)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// resolveRawAndPrint resolves the input file as a single managed cluster template and prints the result for the raw
+// output format. A result that is a string, such as a template of a configuration file rather than a manifest, is
+// printed as is, and any other result is printed as YAML. Errors are printed to stderr, in which case false is
+// returned.
+func (p *policyResolver) resolveRawAndPrint(tmpl []byte) bool {
+	p.resolver.SetInputIsYAML(true)
+
+	p.missingObjects = nil
+
+	tmplResult, err := p.resolver.ResolveTemplate(tmpl, p.templateCtx, &p.resolveOptions)
+	p.addMissingObjects("", tmplResult)
+
+	if tmplResult.CacheCleanUp != nil {
+		defer func() {
+			if cleanUpErr := tmplResult.CacheCleanUp(); cleanUpErr != nil {
+				klog.Errorf("Failed to clean up the template cache: %v", cleanUpErr)
+			}
+		}()
+	}
+
+	if !p.writeRecording() {
+		return false
+	}
+
+	missingObjectsFound := p.printMissingObjects()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to process the templates: %v\n", err)
+		printTemplateErrorContext(err)
+
+		return false
+	}
+
+	if missingObjectsFound {
+		return false
+	}
+
+	var resolvedString string
+
+	if json.Unmarshal(tmplResult.ResolvedJSON, &resolvedString) == nil {
+		if !strings.HasSuffix(resolvedString, "\n") {
+			resolvedString += "\n"
+		}
+
+		fmt.Fprint(p.out, resolvedString)
+
+		return true
+	}
+
+	return p.print(tmplResult.ResolvedJSON)
+}
+
+// addMissingObjects adds the descriptions of the objects that the lookups of the template result didn't find to
+// missingObjects in strict mode. The prefix describes the cluster of the lookups.
+func (p *policyResolver) addMissingObjects(prefix string, result templates.TemplateResult) {
+	if !p.strict {
+		return
+	}
+
+	for _, query := range result.MissingObjects {
+		apiVersion := schema.GroupVersion{Group: query.Group, Version: query.Version}.String()
+
+		var description string
+
+		switch {
+		case query.Name != "" && query.Namespace != "":
+			description = fmt.Sprintf("%s %s %s/%s", apiVersion, query.Kind, query.Namespace, query.Name)
+		case query.Name != "":
+			description = fmt.Sprintf("%s %s %s", apiVersion, query.Kind, query.Name)
+		case query.Namespace != "":
+			description = fmt.Sprintf("%s %s list in the %s namespace", apiVersion, query.Kind, query.Namespace)
+		default:
+			description = fmt.Sprintf("%s %s list", apiVersion, query.Kind)
+		}
+
+		if query.Name == "" && query.Selector != "" {
+			description += " with the label selector " + query.Selector
+		}
+
+		if !slices.Contains(p.missingObjects, prefix+description) {
+			p.missingObjects = append(p.missingObjects, prefix+description)
+		}
+	}
+}
+
+// printMissingObjects prints the objects that the lookups didn't find to stderr in strict mode and returns whether
+// there were any.
+func (p *policyResolver) printMissingObjects() bool {
+	if len(p.missingObjects) == 0 {
+		return false
+	}
+
+	fmt.Fprintln(os.Stderr, "The following objects looked up by the templates were not found:")
+
+	for _, missingObject := range p.missingObjects {
+		fmt.Fprintln(os.Stderr, "- "+missingObject)
+	}
+
+	return true
+}
+
+// writeRecording writes the objects fetched by the managed cluster template lookups to the recordFile if recording is
+// enabled. The error is printed to stderr, in which case false is returned.
+func (p *policyResolver) writeRecording() bool {
+	if p.recorder == nil {
+		return true
+	}
+
+	if err := p.recorder.writeFile(p.recordFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the recorded objects to \"%s\": %v\n", p.recordFile, err)
+
+		return false
+	}
+
+	return true
+}
+
+// print prints the input JSON as indented JSON for the json output format and as YAML otherwise. The error is printed
+// to stderr, in which case false is returned.
+func (p *policyResolver) print(resolvedJSON []byte) bool {
+	if p.output == outputJSON {
+		var indented bytes.Buffer
+
+		if err := json.Indent(&indented, resolvedJSON, "", "  "); err != nil {
+			fmt.Fprintf(os.Stderr, "The result was invalid JSON: %v\n", err)
+
+			return false
+		}
+
+		fmt.Fprintln(p.out, indented.String())
+
+		return true
+	}
+
+	resolvedYAML, err := templates.JSONToYAML(resolvedJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to convert the result back to YAML: %v\n", err)
+
+		return false
+	}
+
+	fmt.Fprintln(p.out, string(resolvedYAML))
+
+	return true
+}
+
+// isConfigurationPolicy returns whether the object is a v1 ConfigurationPolicy, which is resolved like a Policy with it
+// as the only policy template.
+func isConfigurationPolicy(obj map[string]interface{}) bool {
+	objUnstructured := unstructured.Unstructured{Object: obj}
+
+	return objUnstructured.GetAPIVersion() == "policy.open-cluster-management.io/v1" &&
+		objUnstructured.GetKind() == "ConfigurationPolicy"
+}
+
+// wrapInPolicy returns a Policy with the ConfigurationPolicy as the objectDefinition of its only policy template. The
+// Policy has the namespace of the ConfigurationPolicy for the lookups of hub templates.
+func wrapInPolicy(configurationPolicy *unstructured.Unstructured) *unstructured.Unstructured {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "policy.open-cluster-management.io/v1",
+		"kind":       "Policy",
+		"spec": map[string]interface{}{
+			"policy-templates": []interface{}{
+				map[string]interface{}{"objectDefinition": configurationPolicy.Object},
+			},
+		},
+	}}
+	policy.SetName(configurationPolicy.GetName())
+	policy.SetNamespace(configurationPolicy.GetNamespace())
+
+	return policy
+}
+
+// unwrapFromPolicy returns the resolved ConfigurationPolicy of a Policy from wrapInPolicy.
+func unwrapFromPolicy(policy *unstructured.Unstructured) map[string]interface{} {
+	policyTemplates, _, _ := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
+	if len(policyTemplates) != 1 {
+		return nil
+	}
+
+	objectDefinition, _, _ := unstructured.NestedMap(policyTemplates[0].(map[string]interface{}), "objectDefinition")
+
+	return objectDefinition
+}
+
+// resolve resolves the hub and managed cluster templates of the Policy in place. The descriptions of the objects that
+// failed the dry-run validation are returned when dry-run is enabled.
+func (p *policyResolver) resolve(policy *unstructured.Unstructured) (dryRunFailures []string, err error) {
+	policyTemplates, _, err := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
+	if err != nil {
+		return nil, fmt.Errorf("An invalid policy-templates array was provided: %w", err)
+	}
+
+	// In watch mode, end the query batch of each resolver after all the templates are resolved so that the watches
+	// of all the templates are kept.
+	var cacheCleanUp, hubCacheCleanUp templates.CacheCleanUpFunc
+
+	defer func() {
+		for _, cleanUp := range []templates.CacheCleanUpFunc{cacheCleanUp, hubCacheCleanUp} {
+			if cleanUp == nil {
+				continue
+			}
+
+			if cleanUpErr := cleanUp(); cleanUpErr != nil {
+				klog.Errorf("Failed to clean up the template cache: %v", cleanUpErr)
+			}
+		}
+	}()
 
 	for i := range policyTemplates {
 		policyTemplate, ok := policyTemplates[i].(map[string]interface{})
 		if !ok {
-			fmt.Fprintf(os.Stderr, "An invalid policy-templates entry was provided: %v\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("An invalid policy-templates entry was provided at index %d", i)
 		}
 
 		objectDefinition, ok := policyTemplate["objectDefinition"].(map[string]interface{})
 		if !ok {
-			fmt.Fprintf(os.Stderr, "An invalid policy-templates entry was provided: %v\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("An invalid policy-templates entry was provided at index %d", i)
 		}
 
 		objectDefinitionUnstructured := unstructured.Unstructured{Object: objectDefinition}
@@ -187,43 +1076,39 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 			continue
 		}
 
-		if hubResolver != nil {
+		if p.hubResolver != nil {
 			objectDefinitionJSON, err := json.Marshal(objectDefinition)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "An invalid policy-templates entry at index %d was provided: %v\n", i, err)
-				os.Exit(1)
+				return nil, fmt.Errorf("An invalid policy-templates entry at index %d was provided: %w", i, err)
 			}
 
-			hubTemplateResult, err := hubResolver.ResolveTemplate(
-				objectDefinitionJSON, hubTemplateCtx, &hubResolveOptions,
+			hubTemplateResult, err := p.hubResolver.ResolveTemplate(
+				objectDefinitionJSON, p.hubTemplateCtx, &p.hubResolveOptions,
 			)
+			p.addMissingObjects("hub cluster ", hubTemplateResult)
+
+			if hubTemplateResult.CacheCleanUp != nil {
+				hubCacheCleanUp = hubTemplateResult.CacheCleanUp
+			}
+
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "An invalid policy-templates entry at index %d was provided: %v\n", i, err)
-				os.Exit(1)
+				return nil, fmt.Errorf("An invalid policy-templates entry at index %d was provided: %w", i, err)
 			}
 
 			var resolvedObjectDefinition map[string]interface{}
 
 			err = json.Unmarshal(hubTemplateResult.ResolvedJSON, &resolvedObjectDefinition)
 			if err != nil {
-				fmt.Fprintf(
-					os.Stderr,
-					"An invalid policy-templates entry at index %d after resolving templates: %v\n",
-					i,
-					err,
+				return nil, fmt.Errorf(
+					"An invalid policy-templates entry at index %d after resolving templates: %w", i, err,
 				)
-				os.Exit(1)
 			}
 
 			err = unstructured.SetNestedField(policyTemplate, resolvedObjectDefinition, "objectDefinition")
 			if err != nil {
-				fmt.Fprintf(
-					os.Stderr,
-					"An invalid policy-templates entry at index %d after resolving templates: %v\n",
-					i,
-					err,
+				return nil, fmt.Errorf(
+					"An invalid policy-templates entry at index %d after resolving templates: %w", i, err,
 				)
-				os.Exit(1)
 			}
 
 			objectDefinition = policyTemplate["objectDefinition"].(map[string]interface{})
@@ -234,34 +1119,30 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 
 		oTRaw, oTRawFound, _ := unstructured.NestedString(objectDefinition, "spec", "object-templates-raw")
 		if oTRawFound {
-			resolver.SetInputIsYAML(true)
+			p.resolver.SetInputIsYAML(true)
 
 			rawDataList = [][]byte{[]byte(oTRaw)}
 		} else {
-			resolver.SetInputIsYAML(false)
+			p.resolver.SetInputIsYAML(false)
 
 			objTemplates, _, err := unstructured.NestedSlice(objectDefinition, "spec", "object-templates")
 			if err != nil {
-				fmt.Fprintf(
-					os.Stderr,
-					"The ConfigurationPolicy at policy-templates index %d has an invalid object-templates array: %v\n",
+				return nil, fmt.Errorf(
+					"The ConfigurationPolicy at policy-templates index %d has an invalid object-templates array: %w",
 					i,
 					err,
 				)
-				os.Exit(1)
 			}
 
 			for _, objTemplate := range objTemplates {
 				jsonBytes, err := json.Marshal(objTemplate)
 				if err != nil {
-					fmt.Fprintf(
-						os.Stderr,
+					return nil, fmt.Errorf(
 						"The ConfigurationPolicy at policy-templates index %d has an invalid object-templates "+
-							"array: %v\n",
+							"array: %w",
 						i,
 						err,
 					)
-					os.Exit(1)
 				}
 
 				rawDataList = append(rawDataList, jsonBytes)
@@ -271,28 +1152,30 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 		objectTemplates := make([]interface{}, 0, len(rawDataList))
 
 		for _, rawData := range rawDataList {
-			if bytes.Contains(rawData, []byte("{{hub")) {
-				fmt.Fprintf(
-					os.Stderr,
+			if bytes.Contains(rawData, []byte(p.hubResolveOptions.StartDelim)) {
+				return nil, fmt.Errorf(
 					"The ConfigurationPolicy at policy-templates index %d has an unresolved hub template. Use the "+
-						"-hub-kubeconfig argument.\n",
+						"-hub-kubeconfig argument.",
 					i,
 				)
-				os.Exit(1)
 			}
 
-			tmplResult, err := resolver.ResolveTemplate(rawData, nil, nil)
+			tmplResult, err := p.resolver.ResolveTemplate(rawData, p.templateCtx, &p.resolveOptions)
+			p.addMissingObjects("", tmplResult)
+
+			if tmplResult.CacheCleanUp != nil {
+				cacheCleanUp = tmplResult.CacheCleanUp
+			}
+
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to process the templates at policy-templates index %d: %v\n", i, err)
-				os.Exit(1)
+				return nil, fmt.Errorf("Failed to process the templates at policy-templates index %d: %w", i, err)
 			}
 
 			var resolvedOT interface{}
 
 			err = json.Unmarshal(tmplResult.ResolvedJSON, &resolvedOT)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to process the templates at policy-templates index %d: %v\n", i, err)
-				os.Exit(1)
+				return nil, fmt.Errorf("Failed to process the templates at policy-templates index %d: %w", i, err)
 			}
 
 			if oTRawFound {
@@ -302,13 +1185,11 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 				case nil:
 					objectTemplates = []interface{}{}
 				default:
-					fmt.Fprintf(
-						os.Stderr,
+					return nil, fmt.Errorf(
 						"object-templates-raw in policy-templates index %d was not an array after templates were "+
-							"resolved\n",
+							"resolved",
 						i,
 					)
-					os.Exit(1)
 				}
 
 				unstructured.RemoveNestedField(objectDefinition, "spec", "object-templates-raw")
@@ -321,29 +1202,68 @@ func processTemplate(yamlFile, hubKubeConfigPath, clusterName string) {
 
 		err = unstructured.SetNestedSlice(objectDefinition, objectTemplates, "spec", "object-templates")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to process the templates at policy-templates index %d: %v\n", i, err)
-			os.Exit(1)
+			return nil, fmt.Errorf("Failed to process the templates at policy-templates index %d: %w", i, err)
+		}
+
+		if p.dryRun {
+			dryRunFailures = append(dryRunFailures, validateObjectTemplates(p.resolver, i, objectTemplates)...)
 		}
 	}
 
 	err = unstructured.SetNestedSlice(policy.Object, policyTemplates, "spec", "policy-templates")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "The resulting policy-templates were invalid: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("The resulting policy-templates were invalid: %w", err)
 	}
 
-	resolvedPolicy, err := json.Marshal(policy.Object)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "The resulting Policy was invalid JSON: %v\n", err)
-		os.Exit(1)
+	return dryRunFailures, nil
+}
+
+// printTemplateErrorContext prints the lines of the template surrounding the failing template if the error has its
+// position.
+func printTemplateErrorContext(err error) {
+	var tmplErr *templates.TemplateError
+	if !errors.As(err, &tmplErr) {
+		return
 	}
 
-	resolvedYAML, err := templates.JSONToYAML(resolvedPolicy)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to convert the processed Policy back to YAML: %v\n", err)
-		os.Exit(1)
+	fmt.Fprintf(os.Stderr, "The error is at line %d of the template:\n%s", tmplErr.Line, tmplErr.Context(2))
+}
+
+// validateObjectTemplates validates the objectDefinition of each resolved object template that should exist on the
+// cluster with a server-side dry-run apply. A description of each validation failure is returned.
+func validateObjectTemplates(
+	resolver *templates.TemplateResolver, policyTemplateIndex int, objectTemplates []interface{},
+) []string {
+	failures := []string{}
+
+	for j, objectTemplate := range objectTemplates {
+		objectTemplateMap, ok := objectTemplate.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		complianceType, _, _ := unstructured.NestedString(objectTemplateMap, "complianceType")
+		if strings.EqualFold(complianceType, "mustnothave") {
+			continue
+		}
+
+		objectDefinition, ok := objectTemplateMap["objectDefinition"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		objectDefinitionJSON, err := json.Marshal(objectDefinition)
+		if err == nil {
+			err = resolver.ValidateWithDryRun(context.TODO(), objectDefinitionJSON)
+		}
+
+		if err != nil {
+			failures = append(
+				failures,
+				fmt.Sprintf("policy-templates index %d, object-templates index %d: %v", policyTemplateIndex, j, err),
+			)
+		}
 	}
 
-	//nolint: forbidigo
-	fmt.Println(string(resolvedYAML))
+	return failures
 }
@@ -0,0 +1,209 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stolostron/go-template-utils/v4/pkg/templates"
+)
+
+// errNoNamespace is returned by diffObjectTemplate when a namespaced object has no namespace, such as when the
+// ConfigurationPolicy sets the namespace with its namespaceSelector. These objects are skipped.
+var errNoNamespace = errors.New("the namespaced object has no namespace")
+
+// liveDiffer diffs the resolved object templates of a Policy against the live objects on the cluster for -diff.
+type liveDiffer struct {
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+}
+
+func newLiveDiffer(kubeConfig *rest.Config) (*liveDiffer, error) {
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &liveDiffer{
+		dynamicClient: dynamicClient,
+		mapper:        restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}, nil
+}
+
+// diffPolicy returns a unified diff of the live objects on the cluster and the objects as they would be after the
+// resolved Policy is enforced, like kubectl diff. An object that should exist is compared to the result of a
+// server-side dry-run apply of its objectDefinition, and an object that shouldn't exist is compared to no object. The
+// namespaced objects without a namespace are skipped with a note on stderr. An empty string is returned if there are
+// no differences.
+func (d *liveDiffer) diffPolicy(ctx context.Context, policy *unstructured.Unstructured) (string, error) {
+	policyTemplates, _, err := unstructured.NestedSlice(policy.Object, "spec", "policy-templates")
+	if err != nil {
+		return "", fmt.Errorf("The resulting policy-templates were invalid: %w", err)
+	}
+
+	var diffs strings.Builder
+
+	for i, policyTemplate := range policyTemplates {
+		policyTemplateMap, ok := policyTemplate.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		objectDefinition, _, _ := unstructured.NestedMap(policyTemplateMap, "objectDefinition")
+
+		objectDefinitionUnstructured := unstructured.Unstructured{Object: objectDefinition}
+		if objectDefinitionUnstructured.GetAPIVersion() != "policy.open-cluster-management.io/v1" ||
+			objectDefinitionUnstructured.GetKind() != "ConfigurationPolicy" {
+			continue
+		}
+
+		objectTemplates, _, _ := unstructured.NestedSlice(objectDefinition, "spec", "object-templates")
+
+		for j, objectTemplate := range objectTemplates {
+			objectTemplateMap, ok := objectTemplate.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			diff, err := d.diffObjectTemplate(ctx, objectTemplateMap)
+			if errors.Is(err, errNoNamespace) {
+				fmt.Fprintf(
+					os.Stderr,
+					"Skipping the diff of policy-templates index %d, object-templates index %d: %v, such as when the "+
+						"ConfigurationPolicy uses a namespaceSelector\n",
+					i, j, err,
+				)
+
+				continue
+			}
+
+			if err != nil {
+				return "", fmt.Errorf("policy-templates index %d, object-templates index %d: %w", i, j, err)
+			}
+
+			diffs.WriteString(diff)
+		}
+	}
+
+	return diffs.String(), nil
+}
+
+// diffObjectTemplate returns the unified diff of the live object of the object template and the object as it would be
+// after the object template is enforced.
+func (d *liveDiffer) diffObjectTemplate(ctx context.Context, objectTemplate map[string]interface{}) (string, error) {
+	objectDefinition, ok := objectTemplate["objectDefinition"].(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+
+	obj := unstructured.Unstructured{Object: objectDefinition}
+	gvk := obj.GroupVersionKind()
+
+	if obj.GetName() == "" {
+		return "", fmt.Errorf("the %s object must have a name to be diffed", gvk.Kind)
+	}
+
+	mapping, err := d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the API resource of %s: %w", gvk, err)
+	}
+
+	var resource dynamic.ResourceInterface
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			return "", fmt.Errorf("%w: %s %s", errNoNamespace, gvk.Kind, obj.GetName())
+		}
+
+		resource = d.dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	} else {
+		resource = d.dynamicClient.Resource(mapping.Resource)
+	}
+
+	var live, desired map[string]interface{}
+
+	liveObj, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err == nil {
+		live = liveObj.Object
+	} else if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get the %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	complianceType, _, _ := unstructured.NestedString(objectTemplate, "complianceType")
+	if !strings.EqualFold(complianceType, "mustnothave") {
+		desiredObj, err := resource.Apply(
+			ctx,
+			obj.GetName(),
+			&obj,
+			metav1.ApplyOptions{
+				DryRun: []string{metav1.DryRunAll}, FieldManager: templates.DryRunFieldManager, Force: true,
+			},
+		)
+		if err != nil {
+			return "", fmt.Errorf("the %s %s failed the server-side dry-run apply: %w", gvk.Kind, obj.GetName(), err)
+		}
+
+		desired = desiredObj.Object
+	}
+
+	path := gvk.Kind + "/" + obj.GetName()
+	if obj.GetNamespace() != "" {
+		path = gvk.Kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+	}
+
+	return unifiedDiff("live/"+path, "resolved/"+path, live, desired)
+}
+
+// unifiedDiff returns the unified diff of the YAML of the objects, where a nil object is an empty file. The managed
+// fields are omitted like in kubectl diff.
+func unifiedDiff(fromName, toName string, from, to map[string]interface{}) (string, error) {
+	lines := make([][]string, 0, 2)
+
+	for _, obj := range []map[string]interface{}{from, to} {
+		if obj == nil {
+			lines = append(lines, nil)
+
+			continue
+		}
+
+		obj = (&unstructured.Unstructured{Object: obj}).DeepCopy().Object
+		unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+
+		objYAML, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+
+		// SplitLines adds a newline to the last line, so the trailing newline is removed to not add an empty line
+		lines = append(lines, difflib.SplitLines(strings.TrimSuffix(string(objYAML), "\n")))
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines[0],
+		B:        lines[1],
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// inputFile is a file to resolve templates in.
+type inputFile struct {
+	path string
+	// relPath is the path of the file relative to the directory argument it was found in, or the file name if it was
+	// passed directly or matched a glob. It's the path of the output file in -output-dir.
+	relPath string
+}
+
+// expandInputs returns the files to resolve templates in from the positional arguments, which are each a file, a
+// directory, or a glob pattern. The YAML files in a directory and its subdirectories are returned in lexical order.
+func expandInputs(args []string) ([]inputFile, error) {
+	var inputs []inputFile
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			matches, globErr := filepath.Glob(arg)
+			if globErr != nil || len(matches) == 0 {
+				return nil, fmt.Errorf("the file \"%s\" doesn't exist and isn't a glob matching files", arg)
+			}
+
+			sort.Strings(matches)
+
+			for _, match := range matches {
+				inputs = append(inputs, inputFile{path: match, relPath: filepath.Base(match)})
+			}
+
+			continue
+		}
+
+		if !info.IsDir() {
+			inputs = append(inputs, inputFile{path: arg, relPath: filepath.Base(arg)})
+
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() {
+				return nil
+			}
+
+			switch filepath.Ext(path) {
+			case ".yaml", ".yml":
+			default:
+				return nil
+			}
+
+			relPath, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+
+			inputs = append(inputs, inputFile{path: path, relPath: relPath})
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the directory \"%s\": %w", arg, err)
+		}
+	}
+
+	return inputs, nil
+}
+
+// createOutputFile creates the file of the input in the output directory with the same path relative to the output
+// directory as the input has relative to its directory argument.
+func createOutputFile(outputDir string, input inputFile) (*os.File, error) {
+	path := filepath.Join(outputDir, input.relPath)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+
+	// #nosec G304 -- Writing the output files is required for the tool to work.
+	return os.Create(path)
+}
@@ -0,0 +1,160 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// nonObjectKinds are the kinds of the API responses that are not objects to record, such as API discovery.
+var nonObjectKinds = map[string]bool{
+	"APIGroup":        true,
+	"APIGroupList":    true,
+	"APIResourceList": true,
+	"APIVersions":     true,
+	"Status":          true,
+}
+
+// lookupRecorder records the objects in the responses of the Kubernetes API read requests so that they can be
+// replayed with -replay.
+type lookupRecorder struct {
+	lock sync.Mutex
+	// objects is keyed by the apiVersion, kind, namespace, and name of the object.
+	objects map[string]unstructured.Unstructured
+}
+
+func newLookupRecorder() *lookupRecorder {
+	return &lookupRecorder{objects: map[string]unstructured.Unstructured{}}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// wrap returns a round tripper that records the objects of the successful GET responses. This is meant to be used
+// with rest.Config.Wrap.
+func (r *lookupRecorder) wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+
+		// Watch responses are streamed, so they can't be read in full
+		if req.URL.Query().Get("watch") == "true" {
+			return resp, nil
+		}
+
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			return resp, nil
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		r.record(body)
+
+		return resp, nil
+	})
+}
+
+// record records the object or the items of the list in the JSON response body.
+func (r *lookupRecorder) record(body []byte) {
+	obj := unstructured.Unstructured{}
+
+	if err := json.Unmarshal(body, &obj.Object); err != nil {
+		return
+	}
+
+	if nonObjectKinds[obj.GetKind()] || strings.HasPrefix(obj.GetAPIVersion(), "apidiscovery.k8s.io/") {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !obj.IsList() {
+		r.add(obj)
+
+		return
+	}
+
+	items, _, _ := unstructured.NestedSlice(obj.Object, "items")
+
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		itemObj := unstructured.Unstructured{Object: itemMap}
+
+		// The items of a list response may not have the apiVersion and kind set
+		if itemObj.GetAPIVersion() == "" {
+			itemObj.SetAPIVersion(obj.GetAPIVersion())
+		}
+
+		if itemObj.GetKind() == "" {
+			itemObj.SetKind(strings.TrimSuffix(obj.GetKind(), "List"))
+		}
+
+		r.add(itemObj)
+	}
+}
+
+func (r *lookupRecorder) add(obj unstructured.Unstructured) {
+	if obj.GetKind() == "" || obj.GetName() == "" {
+		return
+	}
+
+	key := strings.Join([]string{obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()}, "/")
+	r.objects[key] = obj
+}
+
+// writeFile writes the recorded objects as a multi-document YAML file sorted by the apiVersion, kind, namespace, and
+// name of the objects.
+func (r *lookupRecorder) writeFile(path string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	keys := make([]string, 0, len(r.objects))
+	for key := range r.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var output bytes.Buffer
+
+	for _, key := range keys {
+		obj := r.objects[key]
+
+		objYAML, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+
+		output.WriteString("---\n")
+		output.Write(objYAML)
+	}
+
+	return os.WriteFile(path, output.Bytes(), 0o600)
+}
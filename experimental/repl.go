@@ -0,0 +1,146 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// runREPL runs the repl subcommand, which resolves each entry read from stdin as a managed cluster template and prints
+// the result like -o raw. An entry without the start delimiter is an expression whose value is printed, such as
+// `lookup "v1" "ConfigMap" "default" "my-config"`. A line that ends with a backslash or has an unterminated template
+// action continues on the next line. When stdin is a terminal, the previous entries can be recalled with the arrow
+// keys. Errors are printed to stderr without exiting.
+func runREPL(opts cliOptions) {
+	if opts.hubKubeConfigPath != "" || opts.dryRun || opts.diff || opts.watch || opts.outputDir != "" {
+		fmt.Fprintln(
+			os.Stderr,
+			"The repl subcommand cannot be used with the -hub-kubeconfig, -dry-run, -diff, -watch, or -output-dir "+
+				"arguments",
+		)
+		os.Exit(1)
+	}
+
+	p, _, _ := newPolicyResolver(context.Background(), opts, nil)
+
+	startDelim, stopDelim := "{{", "}}"
+	if opts.startDelim != "" {
+		startDelim, stopDelim = opts.startDelim, opts.stopDelim
+	}
+
+	readLine := newLineReader()
+
+	for {
+		entry, err := readEntry(readLine, startDelim, stopDelim)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the input: %v\n", err)
+			os.Exit(1)
+		}
+
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, startDelim) {
+			entry = startDelim + " toJSON (" + entry + ") " + stopDelim
+		}
+
+		p.resolveRawAndPrint([]byte(entry))
+	}
+}
+
+// readEntry reads the lines of the next entry. A line that ends with a backslash or has an unterminated template
+// action continues on the next line.
+func readEntry(readLine func(prompt string) (string, error), startDelim, stopDelim string) (string, error) {
+	var entry strings.Builder
+
+	prompt := "> "
+
+	for {
+		line, err := readLine(prompt)
+		if err != nil {
+			// Resolve a partial entry at the end of the input
+			if errors.Is(err, io.EOF) && entry.Len() != 0 {
+				return entry.String(), nil
+			}
+
+			return "", err
+		}
+
+		continued := strings.HasSuffix(line, `\`)
+		if continued {
+			line = strings.TrimSuffix(line, `\`)
+		}
+
+		entry.WriteString(line)
+
+		text := entry.String()
+		if !continued && strings.Count(text, startDelim) <= strings.Count(text, stopDelim) {
+			return text, nil
+		}
+
+		entry.WriteString("\n")
+
+		prompt = ". "
+	}
+}
+
+// newLineReader returns a function to read a line from stdin. When stdin is a terminal, the line can be edited, the
+// previous lines can be recalled with the arrow keys, and the prompt is printed. The terminal is only in raw mode
+// while a line is read so that the output of the resolution is printed normally.
+func newLineReader() func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+
+		return func(_ string) (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+
+				return "", io.EOF
+			}
+
+			return scanner.Text(), nil
+		}
+	}
+
+	fmt.Fprintln(
+		os.Stderr,
+		`Enter a template such as {{ (lookup "v1" "ConfigMap" "default" "my-config").data }} or an expression `+
+			`such as lookup "v1" "ConfigMap" "default" "my-config" to resolve it. End a line with \ to continue the `+
+			`entry on the next line. Press Ctrl+D to exit.`,
+	)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+
+	return func(prompt string) (string, error) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+
+		defer func() { _ = term.Restore(fd, state) }()
+
+		terminal.SetPrompt(prompt)
+
+		return terminal.ReadLine()
+	}
+}
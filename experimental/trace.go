@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// lookupTraceExporter is a span exporter that writes a line for each Kubernetes object lookup of the templates for
+// -trace. It relies on the spans of the lookups that the template resolver records when Config.TracerProvider is set.
+type lookupTraceExporter struct {
+	w io.Writer
+	// cluster is the cluster of the lookups of the template resolver, such as hub.
+	cluster string
+	// caching is set when the template resolver uses caching, in which case the lookups are always served from the
+	// watch cache.
+	caching bool
+}
+
+// newLookupTracerProvider returns a tracer provider for the Config.TracerProvider of a template resolver that writes
+// its lookups to the writer.
+func newLookupTracerProvider(w io.Writer, cluster string, caching bool) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(&lookupTraceExporter{w: w, cluster: cluster, caching: caching}),
+	)
+}
+
+func (e *lookupTraceExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, span := range spans {
+		if span.Name() != "getOrList" {
+			continue
+		}
+
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, attr := range span.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+
+		apiVersion := attrs["k8s.version"].AsString()
+		if group := attrs["k8s.group"].AsString(); group != "" {
+			apiVersion = group + "/" + apiVersion
+		}
+
+		source := "api"
+
+		switch {
+		case e.caching:
+			source = "watch-cache"
+		case attrs["cache.hit"].AsBool():
+			source = "cache"
+		}
+
+		line := fmt.Sprintf(
+			"[trace] %s lookup apiVersion=%s kind=%s namespace=%s name=%s selector=%s source=%s duration=%s",
+			e.cluster,
+			apiVersion,
+			attrs["k8s.kind"].AsString(),
+			attrs["k8s.namespace"].AsString(),
+			attrs["k8s.name"].AsString(),
+			strings.Join(attrs["k8s.label_selector"].AsStringSlice(), ","),
+			source,
+			span.EndTime().Sub(span.StartTime()),
+		)
+
+		if span.Status().Code == codes.Error {
+			line += fmt.Sprintf(" error=%q", span.Status().Description)
+		}
+
+		fmt.Fprintln(e.w, line)
+	}
+
+	return nil
+}
+
+func (e *lookupTraceExporter) Shutdown(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/yaml"
+)
+
+// stringSliceFlag is a flag that can be passed multiple times.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+// loadValues returns the .Values of the template context from the -values files and then the -set arguments, in
+// order, where later values override earlier ones like in Helm.
+func loadValues(valuesFiles []string, sets []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	for _, valuesFile := range valuesFiles {
+		// #nosec G304 -- Reading in the values files is required for the tool to work.
+		valuesYAML, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the values file \"%s\": %w", valuesFile, err)
+		}
+
+		var fileValues map[string]interface{}
+
+		err = yaml.Unmarshal(valuesYAML, &fileValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the values file \"%s\": %w", valuesFile, err)
+		}
+
+		mergeValues(values, fileValues)
+	}
+
+	for _, set := range sets {
+		key, value, found := strings.Cut(set, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("the -set argument \"%s\" must be in the format key=value", set)
+		}
+
+		if err := setValue(values, strings.Split(key, "."), parseValue(value)); err != nil {
+			return nil, fmt.Errorf("failed to set the -set argument \"%s\": %w", set, err)
+		}
+	}
+
+	return values, nil
+}
+
+// mergeValues merges src into dst, where the maps are merged recursively and any other value in src replaces the value
+// in dst.
+func mergeValues(dst, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+
+			continue
+		}
+
+		dst[key] = srcValue
+	}
+}
+
+// setValue sets the value at the path of keys in the values, creating the intermediate maps as needed.
+func setValue(values map[string]interface{}, keys []string, value interface{}) error {
+	if slices.Contains(keys, "") {
+		return errors.New("the key has an empty part")
+	}
+
+	for i, key := range keys[:len(keys)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			if _, exists := values[key]; exists {
+				return fmt.Errorf("%s is not a map", strings.Join(keys[:i+1], "."))
+			}
+
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+
+		values = next
+	}
+
+	values[keys[len(keys)-1]] = value
+
+	return nil
+}
+
+// parseValue returns the typed value of a -set argument like Helm, where true, false, null, and integers aren't
+// strings.
+func parseValue(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return intValue
+	}
+
+	return value
+}
@@ -0,0 +1,300 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func (t *TemplateResolver) canLookupHelper(
+	options *ResolveOptions,
+) func(string, string, string, string) (bool, error) {
+	return func(apiVersion string, kind string, namespace string, verb string) (bool, error) {
+		return t.canLookup(options, apiVersion, kind, namespace, verb)
+	}
+}
+
+// canLookup issues a SelfSubjectAccessReview to determine if the resolver's credentials, or the identity of
+// ResolveOptions.Impersonate, are permitted to perform the verb on the kind in the namespace. The verb defaults to
+// "get" if it's not provided. False is returned without an error if the API resource is not installed or the lookup
+// is restricted by the lookup namespaces or the allowed kinds, since a lookup would not be possible in those cases
+// either.
+func (t *TemplateResolver) canLookup(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, verb string,
+) (bool, error) {
+	t.logger(options).V(2).Info(
+		"canLookup", "apiVersion", apiVersion, "kind", kind, "namespace", namespace, "verb", verb,
+	)
+
+	if apiVersion == "" || kind == "" {
+		return false, errors.New("the apiVersion and kind are required")
+	}
+
+	if verb == "" {
+		verb = "get"
+	}
+
+	ns, err := t.getNamespace(namespace, options.lookupNamespaces()...)
+	if err != nil {
+		if errors.Is(err, ErrRestrictedNamespace) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+
+	if checkAllowedGVK(options, gv.WithKind(kind)) != nil {
+		return false, nil
+	}
+
+	scopedGVR, err := t.getScopedGVR(gv.WithKind(kind))
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if !scopedGVR.Namespaced {
+		ns = ""
+
+		if len(options.lookupNamespaces()) != 0 {
+			rsrcIdentifier := ClusterScopedObjectIdentifier{Group: scopedGVR.Group, Kind: kind}
+			if !isClusterScopedAllowed(options, rsrcIdentifier) {
+				return false, nil
+			}
+		}
+	}
+
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: ns,
+				Verb:      verb,
+				Group:     scopedGVR.Group,
+				Version:   scopedGVR.Version,
+				Resource:  scopedGVR.Resource,
+			},
+		},
+	}
+
+	if err := options.countLookup(); err != nil {
+		return false, err
+	}
+
+	_, kubeClient, err := t.clientsFor(options)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(
+		options.apiContext(), review, metav1.CreateOptions{},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create the SelfSubjectAccessReview: %w", err)
+	}
+
+	t.logger(options).V(2).Info("canLookup result", "allowed", result.Status.Allowed, "reason", result.Status.Reason)
+
+	return result.Status.Allowed, nil
+}
+
+// LookupAccessReview is the configuration of ResolveOptions.LookupAccessReview. When User and Groups are empty, the
+// lookups are reviewed with a SelfSubjectAccessReview of the resolver's credentials, or the identity of
+// ResolveOptions.Impersonate. Otherwise, they are reviewed with a SubjectAccessReview of the subject, which requires
+// the resolver's credentials to be allowed to create SubjectAccessReviews.
+//
+// - User is the user name of the subject, such as `system:serviceaccount:namespace:name` for a service account.
+//
+// - Groups are the groups of the subject.
+//
+// - UID is the optional UID of the subject.
+//
+// - Extra is the optional extra information of the subject.
+type LookupAccessReview struct {
+	User   string
+	Groups []string
+	UID    string
+	Extra  map[string]authorizationv1.ExtraValue
+}
+
+// LookupAccessDeniedError is returned when a lookup is not permitted by the access review of
+// ResolveOptions.LookupAccessReview. It matches ErrLookupAccessDenied with errors.Is. Use errors.As to get the denied
+// lookup from the error returned by ResolveTemplate.
+//
+// - ResourceAttributes are the attributes of the denied lookup, where the verb is "get" for a single object and "list"
+// for a list query.
+//
+// - User is the user name of the reviewed subject, which is empty for a SelfSubjectAccessReview.
+//
+// - Reason is the reason of the access review status, which may be empty.
+type LookupAccessDeniedError struct {
+	ResourceAttributes authorizationv1.ResourceAttributes
+	User               string
+	Reason             string
+}
+
+func (e LookupAccessDeniedError) Error() string {
+	attrs := e.ResourceAttributes
+
+	resource := attrs.Resource
+	if attrs.Group != "" {
+		resource += "." + attrs.Group
+	}
+
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+
+	msg := fmt.Sprintf("the %s of '%s'", attrs.Verb, resource)
+
+	if attrs.Name != "" {
+		msg += fmt.Sprintf(" named '%s'", attrs.Name)
+	}
+
+	if attrs.Namespace != "" {
+		msg += fmt.Sprintf(" in the namespace '%s'", attrs.Namespace)
+	}
+
+	if e.User != "" {
+		msg += fmt.Sprintf(" is not permitted for the user '%s'", e.User)
+	} else {
+		msg += " is not permitted"
+	}
+
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+
+	return msg
+}
+
+func (e LookupAccessDeniedError) Unwrap() error {
+	return ErrLookupAccessDenied
+}
+
+// reviewLookup returns a LookupAccessDeniedError if ResolveOptions.LookupAccessReview is set and its access review
+// doesn't permit the lookup of the object, or of the list of objects if the name is empty. The access review results
+// are cached for the ResolveTemplate call, and each access review that isn't cached is counted against MaxLookups.
+func (t *TemplateResolver) reviewLookup(
+	options *ResolveOptions, gvr schema.GroupVersionResource, namespace string, name string, subresource string,
+) error {
+	if options.LookupAccessReview == nil {
+		return nil
+	}
+
+	attrs := authorizationv1.ResourceAttributes{
+		Namespace:   namespace,
+		Verb:        "get",
+		Group:       gvr.Group,
+		Version:     gvr.Version,
+		Resource:    gvr.Resource,
+		Subresource: subresource,
+		Name:        name,
+	}
+
+	if name == "" {
+		attrs.Verb = "list"
+	}
+
+	var status authorizationv1.SubjectAccessReviewStatus
+
+	cached := false
+
+	if options.state != nil {
+		status, cached = options.state.accessReviews[attrs]
+	}
+
+	if !cached {
+		if err := options.countLookup(); err != nil {
+			return err
+		}
+
+		var err error
+
+		status, err = t.createAccessReview(options, attrs)
+		if err != nil {
+			return err
+		}
+
+		if options.state != nil {
+			if options.state.accessReviews == nil {
+				options.state.accessReviews = make(
+					map[authorizationv1.ResourceAttributes]authorizationv1.SubjectAccessReviewStatus,
+				)
+			}
+
+			options.state.accessReviews[attrs] = status
+		}
+	}
+
+	if !status.Allowed || status.Denied {
+		return LookupAccessDeniedError{
+			ResourceAttributes: attrs, User: options.LookupAccessReview.User, Reason: status.Reason,
+		}
+	}
+
+	return nil
+}
+
+// createAccessReview creates the SelfSubjectAccessReview or SubjectAccessReview of ResolveOptions.LookupAccessReview
+// for the resource attributes and returns its status.
+func (t *TemplateResolver) createAccessReview(
+	options *ResolveOptions, attrs authorizationv1.ResourceAttributes,
+) (authorizationv1.SubjectAccessReviewStatus, error) {
+	_, kubeClient, err := t.clientsFor(options)
+	if err != nil {
+		return authorizationv1.SubjectAccessReviewStatus{}, err
+	}
+
+	review := options.LookupAccessReview
+
+	if review.User == "" && len(review.Groups) == 0 {
+		result, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(
+			options.apiContext(),
+			&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attrs},
+			},
+			metav1.CreateOptions{},
+		)
+		if err != nil {
+			return authorizationv1.SubjectAccessReviewStatus{}, fmt.Errorf(
+				"failed to create the SelfSubjectAccessReview of the lookup: %w", err,
+			)
+		}
+
+		return result.Status, nil
+	}
+
+	result, err := kubeClient.AuthorizationV1().SubjectAccessReviews().Create(
+		options.apiContext(),
+		&authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: &attrs,
+				User:               review.User,
+				Groups:             review.Groups,
+				UID:                review.UID,
+				Extra:              review.Extra,
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return authorizationv1.SubjectAccessReviewStatus{}, fmt.Errorf(
+			"failed to create the SubjectAccessReview of the lookup: %w", err,
+		)
+	}
+
+	return result.Status, nil
+}
@@ -0,0 +1,225 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+func TestCanLookup(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		apiVersion      string
+		kind            string
+		namespace       string
+		verb            string
+		lookupNamespace string
+		expected        bool
+		expectedErr     bool
+	}{
+		"allowed":                 {"v1", "ConfigMap", "testns", "", "", true, false},
+		"allowed list":            {"v1", "Secret", "testns", "list", "", true, false},
+		"allowed cluster-scoped":  {"v1", "Namespace", "", "get", "", true, false},
+		"missing API resource":    {"example.com/v1", "Widget", "testns", "get", "", false, false},
+		"restricted namespace":    {"v1", "ConfigMap", "testns", "get", "policies-ns", false, false},
+		"restricted cluster-wide": {"v1", "Namespace", "", "get", "policies-ns", false, false},
+		"missing kind":            {"v1", "", "testns", "get", "", false, true},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			allowed, err := resolver.canLookup(
+				&ResolveOptions{LookupNamespace: test.lookupNamespace},
+				test.apiVersion,
+				test.kind,
+				test.namespace,
+				test.verb,
+			)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+
+			if allowed != test.expected {
+				t.Fatalf("expected: %v, got: %v", test.expected, allowed)
+			}
+		})
+	}
+}
+
+// reviewingTransport responds to the access reviews by denying the Secrets and records them. The other requests are
+// served by the wrapped fake API server.
+type reviewingTransport struct {
+	server  http.RoundTripper
+	lock    sync.Mutex
+	reviews []string
+}
+
+func (r *reviewingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "accessreviews") {
+		return r.server.RoundTrip(req)
+	}
+
+	review := authorizationv1.SubjectAccessReview{}
+
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+		return nil, err
+	}
+
+	attrs := review.Spec.ResourceAttributes
+
+	r.lock.Lock()
+	r.reviews = append(
+		r.reviews, fmt.Sprintf("%s %s %s %s/%s", review.Kind, review.Spec.User, attrs.Verb, attrs.Resource, attrs.Name),
+	)
+	r.lock.Unlock()
+
+	review.Status.Allowed = attrs.Resource != "secrets"
+
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Content-Type", "application/json")
+	recorder.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(recorder).Encode(&review); err != nil {
+		return nil, err
+	}
+
+	resp := recorder.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+func TestResolveTemplateLookupAccessReview(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "app-config", "namespace": "app"},
+			"data":       map[string]interface{}{"replicas": "3"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app-secret", "namespace": "app"},
+			"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+		}},
+	}
+
+	configMapTmpl := `replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'
+names: '{{ range (lookup "v1" "ConfigMap" "app" "").items }}{{ .metadata.name }}{{ end }}'
+again: '{{ fromConfigMap "app" "app-config" "replicas" }}'`
+
+	tests := map[string]struct {
+		inputTmpl       string
+		review          *LookupAccessReview
+		maxLookups      uint
+		expectedResult  string
+		expectedErr     error
+		expectedReviews []string
+	}{
+		"no access review": {
+			inputTmpl:      `password: '{{ fromSecret "app" "app-secret" "password" }}'`,
+			expectedResult: `{"password":"aHVudGVyMg=="}`,
+		},
+		"self access review allowed": {
+			inputTmpl:      configMapTmpl,
+			review:         &LookupAccessReview{},
+			expectedResult: `{"again":"3","names":"app-config","replicas":"3"}`,
+			expectedReviews: []string{
+				"SelfSubjectAccessReview  get configmaps/app-config",
+				"SelfSubjectAccessReview  list configmaps/",
+			},
+		},
+		"self access review denied": {
+			inputTmpl:       `password: '{{ fromSecret "app" "app-secret" "password" }}'`,
+			review:          &LookupAccessReview{},
+			expectedErr:     ErrLookupAccessDenied,
+			expectedReviews: []string{"SelfSubjectAccessReview  get secrets/app-secret"},
+		},
+		"subject access review denied": {
+			inputTmpl: `password: '{{ fromSecret "app" "app-secret" "password" }}'`,
+			review: &LookupAccessReview{
+				User: "system:serviceaccount:tenant:policies", Groups: []string{"system:serviceaccounts"},
+			},
+			expectedErr: ErrLookupAccessDenied,
+			expectedReviews: []string{
+				"SubjectAccessReview system:serviceaccount:tenant:policies get secrets/app-secret",
+			},
+		},
+		"access reviews count against the maximum lookups": {
+			inputTmpl:       `names: '{{ range (list "a" "b" "c") }}{{ lookup "v1" "ConfigMap" "app" . }}{{ end }}'`,
+			review:          &LookupAccessReview{},
+			maxLookups:      2,
+			expectedErr:     ErrLookupQuotaExceeded,
+			expectedReviews: []string{"SelfSubjectAccessReview  get configmaps/a"},
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			server, err := newFakeAPIServer(objects)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			transport := &reviewingTransport{server: server}
+
+			resolver, err := NewResolver(
+				&rest.Config{Host: fakeAPIServerHost, Transport: transport}, Config{InputIsYAML: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			options := &ResolveOptions{LookupAccessReview: test.review, MaxLookups: test.maxLookups}
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr == nil && string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+
+			if errors.Is(test.expectedErr, ErrLookupAccessDenied) {
+				deniedErr := LookupAccessDeniedError{}
+				if !errors.As(err, &deniedErr) || deniedErr.ResourceAttributes.Name != "app-secret" {
+					t.Fatalf("Expected a LookupAccessDeniedError of the Secret but got: %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(transport.reviews, test.expectedReviews) {
+				t.Fatalf("Expected the access reviews %v but got %v", test.expectedReviews, transport.reviews)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// aggregateFieldErrors resolves each templated string field of the input YAML document individually to find all the
+// templates that fail to resolve, since text/template stops at the first error. The input error from resolving the
+// whole document is returned as is if the document can't be split into fields or no individual field fails, such as
+// when a template spans multiple fields. Otherwise, the errors are joined and each includes the failing template.
+func (t *TemplateResolver) aggregateFieldErrors(
+	templateStr string, funcMap template.FuncMap, ctx interface{}, options *ResolveOptions, resolveErr error,
+) error {
+	var doc interface{}
+
+	if err := yaml.Unmarshal([]byte(templateStr), &doc); err != nil {
+		return resolveErr
+	}
+
+	fields := map[string]*templatedField{}
+	t.collectTemplatedFields(options, doc, "", fields)
+
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	fieldFuncMap := template.FuncMap{}
+	for name, fn := range funcMap {
+		fieldFuncMap[name] = fn
+	}
+
+	// The autoindent placeholders are only replaced when resolving the whole document, but the indentation is
+	// irrelevant to whether the field resolves.
+	fieldFuncMap["autoindent"] = func(s string) (string, error) { return s, nil }
+
+	var errs []error
+
+	for _, path := range paths {
+		field := fields[path]
+
+		tmpl, err := t.parseTemplate(path, field.template, fieldFuncMap, options)
+		if err != nil {
+			err = classifyError(ErrParseFailed, err)
+		} else {
+			err = tmpl.Execute(&bytes.Buffer{}, ctx)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the template %q at the field %s failed: %w", field.template, path, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return resolveErr
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveTemplateAggregateErrors(t *testing.T) {
+	t.Parallel()
+
+	tmpl := `
+first: '{{ "" | required "first must be set" }}'
+second: '{{ "valid" }}'
+third:
+  - '{{ "multiple\nlines" | toLiteral }}'
+`
+
+	resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if !errors.Is(err, ErrRequiredValue) || errors.Is(err, ErrNewLinesNotAllowed) {
+		t.Fatalf("Expected only the first error without AggregateErrors but got: %v", err)
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{AggregateErrors: true})
+	if !errors.Is(err, ErrRequiredValue) || !errors.Is(err, ErrNewLinesNotAllowed) {
+		t.Fatalf("Expected both errors with AggregateErrors but got: %v", err)
+	}
+
+	for _, expected := range []string{"at the field first failed", "at the field third.0 failed"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected the error to contain %q but got: %v", expected, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "field second") {
+		t.Fatalf("Expected the error to not reference the valid field but got: %v", err)
+	}
+}
+
+func TestResolveTemplateAggregateErrorsSpanningFields(t *testing.T) {
+	t.Parallel()
+
+	// The range action spans multiple fields, so the fields can't be resolved individually
+	tmpl := `
+items:
+{{- range $i := until 2 }}
+  - '{{ "" | required "the item must be set" }}'
+{{- end }}
+`
+
+	resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{AggregateErrors: true})
+	if !errors.Is(err, ErrRequiredValue) {
+		t.Fatalf("Expected the original error but got: %v", err)
+	}
+}
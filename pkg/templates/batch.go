@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+)
+
+// defaultMaxConcurrency is the default of ResolveOptions.MaxConcurrency.
+const defaultMaxConcurrency = 10
+
+// TemplateInput is a template to resolve with ResolveTemplates.
+//
+// - Template is the template to resolve. See ResolveTemplate.
+//
+// - Context is the template context. See ResolveTemplate.
+type TemplateInput struct {
+	Template []byte
+	Context  interface{}
+}
+
+// ResolveTemplates resolves the input templates concurrently with the same options. The number of templates resolved
+// at once is bounded by options.MaxConcurrency. The returned results and errors are in the order of the input
+// templates, and the error of a template that was resolved successfully is nil.
+//
+// The templates share the lookup cache: when caching is disabled, the temporary call cache is cleared after all the
+// templates are resolved rather than after each one. When caching is enabled, the templates are resolved in a single
+// query batch of options.Watcher, so the watches of all the templates are kept. If options.DisableAutoCacheCleanUp is
+// set, the caller must call the CacheCleanUp function of any of the results when done. Each lookup returns a copy of
+// the cached objects, so a template that modifies a lookup result doesn't affect the other templates. The spans of the
+// templates are children of a single ResolveTemplates span when Config.TracerProvider is set.
+func (t *TemplateResolver) ResolveTemplates(
+	ctx context.Context, inputs []TemplateInput, options *ResolveOptions,
+) ([]TemplateResult, []error) {
+	results := make([]TemplateResult, len(inputs))
+	errs := make([]error, len(inputs))
+
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	ctx, span := t.startSpan(ctx, "ResolveTemplates", attrTemplateCount.Int(len(inputs)))
+	defer span.End()
+
+	batchOptions := *options
+	batchOptions.inBatch = true
+
+	if t.dynamicWatcher != nil {
+		err := t.startBatch(options)
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+
+			return results, errs
+		}
+
+		// The query batch is ended after all the templates are resolved
+		batchOptions.DisableAutoCacheCleanUp = true
+	}
+
+	maxConcurrency := int(options.MaxConcurrency)
+	if maxConcurrency == 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < maxConcurrency && i < len(inputs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				results[index], errs[index] = t.ResolveTemplateWithContext(
+					ctx, inputs[index].Template, inputs[index].Context, &batchOptions,
+				)
+			}
+		}()
+	}
+
+	for i := range inputs {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	if t.tempCallCache != nil {
+		t.tempCallCache.Clear()
+	}
+
+	if t.dynamicWatcher != nil && !options.DisableAutoCacheCleanUp {
+		for i := range results {
+			results[i].CacheCleanUp = nil
+		}
+
+		err := t.dynamicWatcher.EndQueryBatch(*options.Watcher)
+		if err != nil && !errors.Is(err, client.ErrQueryBatchNotStarted) {
+			t.contextLogger(ctx).Error(err, "failed to end the query batch", "watcher", *options.Watcher)
+		}
+	}
+
+	return results, errs
+}
+
+// startBatch starts the query batch of options.Watcher for ResolveTemplates when caching is enabled.
+func (t *TemplateResolver) startBatch(options *ResolveOptions) error {
+	if options.Watcher == nil {
+		return fmt.Errorf("%w: options.Watcher cannot be nil if caching is enabled", ErrInvalidInput)
+	}
+
+	err := t.dynamicWatcher.StartQueryBatch(*options.Watcher)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, client.ErrQueryBatchInProgress) {
+		return classifyError(ErrWatcherFailed, err)
+	}
+
+	if !options.DisableAutoCacheCleanUp {
+		return fmt.Errorf("ResolveTemplates cannot be called with the same watchedObject in parallel: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestResolveTemplates(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "app"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	inputs := []TemplateInput{}
+
+	for i := 0; i < 20; i++ {
+		inputs = append(inputs, TemplateInput{
+			Template: []byte(`value: '{{ .Index }}-{{ fromConfigMap "app" "config" "key" }}'`),
+			Context:  struct{ Index string }{Index: fmt.Sprint(i)},
+		})
+	}
+
+	inputs = append(inputs, TemplateInput{Template: []byte(`value: '{{ notAFunction }}'`)})
+
+	results, errs := resolver.ResolveTemplates(context.TODO(), inputs, &ResolveOptions{MaxConcurrency: 3})
+
+	if len(results) != len(inputs) || len(errs) != len(inputs) {
+		t.Fatalf("Expected %d results and errors but got %d and %d", len(inputs), len(results), len(errs))
+	}
+
+	for i := 0; i < 20; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Expected no error for the template %d but got: %v", i, errs[i])
+		}
+
+		expected := fmt.Sprintf(`{"value":"%d-value"}`, i)
+		if string(results[i].ResolvedJSON) != expected {
+			t.Fatalf("Expected %s for the template %d but got: %s", expected, i, results[i].ResolvedJSON)
+		}
+
+		if len(results[i].ReferencedObjects) != 1 {
+			t.Fatalf("Expected one referenced object for the template %d but got: %v", i, results[i].ReferencedObjects)
+		}
+	}
+
+	if errs[20] == nil {
+		t.Fatalf("Expected an error for the invalid template")
+	}
+}
+
+// TestResolveTemplatesModifiedLookup checks that the templates of a batch don't share the objects of their lookups. Run
+// it with the -race flag to detect concurrent modifications.
+func TestResolveTemplatesModifiedLookup(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "app"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	options := &ResolveOptions{
+		MaxConcurrency:        2,
+		EnabledFunctionGroups: []string{"dicts"},
+		CustomFunctions: map[string]interface{}{
+			"modify": func(data map[string]interface{}, value string) string {
+				data["key"] = value
+
+				return ""
+			},
+		},
+	}
+
+	inputs := []TemplateInput{
+		{Template: []byte(`value: '{{ modify (lookup "v1" "ConfigMap" "app" "config").data "first" }}` +
+			`{{ fromConfigMap "app" "config" "key" }}'`)},
+		{Template: []byte(`value: '{{ $data := (lookup "v1" "ConfigMap" "app" "config").data }}` +
+			`{{ $_ := mergeOverwrite $data (dict "key" "second") }}{{ modify $data "second" }}` +
+			`{{ fromConfigMap "app" "config" "key" }}'`)},
+	}
+
+	results, errs := resolver.ResolveTemplates(context.TODO(), inputs, options)
+
+	for i := range inputs {
+		if errs[i] != nil {
+			t.Fatalf("Expected no error for the template %d but got: %v", i, errs[i])
+		}
+
+		if string(results[i].ResolvedJSON) != `{"value":"value"}` {
+			t.Fatalf("Expected the lookup of the template %d to be unmodified but got: %s", i, results[i].ResolvedJSON)
+		}
+	}
+}
+
+func TestResolveTemplatesCanceled(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, errs := resolver.ResolveTemplates(ctx, []TemplateInput{{Template: []byte(`value: '{{ "value" }}'`)}}, nil)
+
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Fatalf("Expected the context.Canceled error but got: %v", errs)
+	}
+}
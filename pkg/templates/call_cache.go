@@ -0,0 +1,241 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// LookupCache caches the results of the lookups of the template functions when caching is disabled. The key is the
+// object identifier of the query, where a list query has an empty Name. Implementations must be concurrency safe.
+// See Config.LookupCache.
+type LookupCache interface {
+	// Get returns the cached objects of the query. An error wrapping ErrNoCacheEntry must be returned if the query is
+	// not cached. A query for a single object has one object, or no objects if the object was not found.
+	Get(objID client.ObjectIdentifier) ([]unstructured.Unstructured, error)
+	// Set caches the objects of the query.
+	Set(objID client.ObjectIdentifier, objects []unstructured.Unstructured)
+	// Invalidate removes the query from the cache.
+	Invalidate(objID client.ObjectIdentifier)
+}
+
+// objectLookupCache is the built-in LookupCache backed by the client.ObjectCache of the resolver.
+type objectLookupCache struct {
+	cache client.ObjectCache
+}
+
+func (c objectLookupCache) Get(objID client.ObjectIdentifier) ([]unstructured.Unstructured, error) {
+	return c.cache.FromObjectIdentifier(objID)
+}
+
+func (c objectLookupCache) Set(objID client.ObjectIdentifier, objects []unstructured.Unstructured) {
+	c.cache.CacheFromObjectIdentifier(objID, objects)
+}
+
+func (c objectLookupCache) Invalidate(objID client.ObjectIdentifier) {
+	c.cache.UncacheFromObjectIdentifier(objID)
+}
+
+// cacheLRU tracks the usage order of cache entries so that the least recently used entries can be evicted when the
+// number of entries exceeds maxEntries. A nil *cacheLRU is valid and tracks nothing.
+type cacheLRU struct {
+	lock       sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[client.ObjectIdentifier]*list.Element
+}
+
+func newCacheLRU(maxEntries int) *cacheLRU {
+	return &cacheLRU{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[client.ObjectIdentifier]*list.Element{},
+	}
+}
+
+// touch marks the cache entry as the most recently used.
+func (c *cacheLRU) touch(objID client.ObjectIdentifier) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[objID]; ok {
+		c.order.MoveToFront(element)
+	}
+}
+
+// add tracks the cache entry as the most recently used and returns the least recently used entries that must be
+// evicted from the cache to respect maxEntries.
+func (c *cacheLRU) add(objID client.ObjectIdentifier) []client.ObjectIdentifier {
+	if c == nil {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[objID]; ok {
+		c.order.MoveToFront(element)
+
+		return nil
+	}
+
+	c.entries[objID] = c.order.PushFront(objID)
+
+	var evicted []client.ObjectIdentifier
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		oldestID := c.order.Remove(oldest).(client.ObjectIdentifier) //nolint:forcetypeassert
+
+		delete(c.entries, oldestID)
+		evicted = append(evicted, oldestID)
+	}
+
+	return evicted
+}
+
+// remove stops tracking the cache entry.
+func (c *cacheLRU) remove(objID client.ObjectIdentifier) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[objID]; ok {
+		c.order.Remove(element)
+		delete(c.entries, objID)
+	}
+}
+
+// expiringLookupCache is a LookupCache that keeps the entries across ResolveTemplate calls until they expire or are
+// evicted as the least recently used entries. See Config.LookupCacheTTL and Config.LookupCacheMaxEntries.
+type expiringLookupCache struct {
+	lock sync.Mutex
+	// ttl is how long an entry is valid for. Entries don't expire if this is 0.
+	ttl time.Duration
+	// notFoundTTL is how long an entry of an object that was not found is valid for. The ttl is used if this is 0.
+	notFoundTTL time.Duration
+	// sweepInterval is the shortest non-zero TTL, which is how often the expired entries are removed.
+	sweepInterval time.Duration
+	now           func() time.Time
+	log           logr.Logger
+	// lru is nil if the number of entries is not bounded.
+	lru       *cacheLRU
+	entries   map[client.ObjectIdentifier]expiringLookupCacheEntry
+	lastSweep time.Time
+}
+
+type expiringLookupCacheEntry struct {
+	objects []unstructured.Unstructured
+	// expires is the zero time if the entry doesn't expire.
+	expires time.Time
+}
+
+func newExpiringLookupCache(
+	ttl time.Duration, notFoundTTL time.Duration, maxEntries uint, now func() time.Time, log logr.Logger,
+) *expiringLookupCache {
+	cache := &expiringLookupCache{
+		log:           log,
+		ttl:           ttl,
+		notFoundTTL:   notFoundTTL,
+		sweepInterval: ttl,
+		now:           now,
+		entries:       map[client.ObjectIdentifier]expiringLookupCacheEntry{},
+		lastSweep:     now(),
+	}
+
+	if notFoundTTL > 0 && (ttl == 0 || notFoundTTL < ttl) {
+		cache.sweepInterval = notFoundTTL
+	}
+
+	if maxEntries > 0 {
+		cache.lru = newCacheLRU(int(maxEntries))
+	}
+
+	return cache
+}
+
+func (c *expiringLookupCache) Get(objID client.ObjectIdentifier) ([]unstructured.Unstructured, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[objID]
+	if !ok {
+		return nil, ErrNoCacheEntry
+	}
+
+	if c.expired(entry) {
+		c.delete(objID)
+
+		return nil, ErrNoCacheEntry
+	}
+
+	c.lru.touch(objID)
+
+	return entry.objects, nil
+}
+
+func (c *expiringLookupCache) Set(objID client.ObjectIdentifier, objects []unstructured.Unstructured) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+
+	// Remove the expired entries that were not queried again at most once per TTL so that they don't accumulate
+	if c.sweepInterval > 0 && now.Sub(c.lastSweep) >= c.sweepInterval {
+		for entryID, entry := range c.entries {
+			if c.expired(entry) {
+				c.delete(entryID)
+			}
+		}
+
+		c.lastSweep = now
+	}
+
+	ttl := c.ttl
+	if objID.Name != "" && len(objects) == 0 && c.notFoundTTL > 0 {
+		ttl = c.notFoundTTL
+	}
+
+	entry := expiringLookupCacheEntry{objects: objects}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+
+	c.entries[objID] = entry
+
+	for _, evictedID := range c.lru.add(objID) {
+		c.log.V(2).Info("Evicting the least recently used lookup cache entry", "entry", evictedID)
+
+		delete(c.entries, evictedID)
+	}
+}
+
+func (c *expiringLookupCache) Invalidate(objID client.ObjectIdentifier) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.delete(objID)
+}
+
+func (c *expiringLookupCache) expired(entry expiringLookupCacheEntry) bool {
+	return !entry.expires.IsZero() && !c.now().Before(entry.expires)
+}
+
+// delete removes the entry. The lock must be held by the caller.
+func (c *expiringLookupCache) delete(objID client.ObjectIdentifier) {
+	delete(c.entries, objID)
+	c.lru.remove(objID)
+}
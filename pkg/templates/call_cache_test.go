@@ -0,0 +1,354 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCacheLRU(t *testing.T) {
+	t.Parallel()
+
+	objA := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "a"}
+	objB := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "b"}
+	objC := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "c"}
+
+	lru := newCacheLRU(2)
+
+	if evicted := lru.add(objA); len(evicted) != 0 {
+		t.Fatalf("Expected no evictions but got %v", evicted)
+	}
+
+	if evicted := lru.add(objB); len(evicted) != 0 {
+		t.Fatalf("Expected no evictions but got %v", evicted)
+	}
+
+	// Using objA makes objB the least recently used
+	lru.touch(objA)
+
+	evicted := lru.add(objC)
+	if len(evicted) != 1 || evicted[0] != objB {
+		t.Fatalf("Expected objB to be evicted but got %v", evicted)
+	}
+
+	// Adding an existing entry doesn't evict anything
+	if evicted := lru.add(objA); len(evicted) != 0 {
+		t.Fatalf("Expected no evictions but got %v", evicted)
+	}
+
+	// A nil LRU tracks nothing
+	var nilLRU *cacheLRU
+
+	nilLRU.touch(objA)
+
+	if evicted := nilLRU.add(objA); len(evicted) != 0 {
+		t.Fatalf("Expected no evictions but got %v", evicted)
+	}
+}
+
+func TestResolveTemplateTempCallCacheMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `data: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1" }}-` +
+			`{{ fromSecret "testns" "testsecret" "secretkey1" }}-` +
+			`{{ fromConfigMap "testns" "testconfigmap" "cmkey2" }}'`,
+		resolveOptions: ResolveOptions{TempCallCacheMaxEntries: 1},
+		expectedResult: "data: cmkey1Val-c2VjcmV0a2V5MVZhbA==-cmkey2Val",
+	})
+}
+
+// mapLookupCache is a LookupCache backed by a map for testing.
+type mapLookupCache struct {
+	lock    sync.Mutex
+	entries map[client.ObjectIdentifier][]unstructured.Unstructured
+}
+
+func (c *mapLookupCache) Get(objID client.ObjectIdentifier) ([]unstructured.Unstructured, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	objects, ok := c.entries[objID]
+	if !ok {
+		return nil, ErrNoCacheEntry
+	}
+
+	return objects, nil
+}
+
+func (c *mapLookupCache) Set(objID client.ObjectIdentifier, objects []unstructured.Unstructured) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[objID] = objects
+}
+
+func (c *mapLookupCache) Invalidate(objID client.ObjectIdentifier) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, objID)
+}
+
+func TestResolveTemplateLookupCache(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "from-api", "namespace": "app"},
+		"data":       map[string]interface{}{"key": "api"},
+	}}}
+
+	cachedID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "from-cache"}
+	cache := &mapLookupCache{entries: map[client.ObjectIdentifier][]unstructured.Unstructured{
+		cachedID: {{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "from-cache", "namespace": "app"},
+			"data":       map[string]interface{}{"key": "cache"},
+		}}},
+	}}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true, LookupCache: cache})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `value: '{{ fromConfigMap "app" "from-cache" "key" }}-{{ fromConfigMap "app" "from-api" "key" }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if string(result.ResolvedJSON) != `{"value":"cache-api"}` {
+		t.Fatalf("Expected the cached object to be used but got: %s", result.ResolvedJSON)
+	}
+
+	// The cache is not cleared after the resolution
+	apiID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "from-api"}
+	if _, err := cache.Get(apiID); err != nil {
+		t.Fatalf("Expected the lookup to be cached but got: %v", err)
+	}
+
+	if _, err := cache.Get(cachedID); err != nil {
+		t.Fatalf("Expected the cache entry to be kept but got: %v", err)
+	}
+}
+
+func TestExpiringLookupCache(t *testing.T) {
+	t.Parallel()
+
+	objA := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "a"}
+	objB := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "b"}
+	objC := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "c"}
+
+	now := time.Date(2023, time.October, 31, 12, 30, 0, 0, time.UTC)
+	cache := newExpiringLookupCache(time.Minute, 0, 2, func() time.Time { return now }, logr.Discard())
+
+	cache.Set(objA, []unstructured.Unstructured{})
+	cache.Set(objB, []unstructured.Unstructured{})
+
+	now = now.Add(30 * time.Second)
+
+	// Using objA makes objB the least recently used
+	if _, err := cache.Get(objA); err != nil {
+		t.Fatalf("Expected objA to be cached but got: %v", err)
+	}
+
+	cache.Set(objC, []unstructured.Unstructured{})
+
+	if _, err := cache.Get(objB); !errors.Is(err, ErrNoCacheEntry) {
+		t.Fatalf("Expected objB to be evicted but got: %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+
+	if _, err := cache.Get(objA); !errors.Is(err, ErrNoCacheEntry) {
+		t.Fatalf("Expected objA to be expired but got: %v", err)
+	}
+
+	if _, err := cache.Get(objC); err != nil {
+		t.Fatalf("Expected objC to be cached but got: %v", err)
+	}
+
+	cache.Invalidate(objC)
+
+	if _, err := cache.Get(objC); !errors.Is(err, ErrNoCacheEntry) {
+		t.Fatalf("Expected objC to be invalidated but got: %v", err)
+	}
+
+	// The expired entries that are not queried again are removed on a later Set
+	cache.Set(objA, []unstructured.Unstructured{})
+
+	now = now.Add(2 * time.Minute)
+
+	cache.Set(objB, []unstructured.Unstructured{})
+
+	if len(cache.entries) != 1 {
+		t.Fatalf("Expected only objB to be cached but got: %v", cache.entries)
+	}
+}
+
+func TestNewResolverLookupCacheTTL(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "app"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true, LookupCacheTTL: time.Minute})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`value: '{{ fromConfigMap "app" "config" "key" }}'`), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// The lookup is kept after the ResolveTemplate call
+	objID := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "config"}
+	if _, err := resolver.lookupCache.Get(objID); err != nil {
+		t.Fatalf("Expected the lookup to be cached but got: %v", err)
+	}
+
+	_, err = NewResolver(k8sConfig, Config{LookupCacheTTL: time.Minute, LookupCache: &mapLookupCache{}})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+
+	_, err = NewResolver(k8sConfig, Config{LookupCacheTTL: -time.Minute})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
+
+func TestLookupResultModification(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "app"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}}
+
+	options := &ResolveOptions{
+		CustomFunctions: map[string]interface{}{
+			"modify": func(data map[string]interface{}) string {
+				data["key"] = "MUTATED"
+
+				return ""
+			},
+		},
+	}
+
+	tmpl := []byte(`modified: '{{ modify (lookup "v1" "ConfigMap" "app" "config").data }}` +
+		`{{ fromConfigMap "app" "config" "key" }}'`)
+
+	for name, config := range map[string]Config{
+		"per call cache": {InputIsYAML: true},
+		"lookup cache":   {InputIsYAML: true, LookupCacheTTL: time.Minute},
+	} {
+		config := config
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(objects, config)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			for i := 0; i < 2; i++ {
+				result, err := resolver.ResolveTemplate(tmpl, nil, options)
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				if string(result.ResolvedJSON) != `{"modified":"value"}` {
+					t.Fatalf("Expected the modification to not affect the lookups but got %s", result.ResolvedJSON)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiringLookupCacheNotFoundTTL(t *testing.T) {
+	t.Parallel()
+
+	found := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "found"}
+	notFound := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "not-found"}
+	emptyList := client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "testns"}
+
+	now := time.Date(2023, time.October, 31, 12, 30, 0, 0, time.UTC)
+	cache := newExpiringLookupCache(0, 10*time.Second, 0, func() time.Time { return now }, logr.Discard())
+
+	cache.Set(found, []unstructured.Unstructured{{}})
+	cache.Set(notFound, []unstructured.Unstructured{})
+	cache.Set(emptyList, []unstructured.Unstructured{})
+
+	now = now.Add(10 * time.Second)
+
+	if _, err := cache.Get(notFound); !errors.Is(err, ErrNoCacheEntry) {
+		t.Fatalf("Expected the not found entry to be expired but got: %v", err)
+	}
+
+	// Only the lookups of single objects that were not found use the not found TTL
+	for _, objID := range []client.ObjectIdentifier{found, emptyList} {
+		if _, err := cache.Get(objID); err != nil {
+			t.Fatalf("Expected %s to be cached but got: %v", objID, err)
+		}
+	}
+}
+
+func TestResolveTemplateDisableNotFoundCache(t *testing.T) {
+	t.Parallel()
+
+	tmpl := `value: '{{ empty (lookup "v1" "ConfigMap" "app" "not-found") }}-` +
+		`{{ empty (lookup "v1" "ConfigMap" "app" "not-found") }}'`
+
+	tests := map[string]struct {
+		disableNotFoundCache bool
+		expectedErr          error
+	}{
+		"not found is cached": {},
+		"not found is not cached": {
+			disableNotFoundCache: true,
+			expectedErr:          ErrLookupQuotaExceeded,
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(
+				nil, Config{InputIsYAML: true, DisableNotFoundCache: test.disableNotFoundCache},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			// The second lookup exceeds the maximum unless it's served from the cache
+			_, err = resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{MaxLookups: 1})
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
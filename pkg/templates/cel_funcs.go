@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/ext"
+	"github.com/spf13/cast"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// celCostLimit is the maximum runtime cost of a CEL expression, which matches the per expression limit of a
+// Kubernetes ValidatingAdmissionPolicy.
+const celCostLimit = 1000000
+
+func (t *TemplateResolver) celHelper(options *ResolveOptions) func(string, interface{}) (interface{}, error) {
+	return func(expression string, data interface{}) (interface{}, error) {
+		return t.cel(options, expression, data)
+	}
+}
+
+// cel evaluates the CEL expression with the keys of the input map as the variables, like in a Kubernetes
+// ValidatingAdmissionPolicy. For example, `{{ cel "object.spec.replicas > 1" (dict "object" $obj) }}`. The CEL
+// standard library and the strings, sets, math, and encoders extension libraries are available. Scalar results are
+// returned as is and lists and maps are returned as JSON compatible values. The evaluation stops if the runtime cost
+// exceeds celCostLimit or the context of the ResolveTemplateWithContext call is canceled.
+func (t *TemplateResolver) cel(options *ResolveOptions, expression string, data interface{}) (interface{}, error) {
+	vars, err := cast.ToStringMapE(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the CEL variables must be a map with string keys, got %T", ErrInvalidInput, data)
+	}
+
+	varNames := make([]string, 0, len(vars))
+	for name := range vars {
+		varNames = append(varNames, name)
+	}
+
+	sort.Strings(varNames)
+
+	envOptions := []cel.EnvOption{ext.Strings(), ext.Sets(), ext.Math(), ext.Encoders()}
+
+	for _, name := range varNames {
+		envOptions = append(envOptions, cel.Variable(name, cel.DynType))
+	}
+
+	env, err := cel.NewEnv(envOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create the CEL environment: %w", ErrInvalidInput, err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("%w: the CEL expression %s is invalid: %w", ErrInvalidInput, expression, issues.Err())
+	}
+
+	program, err := env.Program(ast, cel.CostLimit(celCostLimit), cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, fmt.Errorf("%w: the CEL expression %s is invalid: %w", ErrInvalidInput, expression, err)
+	}
+
+	result, _, err := program.ContextEval(options.apiContext(), vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate the CEL expression %s: %w", expression, err)
+	}
+
+	switch result.Type() {
+	case types.BoolType, types.IntType, types.UintType, types.DoubleType, types.StringType, types.BytesType:
+		return result.Value(), nil
+	case types.NullType:
+		return nil, nil
+	}
+
+	jsonValue, err := result.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the result of the CEL expression %s: %w", expression, err)
+	}
+
+	return jsonValue.(*structpb.Value).AsInterface(), nil
+}
@@ -0,0 +1,175 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCEL(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{}
+
+	deployment := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web", "labels": map[string]interface{}{"app": "web"}},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "registry.example.com/app:1"},
+						map[string]interface{}{"name": "sidecar", "image": "docker.io/sidecar:2"},
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		expression string
+		data       interface{}
+		expected   interface{}
+	}{
+		"bool": {
+			expression: "object.spec.replicas > 1",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   true,
+		},
+		"int": {
+			expression: "object.spec.replicas * 2",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   int64(6),
+		},
+		"string": {
+			expression: "object.metadata.name + '-' + params.suffix",
+			data:       map[string]interface{}{"object": deployment, "params": map[string]string{"suffix": "svc"}},
+			expected:   "web-svc",
+		},
+		"list": {
+			expression: "object.spec.template.spec.containers.map(c, c.name)",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   []interface{}{"app", "sidecar"},
+		},
+		"all macro": {
+			expression: "object.spec.template.spec.containers.all(c, c.image.startsWith('registry.example.com/'))",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   false,
+		},
+		"has macro": {
+			expression: "has(object.spec.paused)",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   false,
+		},
+		"map": {
+			expression: "{'name': object.metadata.name, 'count': size(object.spec.template.spec.containers)}",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   map[string]interface{}{"name": "web", "count": float64(2)},
+		},
+		"strings extension": {
+			expression: "object.metadata.labels.app.upperAscii()",
+			data:       map[string]interface{}{"object": deployment},
+			expected:   "WEB",
+		},
+		"null": {
+			expression: "null",
+			data:       map[string]interface{}{},
+			expected:   nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.cel(&ResolveOptions{}, test.expression, test.data)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Fatalf("Expected %#v but got %#v", test.expected, result)
+			}
+		})
+	}
+
+	_, err := resolver.cel(&ResolveOptions{}, "object.spec.replicas >", map[string]interface{}{"object": deployment})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for a syntax error but got %v", err)
+	}
+
+	_, err = resolver.cel(&ResolveOptions{}, "missing.spec", map[string]interface{}{"object": deployment})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for an undeclared variable but got %v", err)
+	}
+
+	_, err = resolver.cel(&ResolveOptions{}, "true", []string{"object"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for non-map variables but got %v", err)
+	}
+
+	_, err = resolver.cel(&ResolveOptions{}, "object.spec.missing", map[string]interface{}{"object": deployment})
+	if err == nil {
+		t.Fatal("Expected an evaluation error for a missing key")
+	}
+
+	_, err = resolver.cel(
+		&ResolveOptions{},
+		"items.map(a, items.map(b, items.map(c, a + b + c)))",
+		map[string]interface{}{"items": make([]interface{}, 200)},
+	)
+	if err == nil {
+		t.Fatal("Expected the cost limit to be exceeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = resolver.cel(
+		&ResolveOptions{state: &resolveState{ctx: ctx}},
+		"items.all(a, items.all(b, true))",
+		map[string]interface{}{"items": make([]interface{}, 200)},
+	)
+	if err == nil {
+		t.Fatal("Expected the canceled context to stop the evaluation")
+	}
+}
+
+func TestCELTemplate(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "app"},
+			"spec":       map[string]interface{}{"replicas": int64(3)},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `scaled: '{{ cel "object.spec.replicas >= params.min" ` +
+		`(dict "object" (lookup "apps/v1" "Deployment" "app" "web") "params" (dict "min" 2)) }}'`
+
+	result, err := resolver.ResolveTemplate(
+		[]byte(tmpl), nil, &ResolveOptions{EnabledFunctionGroups: []string{"dicts"}},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"scaled":"true"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("Expected %s but got %s", expected, result.ResolvedJSON)
+	}
+}
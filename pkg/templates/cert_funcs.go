@@ -0,0 +1,107 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCertificate = errors.New("the input is not a valid PEM encoded certificate")
+
+// parseCertificate parses the first certificate in the input PEM, such as the leaf certificate of a chain in the
+// tls.crt key of a Secret. Since the fromSecret template function returns base64 encoded values, the input may also be
+// the base64 encoding of the PEM. An error wrapping ErrInvalidCertificate is returned if no certificate can be parsed.
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	if !strings.Contains(certPEM, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(certPEM))
+		if err != nil {
+			return nil, fmt.Errorf("%w: the input is neither PEM nor base64 encoded", ErrInvalidCertificate)
+		}
+
+		certPEM = string(decoded)
+	}
+
+	rest := []byte(certPEM)
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("%w: no CERTIFICATE PEM block was found", ErrInvalidCertificate)
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
+		}
+
+		return cert, nil
+	}
+}
+
+// certDecode returns the commonly inspected fields of the first certificate in the input PEM as a map with the keys
+// subject, commonName, issuer, serialNumber, notBefore, notAfter, isCA, dnsNames, ipAddresses, emailAddresses, and
+// uris. The subject and issuer are distinguished names and the notBefore and notAfter times are RFC 3339 strings.
+func certDecode(certPEM string) (map[string]interface{}, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	ipAddresses := make([]interface{}, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ipAddresses = append(ipAddresses, ip.String())
+	}
+
+	uris := make([]interface{}, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return map[string]interface{}{
+		"subject":        cert.Subject.String(),
+		"commonName":     cert.Subject.CommonName,
+		"issuer":         cert.Issuer.String(),
+		"serialNumber":   cert.SerialNumber.String(),
+		"notBefore":      cert.NotBefore.UTC().Format(time.RFC3339),
+		"notAfter":       cert.NotAfter.UTC().Format(time.RFC3339),
+		"isCA":           cert.IsCA,
+		"dnsNames":       toInterfaceSlice(cert.DNSNames),
+		"ipAddresses":    ipAddresses,
+		"emailAddresses": toInterfaceSlice(cert.EmailAddresses),
+		"uris":           uris,
+	}, nil
+}
+
+// certExpiryDays returns the number of whole days until the first certificate in the input PEM expires, based on
+// Config.Clock. This is negative if the certificate has already expired. For example,
+// `{{ if lt (certExpiryDays $cert) 30 }}` checks if the certificate expires within 30 days.
+func (t *TemplateResolver) certExpiryDays(certPEM string) (int, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(math.Floor(cert.NotAfter.Sub(t.now()).Hours() / 24)), nil
+}
+
+func toInterfaceSlice(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+
+	return result
+}
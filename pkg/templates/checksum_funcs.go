@@ -0,0 +1,19 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hmacSha256 returns the hex encoded HMAC-SHA256 of the message with the key. The message is the last argument so that
+// it can be piped (e.g. `{{ (lookup "v1" "ConfigMap" "ns" "name").data | toJSON | hmacSha256 $key }}`). Unlike a
+// plain checksum such as sha256sum, the result can't be computed without the key.
+func hmacSha256(key string, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,55 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"testing"
+)
+
+func TestHmacSha256(t *testing.T) {
+	t.Parallel()
+
+	// From RFC 4231 test case 2
+	result := hmacSha256("Jefe", "what do ya want for nothing?")
+
+	expected := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if result != expected {
+		t.Fatalf("Expected %s but got %s", expected, result)
+	}
+}
+
+func TestResolveTemplateChecksums(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"sha256sum": {
+			inputTmpl:      `value: '{{ sha256sum "value" }}'`,
+			expectedResult: "value: cd42404d52ad55ccfa9aca4adc828aa5800ad9d385a0671fbcbf724118320619",
+		},
+		"sha1sum": {
+			inputTmpl:      `value: '{{ sha1sum "value" }}'`,
+			expectedResult: "value: f32b67c7e26342af42efabc674d441dca0a281c5",
+		},
+		"adler32sum": {
+			inputTmpl:      `value: '{{ adler32sum "value" }}'`,
+			expectedResult: `value: "107610654"`,
+		},
+		"hmacSha256": {
+			inputTmpl:      `value: '{{ "what do ya want for nothing?" | hmacSha256 "Jefe" }}'`,
+			expectedResult: "value: 5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+		"checksum of data": {
+			inputTmpl:      `value: '{{ fromJson "{\"b\":\"2\",\"a\":\"1\"}" | toJSON | sha256sum }}'`,
+			expectedResult: "value: 21f76dfbfe6dfe21f762080ef484112cf2952974cef30741fd1931e1c6d92112",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
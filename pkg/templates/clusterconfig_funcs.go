@@ -6,6 +6,10 @@ package templates
 import (
 	"errors"
 	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const clusterClaimAPIVersion string = "cluster.open-cluster-management.io/v1alpha1"
@@ -40,3 +44,141 @@ func (t *TemplateResolver) fromClusterClaim(options *ResolveOptions, claimName s
 
 	return value, nil
 }
+
+func (t *TemplateResolver) fromClusterClaimOrDefaultHelper(
+	options *ResolveOptions,
+) func(string, string) (string, error) {
+	return func(claimName string, defaultVal string) (string, error) {
+		return t.fromClusterClaimOrDefault(options, claimName, defaultVal)
+	}
+}
+
+// fromClusterClaimOrDefault retrieves the Spec value for the given clusterclaim like fromClusterClaim, but returns the
+// default value if the ClusterClaim is not found or the ClusterClaim API resource is not installed.
+func (t *TemplateResolver) fromClusterClaimOrDefault(
+	options *ResolveOptions, claimName string, defaultVal string,
+) (string, error) {
+	if claimName == "" {
+		return "", errors.New("a claim name must be provided")
+	}
+
+	clusterClaim, err := t.getOrList(options, clusterClaimAPIVersion, "ClusterClaim", "", claimName)
+	if err != nil {
+		if apierrors.IsNotFound(err) || errors.Is(err, ErrMissingAPIResource) {
+			return defaultVal, nil
+		}
+
+		return "", err
+	}
+
+	// A cached not found result is returned as nil
+	if clusterClaim == nil {
+		return defaultVal, nil
+	}
+
+	value, _, _ := unstructured.NestedString(clusterClaim, "spec", "value")
+
+	return value, nil
+}
+
+func (t *TemplateResolver) clusterClaimsHelper(options *ResolveOptions) func() (map[string]interface{}, error) {
+	return func() (map[string]interface{}, error) {
+		return t.clusterClaims(options)
+	}
+}
+
+// clusterClaims lists all the ClusterClaims and returns a map of the claim names to their Spec values. This is more
+// efficient than calling fromClusterClaim for each claim since a single list query is made.
+func (t *TemplateResolver) clusterClaims(options *ResolveOptions) (map[string]interface{}, error) {
+	list, err := t.getOrList(options, clusterClaimAPIVersion, "ClusterClaim", "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed listing the ClusterClaims: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+
+	items, _, _ := unstructured.NestedSlice(list, "items")
+	for _, item := range items {
+		clusterClaim, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(clusterClaim, "metadata", "name")
+		value, _, _ := unstructured.NestedString(clusterClaim, "spec", "value")
+		claims[name] = value
+	}
+
+	return claims, nil
+}
+
+func (t *TemplateResolver) getNodesHelper(options *ResolveOptions) func(...string) ([]interface{}, error) {
+	return func(labelSelector ...string) ([]interface{}, error) {
+		return t.getNodes(options, labelSelector...)
+	}
+}
+
+// getNodes lists the Nodes matching the optional label selector and returns a trimmed representation of each, sorted by
+// name. Each Node has the keys name, labels, capacity, and conditions, where conditions maps each condition type to its
+// status (e.g. Ready: "True"). Like lookup, the Nodes must be in the ClusterScopedAllowList when the lookups are
+// restricted to namespaces.
+func (t *TemplateResolver) getNodes(options *ResolveOptions, labelSelector ...string) ([]interface{}, error) {
+	t.logger(options).V(2).Info("getNodes", "labelSelector", labelSelector)
+
+	list, err := t.getOrList(options, "v1", "Node", "", "", labelSelector...)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing the Nodes: %w", err)
+	}
+
+	items, _, _ := unstructured.NestedSlice(list, "items")
+	nodes := make([]interface{}, 0, len(items))
+
+	for _, item := range items {
+		node, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(node, "metadata", "name")
+
+		labels, _, _ := unstructured.NestedMap(node, "metadata", "labels")
+		if labels == nil {
+			labels = map[string]interface{}{}
+		}
+
+		capacity, _, _ := unstructured.NestedMap(node, "status", "capacity")
+		if capacity == nil {
+			capacity = map[string]interface{}{}
+		}
+
+		conditions := map[string]interface{}{}
+
+		rawConditions, _, _ := unstructured.NestedSlice(node, "status", "conditions")
+		for _, rawCondition := range rawConditions {
+			condition, ok := rawCondition.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			conditionType, _, _ := unstructured.NestedString(condition, "type")
+			status, _, _ := unstructured.NestedString(condition, "status")
+
+			if conditionType != "" {
+				conditions[conditionType] = status
+			}
+		}
+
+		nodes = append(nodes, map[string]interface{}{
+			"name":       name,
+			"labels":     labels,
+			"capacity":   capacity,
+			"conditions": conditions,
+		})
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].(map[string]interface{})["name"].(string) < nodes[j].(map[string]interface{})["name"].(string)
+	})
+
+	return nodes, nil
+}
@@ -2,7 +2,12 @@
 
 package templates
 
-import "testing"
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
 
 func TestFromClusterClaimInvalidInput(t *testing.T) {
 	resolver, err := NewResolver(k8sConfig, Config{})
@@ -37,3 +42,169 @@ func TestFromClusterClaimNotFound(t *testing.T) {
 		t.Fatalf("Expected no return value due to the error but got %v", rv)
 	}
 }
+
+func TestGetNodes(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(name string, role string, ready string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]interface{}{"node-role.kubernetes.io/" + role: ""},
+			},
+			"status": map[string]interface{}{
+				"capacity": map[string]interface{}{"cpu": "4", "memory": "16Gi"},
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": ready},
+					map[string]interface{}{"type": "DiskPressure", "status": "False"},
+				},
+			},
+		}}
+	}
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{
+			newNode("worker-2", "worker", "False"),
+			newNode("worker-1", "worker", "True"),
+			newNode("control-plane-1", "master", "True"),
+		},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		options        ResolveOptions
+		expectedResult string
+		expectedErr    error
+	}{
+		"all Nodes": {
+			inputTmpl: `value: '{{ range getNodes }}{{ .name }}={{ .conditions.Ready }}/{{ .capacity.cpu }},` +
+				`{{ end }}'`,
+			expectedResult: `{"value":"control-plane-1=True/4,worker-1=True/4,worker-2=False/4,"}`,
+		},
+		"label selector": {
+			inputTmpl:      `value: '{{ range getNodes "node-role.kubernetes.io/worker" }}{{ .name }},{{ end }}'`,
+			expectedResult: `{"value":"worker-1,worker-2,"}`,
+		},
+		"labels": {
+			inputTmpl: `value: '{{ range getNodes "node-role.kubernetes.io/master" }}` +
+				`{{ hasKey .labels "node-role.kubernetes.io/master" }}{{ end }}'`,
+			options:        ResolveOptions{EnabledFunctionGroups: []string{"dicts"}},
+			expectedResult: `{"value":"true"}`,
+		},
+		"restricted": {
+			inputTmpl:   `value: '{{ len getNodes }}'`,
+			options:     ResolveOptions{LookupNamespace: "app"},
+			expectedErr: ErrClusterScopedLookupRestricted,
+		},
+		"allowed": {
+			inputTmpl: `value: '{{ len getNodes }}'`,
+			options: ResolveOptions{
+				LookupNamespace:        "app",
+				ClusterScopedAllowList: []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "*"}},
+				ClusterScopedDenyList:  []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "control-*"}},
+			},
+			expectedResult: `{"value":"2"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &test.options)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected an error matching %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestClusterClaims(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{}
+
+	for name, value := range map[string]string{"id.k8s.io": "abc123", "platform.open-cluster-management.io": "AWS"} {
+		objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": clusterClaimAPIVersion,
+			"kind":       "ClusterClaim",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"value": value},
+		}})
+	}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	noClaimsResolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := map[string]struct {
+		resolver       *TemplateResolver
+		inputTmpl      string
+		expectedResult string
+	}{
+		"claim with default": {
+			resolver:       resolver,
+			inputTmpl:      `value: '{{ fromClusterClaimOrDefault "platform.open-cluster-management.io" "Other" }}'`,
+			expectedResult: `{"value":"AWS"}`,
+		},
+		"missing claim with default": {
+			resolver:       resolver,
+			inputTmpl:      `value: '{{ fromClusterClaimOrDefault "region.open-cluster-management.io" "none" }}'`,
+			expectedResult: `{"value":"none"}`,
+		},
+		"missing API resource with default": {
+			resolver:       noClaimsResolver,
+			inputTmpl:      `value: '{{ fromClusterClaimOrDefault "id.k8s.io" "none" }}'`,
+			expectedResult: `{"value":"none"}`,
+		},
+		"all claims": {
+			resolver: resolver,
+			inputTmpl: `value: '{{ range $name, $value := clusterClaims }}{{ $name }}={{ $value }},{{ end }}-` +
+				`{{ index clusterClaims "id.k8s.io" }}'`,
+			expectedResult: `{"value":"id.k8s.io=abc123,platform.open-cluster-management.io=AWS,-abc123"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := test.resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"text/template"
+)
+
+var (
+	ErrCustomFunctionCollision = errors.New("the custom template function has the same name as a built-in function")
+	// builtinTemplateFunctions are the functions predefined by the text/template package.
+	builtinTemplateFunctions = []string{
+		"and", "call", "eq", "ge", "gt", "html", "index", "js", "le", "len", "lt", "ne", "not", "or", "print", "printf",
+		"println", "slice", "urlquery",
+	}
+	funcNameRegex = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// addCustomFunctions adds the input custom functions to the function map. An error wrapping
+// ErrCustomFunctionCollision is returned if a custom function has the same name as a function in the function map or
+// a text/template built-in function. An error wrapping ErrInvalidInput is returned if a custom function is not valid
+// for text/template.
+func addCustomFunctions(funcMap template.FuncMap, customFunctions map[string]interface{}) error {
+	for name, fn := range customFunctions {
+		if _, ok := funcMap[name]; ok {
+			return fmt.Errorf("%w: %s", ErrCustomFunctionCollision, name)
+		}
+
+		for _, builtin := range builtinTemplateFunctions {
+			if name == builtin {
+				return fmt.Errorf("%w: %s", ErrCustomFunctionCollision, name)
+			}
+		}
+
+		if err := validateCustomFunction(name, fn); err != nil {
+			return err
+		}
+	}
+
+	for name, fn := range customFunctions {
+		funcMap[name] = fn
+	}
+
+	return nil
+}
+
+// validateCustomFunction verifies that the input function satisfies the requirements of text/template, which would
+// otherwise cause a panic.
+func validateCustomFunction(name string, fn interface{}) error {
+	if !funcNameRegex.MatchString(name) {
+		return fmt.Errorf("%w: the custom template function name %q is not a valid identifier", ErrInvalidInput, name)
+	}
+
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return fmt.Errorf("%w: the custom template function %s is not a function", ErrInvalidInput, name)
+	}
+
+	switch {
+	case fnType.NumOut() == 1:
+		return nil
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+		return nil
+	default:
+		return fmt.Errorf(
+			"%w: the custom template function %s must return a single value or a value and an error",
+			ErrInvalidInput,
+			name,
+		)
+	}
+}
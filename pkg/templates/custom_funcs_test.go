@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveTemplateCustomFunctions(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"custom function": {
+			inputTmpl: `value: '{{ orgDefaults "region" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{
+					"orgDefaults": func(key string) string { return "default-" + key },
+				},
+			},
+			expectedResult: "value: default-region",
+		},
+		"custom function with error": {
+			inputTmpl: `value: '{{ "a" | shout }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{
+					"shout": func(s string) (string, error) { return strings.ToUpper(s), nil },
+				},
+			},
+			expectedResult: "value: A",
+		},
+		"collision with a custom function": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"lookup": func() string { return "" }},
+			},
+			expectedErr: ErrCustomFunctionCollision,
+		},
+		"collision with a sprig function": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"upper": func() string { return "" }},
+			},
+			expectedErr: ErrCustomFunctionCollision,
+		},
+		"collision with a text/template function": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"len": func() string { return "" }},
+			},
+			expectedErr: ErrCustomFunctionCollision,
+		},
+		"not a function": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"notAFunc": "value"},
+			},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid return values": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"twoValues": func() (string, string) { return "", "" }},
+			},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid name": {
+			inputTmpl: `value: '{{ "a" }}'`,
+			resolveOptions: ResolveOptions{
+				CustomFunctions: map[string]interface{}{"my-func": func() string { return "" }},
+			},
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrDeniedFunction = errors.New("the template function is denied")
+	// undefinedFunctionRegex captures the function name from the text/template parsing error of an undefined function.
+	undefinedFunctionRegex = regexp.MustCompile(`function "([^"]+)" not defined`)
+)
+
+// removeDeniedFunctions removes the input denied functions from the function map.
+func removeDeniedFunctions(funcMap template.FuncMap, deniedFunctions []string) {
+	for _, funcName := range deniedFunctions {
+		delete(funcMap, funcName)
+	}
+}
+
+// deniedFunctionError wraps the input template parsing error with ErrDeniedFunction if it's caused by the use of a
+// function in the input denied functions. Otherwise, the error is returned as is.
+func deniedFunctionError(err error, deniedFunctions []string) error {
+	if len(deniedFunctions) == 0 {
+		return err
+	}
+
+	match := undefinedFunctionRegex.FindStringSubmatch(err.Error())
+	if match == nil || !slices.Contains(deniedFunctions, match[1]) {
+		return err
+	}
+
+	return fmt.Errorf("%w by options.DeniedFunctions: %s: %w", ErrDeniedFunction, match[1], err)
+}
@@ -0,0 +1,91 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveTemplateDeniedFunctions(t *testing.T) {
+	t.Parallel()
+
+	ctx := struct{ ClusterName string }{ClusterName: "cluster1"}
+
+	testcases := map[string]resolveTestCase{
+		"denied function used": {
+			inputTmpl:      `value: '{{ fromSecret "namespace" "name" "key" }}'`,
+			resolveOptions: ResolveOptions{DeniedFunctions: []string{"lookup", "fromSecret"}},
+			expectedErr:    ErrDeniedFunction,
+		},
+		"denied function not used": {
+			inputTmpl:      `value: '{{ .ClusterName | upper }}'`,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{DeniedFunctions: []string{"lookup", "fromSecret"}},
+			expectedResult: "value: CLUSTER1",
+		},
+		"denied function in a branch that is not executed": {
+			inputTmpl:      `value: '{{ if false }}{{ lookup "v1" "Secret" "namespace" "name" }}{{ end }}'`,
+			resolveOptions: ResolveOptions{DeniedFunctions: []string{"lookup"}},
+			expectedErr:    ErrDeniedFunction,
+		},
+		"denied function in dependency order": {
+			inputTmpl: `value: '{{ lookup "v1" "Secret" "namespace" "name" }}'`,
+			resolveOptions: ResolveOptions{
+				DeniedFunctions: []string{"lookup"}, ResolveInDependencyOrder: true,
+			},
+			expectedErr: ErrDeniedFunction,
+		},
+		"denied function used in a nested template": {
+			inputTmpl: `value: '{{ printf "%s lookup \"v1\" \"Secret\" \"namespace\" \"name\" %s" "{{" "}}" }}'`,
+			resolveOptions: ResolveOptions{
+				DeniedFunctions: []string{"lookup"}, MaxPasses: 2,
+			},
+			expectedErr: ErrDeniedFunction,
+		},
+		"undefined function that is not denied": {
+			inputTmpl:      `value: '{{ notAFunction }}'`,
+			resolveOptions: ResolveOptions{DeniedFunctions: []string{"lookup"}},
+			expectedErr: errors.New(
+				`failed to parse the template JSON string {"value":"{{ notAFunction }}"}: template: tmpl:1: ` +
+					`function "notAFunction" not defined`,
+			),
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestValidateDeniedFunctions(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	issues := resolver.Validate(
+		[]byte("first: value\nsecond: '{{ lookup \"v1\" \"Secret\" \"namespace\" \"name\" }}'"),
+		ResolveOptions{DeniedFunctions: []string{"lookup"}},
+	)
+
+	if len(issues) != 1 {
+		t.Fatalf("Expected one issue but got: %v", issues)
+	}
+
+	if !errors.Is(issues[0].Err, ErrDeniedFunction) || !strings.Contains(issues[0].Err.Error(), "lookup") {
+		t.Fatalf("Expected an ErrDeniedFunction issue for lookup but got: %v", issues[0].Err)
+	}
+
+	if issues[0].Line != 2 {
+		t.Fatalf("Expected the issue on line 2 but got line %d", issues[0].Line)
+	}
+}
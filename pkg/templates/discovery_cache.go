@@ -0,0 +1,147 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+const defaultDiscoveryCacheTTL = 10 * time.Minute
+
+// discoveryCache caches the GVK to GVR conversions of the API discovery across ResolveTemplate calls, since the API
+// discovery cache of the temporary call cache is cleared after each call. Only successful conversions are cached, so a
+// freshly installed API resource (e.g. a new CRD) is found by the next lookup of it.
+type discoveryCache struct {
+	lock            sync.RWMutex
+	discoveryClient discovery.DiscoveryInterface
+	// ttl is how long an entry is valid for. Nothing is cached if this is negative.
+	ttl     time.Duration
+	now     func() time.Time
+	log     logr.Logger
+	entries map[schema.GroupVersionKind]discoveryCacheEntry
+	// version is the cached GitVersion of the API server, which is empty if it's not cached.
+	version        string
+	versionExpires time.Time
+}
+
+type discoveryCacheEntry struct {
+	scopedGVR client.ScopedGVR
+	expires   time.Time
+}
+
+func newDiscoveryCache(
+	discoveryClient discovery.DiscoveryInterface, ttl time.Duration, now func() time.Time, log logr.Logger,
+) *discoveryCache {
+	if ttl == 0 {
+		ttl = defaultDiscoveryCacheTTL
+	}
+
+	return &discoveryCache{
+		discoveryClient: discoveryClient,
+		ttl:             ttl,
+		now:             now,
+		log:             log,
+		entries:         map[schema.GroupVersionKind]discoveryCacheEntry{},
+	}
+}
+
+// get returns the cached conversion of the GVK and whether it was cached and not expired.
+func (d *discoveryCache) get(gvk schema.GroupVersionKind) (client.ScopedGVR, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	entry, ok := d.entries[gvk]
+	if !ok || d.now().After(entry.expires) {
+		return client.ScopedGVR{}, false
+	}
+
+	return entry.scopedGVR, true
+}
+
+func (d *discoveryCache) set(gvk schema.GroupVersionKind, scopedGVR client.ScopedGVR) {
+	if d.ttl < 0 {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.entries[gvk] = discoveryCacheEntry{scopedGVR: scopedGVR, expires: d.now().Add(d.ttl)}
+}
+
+// invalidate removes the cached conversion of the GVK, such as when the API resource is no longer served.
+func (d *discoveryCache) invalidate(gvk schema.GroupVersionKind) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.entries, gvk)
+}
+
+// discover converts the GVK to a GVR with an API discovery query, bypassing all caches. This is used to retry a
+// conversion that failed with client.ErrNoVersionedResource, since the failure may be cached by the API discovery cache
+// of the current caching mode. client.ErrNoVersionedResource is returned if the API resource is still not installed.
+func (d *discoveryCache) discover(gvk schema.GroupVersionKind) (client.ScopedGVR, error) {
+	groupVersion := gvk.GroupVersion().String()
+
+	resources, err := d.discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return client.ScopedGVR{}, client.ErrNoVersionedResource
+		}
+
+		return client.ScopedGVR{}, err
+	}
+
+	for _, apiRes := range resources.APIResources {
+		// Skip subresources such as deployments/scale, which can have the kind of another resource
+		if apiRes.Kind != gvk.Kind || strings.Contains(apiRes.Name, "/") {
+			continue
+		}
+
+		d.log.V(2).Info("Found the API resource after retrying the discovery", "apiResource", apiRes)
+
+		return client.ScopedGVR{
+			GroupVersionResource: gvk.GroupVersion().WithResource(apiRes.Name),
+			Namespaced:           apiRes.Namespaced,
+		}, nil
+	}
+
+	return client.ScopedGVR{}, client.ErrNoVersionedResource
+}
+
+// serverVersion returns the GitVersion of the API server (e.g. v1.28.3), querying the /version endpoint if the cached
+// version is expired.
+func (d *discoveryCache) serverVersion() (string, error) {
+	d.lock.RLock()
+	version, expires := d.version, d.versionExpires
+	d.lock.RUnlock()
+
+	if version != "" && !d.now().After(expires) {
+		return version, nil
+	}
+
+	info, err := d.discoveryClient.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+
+	if d.ttl < 0 {
+		return info.GitVersion, nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.version = info.GitVersion
+	d.versionExpires = d.now().Add(d.ttl)
+
+	return info.GitVersion, nil
+}
@@ -0,0 +1,194 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// discoveryCountingTransport counts the API discovery requests of a resource list sent to the fake API server.
+type discoveryCountingTransport struct {
+	server    *fakeAPIServer
+	discovery atomic.Int32
+	version   atomic.Int32
+}
+
+func (d *discoveryCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.Trim(req.URL.Path, "/")
+	if path == "api/v1" || (strings.HasPrefix(path, "apis/") && strings.Count(path, "/") == 2) {
+		d.discovery.Add(1)
+	} else if path == "version" {
+		d.version.Add(1)
+	}
+
+	return d.server.RoundTrip(req)
+}
+
+func newDiscoveryCountingResolver(
+	t *testing.T, objects []unstructured.Unstructured, config Config,
+) (*TemplateResolver, *discoveryCountingTransport) {
+	t.Helper()
+
+	server, err := newFakeAPIServer(objects)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	transport := &discoveryCountingTransport{server: server}
+
+	resolver, err := NewResolver(&rest.Config{Host: fakeAPIServerHost, Transport: transport}, config)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	return resolver, transport
+}
+
+func TestDiscoveryCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	resolver, transport := newDiscoveryCountingResolver(
+		t, nil, Config{InputIsYAML: true, Clock: clock, DiscoveryCacheTTL: time.Minute},
+	)
+
+	tmpl := []byte(`value: '{{ empty (lookup "v1" "ConfigMap" "default" "not-found") }}'`)
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.ResolveTemplate(tmpl, nil, nil); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	if count := transport.discovery.Load(); count != 1 {
+		t.Fatalf("Expected 1 API discovery request across the calls but got %d", count)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, err := resolver.ResolveTemplate(tmpl, nil, nil); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if count := transport.discovery.Load(); count != 2 {
+		t.Fatalf("Expected the expired entry to cause a second API discovery request but got %d", count)
+	}
+}
+
+func TestDiscoveryCacheDisabled(t *testing.T) {
+	t.Parallel()
+
+	resolver, transport := newDiscoveryCountingResolver(t, nil, Config{InputIsYAML: true, DiscoveryCacheTTL: -1})
+
+	tmpl := []byte(`value: '{{ empty (lookup "v1" "ConfigMap" "default" "not-found") }}'`)
+
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.ResolveTemplate(tmpl, nil, nil); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	if count := transport.discovery.Load(); count != 2 {
+		t.Fatalf("Expected an API discovery request per call but got %d", count)
+	}
+}
+
+func TestDiscoveryCacheNewAPIResource(t *testing.T) {
+	t.Parallel()
+
+	resolver, transport := newDiscoveryCountingResolver(t, nil, Config{InputIsYAML: true})
+
+	tmpl := []byte(`value: '{{ empty (lookup "example.com/v1" "Widget" "default" "widget") }}'`)
+
+	_, err := resolver.ResolveTemplate(tmpl, nil, nil)
+	if !errors.Is(err, ErrMissingAPIResource) {
+		t.Fatalf("Expected ErrMissingAPIResource but got: %v", err)
+	}
+
+	// The API discovery is retried once without caching before failing
+	if count := transport.discovery.Load(); count != 2 {
+		t.Fatalf("Expected 2 API discovery requests but got %d", count)
+	}
+
+	// Install the API resource like a new CRD
+	transport.server.addResource(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, true)
+
+	result, err := resolver.ResolveTemplate(tmpl, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if string(result.ResolvedJSON) != `{"value":"true"}` {
+		t.Fatalf("Unexpected result: %s", result.ResolvedJSON)
+	}
+}
+
+func TestDiscoveryCacheServerVersion(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	resolver, transport := newDiscoveryCountingResolver(
+		t, nil, Config{InputIsYAML: true, Clock: clock, DiscoveryCacheTTL: time.Minute},
+	)
+
+	tmpl := []byte(`value: '{{ kubeVersion }}'`)
+
+	for i := 0; i < 2; i++ {
+		result, err := resolver.ResolveTemplate(tmpl, nil, nil)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if string(result.ResolvedJSON) != `{"value":"v1.28.3"}` {
+			t.Fatalf("Unexpected result: %s", result.ResolvedJSON)
+		}
+	}
+
+	if count := transport.version.Load(); count != 1 {
+		t.Fatalf("Expected 1 version request across the calls but got %d", count)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, err := resolver.ResolveTemplate(tmpl, nil, nil); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if count := transport.version.Load(); count != 2 {
+		t.Fatalf("Expected the expired version to cause a second version request but got %d", count)
+	}
+}
+
+func TestDiscoveryCacheDiscover(t *testing.T) {
+	t.Parallel()
+
+	resolver, _ := newDiscoveryCountingResolver(t, nil, Config{})
+
+	scopedGVR, err := resolver.discoveryCache.discover(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if scopedGVR.Resource != "namespaces" || scopedGVR.Namespaced {
+		t.Fatalf("Unexpected GVR: %v", scopedGVR)
+	}
+
+	_, err = resolver.discoveryCache.discover(schema.GroupVersionKind{Version: "v1", Kind: "Widget"})
+	if !errors.Is(err, client.ErrNoVersionedResource) {
+		t.Fatalf("Expected the missing API resource error but got: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// DryRunFieldManager is the field manager used for the server-side apply requests of ValidateWithDryRun.
+const DryRunFieldManager = "go-template-utils"
+
+var ErrDryRunFailed = errors.New("the object failed the server-side dry-run validation")
+
+// ValidateWithDryRun submits the input object, such as the ResolvedJSON of a TemplateResult, to the Kubernetes API
+// server as a server-side apply with dryRun=All. This validates the object against the API resource's schema and the
+// admission webhooks without persisting it. Note that this requires permission to patch the object.
+//
+// An error wrapping ErrDryRunFailed and the Kubernetes API error is returned if the object is rejected. The Kubernetes
+// API error can be used to get the individual causes of the validation failure. An error wrapping ErrInvalidInput is
+// returned when caching is enabled since the resolver has no client for direct Kubernetes API requests.
+func (t *TemplateResolver) ValidateWithDryRun(ctx context.Context, objJSON []byte) error {
+	if t.dynamicWatcher != nil {
+		return fmt.Errorf("%w: ValidateWithDryRun is not supported when caching is enabled", ErrInvalidInput)
+	}
+
+	return t.validateWithDryRun(ctx, t.dynamicClient, objJSON)
+}
+
+// validateWithDryRun is ValidateWithDryRun with the input client, such as one impersonating ResolveOptions.Impersonate.
+func (t *TemplateResolver) validateWithDryRun(
+	ctx context.Context, dynamicClient dynamic.Interface, objJSON []byte,
+) error {
+	obj := unstructured.Unstructured{}
+
+	err := obj.UnmarshalJSON(objJSON)
+	if err != nil {
+		return fmt.Errorf("%w: the input must be a Kubernetes object: %w", ErrInvalidInput, err)
+	}
+
+	if obj.GetName() == "" {
+		return fmt.Errorf("%w: the object must have a name", ErrInvalidInput)
+	}
+
+	gvk := obj.GroupVersionKind()
+
+	t.contextLogger(ctx).V(2).Info(
+		"Validating the object with a dry-run", "gvk", gvk, "namespace", obj.GetNamespace(), "name", obj.GetName(),
+	)
+
+	scopedGVRObj, err := t.getScopedGVR(gvk)
+	if err != nil {
+		return err
+	}
+
+	var dynamicClientRes dynamic.ResourceInterface
+
+	if scopedGVRObj.Namespaced {
+		if obj.GetNamespace() == "" {
+			return fmt.Errorf("%w: the namespaced object must have a namespace", ErrInvalidInput)
+		}
+
+		dynamicClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(obj.GetNamespace())
+	} else {
+		dynamicClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+	}
+
+	_, err = dynamicClientRes.Apply(
+		ctx,
+		obj.GetName(),
+		&obj,
+		metav1.ApplyOptions{DryRun: []string{metav1.DryRunAll}, FieldManager: DryRunFieldManager, Force: true},
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: %s %s: %w", ErrDryRunFailed, gvk.Kind, obj.GetName(), wrapAPIUnavailableError(gvk, err),
+		)
+	}
+
+	return nil
+}
+
+// validateResolvedObjects validates the resolved template, which is either a Kubernetes object or a list of them, with
+// ValidateWithDryRun, impersonating ResolveOptions.Impersonate if it's set. The errors of the objects that failed the
+// validation are returned.
+func (t *TemplateResolver) validateResolvedObjects(
+	ctx context.Context, options *ResolveOptions, resolvedJSON []byte,
+) []error {
+	dynamicClient, _, err := t.clientsFor(options)
+	if err != nil {
+		return []error{err}
+	}
+
+	var resolved interface{}
+
+	err = json.Unmarshal(resolvedJSON, &resolved)
+	if err != nil {
+		return []error{fmt.Errorf("%w: the resolved template is not valid JSON: %w", ErrInvalidInput, err)}
+	}
+
+	resolvedList, isList := resolved.([]interface{})
+	if !isList {
+		err := t.validateResolvedObject(ctx, dynamicClient, resolved)
+		if err != nil {
+			return []error{err}
+		}
+
+		return nil
+	}
+
+	var validationErrs []error
+
+	for i, item := range resolvedList {
+		err := t.validateResolvedObject(ctx, dynamicClient, item)
+		if err != nil {
+			validationErrs = append(validationErrs, fmt.Errorf("index %d: %w", i, err))
+		}
+	}
+
+	return validationErrs
+}
+
+func (t *TemplateResolver) validateResolvedObject(
+	ctx context.Context, dynamicClient dynamic.Interface, resolved interface{},
+) error {
+	if _, ok := resolved.(map[string]interface{}); !ok {
+		return fmt.Errorf("%w: the resolved template must be a Kubernetes object", ErrInvalidInput)
+	}
+
+	objJSON, err := json.Marshal(resolved)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	return t.validateWithDryRun(ctx, dynamicClient, objJSON)
+}
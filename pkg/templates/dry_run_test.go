@@ -0,0 +1,111 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+)
+
+func TestResolveTemplateValidateAgainstCluster(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		inputTmpl      string
+		expectedErrors int
+	}{
+		"not a Kubernetes object": {
+			inputTmpl:      `data: '{{ "value" }}'`,
+			expectedErrors: 1,
+		},
+		"list of scalars": {
+			inputTmpl:      "- '{{ \"a\" }}'\n- b",
+			expectedErrors: 2,
+		},
+		"scalar": {
+			inputTmpl:      `'{{ "a" }}'`,
+			expectedErrors: 1,
+		},
+		"empty list": {
+			inputTmpl:      `[]`,
+			expectedErrors: 0,
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{ValidateAgainstCluster: true},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if len(result.ValidationErrors) != test.expectedErrors {
+				t.Fatalf("Expected %d validation errors but got: %v", test.expectedErrors, result.ValidationErrors)
+			}
+
+			for _, validationErr := range result.ValidationErrors {
+				if !errors.Is(validationErr, ErrInvalidInput) {
+					t.Fatalf("Expected the validation error to wrap ErrInvalidInput but got: %v", validationErr)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveTemplateValidateAgainstClusterCaching(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Simulate NewResolverWithCaching, which has no client for direct Kubernetes API requests
+	resolver.dynamicWatcher = &changingWatcher{}
+	resolver.dynamicClient = nil
+
+	options := &ResolveOptions{
+		ValidateAgainstCluster: true,
+		Watcher: &client.ObjectIdentifier{
+			Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "policy",
+		},
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`data: '{{ "value" }}'`), nil, options)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
+
+func TestValidateWithDryRunCaching(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver.dynamicWatcher = &changingWatcher{}
+	resolver.dynamicClient = nil
+
+	err = resolver.ValidateWithDryRun(
+		context.TODO(), []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"app","namespace":"app"}}`),
+	)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
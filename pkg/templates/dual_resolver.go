@@ -0,0 +1,179 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// DualResolver resolves the hub templates of a template, such as `{{hub .ManagedClusterName hub}}`, with a hub cluster
+// resolver and then the other templates with a managed cluster resolver, like the policy framework does for the
+// policies on a managed cluster. It's better to use the NewDualResolver function instead of instantiating this
+// directly so that the resolvers are validated.
+type DualResolver struct {
+	hub     *TemplateResolver
+	managed *TemplateResolver
+}
+
+// DualResolveOptions is a struct containing the inputs of a DualResolver.ResolveTemplate call other than the template.
+//
+// - HubContext is the template context of the hub templates, such as a struct with the ManagedClusterName field. See
+// ResolveTemplate.
+//
+// - HubOptions are the options of the hub template resolution, which may be nil. See ResolveTemplate.
+//
+// - ManagedContext is the template context of the managed cluster templates. See ResolveTemplate.
+//
+// - ManagedOptions are the options of the managed cluster template resolution, which may be nil. See ResolveTemplate.
+type DualResolveOptions struct {
+	HubContext     interface{}
+	HubOptions     *ResolveOptions
+	ManagedContext interface{}
+	ManagedOptions *ResolveOptions
+}
+
+// DualTemplateResult is the result of a DualResolver.ResolveTemplate call. The embedded TemplateResult merges the
+// results of the hub and managed cluster resolutions: ResolvedJSON and ResolveID are those of the managed cluster
+// resolution, the boolean fields are set if they are set in either result, the list fields have the entries of the hub
+// result followed by those of the managed cluster result, except for SensitiveObjects which are merged per object, the
+// LookupStats are added, and CacheCleanUp cleans up both caches.
+//
+// - Hub is the result of the hub template resolution, which is empty if the template has no hub templates.
+//
+// - Managed is the result of the managed cluster template resolution. Use the ReferencedObjects and MissingObjects of
+// Hub and Managed to know which cluster the objects are on.
+type DualTemplateResult struct {
+	TemplateResult
+	Hub     TemplateResult
+	Managed TemplateResult
+}
+
+// NewDualResolver creates a new DualResolver instance from a hub cluster resolver and a managed cluster resolver. An
+// error wrapping ErrInvalidInput is returned if the resolvers have the same start delimiter, since the hub templates
+// must be distinguishable from the managed cluster templates, such as with the "{{hub" and "hub}}" delimiters.
+func NewDualResolver(hub *TemplateResolver, managed *TemplateResolver) (*DualResolver, error) {
+	if hub == nil || managed == nil {
+		return nil, fmt.Errorf("%w: the hub and managed cluster resolvers are required", ErrInvalidInput)
+	}
+
+	if hub.config.StartDelim == managed.config.StartDelim {
+		return nil, fmt.Errorf(
+			"%w: the hub and managed cluster resolvers must have different start delimiters but both have %s",
+			ErrInvalidInput, hub.config.StartDelim,
+		)
+	}
+
+	return &DualResolver{hub: hub, managed: managed}, nil
+}
+
+// ResolveTemplate resolves the hub templates of the input template with the hub cluster resolver and then the other
+// templates of the result with the managed cluster resolver. The hub template resolution is skipped if the template has
+// no hub templates. Both resolvers must have the same InputIsYAML configuration, which is the format of the input
+// template. See TemplateResolver.ResolveTemplate.
+func (d *DualResolver) ResolveTemplate(tmplRaw []byte, options *DualResolveOptions) (DualTemplateResult, error) {
+	return d.ResolveTemplateWithContext(context.Background(), tmplRaw, options)
+}
+
+// ResolveTemplateWithContext is the same as ResolveTemplate except that the input resolveCtx is used for the
+// Kubernetes API queries of the template functions of both resolutions. See
+// TemplateResolver.ResolveTemplateWithContext.
+func (d *DualResolver) ResolveTemplateWithContext(
+	resolveCtx context.Context, tmplRaw []byte, options *DualResolveOptions,
+) (DualTemplateResult, error) {
+	if options == nil {
+		options = &DualResolveOptions{}
+	}
+
+	result := DualTemplateResult{}
+
+	if d.hub.config.InputIsYAML != d.managed.config.InputIsYAML {
+		return result, fmt.Errorf(
+			"%w: the hub and managed cluster resolvers must have the same InputIsYAML configuration", ErrInvalidInput,
+		)
+	}
+
+	hubStartDelim, _ := d.hub.delims(options.HubOptions)
+	if managedStartDelim, _ := d.managed.delims(options.ManagedOptions); hubStartDelim == managedStartDelim {
+		return result, fmt.Errorf(
+			"%w: the hub and managed cluster resolutions must have different start delimiters but both have %s",
+			ErrInvalidInput, hubStartDelim,
+		)
+	}
+
+	managedTmpl := tmplRaw
+
+	if HasTemplate(tmplRaw, hubStartDelim, false) {
+		var err error
+
+		result.Hub, err = d.hub.ResolveTemplateWithContext(resolveCtx, tmplRaw, options.HubContext, options.HubOptions)
+		if err != nil {
+			result.TemplateResult = mergeTemplateResults(result.Hub, TemplateResult{})
+
+			return result, fmt.Errorf("failed to resolve the hub templates: %w", err)
+		}
+
+		managedTmpl = result.Hub.ResolvedJSON
+
+		// The managed cluster resolver expects the same input format as the hub cluster resolver
+		if d.managed.config.InputIsYAML {
+			managedTmpl, err = JSONToYAML(managedTmpl)
+			if err != nil {
+				result.TemplateResult = mergeTemplateResults(result.Hub, TemplateResult{})
+
+				return result, fmt.Errorf("failed to convert the resolved hub templates to YAML: %w", err)
+			}
+		}
+	}
+
+	var err error
+
+	result.Managed, err = d.managed.ResolveTemplateWithContext(
+		resolveCtx, managedTmpl, options.ManagedContext, options.ManagedOptions,
+	)
+	result.TemplateResult = mergeTemplateResults(result.Hub, result.Managed)
+
+	return result, err
+}
+
+// mergeTemplateResults merges the results of the hub and managed cluster resolutions as described in
+// DualTemplateResult.
+func mergeTemplateResults(hub TemplateResult, managed TemplateResult) TemplateResult {
+	merged := managed
+
+	merged.HasSensitiveData = hub.HasSensitiveData || managed.HasSensitiveData
+	merged.DecryptedWithPreviousKey = hub.DecryptedWithPreviousKey || managed.DecryptedWithPreviousKey
+
+	// The values of the hub templates end up in the same objects of the managed cluster resolution
+	merged.SensitiveObjects = slices.Clone(managed.SensitiveObjects)
+	for i := range merged.SensitiveObjects {
+		if i < len(hub.SensitiveObjects) && hub.SensitiveObjects[i] {
+			merged.SensitiveObjects[i] = true
+		}
+	}
+
+	merged.ReferencedObjects = append(slices.Clone(hub.ReferencedObjects), managed.ReferencedObjects...)
+	merged.MissingObjects = append(slices.Clone(hub.MissingObjects), managed.MissingObjects...)
+	merged.ValidationErrors = append(slices.Clone(hub.ValidationErrors), managed.ValidationErrors...)
+	merged.Warnings = append(slices.Clone(hub.Warnings), managed.Warnings...)
+
+	merged.LookupStats = LookupStats{
+		Lookups:      hub.LookupStats.Lookups + managed.LookupStats.Lookups,
+		CacheHits:    hub.LookupStats.CacheHits + managed.LookupStats.CacheHits,
+		APICalls:     hub.LookupStats.APICalls + managed.LookupStats.APICalls,
+		BytesFetched: hub.LookupStats.BytesFetched + managed.LookupStats.BytesFetched,
+	}
+
+	if hub.CacheCleanUp != nil && managed.CacheCleanUp != nil {
+		merged.CacheCleanUp = func() error {
+			return errors.Join(hub.CacheCleanUp(), managed.CacheCleanUp())
+		}
+	} else if hub.CacheCleanUp != nil {
+		merged.CacheCleanUp = hub.CacheCleanUp
+	}
+
+	return merged
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDualResolverResolveTemplate(t *testing.T) {
+	t.Parallel()
+
+	hubResolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "hub-config", "namespace": "policies"},
+			"data":       map[string]interface{}{"configName": "app-config"},
+		}}},
+		Config{InputIsYAML: true, StartDelim: "{{hub", StopDelim: "hub}}"},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	managedResolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "app-config", "namespace": "app"},
+			"data":       map[string]interface{}{"replicas": "3"},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver, err := NewDualResolver(hubResolver, managedResolver)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	hubCtx := struct{ ManagedClusterName string }{"cluster1"}
+
+	tests := map[string]struct {
+		inputTmpl       string
+		expectedResult  string
+		expectedErr     string
+		expectedLookups uint
+		expectedHub     bool
+	}{
+		"hub and managed cluster templates": {
+			inputTmpl: `cluster: '{{hub .ManagedClusterName hub}}'
+replicas: '{{ fromConfigMap "app" "{{hub fromConfigMap "policies" "hub-config" "configName" hub}}" "replicas" }}'`,
+			expectedResult:  `{"cluster":"cluster1","replicas":"3"}`,
+			expectedLookups: 2,
+			expectedHub:     true,
+		},
+		"only managed cluster templates": {
+			inputTmpl:       `replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'`,
+			expectedResult:  `{"replicas":"3"}`,
+			expectedLookups: 1,
+		},
+		"hub template failure": {
+			inputTmpl:   `cluster: '{{hub .NotAField hub}}'`,
+			expectedErr: "failed to resolve the hub templates",
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), &DualResolveOptions{HubContext: hubCtx},
+			)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("Expected the error %q but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+
+			if result.LookupStats.Lookups != test.expectedLookups {
+				t.Fatalf("Expected %d lookups but got %d", test.expectedLookups, result.LookupStats.Lookups)
+			}
+
+			if (result.Hub.ResolvedJSON != nil) != test.expectedHub {
+				t.Fatalf("Expected the hub templates to be resolved: %v", test.expectedHub)
+			}
+
+			referencedCount := len(result.Hub.ReferencedObjects) + len(result.Managed.ReferencedObjects)
+			if len(result.ReferencedObjects) != referencedCount {
+				t.Fatalf("Expected the referenced objects of both clusters but got %v", result.ReferencedObjects)
+			}
+		})
+	}
+}
+
+func TestNewDualResolverInvalid(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = NewDualResolver(resolver, resolver)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for the same delimiters but got: %v", err)
+	}
+
+	_, err = NewDualResolver(nil, resolver)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for a missing resolver but got: %v", err)
+	}
+}
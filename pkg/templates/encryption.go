@@ -6,14 +6,10 @@ package templates
 import (
 	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
-
-	"k8s.io/klog"
 )
 
 func (t *TemplateResolver) protectHelper(options *ResolveOptions) func(string) (string, error) {
@@ -22,92 +18,92 @@ func (t *TemplateResolver) protectHelper(options *ResolveOptions) func(string) (
 	}
 }
 
-// protect encrypts the input value using AES-CBC. If a salt is set on t.config.Salt, it will prefix the plaintext
-// value before it is encrypted. The returned value is in the format of `$ocm_encrypted:<base64 of encrypted string>`.
-// An error is returned if the AES key is invalid.
+// protect encrypts the input value using the EncryptionProvider of the options, which defaults to the AES key of the
+// options. The returned value is in the format of `$ocm_encrypted:<encrypted value>`. An error wrapping
+// ErrEncryptionFailed is returned if the encryption fails, such as when the AES key is invalid.
 func (t *TemplateResolver) protect(options *ResolveOptions, value string) (string, error) {
 	if value == "" {
 		return value, nil
 	}
 
-	block, err := aes.NewCipher(options.AESKey)
-	if err != nil {
-		return "", fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
-	}
+	provider := options.encryptionProvider()
 
-	// This is already validated in the NewResolver method, but is checked again in case that method was bypassed
-	// to avoid a panic.
-	if len(options.InitializationVector) != IVSize {
-		return "", ErrInvalidIV
+	encryptedValue, err := provider.Encrypt(options.apiContext(), value)
+	if err != nil {
+		return "", classifyError(
+			ErrEncryptionFailed, fmt.Errorf("failed to encrypt with the key %s: %w", provider.KeyID(), err),
+		)
 	}
 
-	blockSize := block.BlockSize()
-	blockMode := cipher.NewCBCEncrypter(block, options.InitializationVector)
-
-	valueBytes := []byte(value)
-	valueBytes = pkcs7Pad(valueBytes, blockSize)
-
-	encryptedValue := make([]byte, len(valueBytes))
-	blockMode.CryptBlocks(encryptedValue, valueBytes)
+	return protectedPrefix + encryptedValue, nil
+}
 
-	return protectedPrefix + base64.StdEncoding.EncodeToString(encryptedValue), nil
+func (t *TemplateResolver) protectObjectHelper(options *ResolveOptions) func(interface{}) (string, error) {
+	return func(value interface{}) (string, error) {
+		return t.protectObject(options, value)
+	}
 }
 
-// decrypt will decrypt a string that was encrypted using the protect method. An error is returned if the base64 or
-// the AES key is invalid.
-func (t *TemplateResolver) decrypt(options *ResolveOptions, value string) (string, error) {
-	// This is already validated in the NewResolver method, but is checked again in case that method was bypassed
-	// to avoid a panic.
-	if len(options.InitializationVector) != IVSize {
-		return "", ErrInvalidIV
+// protectObject encrypts the JSON of the input map or list like protect, such as the whole data of a Secret, so that
+// neither the values nor the keys appear in plaintext. The returned value is in the format of
+// `$ocm_encrypted:obj:<encrypted value>`, which is replaced by the JSON of the input value when decrypted.
+func (t *TemplateResolver) protectObject(options *ResolveOptions, value interface{}) (string, error) {
+	switch value.(type) {
+	case map[string]interface{}, map[string]string, []interface{}, []string:
+	default:
+		return "", fmt.Errorf("%w: protectObject requires a map or a list but got %T", ErrInvalidInput, value)
 	}
 
-	decodedValue, err := base64.StdEncoding.DecodeString(value)
+	valueJSON, err := encodeJSON(value, "")
 	if err != nil {
-		return "", fmt.Errorf("%s: %w: %w", value, ErrInvalidB64OfEncrypted, err)
+		return "", err
 	}
 
-	var decryptionErr error
-	var decryptedValue []byte
-
-	var aesKeys [][]byte
-	if options.AESKeyFallback == nil {
-		aesKeys = [][]byte{options.AESKey}
-	} else {
-		aesKeys = [][]byte{options.AESKey, options.AESKeyFallback}
+	encryptedValue, err := t.protect(options, valueJSON)
+	if err != nil {
+		return "", err
 	}
 
-	for _, aesKey := range aesKeys {
-		block, err := aes.NewCipher(aesKey)
-		if err != nil {
-			decryptionErr = fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
+	return protectedPrefix + objectPrefix + strings.TrimPrefix(encryptedValue, protectedPrefix), nil
+}
 
-			continue
+// decrypt will decrypt a string that was encrypted using the protect method with the EncryptionProvider of the
+// options. It also returns whether the value was decrypted with a previous key, which is only reported by the AES
+// provider. An error is returned if the encrypted value or the key is invalid.
+func (t *TemplateResolver) decrypt(
+	ctx context.Context, options *ResolveOptions, value string,
+) (string, bool, error) {
+	if strings.HasPrefix(value, objectPrefix) {
+		decryptedValue, previousKey, err := t.decrypt(ctx, options, strings.TrimPrefix(value, objectPrefix))
+		if err != nil {
+			return "", false, err
 		}
 
-		blockMode := cipher.NewCBCDecrypter(block, options.InitializationVector)
-		decryptedValue = make([]byte, len(decodedValue))
-		blockMode.CryptBlocks(decryptedValue, decodedValue)
+		// The JSON is used as is in the YAML template as a flow mapping or sequence, so ensure it's not another value
+		if !json.Valid([]byte(decryptedValue)) {
+			return "", false, fmt.Errorf("%w: the decrypted object is not valid JSON", ErrInvalidInput)
+		}
 
-		// If the unpadding fails, it is either because the value was not generated by the "protect"
-		// template function or the value was encrypted with a different AES key.
-		decryptedValue, err = pkcs7Unpad(decryptedValue)
-		if err != nil {
-			decryptionErr = err
+		return decryptedValue, previousKey, nil
+	}
 
-			continue
-		}
+	provider := options.encryptionProvider()
 
-		decryptionErr = nil
+	var decryptedValue string
+	var previousKey bool
+	var err error
 
-		break
+	if rotatingProvider, ok := provider.(previousKeyDecrypter); ok {
+		decryptedValue, previousKey, err = rotatingProvider.decryptWithPreviousKey(value)
+	} else {
+		decryptedValue, err = provider.Decrypt(ctx, value)
 	}
 
-	if decryptionErr != nil {
-		return "", decryptionErr
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt with the key %s: %w", provider.KeyID(), err)
 	}
 
-	return string(decryptedValue), nil
+	return decryptedValue, previousKey, nil
 }
 
 // pkcs7Pad right-pads the given value to match the input block size for AES encryption. The padding
@@ -160,8 +156,9 @@ func pkcs7Unpad(paddedValue []byte) ([]byte, error) {
 // concurrently and the concurrency limit is controlled by t.config.DecryptionConcurrency. If a decryption fails,
 // the rest of the decryption is halted and an error is returned.
 func (t *TemplateResolver) processEncryptedStrs(options *ResolveOptions, templateStr string) (string, error) {
-	// This catching any encrypted string in the format of $ocm_encrypted:<base64 of the encrypted value>.
-	re := regexp.MustCompile(regexp.QuoteMeta(protectedPrefix) + "([a-zA-Z0-9+/=]+)")
+	// This catching any encrypted string in the format of $ocm_encrypted:<base64 of the encrypted value>, optionally
+	// with scheme prefixes before the base64 such as obj: for protectObject and gcm: for the AES-GCM algorithm.
+	re := regexp.MustCompile(regexp.QuoteMeta(protectedPrefix) + "((?:[a-z0-9]+:){0,2}[a-zA-Z0-9+/=]+)")
 	// Each submatch will have index 0 be the whole match and index 1 as the base64 of the encrypted value.
 	submatches := re.FindAllStringSubmatch(templateStr, -1)
 
@@ -183,10 +180,10 @@ func (t *TemplateResolver) processEncryptedStrs(options *ResolveOptions, templat
 	submatchesChan := make(chan []string, len(submatches))
 	resultsChan := make(chan decryptResult, len(submatches))
 
-	klog.V(2).Infof("Will decrypt %d value(s) with %d Goroutines", len(submatches), numWorkers)
+	t.logger(options).V(2).Info("Decrypting the encrypted values", "count", len(submatches), "goroutines", numWorkers)
 
 	// Create a context to be able to cancel decryption in case one fails.
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(options.apiContext())
 	defer cancel()
 
 	// Start up all the Goroutines.
@@ -209,14 +206,20 @@ func (t *TemplateResolver) processEncryptedStrs(options *ResolveOptions, templat
 			cancel()
 			close(submatchesChan)
 			close(resultsChan)
-			klog.Errorf("Decryption failed %v", result.err)
+			t.logger(options).Error(result.err, "Decryption failed")
 
-			return "", fmt.Errorf("decryption of %s failed: %w", result.match, result.err)
+			return "", fmt.Errorf(
+				"decryption of %s failed: %w", result.match, classifyError(ErrDecryptionFailed, result.err),
+			)
 		}
 
 		processed = strings.Replace(processed, result.match, result.plaintext, 1)
 		processedResults++
 
+		if result.previousKey {
+			options.state.decryptedWithPreviousKey = true
+		}
+
 		// Once the decryption is complete, it's safe to close the channels and stop blocking in this Goroutine.
 		if processedResults == len(submatches) {
 			close(submatchesChan)
@@ -224,7 +227,7 @@ func (t *TemplateResolver) processEncryptedStrs(options *ResolveOptions, templat
 		}
 	}
 
-	klog.V(2).Infof("Finished decrypting %d value(s)", len(submatches))
+	t.logger(options).V(2).Info("Finished decrypting the encrypted values", "count", len(submatches))
 
 	return processed, nil
 }
@@ -234,6 +237,8 @@ type decryptResult struct {
 	match     string
 	plaintext string
 	err       error
+	// previousKey is set when the value was decrypted with a previous key.
+	previousKey bool
 }
 
 // decryptWrapper wraps the decrypt method for concurrency. ctx is the context that will get canceled if one or more
@@ -249,14 +254,16 @@ func (t *TemplateResolver) decryptWrapper(
 		encryptedValue := submatch[1]
 		var result decryptResult
 
-		plaintext, err := t.decrypt(options, encryptedValue)
+		plaintext, previousKey, err := t.decrypt(ctx, options, encryptedValue)
 		if err != nil {
-			result = decryptResult{match, "", err}
+			result = decryptResult{match, "", err, false}
 		} else {
 			// Escape new lines so that they do not affect the structure of the YAML document. This also allows piping
 			// the decrypted value to template function.
-			plaintext = strings.ReplaceAll(plaintext, "\n", "\\n")
-			result = decryptResult{match, plaintext, nil}
+			escaped := strings.ReplaceAll(plaintext, "\n", "\\n")
+			options.state.taint.add(plaintext, escaped)
+			plaintext = escaped
+			result = decryptResult{match, plaintext, nil, previousKey}
 		}
 
 		select {
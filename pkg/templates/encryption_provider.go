@@ -0,0 +1,253 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// EncryptionProvider encrypts the values of the "protect" template function and decrypts them when decryption is
+// enabled. This allows the encryption key to be kept outside of the cluster, such as in an external key management
+// service (KMS). The default provider is the AES provider of the EncryptionConfig AES key (see
+// NewAESEncryptionProvider).
+//
+// - Encrypt returns the encrypted value of the plaintext value. The encrypted value must be base64 encoded and may have
+// a lowercase scheme prefix followed by a colon (e.g. kms:), since the encrypted values are found in the template with
+// these characters.
+//
+// - Decrypt returns the plaintext value of the encrypted value returned by Encrypt.
+//
+// - KeyID returns the identifier of the encryption key, which is used in error messages and must not be sensitive.
+type EncryptionProvider interface {
+	Encrypt(ctx context.Context, plaintext string) (string, error)
+	Decrypt(ctx context.Context, encrypted string) (string, error)
+	KeyID() string
+}
+
+// previousKeyDecrypter is implemented by the EncryptionProvider implementations that report whether a value was
+// decrypted with a previous key, so that TemplateResult.DecryptedWithPreviousKey can be set.
+type previousKeyDecrypter interface {
+	decryptWithPreviousKey(encrypted string) (string, bool, error)
+}
+
+// aesEncryptionProvider is the EncryptionProvider of the AES key, fallback AES key, initialization vector, and
+// algorithm of an EncryptionConfig.
+type aesEncryptionProvider struct {
+	config EncryptionConfig
+}
+
+// NewAESEncryptionProvider returns the EncryptionProvider that uses the AES key, fallback AES key, initialization
+// vector, and algorithm of the input EncryptionConfig, which is the default when EncryptionConfig.Provider isn't set.
+// This is useful to decrypt values encrypted with an AES key while migrating to another EncryptionProvider.
+func NewAESEncryptionProvider(config EncryptionConfig) (EncryptionProvider, error) {
+	config.Provider = nil
+	config.EncryptionEnabled = true
+
+	if err := validateEncryptionConfig(config, defaultLogger); err != nil {
+		return nil, err
+	}
+
+	return &aesEncryptionProvider{config: config}, nil
+}
+
+// encryptionProvider returns the configured EncryptionProvider or the AES provider of the configuration.
+func (e EncryptionConfig) encryptionProvider() EncryptionProvider {
+	if e.Provider != nil {
+		return e.Provider
+	}
+
+	return &aesEncryptionProvider{config: e}
+}
+
+// KeyID returns "aes-" followed by a fingerprint of the AES key, which is the start of the hex encoded SHA-256 hash of
+// the key.
+func (a *aesEncryptionProvider) KeyID() string {
+	hash := sha256.Sum256(a.config.AESKey)
+
+	return "aes-" + hex.EncodeToString(hash[:])[:12]
+}
+
+// Encrypt encrypts the input value using the configured EncryptionAlgorithm, which defaults to AES-CBC. The returned
+// value is the base64 of the encrypted value or, with AES-GCM, `gcm:<base64 of the nonce and encrypted value>`.
+func (a *aesEncryptionProvider) Encrypt(_ context.Context, value string) (string, error) {
+	block, err := aes.NewCipher(a.config.AESKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
+	}
+
+	// This is already validated in the NewResolver method, but is checked again in case that method was bypassed
+	// to avoid a panic.
+	if len(a.config.InitializationVector) != IVSize {
+		return "", ErrInvalidIV
+	}
+
+	switch a.config.Algorithm {
+	case "", EncryptionAlgorithmAESCBC:
+	case EncryptionAlgorithmAESGCM:
+		encryptedValue, err := a.encryptGCM(block, value)
+		if err != nil {
+			return "", err
+		}
+
+		return gcmPrefix + base64.StdEncoding.EncodeToString(encryptedValue), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidAlgorithm, a.config.Algorithm)
+	}
+
+	blockSize := block.BlockSize()
+	blockMode := cipher.NewCBCEncrypter(block, a.config.InitializationVector)
+
+	valueBytes := []byte(value)
+	valueBytes = pkcs7Pad(valueBytes, blockSize)
+
+	encryptedValue := make([]byte, len(valueBytes))
+	blockMode.CryptBlocks(encryptedValue, valueBytes)
+
+	return base64.StdEncoding.EncodeToString(encryptedValue), nil
+}
+
+// encryptGCM encrypts the input value using AES-GCM and returns the nonce followed by the encrypted value. The nonce is
+// an HMAC of the IV and the value with a key derived from the AES key, so the same value always yields the same
+// encrypted value while different values don't reuse a nonce.
+func (a *aesEncryptionProvider) encryptGCM(block cipher.Block, value string) ([]byte, error) {
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceKey := hmac.New(sha256.New, a.config.AESKey)
+	nonceKey.Write([]byte("ocm-encryption-gcm-nonce"))
+
+	nonceMAC := hmac.New(sha256.New, nonceKey.Sum(nil))
+	nonceMAC.Write(a.config.InitializationVector)
+	nonceMAC.Write([]byte(value))
+
+	nonce := nonceMAC.Sum(nil)[:gcm.NonceSize()]
+
+	return gcm.Seal(nonce, nonce, []byte(value), nil), nil
+}
+
+// Decrypt decrypts a value that was encrypted with either encryption algorithm, trying the fallback and previous AES
+// keys if the decryption fails using the AES key. An error is returned if the base64 or the AES key is invalid.
+func (a *aesEncryptionProvider) Decrypt(_ context.Context, value string) (string, error) {
+	decryptedValue, _, err := a.decryptWithPreviousKey(value)
+
+	return decryptedValue, err
+}
+
+// decryptWithPreviousKey is like Decrypt but also returns whether the value was decrypted with a key other than the
+// AES key.
+func (a *aesEncryptionProvider) decryptWithPreviousKey(value string) (string, bool, error) {
+	// This is already validated in the NewResolver method, but is checked again in case that method was bypassed
+	// to avoid a panic.
+	if len(a.config.InitializationVector) != IVSize {
+		return "", false, ErrInvalidIV
+	}
+
+	if strings.HasPrefix(value, gcmPrefix) {
+		return a.decryptGCM(value)
+	}
+
+	decodedValue, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w: %w", value, ErrInvalidB64OfEncrypted, err)
+	}
+
+	// CBC decryption panics if the input isn't full blocks, which is the case if the value wasn't from "protect"
+	if len(decodedValue) == 0 || len(decodedValue)%aes.BlockSize != 0 {
+		return "", false, fmt.Errorf(
+			"%s: %w: the encrypted value is not a multiple of the AES block size", value, ErrInvalidPKCS7Padding,
+		)
+	}
+
+	var decryptionErr error
+	var decryptedValue []byte
+
+	for i, aesKey := range a.aesKeys() {
+		block, err := aes.NewCipher(aesKey)
+		if err != nil {
+			decryptionErr = fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
+
+			continue
+		}
+
+		blockMode := cipher.NewCBCDecrypter(block, a.config.InitializationVector)
+		decryptedValue = make([]byte, len(decodedValue))
+		blockMode.CryptBlocks(decryptedValue, decodedValue)
+
+		// If the unpadding fails, it is either because the value was not generated by the "protect"
+		// template function or the value was encrypted with a different AES key.
+		decryptedValue, err = pkcs7Unpad(decryptedValue)
+		if err != nil {
+			decryptionErr = err
+
+			continue
+		}
+
+		return string(decryptedValue), i > 0, nil
+	}
+
+	return "", false, decryptionErr
+}
+
+// decryptGCM decrypts the input value with the gcmPrefix that was encrypted using AES-GCM. An error wrapping
+// ErrGCMAuthFailed is returned if the value was modified or was encrypted with a different AES key.
+func (a *aesEncryptionProvider) decryptGCM(value string) (string, bool, error) {
+	decodedValue, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, gcmPrefix))
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w: %w", value, ErrInvalidB64OfEncrypted, err)
+	}
+
+	var decryptionErr error
+
+	for i, aesKey := range a.aesKeys() {
+		block, err := aes.NewCipher(aesKey)
+		if err != nil {
+			decryptionErr = fmt.Errorf("%w: %w", ErrInvalidAESKey, err)
+
+			continue
+		}
+
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return "", false, err
+		}
+
+		if len(decodedValue) < gcm.NonceSize()+gcm.Overhead() {
+			return "", false, fmt.Errorf("%s: %w: the encrypted value is too short", value, ErrGCMAuthFailed)
+		}
+
+		nonce, encryptedValue := decodedValue[:gcm.NonceSize()], decodedValue[gcm.NonceSize():]
+
+		decryptedValue, err := gcm.Open(nil, nonce, encryptedValue, nil)
+		if err != nil {
+			decryptionErr = fmt.Errorf("%s: %w: %w", value, ErrGCMAuthFailed, err)
+
+			continue
+		}
+
+		return string(decryptedValue), i > 0, nil
+	}
+
+	return "", false, decryptionErr
+}
+
+// aesKeys returns the AES keys to try when decrypting, which is AESKey followed by AESKeyFallback if it's set and then
+// PreviousAESKeys.
+func (a *aesEncryptionProvider) aesKeys() [][]byte {
+	aesKeys := [][]byte{a.config.AESKey}
+
+	if a.config.AESKeyFallback != nil {
+		aesKeys = append(aesKeys, a.config.AESKeyFallback)
+	}
+
+	return append(aesKeys, a.config.PreviousAESKeys...)
+}
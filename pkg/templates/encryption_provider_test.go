@@ -0,0 +1,189 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errTestDecryption = errors.New("the test provider can't decrypt the value")
+
+// testEncryptionProvider "encrypts" values by reversing them and base64 encoding the result with the test: scheme.
+type testEncryptionProvider struct{}
+
+func (p testEncryptionProvider) Encrypt(_ context.Context, plaintext string) (string, error) {
+	return "test:" + base64.StdEncoding.EncodeToString([]byte(reverse(plaintext))), nil
+}
+
+func (p testEncryptionProvider) Decrypt(_ context.Context, encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, "test:") {
+		return "", errTestDecryption
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, "test:"))
+	if err != nil {
+		return "", err
+	}
+
+	return reverse(string(decoded)), nil
+}
+
+func (p testEncryptionProvider) KeyID() string {
+	return "test-key"
+}
+
+func reverse(value string) string {
+	reversed := []rune(value)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	return string(reversed)
+}
+
+func TestResolveTemplateWithEncryptionProvider(t *testing.T) {
+	t.Parallel()
+
+	provider := EncryptionConfig{Provider: testEncryptionProvider{}, EncryptionEnabled: true, DecryptionEnabled: true}
+
+	testcases := map[string]resolveTestCase{
+		"protect": {
+			inputTmpl:      `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: ResolveOptions{EncryptionConfig: provider},
+			expectedResult: "value: $ocm_encrypted:test:aGdpZWxhUg==",
+		},
+		"decrypt": {
+			inputTmpl:      "value: $ocm_encrypted:test:aGdpZWxhUg==",
+			resolveOptions: ResolveOptions{EncryptionConfig: provider},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fails": {
+			inputTmpl:      "value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: ResolveOptions{EncryptionConfig: provider},
+			expectedErr:    errTestDecryption,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestNewAESEncryptionProvider(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{byte('A')}, 256/8)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+
+	provider, err := NewAESEncryptionProvider(EncryptionConfig{AESKey: key, InitializationVector: iv})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encrypted, err := provider.Encrypt(context.TODO(), "Raleigh")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// This matches the value of the protect template function with the same AES key and IV
+	if encrypted != "Eud/p3S7TvuP03S9fuNV+w==" {
+		t.Fatalf("Unexpected encrypted value: %s", encrypted)
+	}
+
+	decrypted, err := provider.Decrypt(context.TODO(), "gcm:XVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg=")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if decrypted != "Raleigh" {
+		t.Fatalf("Unexpected decrypted value: %s", decrypted)
+	}
+
+	if keyID := provider.KeyID(); !strings.HasPrefix(keyID, "aes-") || len(keyID) != 16 {
+		t.Fatalf("Unexpected key ID: %s", keyID)
+	}
+
+	_, err = NewAESEncryptionProvider(EncryptionConfig{AESKey: key})
+	if !errors.Is(err, ErrIVNotSet) {
+		t.Fatalf("Expected ErrIVNotSet but got: %v", err)
+	}
+}
+
+func TestDecryptedWithPreviousKey(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{byte('A')}, 256/8)
+	otherKey := bytes.Repeat([]byte{byte('B')}, 256/8)
+	newKey := bytes.Repeat([]byte{byte('C')}, 256/8)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+
+	testcases := map[string]struct {
+		config              EncryptionConfig
+		expectedPreviousKey bool
+	}{
+		"current key":  {EncryptionConfig{AESKey: key}, false},
+		"fallback key": {EncryptionConfig{AESKey: otherKey, AESKeyFallback: key}, true},
+		"previous key": {
+			EncryptionConfig{AESKey: newKey, AESKeyFallback: otherKey, PreviousAESKeys: [][]byte{otherKey, key}}, true,
+		},
+	}
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test.config.DecryptionEnabled = true
+			test.config.InitializationVector = iv
+
+			result, err := resolver.ResolveTemplate(
+				[]byte("value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==\n"+
+					"value2: $ocm_encrypted:gcm:XVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg="),
+				nil,
+				&ResolveOptions{EncryptionConfig: test.config},
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != `{"value":"Raleigh","value2":"Raleigh"}` {
+				t.Fatalf("Unexpected result: %s", result.ResolvedJSON)
+			}
+
+			if result.DecryptedWithPreviousKey != test.expectedPreviousKey {
+				t.Fatalf(
+					"Expected DecryptedWithPreviousKey to be %t but got %t",
+					test.expectedPreviousKey, result.DecryptedWithPreviousKey,
+				)
+			}
+		})
+	}
+
+	_, err = resolver.ResolveTemplate(
+		[]byte("value: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w=="),
+		nil,
+		&ResolveOptions{EncryptionConfig: EncryptionConfig{
+			AESKey: key, DecryptionEnabled: true, InitializationVector: iv, PreviousAESKeys: [][]byte{[]byte("short")},
+		}},
+	)
+	if !errors.Is(err, ErrInvalidAESKey) {
+		t.Fatalf("Expected ErrInvalidAESKey but got: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+var (
+	ErrExecutionTimeout       = errors.New("the template execution exceeded the timeout")
+	ErrExecutionStepsExceeded = errors.New("the template execution exceeded the maximum number of steps")
+)
+
+// hasExecutionLimits returns whether ExecutionTimeout or MaxExecutionSteps is set on the options of a ResolveTemplate
+// call.
+func (o *ResolveOptions) hasExecutionLimits() bool {
+	return o.state != nil && (o.ExecutionTimeout > 0 || o.MaxExecutionSteps > 0)
+}
+
+// rangeStepFunc is the name of the function that limitRanges calls at the start of each range iteration, and
+// rangeStepVariable is the variable assigned its result so that the call has no output.
+const (
+	rangeStepFunc     = "_executionStep"
+	rangeStepVariable = "$_executionStep"
+)
+
+// step counts a step of the template execution, which is a template function call, a range iteration, or a write of
+// the template output, against MaxExecutionSteps and checks that the ExecutionTimeout deadline hasn't passed. An error
+// wrapping ErrExecutionStepsExceeded or ErrExecutionTimeout is returned if the execution must be aborted.
+func (o *ResolveOptions) step() error {
+	if !o.hasExecutionLimits() {
+		return nil
+	}
+
+	if !o.state.deadline.IsZero() && !time.Now().Before(o.state.deadline) {
+		return fmt.Errorf("%w: options.ExecutionTimeout is %s", ErrExecutionTimeout, o.ExecutionTimeout)
+	}
+
+	if o.MaxExecutionSteps == 0 {
+		return nil
+	}
+
+	if o.state.executionSteps >= o.MaxExecutionSteps {
+		return fmt.Errorf("%w: options.MaxExecutionSteps is %d", ErrExecutionStepsExceeded, o.MaxExecutionSteps)
+	}
+
+	o.state.executionSteps++
+
+	return nil
+}
+
+// limitFuncMap wraps the template functions in the input function map so that each call is a step of the template
+// execution (see step). The wrappers panic with the step error since the functions may not return an error, which
+// text/template recovers from and returns as the execution error.
+func (o *ResolveOptions) limitFuncMap(funcMap map[string]interface{}) {
+	for name, fn := range funcMap {
+		funcMap[name] = wrapFunc(fn, func() {
+			if err := o.step(); err != nil {
+				panic(err)
+			}
+		})
+	}
+}
+
+// wrapFunc returns a function with the same signature as the input function that calls before and then the input
+// function. The input is returned as is if it's not a function.
+func wrapFunc(fn interface{}, before func()) interface{} {
+	fnValue := reflect.ValueOf(fn)
+	if fnValue.Kind() != reflect.Func {
+		return fn
+	}
+
+	return reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+		before()
+
+		if fnValue.Type().IsVariadic() {
+			return fnValue.CallSlice(args)
+		}
+
+		return fnValue.Call(args)
+	}).Interface()
+}
+
+// limitWriter returns the input writer of the template output wrapped so that each write is a step of the template
+// execution (see step).
+func (o *ResolveOptions) limitWriter(w io.Writer) io.Writer {
+	if !o.hasExecutionLimits() {
+		return w
+	}
+
+	return &stepWriter{w: w, options: o}
+}
+
+type stepWriter struct {
+	w       io.Writer
+	options *ResolveOptions
+}
+
+func (s *stepWriter) Write(p []byte) (int, error) {
+	if err := s.options.step(); err != nil {
+		return 0, err
+	}
+
+	return s.w.Write(p)
+}
+
+// limitRanges adds a call to a step function at the start of the body of each range loop in the input template and
+// its associated templates so that each iteration is a step of the template execution (see step), even when the body
+// has no template function calls or output. The parse trees are copied before being modified since the clones of the
+// cached templates share them.
+func (o *ResolveOptions) limitRanges(tmpl *template.Template) *template.Template {
+	if !o.hasExecutionLimits() {
+		return tmpl
+	}
+
+	tmpl.Funcs(template.FuncMap{rangeStepFunc: func() (string, error) {
+		return "", o.step()
+	}})
+
+	for _, associated := range tmpl.Templates() {
+		if associated.Tree == nil || associated.Tree.Root == nil {
+			continue
+		}
+
+		tree := associated.Tree.Copy()
+		addRangeSteps(tree.Root)
+		associated.Tree = tree
+	}
+
+	return tmpl
+}
+
+// addRangeSteps prepends a rangeStepFunc call to the body of each range loop in the input list of nodes, including
+// the loops nested in other actions.
+func addRangeSteps(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.IfNode:
+			addRangeSteps(node.List)
+			addRangeSteps(node.ElseList)
+		case *parse.WithNode:
+			addRangeSteps(node.List)
+			addRangeSteps(node.ElseList)
+		case *parse.RangeNode:
+			addRangeSteps(node.List)
+			addRangeSteps(node.ElseList)
+
+			if node.List != nil {
+				node.List.Nodes = append([]parse.Node{rangeStepNode(node.Pos, node.Line)}, node.List.Nodes...)
+			}
+		}
+	}
+}
+
+// rangeStepNode returns the `{{ $_executionStep := _executionStep }}` action at the input position.
+func rangeStepNode(pos parse.Pos, line int) parse.Node {
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      pos,
+		Line:     line,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      pos,
+			Line:     line,
+			Decl: []*parse.VariableNode{
+				{NodeType: parse.NodeVariable, Pos: pos, Ident: []string{rangeStepVariable}},
+			},
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Pos:      pos,
+				Args: []parse.Node{
+					&parse.IdentifierNode{NodeType: parse.NodeIdentifier, Pos: pos, Ident: rangeStepFunc},
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecutionLimits(t *testing.T) {
+	t.Parallel()
+
+	slow := func() string {
+		time.Sleep(10 * time.Millisecond)
+
+		return "a"
+	}
+
+	tests := map[string]struct {
+		inputTmpl   string
+		options     ResolveOptions
+		expectedErr error
+	}{
+		"within the maximum steps": {
+			inputTmpl: `value: '{{ range $i := until 3 }}{{ $i }}{{ end }}'`,
+			options:   ResolveOptions{MaxExecutionSteps: 20},
+		},
+		"range exceeds the maximum steps": {
+			inputTmpl:   `value: '{{ range $i := until 1000 }}{{ $i }}{{ end }}'`,
+			options:     ResolveOptions{MaxExecutionSteps: 100},
+			expectedErr: ErrExecutionStepsExceeded,
+		},
+		"range without output exceeds the maximum steps": {
+			inputTmpl:   `value: '{{ range $i := until 1000 }}{{ $_ := add $i 1 }}{{ end }}'`,
+			options:     ResolveOptions{MaxExecutionSteps: 100},
+			expectedErr: ErrExecutionStepsExceeded,
+		},
+		"range with an empty body exceeds the maximum steps": {
+			inputTmpl:   `value: '{{ range $i := until 1000 }}{{ end }}'`,
+			options:     ResolveOptions{MaxExecutionSteps: 100},
+			expectedErr: ErrExecutionStepsExceeded,
+		},
+		"nested range in a defined template exceeds the maximum steps": {
+			inputTmpl: `value: '{{ define "loop" }}{{ range $i := until 1000 }}{{ end }}{{ end }}` +
+				`{{ if true }}{{ template "loop" }}{{ end }}'`,
+			options:     ResolveOptions{MaxExecutionSteps: 100},
+			expectedErr: ErrExecutionStepsExceeded,
+		},
+		"nested passes share the maximum steps": {
+			inputTmpl:   `value: '{{ "{{ range $i := until 1000 }}{{ $i }}{{ end }}" }}'`,
+			options:     ResolveOptions{MaxExecutionSteps: 100, MaxPasses: 2},
+			expectedErr: ErrExecutionStepsExceeded,
+		},
+		"within the timeout": {
+			inputTmpl: `value: '{{ slow }}'`,
+			options: ResolveOptions{
+				ExecutionTimeout: time.Minute,
+				CustomFunctions:  map[string]interface{}{"slow": slow},
+			},
+		},
+		"range exceeds the timeout": {
+			inputTmpl: `value: '{{ range $i := until 1000 }}{{ slow }}{{ end }}'`,
+			options: ResolveOptions{
+				ExecutionTimeout: 50 * time.Millisecond,
+				CustomFunctions:  map[string]interface{}{"slow": slow},
+			},
+			expectedErr: ErrExecutionTimeout,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			_, err = resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &test.options)
+			if test.expectedErr == nil {
+				if err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				return
+			}
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServerHost is the host of the rest.Config used by NewFakeResolver. No connection is ever made to it since
+// all requests are handled in memory.
+const fakeAPIServerHost = "https://fake-api-server.invalid"
+
+// fakeKubeVersion is the Kubernetes version reported by the /version endpoint of the fake API server.
+const fakeKubeVersion = "v1.28.3"
+
+// NewFakeResolver creates a new TemplateResolver instance that serves the lookups of the template functions (e.g.
+// lookup, fromConfigMap, and fromSecret) from the input objects instead of a Kubernetes cluster. This is useful to
+// test templates without cluster access, such as in CI pipelines.
+//
+// The API resources are discovered from the kinds of the input objects, in addition to the v1 ConfigMap, Secret, and
+// Namespace kinds. A kind is namespaced if any of its objects has a namespace. The stringData of Secret objects is
+// converted to base64 encoded data like the Kubernetes API server does. All access reviews (e.g. canLookup and
+// ResolveOptions.LookupAccessReview) are allowed, and requests that would change objects (e.g. ValidateWithDryRun)
+// fail. The Kubernetes version returned by the kubeVersion template function is v1.28.3.
+//
+// - objects are the Kubernetes objects to serve. Each must have an apiVersion, kind, and name.
+//
+// - config is the Config instance for configuring optional values for template processing.
+func NewFakeResolver(objects []unstructured.Unstructured, config Config) (*TemplateResolver, error) {
+	server, err := newFakeAPIServer(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResolver(&rest.Config{Host: fakeAPIServerHost, Transport: server}, config)
+}
+
+// fakeAPIResource is an API resource served by the fake API server and the objects of it.
+type fakeAPIResource struct {
+	apiResource metav1.APIResource
+	objects     []unstructured.Unstructured
+}
+
+// fakeAPIServer is an http.RoundTripper that serves the Kubernetes API discovery and read requests from memory.
+type fakeAPIServer struct {
+	// resources is keyed by the group version and then by the plural resource name.
+	resources map[schema.GroupVersion]map[string]*fakeAPIResource
+}
+
+func newFakeAPIServer(objects []unstructured.Unstructured) (*fakeAPIServer, error) {
+	server := &fakeAPIServer{resources: map[schema.GroupVersion]map[string]*fakeAPIResource{}}
+
+	server.addResource(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, true)
+	server.addResource(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, true)
+	server.addResource(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, false)
+
+	for i := range objects {
+		obj := *objects[i].DeepCopy()
+		gvk := obj.GroupVersionKind()
+
+		if gvk.Kind == "" || gvk.Version == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf(
+				"%w: the object at index %d must have an apiVersion, kind, and name", ErrInvalidInput, i,
+			)
+		}
+
+		if gvk.Group == "" && gvk.Kind == "Secret" {
+			convertStringData(&obj)
+		}
+
+		resource := server.addResource(gvk, obj.GetNamespace() != "")
+		resource.objects = append(resource.objects, obj)
+	}
+
+	return server, nil
+}
+
+// addResource adds the API resource of the input kind if it's not already added and returns it. The resource is
+// marked as namespaced if namespaced is set.
+func (s *fakeAPIServer) addResource(gvk schema.GroupVersionKind, namespaced bool) *fakeAPIResource {
+	gv := gvk.GroupVersion()
+	plural, singular := meta.UnsafeGuessKindToResource(gvk)
+
+	if s.resources[gv] == nil {
+		s.resources[gv] = map[string]*fakeAPIResource{}
+	}
+
+	resource, ok := s.resources[gv][plural.Resource]
+	if !ok {
+		resource = &fakeAPIResource{
+			apiResource: metav1.APIResource{
+				Name:         plural.Resource,
+				SingularName: singular.Resource,
+				Kind:         gvk.Kind,
+				Verbs:        metav1.Verbs{"get", "list"},
+			},
+		}
+		s.resources[gv][plural.Resource] = resource
+	}
+
+	if namespaced {
+		resource.apiResource.Namespaced = true
+	}
+
+	return resource
+}
+
+// convertStringData merges the stringData of the Secret into its data as base64 encoded values.
+func convertStringData(secret *unstructured.Unstructured) {
+	stringData, _, _ := unstructured.NestedStringMap(secret.Object, "stringData")
+	if len(stringData) == 0 {
+		return
+	}
+
+	data, _, _ := unstructured.NestedMap(secret.Object, "data")
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	for key, value := range stringData {
+		data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	_ = unstructured.SetNestedMap(secret.Object, data, "data")
+	unstructured.RemoveNestedField(secret.Object, "stringData")
+}
+
+func (s *fakeAPIServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Content-Type", "application/json")
+
+	status, body := s.handle(req)
+
+	recorder.WriteHeader(status)
+
+	if err := json.NewEncoder(recorder).Encode(body); err != nil {
+		return nil, err
+	}
+
+	resp := recorder.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// handle returns the HTTP status code and the body of the response to the request.
+func (s *fakeAPIServer) handle(req *http.Request) (int, interface{}) {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	var gv schema.GroupVersion
+
+	switch {
+	case len(segments) == 1 && segments[0] == "version":
+		return http.StatusOK, &version.Info{Major: "1", Minor: "28", GitVersion: fakeKubeVersion}
+	case len(segments) == 1 && segments[0] == "api":
+		return http.StatusOK, &metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		}
+	case len(segments) == 1 && segments[0] == "apis":
+		return http.StatusOK, s.apiGroupList()
+	case len(segments) >= 2 && segments[0] == "api":
+		gv = schema.GroupVersion{Version: segments[1]}
+		segments = segments[2:]
+	case len(segments) >= 3 && segments[0] == "apis":
+		gv = schema.GroupVersion{Group: segments[1], Version: segments[2]}
+		segments = segments[3:]
+	default:
+		return statusResponse(apierrors.NewNotFound(schema.GroupResource{}, req.URL.Path))
+	}
+
+	if gv.Group == "authorization.k8s.io" && len(segments) == 1 &&
+		(segments[0] == "selfsubjectaccessreviews" || segments[0] == "subjectaccessreviews") {
+		return s.allowAccessReview(req)
+	}
+
+	resources, ok := s.resources[gv]
+	if !ok {
+		return statusResponse(apierrors.NewNotFound(schema.GroupResource{Group: gv.Group}, gv.String()))
+	}
+
+	if len(segments) == 0 {
+		return http.StatusOK, s.apiResourceList(gv)
+	}
+
+	if req.Method != http.MethodGet {
+		return statusResponse(apierrors.NewMethodNotSupported(schema.GroupResource{Group: gv.Group}, req.Method))
+	}
+
+	namespace := ""
+
+	// The namespaces resource is itself accessed without a namespace (e.g. /api/v1/namespaces/name)
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		namespace = segments[1]
+		segments = segments[2:]
+	}
+
+	resource, ok := resources[segments[0]]
+	if !ok {
+		return statusResponse(apierrors.NewNotFound(gv.WithResource(segments[0]).GroupResource(), ""))
+	}
+
+	groupResource := gv.WithResource(segments[0]).GroupResource()
+
+	switch len(segments) {
+	case 1:
+		return s.list(req, gv, resource, namespace)
+	case 2:
+		for _, obj := range resource.objects {
+			if obj.GetNamespace() == namespace && obj.GetName() == segments[1] {
+				return http.StatusOK, obj.Object
+			}
+		}
+
+		return statusResponse(apierrors.NewNotFound(groupResource, segments[1]))
+	default:
+		// Subresources are not served
+		return statusResponse(apierrors.NewNotFound(groupResource, strings.Join(segments[1:], "/")))
+	}
+}
+
+func (s *fakeAPIServer) list(
+	req *http.Request, gv schema.GroupVersion, resource *fakeAPIResource, namespace string,
+) (int, interface{}) {
+	labelSelector, err := labels.Parse(req.URL.Query().Get("labelSelector"))
+	if err != nil {
+		return statusResponse(apierrors.NewBadRequest(err.Error()))
+	}
+
+	fieldSelector, err := fields.ParseSelector(req.URL.Query().Get("fieldSelector"))
+	if err != nil {
+		return statusResponse(apierrors.NewBadRequest(err.Error()))
+	}
+
+	items := []interface{}{}
+
+	for _, obj := range filterByFieldSelector(resource.objects, fieldSelector) {
+		if namespace != "" && obj.GetNamespace() != namespace {
+			continue
+		}
+
+		if !labelSelector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+
+		items = append(items, obj.Object)
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"apiVersion": gv.String(),
+		"kind":       resource.apiResource.Kind + "List",
+		"metadata":   map[string]interface{}{},
+		"items":      items,
+	}
+}
+
+// allowAccessReview responds to a SelfSubjectAccessReview or SubjectAccessReview by allowing it.
+func (s *fakeAPIServer) allowAccessReview(req *http.Request) (int, interface{}) {
+	review := map[string]interface{}{}
+
+	body, err := io.ReadAll(req.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &review)
+	}
+
+	if err != nil {
+		return statusResponse(apierrors.NewBadRequest(err.Error()))
+	}
+
+	review["status"] = map[string]interface{}{"allowed": true}
+
+	return http.StatusCreated, review
+}
+
+func (s *fakeAPIServer) apiGroupList() *metav1.APIGroupList {
+	groups := map[string]*metav1.APIGroup{}
+
+	for gv := range s.resources {
+		if gv.Group == "" {
+			continue
+		}
+
+		group, ok := groups[gv.Group]
+		if !ok {
+			group = &metav1.APIGroup{Name: gv.Group}
+			groups[gv.Group] = group
+		}
+
+		group.Versions = append(
+			group.Versions, metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version},
+		)
+	}
+
+	groupList := &metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+
+	for _, group := range groups {
+		sort.Slice(group.Versions, func(i, j int) bool {
+			return group.Versions[i].Version < group.Versions[j].Version
+		})
+
+		group.PreferredVersion = group.Versions[0]
+		groupList.Groups = append(groupList.Groups, *group)
+	}
+
+	sort.Slice(groupList.Groups, func(i, j int) bool { return groupList.Groups[i].Name < groupList.Groups[j].Name })
+
+	return groupList
+}
+
+func (s *fakeAPIServer) apiResourceList(gv schema.GroupVersion) *metav1.APIResourceList {
+	resourceList := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+	}
+
+	for _, resource := range s.resources[gv] {
+		resourceList.APIResources = append(resourceList.APIResources, resource.apiResource)
+	}
+
+	sort.Slice(resourceList.APIResources, func(i, j int) bool {
+		return resourceList.APIResources[i].Name < resourceList.APIResources[j].Name
+	})
+
+	return resourceList
+}
+
+func statusResponse(err *apierrors.StatusError) (int, interface{}) {
+	status := err.ErrStatus
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+
+	return int(status.Code), &status
+}
@@ -0,0 +1,141 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewFakeResolver(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "app-config",
+				"namespace": "app",
+				"labels":    map[string]interface{}{"app": "web"},
+			},
+			"data": map[string]interface{}{"replicas": "3"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "other-config",
+				"namespace": "app",
+				"labels":    map[string]interface{}{"app": "db"},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app-secret", "namespace": "app"},
+			"stringData": map[string]interface{}{"password": "hunter2"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "cluster.open-cluster-management.io/v1alpha1",
+			"kind":       "ClusterClaim",
+			"metadata":   map[string]interface{}{"name": "id.k8s.io"},
+			"spec":       map[string]interface{}{"value": "cluster-id"},
+		}},
+	}
+
+	testcases := map[string]resolveTestCase{
+		"fromConfigMap": {
+			inputTmpl:      `value: '{{ fromConfigMap "app" "app-config" "replicas" }}'`,
+			expectedResult: "value: \"3\"",
+		},
+		"fromSecret with stringData": {
+			inputTmpl:      `value: '{{ fromSecret "app" "app-secret" "password" }}'`,
+			expectedResult: "value: aHVudGVyMg==",
+		},
+		"fromClusterClaim": {
+			inputTmpl:      `value: '{{ fromClusterClaim "id.k8s.io" }}'`,
+			expectedResult: "value: cluster-id",
+		},
+		"lookup not found": {
+			inputTmpl:      `value: '{{ (lookup "v1" "ConfigMap" "app" "not-found").metadata.name }}'`,
+			expectedResult: "value: <no value>",
+		},
+		"lookup list with a label selector": {
+			inputTmpl: `value: '{{ range (lookup "v1" "ConfigMap" "app" "" "app=db").items }}` +
+				`{{ .metadata.name }}{{ end }}'`,
+			expectedResult: "value: other-config",
+		},
+		"lookup list with a field selector": {
+			inputTmpl: `value: '{{ range (lookup "v1" "ConfigMap" "app" "" "" "metadata.name=app-config").items }}` +
+				`{{ .metadata.name }}{{ end }}'`,
+			expectedResult: "value: app-config",
+		},
+		"canLookup": {
+			inputTmpl:      `value: '{{ canLookup "v1" "Secret" "app" "list" }}'`,
+			expectedResult: "value: \"true\"",
+		},
+		"kubeVersion": {
+			inputTmpl:      `value: '{{ kubeVersion }},{{ semverCompare ">=1.25.0" kubeVersion }}'`,
+			expectedResult: "value: v1.28.3,true",
+		},
+		"apiResourceExists": {
+			inputTmpl: `value: '{{ apiResourceExists "cluster.open-cluster-management.io/v1alpha1" "ClusterClaim" }},` +
+				`{{ apiResourceExists "monitoring.coreos.com/v1" "ServiceMonitor" }}'`,
+			expectedResult: "value: true,false",
+		},
+		"missing API resource": {
+			inputTmpl:   `value: '{{ lookup "v1" "NotAResource" "app" "name" }}'`,
+			expectedErr: ErrMissingAPIResource,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(val) != test.expectedResult+"\n" {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, string(val))
+			}
+		})
+	}
+}
+
+func TestNewFakeResolverInvalidObject(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}}},
+		Config{},
+	)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	ErrFieldDependencyCycle   = errors.New("the templated fields have a circular dependency")
+	ErrFieldValueNotAvailable = errors.New(
+		"the fieldValue template function is only available when ResolveInDependencyOrder is set",
+	)
+	// fieldValueRegex captures the literal path argument of each fieldValue call. This is the explicit dependency
+	// declaration used to determine the order of resolution.
+	fieldValueRegex = regexp.MustCompile(`fieldValue\s+"([^"]+)"`)
+)
+
+// templatedField is a string field in the input document that contains a template.
+type templatedField struct {
+	path         string
+	template     string
+	dependencies []string
+	// verbatimBlocks are the verbatim blocks replaced with placeholders in the template.
+	verbatimBlocks []string
+}
+
+// resolveInDependencyOrder resolves each templated string field of the input YAML document individually. Fields may
+// reference the resolved value of other fields with the fieldValue template function, which determines the order of
+// resolution. An error wrapping ErrFieldDependencyCycle is returned if the fields reference each other in a cycle.
+// The resolved document is returned as JSON.
+func (t *TemplateResolver) resolveInDependencyOrder(
+	templateStr string, funcMap template.FuncMap, ctx interface{}, options *ResolveOptions,
+) ([]byte, error) {
+	var doc interface{}
+
+	err := yaml.Unmarshal([]byte(templateStr), &doc)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to parse the template for dependency ordering: %w", classifyError(ErrParseFailed, err),
+		)
+	}
+
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf(
+			"%w: the template must be a YAML map to be resolved in dependency order", ErrInvalidInput,
+		)
+	}
+
+	fields := map[string]*templatedField{}
+	t.collectTemplatedFields(options, doc, "", fields)
+
+	for _, field := range fields {
+		for _, match := range fieldValueRegex.FindAllStringSubmatch(field.template, -1) {
+			for path := range fields {
+				if path == match[1] || strings.HasPrefix(path, match[1]+".") {
+					field.dependencies = append(field.dependencies, path)
+				}
+			}
+		}
+
+		sort.Strings(field.dependencies)
+	}
+
+	order, err := sortFieldsByDependency(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	t.logger(options).V(2).Info("Resolving the templated fields", "order", order)
+
+	pending := make(map[string]bool, len(fields))
+	for path := range fields {
+		pending[path] = true
+	}
+
+	fieldFuncMap := template.FuncMap{}
+	for name, fn := range funcMap {
+		fieldFuncMap[name] = fn
+	}
+
+	fieldFuncMap["fieldValue"] = func(path string) (interface{}, error) {
+		for pendingPath := range pending {
+			if pendingPath == path || strings.HasPrefix(pendingPath, path+".") {
+				return nil, fmt.Errorf(
+					"%w: the field %s is not resolved yet, reference it with a literal string", ErrInvalidInput, path,
+				)
+			}
+		}
+
+		value, found := getFieldByPath(doc, path)
+		if !found {
+			return nil, fmt.Errorf("%w: the field %s referenced by fieldValue does not exist", ErrInvalidInput, path)
+		}
+
+		return value, nil
+	}
+
+	for _, path := range order {
+		field := fields[path]
+
+		tmpl, err := t.parseTemplate(path, field.template, fieldFuncMap, options)
+		if err != nil {
+			err = classifyError(ErrParseFailed, deniedFunctionError(err, options.DeniedFunctions))
+
+			return nil, fmt.Errorf("failed to parse the template at the field %s: %w", path, err)
+		}
+
+		var buf bytes.Buffer
+
+		err = tmpl.Execute(options.limitWriter(&buf), ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the template at the field %s: %w", path, err)
+		}
+
+		resolved := restoreVerbatimBlocks(buf.Bytes(), field.verbatimBlocks)
+
+		var value interface{} = string(resolved)
+
+		// Mirror processForDataTypes by allowing the field to become a non-string type
+		if t.hasDataTypeFunction(options, field.template) {
+			var typedValue interface{}
+
+			if err := yaml.Unmarshal(resolved, &typedValue); err == nil {
+				value = typedValue
+			}
+		}
+
+		doc = setFieldByPath(doc, path, value)
+
+		delete(pending, path)
+	}
+
+	return json.Marshal(doc) //nolint:wrapcheck
+}
+
+// collectTemplatedFields walks the input document and adds every string field containing the start delimiter outside of
+// verbatim blocks to the input fields map, keyed by its dot separated path.
+func (t *TemplateResolver) collectTemplatedFields(
+	options *ResolveOptions, node interface{}, path string, fields map[string]*templatedField,
+) {
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typedNode {
+			t.collectTemplatedFields(options, value, joinFieldPath(path, key), fields)
+		}
+	case []interface{}:
+		for i, value := range typedNode {
+			t.collectTemplatedFields(options, value, joinFieldPath(path, strconv.Itoa(i)), fields)
+		}
+	case string:
+		// The verbatim blocks are left untouched, so a field with only verbatim blocks is not templated
+		tmpl, verbatimBlocks := t.protectVerbatimBlocks(options, typedNode)
+
+		if strings.Contains(tmpl, "toYamlIndented") {
+			tmpl = t.processForYAMLIndent(options, tmpl)
+		}
+
+		if startDelim, _ := t.delims(options); strings.Contains(tmpl, startDelim) {
+			fields[path] = &templatedField{path: path, template: tmpl, verbatimBlocks: verbatimBlocks}
+		}
+	}
+}
+
+// hasDataTypeFunction determines if the template pipes its output to toInt, toBool, toLiteral, or toYamlIndented.
+func (t *TemplateResolver) hasDataTypeFunction(options *ResolveOptions, tmpl string) bool {
+	startDelim, stopDelim := t.delims(options)
+	d1 := regexp.QuoteMeta(startDelim)
+	d2 := regexp.QuoteMeta(stopDelim)
+	re := regexp.MustCompile(d1 + `.*\|\s*(?:toInt|toBool|toLiteral|toYamlIndented).*` + d2)
+
+	return re.MatchString(tmpl)
+}
+
+// sortFieldsByDependency returns the field paths sorted so that each field comes after the fields it depends on.
+// Independent fields are sorted lexicographically for a deterministic order.
+func sortFieldsByDependency(fields map[string]*templatedField) ([]string, error) {
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	// The zero value of a state indicates that the field has not been visited
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	states := make(map[string]int, len(fields))
+	order := make([]string, 0, len(fields))
+
+	var visit func(path string, stack []string) error
+
+	visit = func(path string, stack []string) error {
+		switch states[path] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrFieldDependencyCycle, strings.Join(append(stack, path), " -> "))
+		}
+
+		states[path] = visiting
+
+		for _, dependency := range fields[path].dependencies {
+			if err := visit(dependency, append(stack, path)); err != nil {
+				return err
+			}
+		}
+
+		states[path] = visited
+		order = append(order, path)
+
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := visit(path, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func joinFieldPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// getFieldByPath returns the value at the dot separated path in the document. List indexes are numeric path segments.
+func getFieldByPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+
+	for _, key := range strings.Split(path, ".") {
+		switch typedCurrent := current.(type) {
+		case map[string]interface{}:
+			value, ok := typedCurrent[key]
+			if !ok {
+				return nil, false
+			}
+
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(typedCurrent) {
+				return nil, false
+			}
+
+			current = typedCurrent[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// setFieldByPath sets the value at the dot separated path in the document and returns the updated document. The path
+// must already exist in the document.
+func setFieldByPath(doc interface{}, path string, value interface{}) interface{} {
+	key, remainingPath, _ := strings.Cut(path, ".")
+
+	switch typedDoc := doc.(type) {
+	case map[string]interface{}:
+		if remainingPath == "" {
+			typedDoc[key] = value
+		} else {
+			typedDoc[key] = setFieldByPath(typedDoc[key], remainingPath, value)
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(typedDoc) {
+			return doc
+		}
+
+		if remainingPath == "" {
+			typedDoc[i] = value
+		} else {
+			typedDoc[i] = setFieldByPath(typedDoc[i], remainingPath, value)
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveInDependencyOrder(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"fields referencing other fields": {
+			inputTmpl: `
+spec:
+  fullName: '{{ fieldValue "spec.first" }}-{{ fieldValue "spec.last" }}'
+  first: '{{ "jane" | upper }}'
+  last: doe
+  replicas: '{{ fieldValue "spec.count" | toInt }}'
+  count: '{{ "3" }}'`,
+			resolveOptions: ResolveOptions{ResolveInDependencyOrder: true},
+			expectedResult: "spec:\n  count: \"3\"\n  first: JANE\n  fullName: JANE-doe\n  last: doe\n  replicas: 3",
+		},
+		"field referencing a parent map": {
+			inputTmpl: `
+labels:
+  app: '{{ "web" }}'
+labelCount: '{{ len (fieldValue "labels") }}'`,
+			resolveOptions: ResolveOptions{ResolveInDependencyOrder: true},
+			expectedResult: "labelCount: \"1\"\nlabels:\n  app: web",
+		},
+		"toYamlIndented": {
+			inputTmpl: `
+containers:
+  - '{{ fromJSON "{\"name\":\"web\",\"ports\":[80]}" | toYamlIndented }}'
+first: '{{ (index (fieldValue "containers") 0).name }}'`,
+			resolveOptions: ResolveOptions{ResolveInDependencyOrder: true},
+			expectedResult: "containers:\n  - name: web\n    ports:\n      - 80\nfirst: web",
+		},
+		"circular dependency": {
+			inputTmpl: `
+a: '{{ fieldValue "b" }}'
+b: '{{ fieldValue "a" }}'`,
+			resolveOptions: ResolveOptions{ResolveInDependencyOrder: true},
+			expectedErr:    ErrFieldDependencyCycle,
+		},
+		"missing field": {
+			inputTmpl:      `a: '{{ fieldValue "b" }}'`,
+			resolveOptions: ResolveOptions{ResolveInDependencyOrder: true},
+			expectedErr:    ErrInvalidInput,
+		},
+		"not enabled": {
+			inputTmpl:   `a: '{{ fieldValue "b" }}'`,
+			expectedErr: ErrFieldValueNotAvailable,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestSortFieldsByDependency(t *testing.T) {
+	t.Parallel()
+
+	fields := map[string]*templatedField{
+		"c": {path: "c", dependencies: []string{"a", "b"}},
+		"b": {path: "b", dependencies: []string{"a"}},
+		"a": {path: "a"},
+		"d": {path: "d"},
+	}
+
+	order, err := sortFieldsByDependency(fields)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	expected := []string{"a", "b", "c", "d"}
+
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("Expected the order %v but got %v", expected, order)
+		}
+	}
+
+	fields["a"].dependencies = []string{"c"}
+
+	_, err = sortFieldsByDependency(fields)
+	if !errors.Is(err, ErrFieldDependencyCycle) {
+		t.Fatalf("Expected ErrFieldDependencyCycle but got: %v", err)
+	}
+}
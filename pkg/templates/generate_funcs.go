@@ -0,0 +1,126 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cast"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	randomStringChars     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	maxRandomStringLength = 4096
+)
+
+func (t *TemplateResolver) genRandomStringHelper(
+	options *ResolveOptions,
+) func(interface{}, string, string, string) (string, error) {
+	return func(length interface{}, namespace string, name string, key string) (string, error) {
+		return t.genRandomString(options, length, namespace, name, key)
+	}
+}
+
+// genRandomString returns the base64 encoded value of the key in the given Secret, or a new cryptographically random
+// alphanumeric string of the input length if the Secret or key is not found. When the resolved template outputs this
+// value in the data of the same Secret, such as a generated password, later resolutions return the stored value so
+// that the output is stable.
+func (t *TemplateResolver) genRandomString(
+	options *ResolveOptions, length interface{}, namespace string, name string, key string,
+) (string, error) {
+	t.logger(options).V(2).Info("genRandomString", "namespace", namespace, "name", name, "key", key)
+
+	size, err := cast.ToIntE(length)
+	if err != nil || size < 1 || size > maxRandomStringLength {
+		return "", fmt.Errorf(
+			"%w: the length %v is not an integer from 1 to %d", ErrInvalidInput, length, maxRandomStringLength,
+		)
+	}
+
+	return t.getOrGenerateSecretValue(options, namespace, name, key, func() (string, error) {
+		result := make([]byte, size)
+		charCount := big.NewInt(int64(len(randomStringChars)))
+
+		for i := range result {
+			index, err := rand.Int(rand.Reader, charCount)
+			if err != nil {
+				return "", err
+			}
+
+			result[i] = randomStringChars[index.Int64()]
+		}
+
+		return string(result), nil
+	})
+}
+
+func (t *TemplateResolver) genUUIDHelper(options *ResolveOptions) func(string, string, string) (string, error) {
+	return func(namespace string, name string, key string) (string, error) {
+		return t.genUUID(options, namespace, name, key)
+	}
+}
+
+// genUUID returns the base64 encoded value of the key in the given Secret, or a new random (version 4) UUID if the
+// Secret or key is not found. Like genRandomString, the output is stable once the value is stored in the Secret.
+func (t *TemplateResolver) genUUID(
+	options *ResolveOptions, namespace string, name string, key string,
+) (string, error) {
+	t.logger(options).V(2).Info("genUUID", "namespace", namespace, "name", name, "key", key)
+
+	return t.getOrGenerateSecretValue(options, namespace, name, key, func() (string, error) {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", err
+		}
+
+		return id.String(), nil
+	})
+}
+
+// getOrGenerateSecretValue returns the base64 encoded value of the key in the given Secret or the base64 encoding of a
+// value from generate if the key is not found. A generated value is reused by later calls for the same key in the same
+// ResolveTemplate call, so a generated password can be referenced by multiple objects. The output is encrypted with the
+// "protect" method when encryption is enabled, like fromSecret.
+func (t *TemplateResolver) getOrGenerateSecretValue(
+	options *ResolveOptions, namespace string, name string, key string, generate func() (string, error),
+) (string, error) {
+	value, found, err := t.getSecretValue(options, namespace, name, key)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	if !found {
+		stateKey := namespace + "/" + name + "/" + key
+
+		var generated bool
+
+		value, generated = options.state.generatedValues[stateKey]
+		if !generated {
+			decoded, err := generate()
+			if err != nil {
+				return "", fmt.Errorf("failed to generate the value of the key %s in the secret %s from %s: %w",
+					key, name, namespace, err)
+			}
+
+			value = base64.StdEncoding.EncodeToString([]byte(decoded))
+
+			if options.state.generatedValues == nil {
+				options.state.generatedValues = map[string]string{}
+			}
+
+			options.state.generatedValues[stateKey] = value
+			options.state.taint.addBase64(value)
+		}
+	}
+
+	if options.EncryptionEnabled {
+		return t.protect(options, value)
+	}
+
+	return value, nil
+}
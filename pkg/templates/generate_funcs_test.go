@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerateFuncs(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "creds", "namespace": "app"},
+			"stringData": map[string]interface{}{"password": "existing", "id": "existing-id"},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `stored: '{{ genRandomString 16 "app" "creds" "password" }}'
+storedID: '{{ genUUID "app" "creds" "id" }}'
+generated: '{{ genRandomString 16 "app" "creds" "other" }}'
+generatedAgain: '{{ genRandomString 16 "app" "creds" "other" }}'
+generatedNewSecret: '{{ genRandomString 24 "app" "new-creds" "password" }}'
+generatedID: '{{ genUUID "app" "new-creds" "id" }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !result.HasSensitiveData {
+		t.Fatal("Expected the result to have sensitive data")
+	}
+
+	resolved := map[string]string{}
+	if err := json.Unmarshal(result.ResolvedJSON, &resolved); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	decoded := map[string]string{}
+
+	for key, value := range resolved {
+		decodedValue, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatalf("Expected %s to be base64 encoded: %v", key, err)
+		}
+
+		decoded[key] = string(decodedValue)
+	}
+
+	if decoded["stored"] != "existing" || decoded["storedID"] != "existing-id" {
+		t.Fatalf("Expected the stored values but got %v", decoded)
+	}
+
+	if !regexp.MustCompile(`^[a-zA-Z0-9]{16}$`).MatchString(decoded["generated"]) {
+		t.Fatalf("Expected a 16 character alphanumeric string but got %s", decoded["generated"])
+	}
+
+	if decoded["generatedAgain"] != decoded["generated"] {
+		t.Fatalf("Expected the same generated value but got %s", decoded["generatedAgain"])
+	}
+
+	if len(decoded["generatedNewSecret"]) != 24 {
+		t.Fatalf("Expected a 24 character string but got %s", decoded["generatedNewSecret"])
+	}
+
+	if _, err := uuid.Parse(decoded["generatedID"]); err != nil {
+		t.Fatalf("Expected a UUID but got %s", decoded["generatedID"])
+	}
+
+	result2, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolved2 := map[string]string{}
+	if err := json.Unmarshal(result2.ResolvedJSON, &resolved2); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if resolved2["generated"] == resolved["generated"] {
+		t.Fatal("Expected a new value to be generated when the key is still not stored")
+	}
+}
+
+func TestGenRandomStringInvalidLength(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, length := range []string{"0", "-1", "4097", `"ten"`} {
+		_, err := resolver.ResolveTemplate(
+			[]byte(`value: '{{ genRandomString `+length+` "app" "creds" "password" }}'`), nil, nil,
+		)
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for the length %s but got %v", length, err)
+		}
+	}
+}
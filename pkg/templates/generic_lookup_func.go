@@ -7,48 +7,129 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/spf13/cast"
 	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slices"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/klog"
 )
 
+// defaultAnnotations are the conventional annotations used to mark a resource as the default of its kind.
+var defaultAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+	"ingressclass.kubernetes.io/is-default-class",
+	"snapshot.storage.kubernetes.io/is-default-class",
+}
+
+// ClusterScopedLookupRestrictedError is returned when a cluster-scoped resource is looked up while the lookups are
+// restricted to namespaces and the resource is not in ResolveOptions.ClusterScopedAllowList or is in
+// ResolveOptions.ClusterScopedDenyList. It matches ErrClusterScopedLookupRestricted with errors.Is. Use errors.As to
+// get the blocked resource from the error returned by ResolveTemplate.
+//
+// - Group is the API group of the blocked resource, which is empty for the core API group.
+//
+// - Kind is the kind of the blocked resource.
+//
+// - Name is the name of the blocked resource, which is empty if the lookup was a list query.
 type ClusterScopedLookupRestrictedError struct {
-	kind string
-	name string
+	Group string
+	Kind  string
+	Name  string
 }
 
 func (e ClusterScopedLookupRestrictedError) Error() string {
-	return fmt.Sprintf("lookup of cluster-scoped resource '%v/%v' is not allowed", e.kind, e.name)
+	return fmt.Sprintf("lookup of cluster-scoped resource '%v/%v' is not allowed", e.Kind, e.Name)
+}
+
+func (e ClusterScopedLookupRestrictedError) Unwrap() error {
+	return ErrClusterScopedLookupRestricted
+}
+
+// KindLookupRestrictedError is returned when an object of a kind that is not in ResolveOptions.AllowedGVKs is looked
+// up. It matches ErrKindLookupRestricted with errors.Is. Use errors.As to get the blocked kind from the error returned
+// by ResolveTemplate.
+type KindLookupRestrictedError struct {
+	GroupVersionKind schema.GroupVersionKind
+}
+
+func (e KindLookupRestrictedError) Error() string {
+	return fmt.Sprintf("lookup of the kind '%s' is not allowed", e.GroupVersionKind)
+}
+
+func (e KindLookupRestrictedError) Unwrap() error {
+	return ErrKindLookupRestricted
+}
+
+// checkAllowedGVK returns a KindLookupRestrictedError if options.AllowedGVKs is set and the GVK doesn't match any of
+// its entries. Each field of an entry may be the wildcard value `*` to match any value.
+func checkAllowedGVK(options *ResolveOptions, gvk schema.GroupVersionKind) error {
+	if len(options.AllowedGVKs) == 0 {
+		return nil
+	}
+
+	for _, allowed := range options.AllowedGVKs {
+		if (allowed.Group == "*" || allowed.Group == gvk.Group) &&
+			(allowed.Version == "*" || allowed.Version == gvk.Version) &&
+			(allowed.Kind == "*" || allowed.Kind == gvk.Kind) {
+			return nil
+		}
+	}
+
+	return KindLookupRestrictedError{GroupVersionKind: gvk}
 }
 
 // getNamespace checks that the target namespace is allowed based on the configured
-// lookupNamespace. If it's not, an error is returned. It then returns the namespace
-// that should be used. If the target namespace is not set and the lookupNamespace
-// configuration is, then the namespace of lookupNamespace is returned for convenience.
-func (t *TemplateResolver) getNamespace(namespace string, lookupNamespace string) (string, error) {
-	// When lookupNamespace is an empty string, there are no namespace restrictions.
-	if lookupNamespace != "" {
-		// If lookupNamespace is set but namespace is an empty string, then default
-		// to lookupNamespace for convenience
-		if namespace == "" {
-			return lookupNamespace, nil
+// lookupNamespaces. If it's not, an error is returned. It then returns the namespace
+// that should be used. If the target namespace is not set and a single lookup namespace
+// is configured, then that namespace is returned for convenience.
+func (t *TemplateResolver) getNamespace(namespace string, lookupNamespaces ...string) (string, error) {
+	allowed := make([]string, 0, len(lookupNamespaces))
+
+	for _, lookupNamespace := range lookupNamespaces {
+		if lookupNamespace != "" && !slices.Contains(allowed, lookupNamespace) {
+			allowed = append(allowed, lookupNamespace)
 		}
+	}
+
+	// When there are no lookup namespaces, there are no namespace restrictions.
+	if len(allowed) == 0 {
+		return namespace, nil
+	}
 
-		if lookupNamespace != namespace {
-			return "", fmt.Errorf("%w to %s", ErrRestrictedNamespace, lookupNamespace)
+	if namespace == "" {
+		// If there is a single lookup namespace but namespace is an empty string, then default
+		// to it for convenience
+		if len(allowed) == 1 {
+			return allowed[0], nil
 		}
+
+		return "", fmt.Errorf(
+			"%w to %s and a namespace must be specified", ErrRestrictedNamespace, strings.Join(allowed, ", "),
+		)
+	}
+
+	if !slices.Contains(allowed, namespace) {
+		return "", fmt.Errorf("%w to %s", ErrRestrictedNamespace, strings.Join(allowed, ", "))
 	}
 
 	return namespace, nil
 }
 
+// getOrList returns a copy of the object or list of objects for the query so that neither the post-fetch processing
+// configured in the options, such as trimming managed fields, nor a template that modifies the result, such as with the
+// set template function, modifies the cached objects that later lookups and ResolveTemplate calls return. Each query
+// has a span that is a child of the span of the ResolveTemplate call.
 func (t *TemplateResolver) getOrList(
 	options *ResolveOptions,
 	apiVersion string,
@@ -57,19 +138,106 @@ func (t *TemplateResolver) getOrList(
 	name string,
 	labelSelector ...string,
 ) (
-	map[string]interface{}, error,
+	result map[string]interface{}, err error,
 ) {
 	if options == nil {
 		options = &ResolveOptions{}
 	}
 
-	if apiVersion == "" || kind == "" {
-		return nil, errors.New("the apiVersion and kind are required")
+	ctx, span := t.startSpan(
+		options.apiContext(), "getOrList",
+		attrKind.String(kind), attrNamespace.String(namespace), attrName.String(name),
+		attrLabelSelector.StringSlice(labelSelector),
+	)
+	defer func() { endSpan(span, err) }()
+
+	result, err = t.getOrListRaw(ctx, options, apiVersion, kind, namespace, name, labelSelector...)
+	options.recordLookup(apiVersion, kind, namespace, name, labelSelector, result, err)
+
+	if err != nil || result == nil {
+		return result, err
 	}
 
-	ns, err := t.getNamespace(namespace, options.LookupNamespace)
-	if err != nil {
-		return nil, err
+	result = runtime.DeepCopyJSON(result)
+
+	// The result is only sensitive if the Secret or list of Secrets was read
+	if apiVersion == "v1" && kind == "Secret" && options.state != nil {
+		options.state.hasSensitiveData = true
+		options.state.taint.addSecretData(result)
+	}
+
+	if name == "" {
+		result = filterDeniedObjects(options, apiVersion, result)
+	}
+
+	if !options.TrimManagedFields {
+		return result, nil
+	}
+
+	if name != "" {
+		trimManagedFields(result, options.PreservedFieldManagers)
+
+		return result, nil
+	}
+
+	items, _, _ := unstructured.NestedSlice(result, "items")
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok {
+			trimManagedFields(obj, options.PreservedFieldManagers)
+		}
+	}
+
+	return result, nil
+}
+
+// trimManagedFields removes the metadata.managedFields entries of the input object except for those of the input field
+// managers. If no field managers are provided, metadata.managedFields is removed entirely.
+func trimManagedFields(obj map[string]interface{}, preservedManagers []string) {
+	managedFields, found, _ := unstructured.NestedSlice(obj, "metadata", "managedFields")
+	if !found {
+		return
+	}
+
+	preserved := make([]interface{}, 0, len(managedFields))
+
+	for _, entry := range managedFields {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		manager, _, _ := unstructured.NestedString(entryMap, "manager")
+		if slices.Contains(preservedManagers, manager) {
+			preserved = append(preserved, entry)
+		}
+	}
+
+	if len(preserved) == 0 {
+		unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+
+		return
+	}
+
+	_ = unstructured.SetNestedSlice(obj, preserved, "metadata", "managedFields")
+}
+
+// getOrListRaw returns the object or list of objects for the query from the cache or the Kubernetes API. The
+// selectors are the label selector requirements followed by an empty string and the field selector requirements. The
+// selectors only apply to list queries. The input context is used for the API queries and its span gets the attributes
+// of the query.
+func (t *TemplateResolver) getOrListRaw(
+	ctx context.Context,
+	options *ResolveOptions,
+	apiVersion string,
+	kind string,
+	namespace string,
+	name string,
+	labelSelector ...string,
+) (
+	object map[string]interface{}, err error,
+) {
+	if apiVersion == "" || kind == "" {
+		return nil, errors.New("the apiVersion and kind are required")
 	}
 
 	gv, err := schema.ParseGroupVersion(apiVersion)
@@ -83,6 +251,20 @@ func (t *TemplateResolver) getOrList(
 		Kind:    kind,
 	}
 
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attrGroup.String(gvk.Group), attrVersion.String(gvk.Version))
+
+	if err := checkAllowedGVK(options, gvk); err != nil {
+		return nil, err
+	}
+
+	labelSelector, fieldSelector := splitSelectors(labelSelector)
+
+	parsedFieldSelector, err := fields.ParseSelector(strings.Join(fieldSelector, ","))
+	if err != nil {
+		return nil, fmt.Errorf("%w: the field selector is invalid: %w", ErrInvalidInput, err)
+	}
+
 	parsedSelector := labels.NewSelector()
 	// If labelSelector is defined, and is not an empty string, then add the labels to the listOptions
 	// Note there can be multiple values passed to labelSelector so we need to treat it as an array
@@ -96,47 +278,103 @@ func (t *TemplateResolver) getOrList(
 		}
 	}
 
-	var scopedGVRObj client.ScopedGVR
-	if t.dynamicWatcher != nil {
-		scopedGVRObj, err = t.dynamicWatcher.GVKToGVR(gvk)
-	} else {
-		scopedGVRObj, err = t.tempCallCache.GVKToGVR(gvk)
-	}
-
+	scopedGVRObj, err := t.getScopedGVR(gvk)
 	if err != nil {
-		if errors.Is(err, client.ErrNoVersionedResource) {
-			return nil, ErrMissingAPIResource
-		}
-
 		return nil, err
 	}
 
-	if !scopedGVRObj.Namespaced && options.LookupNamespace != "" {
+	if !scopedGVRObj.Namespaced && len(options.lookupNamespaces()) != 0 {
 		rsrcIdentifier := ClusterScopedObjectIdentifier{
 			Group: scopedGVRObj.Group,
 			Kind:  kind,
 			Name:  name,
 		}
-		if !onAllowlist(options.ClusterScopedAllowList, rsrcIdentifier) {
-			return nil, ClusterScopedLookupRestrictedError{kind, name}
+		if !isClusterScopedAllowed(options, rsrcIdentifier) {
+			return nil, ClusterScopedLookupRestrictedError{Group: scopedGVRObj.Group, Kind: kind, Name: name}
+		}
+	}
+
+	var ns string
+
+	// The namespace is not relevant to cluster-scoped resources unless it was explicitly provided
+	if scopedGVRObj.Namespaced || namespace != "" {
+		ns, err = t.getNamespace(namespace, options.lookupNamespaces()...)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	if err := t.reviewLookup(options, scopedGVRObj.GroupVersionResource, ns, name, ""); err != nil {
+		return nil, err
+	}
+
+	queryID := client.ObjectIdentifier{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: ns,
+		Name:      name,
+		Selector:  parsedSelector.String(),
+	}
+
+	span.SetAttributes(attrNamespace.String(ns))
+	options.addReferencedObject(queryID)
+
+	defer func() {
+		if isMissingObject(object, err) {
+			options.addMissingObject(queryID)
+		}
+	}()
+	t.metrics.observeLookup(gvk)
+
+	stats := options.lookupStats()
+	stats.Lookups++
+
 	if t.dynamicWatcher != nil {
+		stats.CacheHits++
+
+		// Pin the objects to the first version seen in the resolution since the watch cache may be updated meanwhile
+		snapshot := &objectSnapshot{}
+		if options.state != nil {
+			snapshot = &options.state.snapshot
+		}
+
 		if name == "" {
-			result, err := t.dynamicWatcher.List(*options.Watcher, gvk, ns, parsedSelector)
-			if err != nil {
-				return nil, err
+			result, pinned := snapshot.lists[queryID]
+			if !pinned {
+				// Only the first query in the resolution is counted since it may add a watch
+				if err := options.countLookup(); err != nil {
+					return nil, err
+				}
+
+				result, err = t.dynamicWatcher.List(*options.Watcher, gvk, ns, parsedSelector)
+				if err != nil {
+					return nil, wrapAPIUnavailableError(gvk, t.watcherError(gvk, err))
+				}
+
+				result = snapshot.pinList(queryID, result)
 			}
 
+			// The watches only support label selectors, so the field selector is applied to the cached objects
+			result = filterByFieldSelector(result, parsedFieldSelector)
+
 			resultList := unstructured.UnstructuredList{Items: result}
 
 			return resultList.UnstructuredContent(), nil
 		}
 
-		result, err := t.dynamicWatcher.Get(*options.Watcher, gvk, ns, name)
-		if err != nil {
-			return nil, err
+		result, pinned := snapshot.get(queryID)
+		if !pinned {
+			if err := options.countLookup(); err != nil {
+				return nil, err
+			}
+
+			result, err = t.dynamicWatcher.Get(*options.Watcher, gvk, ns, name)
+			if err != nil {
+				return nil, wrapAPIUnavailableError(gvk, t.watcherError(gvk, err))
+			}
+
+			result = snapshot.pinGet(queryID, result)
 		}
 
 		if result == nil {
@@ -147,21 +385,32 @@ func (t *TemplateResolver) getOrList(
 	}
 
 	// The dynamic watcher is not used, so use the temporary call cache
-	lookupID := client.ObjectIdentifier{
-		Group:     gvk.Group,
-		Version:   gvk.Version,
-		Kind:      gvk.Kind,
-		Namespace: ns,
-		Name:      name,
-		Selector:  parsedSelector.String(),
+	lookupID := queryID
+
+	if !parsedFieldSelector.Empty() {
+		// The temporary call cache is only keyed by the object identifier, so include the field selector in it
+		lookupID.Selector += ";fields:" + parsedFieldSelector.String()
 	}
 
-	cachedResults, err := t.tempCallCache.FromObjectIdentifier(lookupID)
+	if options.Impersonate != nil {
+		// The impersonated identities may not be allowed to read the same objects, so they don't share cache entries
+		lookupID.Selector += ";impersonate:" + impersonationKey(options.Impersonate)
+	}
+
+	cachedResults, err := t.lookupCache.Get(lookupID)
 	if err != nil {
 		if !errors.Is(err, client.ErrNoCacheEntry) {
 			return nil, err
 		}
+
+		t.metrics.observeTempCallCache(false)
+		span.SetAttributes(attrCacheHit.Bool(false))
 	} else {
+		t.metrics.observeTempCallCache(true)
+		span.SetAttributes(attrCacheHit.Bool(true))
+		stats.CacheHits++
+		options.tempCallCacheLRU().touch(lookupID)
+
 		// Check if this is a Get or List query
 		if name != "" {
 			if len(cachedResults) > 0 {
@@ -177,48 +426,215 @@ func (t *TemplateResolver) getOrList(
 	}
 
 	// It's not cached so it must be retrieved using the dynamic client and then cached
+	if err := options.countLookup(); err != nil {
+		return nil, err
+	}
+
+	stats.APICalls++
+
+	dynamicClient, _, err := t.clientsFor(options)
+	if err != nil {
+		return nil, err
+	}
 
 	var dynamciClientRes dynamic.ResourceInterface
 
 	if scopedGVRObj.Namespaced && ns != "" {
-		dynamciClientRes = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(ns)
+		dynamciClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(ns)
 	} else {
-		dynamciClientRes = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+		dynamciClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
 	}
 
 	if name == "" {
 		resultUnstructuredList, err := dynamciClientRes.List(
-			context.TODO(), metav1.ListOptions{LabelSelector: parsedSelector.String()},
+			ctx,
+			metav1.ListOptions{LabelSelector: parsedSelector.String(), FieldSelector: parsedFieldSelector.String()},
 		)
 		if err != nil {
-			return nil, err
+			// A list query is only not found if the API resource is no longer served, so rediscover it next time
+			if apierrors.IsNotFound(err) {
+				t.discoveryCache.invalidate(gvk)
+			}
+
+			return nil, wrapAPIUnavailableError(gvk, err)
 		}
 
-		t.tempCallCache.CacheFromObjectIdentifier(lookupID, resultUnstructuredList.Items)
+		t.cacheTempCallResult(options, lookupID, resultUnstructuredList.Items)
 
 		// Strip out the other metadata to match what is returned from the cache
 		resultUnstructuredList = &unstructured.UnstructuredList{Items: resultUnstructuredList.Items}
+		stats.addBytesFetched(resultUnstructuredList.UnstructuredContent())
 
 		return resultUnstructuredList.UnstructuredContent(), nil
 	}
 
-	resultUnstructured, err := dynamciClientRes.Get(context.TODO(), name, metav1.GetOptions{})
+	resultUnstructured, err := dynamciClientRes.Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
-		t.tempCallCache.CacheFromObjectIdentifier(lookupID, []unstructured.Unstructured{*resultUnstructured})
+		t.cacheTempCallResult(options, lookupID, []unstructured.Unstructured{*resultUnstructured})
 	}
 
 	if err != nil {
 		// Cache a not found result
-		if apierrors.IsNotFound(err) {
-			t.tempCallCache.CacheFromObjectIdentifier(lookupID, []unstructured.Unstructured{})
+		if apierrors.IsNotFound(err) && !t.config.DisableNotFoundCache {
+			t.cacheTempCallResult(options, lookupID, []unstructured.Unstructured{})
 		}
 
-		return nil, err
+		return nil, wrapAPIUnavailableError(gvk, err)
 	}
 
+	stats.addBytesFetched(resultUnstructured.UnstructuredContent())
+
 	return resultUnstructured.UnstructuredContent(), nil
 }
 
+// isMissingObject returns whether a lookup returned no objects, which is an object that was not found or a list with no
+// items.
+func isMissingObject(object map[string]interface{}, err error) bool {
+	if err != nil {
+		return apierrors.IsNotFound(err)
+	}
+
+	if object == nil {
+		return true
+	}
+
+	items, isList, _ := unstructured.NestedSlice(object, "items")
+
+	return isList && len(items) == 0
+}
+
+// splitSelectors splits the selector arguments of lookup into the label selector arguments before the first empty
+// string (after the first argument) and the field selector arguments after it. For backwards compatibility, a leading
+// empty string means there is no label selector.
+func splitSelectors(selectors []string) (labelSelector []string, fieldSelector []string) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	if selectors[0] == "" {
+		return nil, selectors[1:]
+	}
+
+	for i, selector := range selectors {
+		if selector == "" {
+			return selectors[:i], selectors[i+1:]
+		}
+	}
+
+	return selectors, nil
+}
+
+// objectFields implements fields.Fields for an unstructured object using dot separated field paths.
+type objectFields map[string]interface{}
+
+func (o objectFields) Has(field string) bool {
+	_, found := getFieldByPath(map[string]interface{}(o), field)
+
+	return found
+}
+
+func (o objectFields) Get(field string) string {
+	value, found := getFieldByPath(map[string]interface{}(o), field)
+	if !found || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// filterByFieldSelector returns the objects that match the field selector.
+func filterByFieldSelector(objects []unstructured.Unstructured, selector fields.Selector) []unstructured.Unstructured {
+	if selector.Empty() {
+		return objects
+	}
+
+	filtered := make([]unstructured.Unstructured, 0, len(objects))
+
+	for _, obj := range objects {
+		if selector.Matches(objectFields(obj.Object)) {
+			filtered = append(filtered, obj)
+		}
+	}
+
+	return filtered
+}
+
+// getScopedGVR converts the GVK to a GVR with the resolver's discovery cache, falling back to the API discovery cache
+// of the current caching mode. If the API resource is not found, the API discovery is retried once without caching in
+// case the API resource was installed after the failure was cached. ErrMissingAPIResource is returned if the API
+// resource is not installed and an error wrapping ErrAPIUnavailable is returned if the API server serving the API
+// resource is unavailable.
+func (t *TemplateResolver) getScopedGVR(gvk schema.GroupVersionKind) (client.ScopedGVR, error) {
+	if scopedGVRObj, ok := t.discoveryCache.get(gvk); ok {
+		return scopedGVRObj, nil
+	}
+
+	var scopedGVRObj client.ScopedGVR
+	var err error
+
+	if t.dynamicWatcher != nil {
+		scopedGVRObj, err = t.dynamicWatcher.GVKToGVR(gvk)
+	} else {
+		scopedGVRObj, err = t.tempCallCache.GVKToGVR(gvk)
+	}
+
+	if errors.Is(err, client.ErrNoVersionedResource) {
+		t.log.V(2).Info("Retrying the API discovery of the missing API resource", "gvk", gvk)
+
+		scopedGVRObj, err = t.discoveryCache.discover(gvk)
+	}
+
+	if err != nil {
+		if errors.Is(err, client.ErrNoVersionedResource) {
+			return scopedGVRObj, ErrMissingAPIResource
+		}
+
+		return scopedGVRObj, wrapAPIUnavailableError(gvk, err)
+	}
+
+	t.discoveryCache.set(gvk, scopedGVRObj)
+
+	return scopedGVRObj, nil
+}
+
+// watcherError converts the input error of a lookup with the dynamic watcher. If it indicates that the API resource is
+// no longer installed, such as when a CRD was deleted, the cached GVK to GVR conversion is invalidated and
+// ErrMissingAPIResource is returned. Otherwise, the input error is wrapped so that it also matches ErrWatcherFailed.
+func (t *TemplateResolver) watcherError(gvk schema.GroupVersionKind, err error) error {
+	if !errors.Is(err, client.ErrNoVersionedResource) {
+		return classifyError(ErrWatcherFailed, err)
+	}
+
+	t.discoveryCache.invalidate(gvk)
+
+	return ErrMissingAPIResource
+}
+
+// wrapAPIUnavailableError wraps the input error with ErrAPIUnavailable if the API server responded that the service is
+// unavailable. This is typically the case when the API is served by an aggregated API server (e.g. metrics.k8s.io)
+// that is down or whose APIService is not available.
+func wrapAPIUnavailableError(gvk schema.GroupVersionKind, err error) error {
+	if !apierrors.IsServiceUnavailable(err) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s: %w", ErrAPIUnavailable, gvk.GroupVersion().String(), err)
+}
+
+// cacheTempCallResult caches the objects in the temporary call cache and evicts the least recently used entries if
+// options.TempCallCacheMaxEntries is exceeded.
+func (t *TemplateResolver) cacheTempCallResult(
+	options *ResolveOptions, lookupID client.ObjectIdentifier, objects []unstructured.Unstructured,
+) {
+	t.lookupCache.Set(lookupID, objects)
+
+	for _, evictedID := range options.tempCallCacheLRU().add(lookupID) {
+		t.logger(options).V(2).Info("Evicting the least recently used temporary cache entry", "entry", evictedID)
+
+		t.lookupCache.Invalidate(evictedID)
+	}
+}
+
 func (t *TemplateResolver) lookupHelper(
 	options *ResolveOptions,
 ) func(string, string, string, string, ...string) (map[string]interface{}, error) {
@@ -243,7 +659,7 @@ func (t *TemplateResolver) lookup(
 ) (
 	map[string]interface{}, error,
 ) {
-	klog.V(2).Infof("lookup :  %v, %v, %v, %v", apiVersion, kind, namespace, name)
+	t.logger(options).V(2).Info("lookup", "apiVersion", apiVersion, "kind", kind, "namespace", namespace, "name", name)
 
 	result, lookupErr := t.getOrList(options, apiVersion, kind, namespace, name, labelSelector...)
 
@@ -252,29 +668,459 @@ func (t *TemplateResolver) lookup(
 		lookupErr = nil
 	}
 
-	klog.V(2).Infof("lookup result:  %v", result)
+	if log := t.logger(options).V(2); log.Enabled() {
+		log.Info("lookup result", "result", options.state.redact(options, fmt.Sprint(result)))
+	}
 
 	return result, lookupErr
 }
 
-func onAllowlist(allowlist []ClusterScopedObjectIdentifier, rsrc ClusterScopedObjectIdentifier) bool {
-	if len(allowlist) == 0 {
-		return false
+func (t *TemplateResolver) lookupAllHelper(
+	options *ResolveOptions,
+) func(string, string, string, ...string) (map[string]interface{}, error) {
+	return func(
+		apiVersion string, kind string, namespaceSelector string, labelSelector ...string,
+	) (map[string]interface{}, error) {
+		return t.lookupAll(options, apiVersion, kind, namespaceSelector, labelSelector...)
 	}
+}
 
-	for _, item := range allowlist {
-		if item.Group != "*" && item.Group != rsrc.Group {
+// lookupAll lists the namespaced objects of the input kind in all namespaces matching the namespace label selector and
+// aggregates them in a single list. An empty namespace selector matches all namespaces. When the lookups are restricted
+// to namespaces, only those namespaces are considered and the Namespace objects must be in the ClusterScopedAllowList
+// to use a namespace selector.
+func (t *TemplateResolver) lookupAll(
+	options *ResolveOptions, apiVersion string, kind string, namespaceSelector string, labelSelector ...string,
+) (map[string]interface{}, error) {
+	t.logger(options).V(2).Info(
+		"lookupAll", "apiVersion", apiVersion, "kind", kind, "namespaceSelector", namespaceSelector,
+		"labelSelector", labelSelector,
+	)
+
+	if apiVersion == "" || kind == "" {
+		return nil, errors.New("the apiVersion and kind are required")
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	scopedGVRObj, err := t.getScopedGVR(gv.WithKind(kind))
+	if err != nil {
+		return nil, err
+	}
+
+	if !scopedGVRObj.Namespaced {
+		return nil, fmt.Errorf("%w: lookupAll only supports namespaced kinds but got %s", ErrInvalidInput, kind)
+	}
+
+	namespaces := options.lookupNamespaces()
+
+	if len(namespaces) == 0 || namespaceSelector != "" {
+		nsResult, err := t.getOrList(options, "v1", "Namespace", "", "", namespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		items, _, _ := unstructured.NestedSlice(nsResult, "items")
+		matchingNamespaces := make([]string, 0, len(items))
+
+		for _, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				nsName := (&unstructured.Unstructured{Object: obj}).GetName()
+
+				if len(namespaces) == 0 || slices.Contains(namespaces, nsName) {
+					matchingNamespaces = append(matchingNamespaces, nsName)
+				}
+			}
+		}
+
+		namespaces = matchingNamespaces
+	}
+
+	sort.Strings(namespaces)
+
+	aggregated := []interface{}{}
+
+	for _, namespace := range namespaces {
+		result, err := t.getOrList(options, apiVersion, kind, namespace, "", labelSelector...)
+		if err != nil {
+			return nil, err
+		}
+
+		items, _, _ := unstructured.NestedSlice(result, "items")
+		aggregated = append(aggregated, items...)
+	}
+
+	return map[string]interface{}{"items": aggregated}, nil
+}
+
+func (t *TemplateResolver) lookupAnyHelper(
+	options *ResolveOptions,
+) func(interface{}, string, string, string) (map[string]interface{}, error) {
+	return func(
+		candidates interface{}, kind string, namespace string, name string,
+	) (map[string]interface{}, error) {
+		return t.lookupAny(options, candidates, kind, namespace, name)
+	}
+}
+
+// lookupAny performs a lookup with each of the candidate API versions in order and returns the first result. A
+// candidate whose API resource is not installed or whose object is not found is skipped. Any other error is returned
+// immediately. If none of the candidate API resources are installed, ErrMissingAPIResource is returned.
+func (t *TemplateResolver) lookupAny(
+	options *ResolveOptions, candidates interface{}, kind string, namespace string, name string,
+) (map[string]interface{}, error) {
+	apiVersions, err := cast.ToStringSliceE(candidates)
+	if err != nil || len(apiVersions) == 0 {
+		return nil, fmt.Errorf("%w: the candidate apiVersions must be a non-empty list of strings", ErrInvalidInput)
+	}
+
+	t.logger(options).V(2).Info(
+		"lookupAny", "apiVersions", apiVersions, "kind", kind, "namespace", namespace, "name", name,
+	)
+
+	apiResourceFound := false
+
+	for _, apiVersion := range apiVersions {
+		result, err := t.getOrList(options, apiVersion, kind, namespace, name)
+		if err != nil {
+			if errors.Is(err, ErrMissingAPIResource) {
+				t.logger(options).V(2).Info(
+					"lookupAny skipping the uninstalled API resource", "apiVersion", apiVersion, "kind", kind,
+				)
+
+				continue
+			}
+
+			if apierrors.IsNotFound(err) {
+				apiResourceFound = true
+
+				continue
+			}
+
+			return nil, err
+		}
+
+		apiResourceFound = true
+
+		// A cached not found result is returned as nil
+		if result != nil {
+			return result, nil
+		}
+	}
+
+	if !apiResourceFound {
+		return nil, ErrMissingAPIResource
+	}
+
+	return nil, nil
+}
+
+func (t *TemplateResolver) getDefaultHelper(
+	options *ResolveOptions,
+) func(string, string) (map[string]interface{}, error) {
+	return func(apiVersion string, kind string) (map[string]interface{}, error) {
+		return t.getDefault(options, apiVersion, kind)
+	}
+}
+
+// getDefault lists all the objects of the input kind and returns the one marked as the default with one of the
+// conventional annotations (e.g. storageclass.kubernetes.io/is-default-class: "true"). If none are marked as the
+// default, nil is returned. If multiple are marked as the default, an error wrapping ErrMultipleDefaults is returned.
+func (t *TemplateResolver) getDefault(
+	options *ResolveOptions, apiVersion string, kind string,
+) (map[string]interface{}, error) {
+	t.logger(options).V(2).Info("getDefault", "apiVersion", apiVersion, "kind", kind)
+
+	result, err := t.getOrList(options, apiVersion, kind, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	items, _, _ := unstructured.NestedSlice(result, "items")
+
+	return defaultFromList(items)
+}
+
+// defaultFromList returns the object in the input list marked as the default with one of the defaultAnnotations.
+func defaultFromList(items []interface{}) (map[string]interface{}, error) {
+	var defaultObj map[string]interface{}
+
+	defaultNames := []string{}
+
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
 			continue
 		}
 
-		if item.Kind != "*" && item.Kind != rsrc.Kind {
+		annotations := (&unstructured.Unstructured{Object: obj}).GetAnnotations()
+
+		for _, annotation := range defaultAnnotations {
+			if annotations[annotation] == "true" {
+				defaultObj = obj
+				defaultNames = append(defaultNames, (&unstructured.Unstructured{Object: obj}).GetName())
+
+				break
+			}
+		}
+	}
+
+	if len(defaultNames) > 1 {
+		return nil, fmt.Errorf("%w: %s", ErrMultipleDefaults, strings.Join(defaultNames, ", "))
+	}
+
+	return defaultObj, nil
+}
+
+func (t *TemplateResolver) existingNamesHelper(
+	options *ResolveOptions,
+) func(string, string, string, interface{}) ([]string, error) {
+	return func(apiVersion string, kind string, namespace string, names interface{}) ([]string, error) {
+		return t.existingNames(options, apiVersion, kind, namespace, names)
+	}
+}
+
+// existingNames returns the sorted subset of the input names of objects of the input kind that exist in the namespace.
+// This is determined with a single list query rather than a lookup per name. Like lookup, ErrMissingAPIResource is
+// returned if the API resource is not installed.
+func (t *TemplateResolver) existingNames(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, names interface{},
+) ([]string, error) {
+	desiredNames, err := cast.ToStringSliceE(names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the names must be a list of strings", ErrInvalidInput)
+	}
+
+	t.logger(options).V(2).Info(
+		"existingNames", "apiVersion", apiVersion, "kind", kind, "namespace", namespace, "names", desiredNames,
+	)
+
+	if len(desiredNames) == 0 {
+		return []string{}, nil
+	}
+
+	result, err := t.getOrList(options, apiVersion, kind, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	items, _, _ := unstructured.NestedSlice(result, "items")
+
+	return intersectNames(items, desiredNames), nil
+}
+
+// intersectNames returns the sorted and deduplicated names in the input list that are names of objects in items.
+func intersectNames(items []interface{}, names []string) []string {
+	existing := make(map[string]bool, len(items))
+
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
 			continue
 		}
 
-		if item.Name == "*" || item.Name == rsrc.Name {
+		existing[(&unstructured.Unstructured{Object: obj}).GetName()] = true
+	}
+
+	intersection := []string{}
+
+	for _, name := range names {
+		if existing[name] {
+			intersection = append(intersection, name)
+			// Prevent duplicates in the output
+			existing[name] = false
+		}
+	}
+
+	sort.Strings(intersection)
+
+	return intersection
+}
+
+func (t *TemplateResolver) getObjectsByOwnerHelper(
+	options *ResolveOptions,
+) func(string, string, string, interface{}) (map[string]interface{}, error) {
+	return func(apiVersion string, kind string, namespace string, owners interface{}) (map[string]interface{}, error) {
+		return t.getObjectsByOwner(options, apiVersion, kind, namespace, owners)
+	}
+}
+
+// getObjectsByOwner lists the objects of the input kind in the namespace and returns the list of those with an owner
+// reference whose UID or name is one of the input owners. The owners can be a single string or a list of strings. Like
+// lookup, ErrMissingAPIResource is returned if the API resource is not installed.
+func (t *TemplateResolver) getObjectsByOwner(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, owners interface{},
+) (map[string]interface{}, error) {
+	ownerList, err := cast.ToStringSliceE(owners)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the owners must be a string or a list of strings", ErrInvalidInput)
+	}
+
+	t.logger(options).V(2).Info(
+		"getObjectsByOwner", "apiVersion", apiVersion, "kind", kind, "namespace", namespace, "owners", ownerList,
+	)
+
+	result, err := t.getOrList(options, apiVersion, kind, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	items, _, _ := unstructured.NestedSlice(result, "items")
+	owned := []interface{}{}
+
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, ownerRef := range (&unstructured.Unstructured{Object: obj}).GetOwnerReferences() {
+			if slices.Contains(ownerList, string(ownerRef.UID)) || slices.Contains(ownerList, ownerRef.Name) {
+				owned = append(owned, obj)
+
+				break
+			}
+		}
+	}
+
+	return map[string]interface{}{"items": owned}, nil
+}
+
+// resourceFor returns the plural resource name (e.g. networkpolicies) of the input kind using API discovery.
+// ErrMissingAPIResource is returned if the API resource is not installed.
+func (t *TemplateResolver) resourceFor(apiVersion string, kind string) (string, error) {
+	t.log.V(2).Info("resourceFor", "apiVersion", apiVersion, "kind", kind)
+
+	if apiVersion == "" || kind == "" {
+		return "", errors.New("the apiVersion and kind are required")
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return "", err
+	}
+
+	scopedGVRObj, err := t.getScopedGVR(gv.WithKind(kind))
+	if err != nil {
+		return "", err
+	}
+
+	return scopedGVRObj.Resource, nil
+}
+
+// apiResourceExists returns whether the API resource of the input kind is installed using API discovery, such as
+// `{{ if apiResourceExists "monitoring.coreos.com/v1" "ServiceMonitor" }}` to only create a ServiceMonitor object if
+// the Prometheus Operator CRDs are installed. Unlike lookup, this doesn't fail with ErrMissingAPIResource.
+func (t *TemplateResolver) apiResourceExists(apiVersion string, kind string) (bool, error) {
+	t.log.V(2).Info("apiResourceExists", "apiVersion", apiVersion, "kind", kind)
+
+	if apiVersion == "" || kind == "" {
+		return false, errors.New("the apiVersion and kind are required")
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = t.getScopedGVR(gv.WithKind(kind))
+	if err != nil {
+		if errors.Is(err, ErrMissingAPIResource) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// kubeVersion returns the Kubernetes version of the API server (e.g. v1.28.3) from the /version endpoint, which can be
+// compared with the semverCompare template function. The version is cached like the API discovery.
+func (t *TemplateResolver) kubeVersion() (string, error) {
+	return t.discoveryCache.serverVersion()
+}
+
+func onAllowlist(allowlist []ClusterScopedObjectIdentifier, rsrc ClusterScopedObjectIdentifier) bool {
+	for _, item := range allowlist {
+		if item.matches(rsrc) {
 			return true
 		}
 	}
 
 	return false
 }
+
+// isClusterScopedAllowed returns true if the cluster-scoped resource is on options.ClusterScopedAllowList and not on
+// options.ClusterScopedDenyList.
+func isClusterScopedAllowed(options *ResolveOptions, rsrc ClusterScopedObjectIdentifier) bool {
+	return onAllowlist(options.ClusterScopedAllowList, rsrc) && !onAllowlist(options.ClusterScopedDenyList, rsrc)
+}
+
+// matches returns true if each field of the identifier, which may be a pattern, matches the field of the resource.
+func (c ClusterScopedObjectIdentifier) matches(rsrc ClusterScopedObjectIdentifier) bool {
+	return matchesPattern(c.Group, rsrc.Group) && matchesPattern(c.Kind, rsrc.Kind) && matchesPattern(c.Name, rsrc.Name)
+}
+
+// matchesPattern returns true if the value is equal to the pattern or starts with the prefix of a pattern ending with
+// `*`, such as `kube-*`. The pattern `*` matches any value.
+func matchesPattern(pattern string, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+
+	return pattern == value
+}
+
+// filterDeniedObjects removes the cluster-scoped objects on options.ClusterScopedDenyList from the result of a list
+// query when the lookups are restricted to namespaces. For example, this allows listing all Nodes except the control
+// plane Nodes. The input result is not modified since it may be cached.
+func filterDeniedObjects(
+	options *ResolveOptions, apiVersion string, result map[string]interface{},
+) map[string]interface{} {
+	if len(options.lookupNamespaces()) == 0 || len(options.ClusterScopedDenyList) == 0 {
+		return result
+	}
+
+	items, ok := result["items"].([]interface{})
+	if !ok {
+		return result
+	}
+
+	group := ""
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+		group = gv.Group
+	}
+
+	kept := make([]interface{}, 0, len(items))
+
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if ok {
+			objMeta := unstructured.Unstructured{Object: obj}
+
+			rsrc := ClusterScopedObjectIdentifier{Group: group, Kind: objMeta.GetKind(), Name: objMeta.GetName()}
+			if objMeta.GetNamespace() == "" && onAllowlist(options.ClusterScopedDenyList, rsrc) {
+				continue
+			}
+		}
+
+		kept = append(kept, item)
+	}
+
+	if len(kept) == len(items) {
+		return result
+	}
+
+	filtered := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		filtered[key] = value
+	}
+
+	filtered["items"] = kept
+
+	return filtered
+}
@@ -5,10 +5,18 @@ package templates
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 )
 
 func TestLookup(t *testing.T) {
@@ -279,7 +287,7 @@ func TestLookupWithLabels(t *testing.T) {
 func TestLookupClusterScoped(t *testing.T) {
 	t.Parallel()
 
-	clusterScopedErr := ClusterScopedLookupRestrictedError{"Node", "foo"}
+	clusterScopedErr := ClusterScopedLookupRestrictedError{Kind: "Node", Name: "foo"}
 
 	testcases := []struct {
 		inputNs         string
@@ -388,3 +396,901 @@ func TestLookupClusterScoped(t *testing.T) {
 		}
 	}
 }
+
+func TestGetDefault(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// No StorageClass objects exist in the test environment
+	val, err := resolver.getDefault(&ResolveOptions{}, "storage.k8s.io/v1", "StorageClass")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if val != nil {
+		t.Fatalf("Expected no default StorageClass but got: %v", val)
+	}
+
+	_, err = resolver.getDefault(
+		&ResolveOptions{LookupNamespace: "policies-ns"}, "storage.k8s.io/v1", "StorageClass",
+	)
+	if !errors.As(err, &ClusterScopedLookupRestrictedError{}) {
+		t.Fatalf("Expected ClusterScopedLookupRestrictedError error but got %v", err)
+	}
+}
+
+func TestMatchesPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"kube-*", "kube-system", true},
+		{"kube-*", "kube-", true},
+		{"kube-*", "my-kube-system", false},
+		{"foo", "foo", true},
+		{"foo", "foobar", false},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		if actual := matchesPattern(test.pattern, test.value); actual != test.expected {
+			t.Fatalf("Expected matchesPattern(%q, %q) to be %v", test.pattern, test.value, test.expected)
+		}
+	}
+}
+
+func TestClusterScopedDenyList(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{}
+
+	for _, name := range []string{"worker-1", "control-plane-1", "control-plane-2"} {
+		objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata":   map[string]interface{}{"name": name},
+		}})
+	}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	allowAllNodes := []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "*"}}
+	denyControlPlane := []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "control-plane-*"}}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		allowList      []ClusterScopedObjectIdentifier
+		denyList       []ClusterScopedObjectIdentifier
+		expectedResult string
+		expectedErr    error
+	}{
+		"allowed by the allow list": {
+			inputTmpl:      `value: '{{ (lookup "v1" "Node" "" "worker-1").metadata.name }}'`,
+			allowList:      allowAllNodes,
+			denyList:       denyControlPlane,
+			expectedResult: `{"value":"worker-1"}`,
+		},
+		"denied by the deny list": {
+			inputTmpl:   `value: '{{ (lookup "v1" "Node" "" "control-plane-1").metadata.name }}'`,
+			allowList:   allowAllNodes,
+			denyList:    denyControlPlane,
+			expectedErr: ErrClusterScopedLookupRestricted,
+		},
+		"denied objects removed from list": {
+			inputTmpl: `value: '{{ range (lookup "v1" "Node" "" "").items }}{{ .metadata.name }},{{ end }}'`,
+			allowList: allowAllNodes,
+			denyList:  denyControlPlane,
+			// The list query itself is allowed since only specific names are denied
+			expectedResult: `{"value":"worker-1,"}`,
+		},
+		"prefix in the allow list": {
+			inputTmpl:      `value: '{{ (lookup "v1" "Node" "" "control-plane-2").metadata.name }}'`,
+			allowList:      []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "control-*"}},
+			expectedResult: `{"value":"control-plane-2"}`,
+		},
+		"prefix not in the allow list": {
+			inputTmpl:   `value: '{{ (lookup "v1" "Node" "" "worker-1").metadata.name }}'`,
+			allowList:   []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "control-*"}},
+			expectedErr: ErrClusterScopedLookupRestricted,
+		},
+		"deny list of all Nodes": {
+			inputTmpl:   `value: '{{ (lookup "v1" "Node" "" "worker-1").metadata.name }}'`,
+			allowList:   []ClusterScopedObjectIdentifier{{Group: "*", Kind: "*", Name: "*"}},
+			denyList:    []ClusterScopedObjectIdentifier{{Group: "", Kind: "Node", Name: "*"}},
+			expectedErr: ErrClusterScopedLookupRestricted,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &ResolveOptions{
+				LookupNamespace:        "app",
+				ClusterScopedAllowList: test.allowList,
+				ClusterScopedDenyList:  test.denyList,
+			})
+
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected an error matching %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestAllowedGVKs(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "cm", "namespace": "app"},
+			"data":       map[string]interface{}{"key": "value"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "secret", "namespace": "app"},
+			"stringData": map[string]interface{}{"key": "value"},
+		}},
+	}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	configMapsOnly := []schema.GroupVersionKind{{Version: "v1", Kind: "ConfigMap"}}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		allowedGVKs    []schema.GroupVersionKind
+		expectedResult string
+		expectedErr    *KindLookupRestrictedError
+	}{
+		"allowed kind": {
+			inputTmpl:      `value: '{{ fromConfigMap "app" "cm" "key" }}'`,
+			allowedGVKs:    configMapsOnly,
+			expectedResult: `{"value":"value"}`,
+		},
+		"disallowed kind": {
+			inputTmpl:   `value: '{{ fromSecret "app" "secret" "key" }}'`,
+			allowedGVKs: configMapsOnly,
+			expectedErr: &KindLookupRestrictedError{
+				GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "Secret"},
+			},
+		},
+		"disallowed kind with canLookup": {
+			inputTmpl:      `value: '{{ canLookup "v1" "Secret" "app" "get" }}'`,
+			allowedGVKs:    configMapsOnly,
+			expectedResult: `{"value":"false"}`,
+		},
+		"wildcard": {
+			inputTmpl:      `value: '{{ (lookup "v1" "Secret" "app" "secret").metadata.name }}'`,
+			allowedGVKs:    []schema.GroupVersionKind{{Group: "", Version: "*", Kind: "*"}},
+			expectedResult: `{"value":"secret"}`,
+		},
+		"no restrictions": {
+			inputTmpl:      `value: '{{ (lookup "v1" "Secret" "app" "secret").metadata.name }}'`,
+			expectedResult: `{"value":"secret"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{AllowedGVKs: test.allowedGVKs},
+			)
+
+			if test.expectedErr != nil {
+				var restrictedErr KindLookupRestrictedError
+				if !errors.As(err, &restrictedErr) || !errors.Is(err, ErrKindLookupRestricted) {
+					t.Fatalf("Expected a KindLookupRestrictedError but got: %v", err)
+				}
+
+				if restrictedErr != *test.expectedErr {
+					t.Fatalf("Expected %#v but got %#v", *test.expectedErr, restrictedErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestGetObjectsByOwner(t *testing.T) {
+	t.Parallel()
+
+	newObject := func(apiVersion, kind, name, uid, ownerKind, ownerName, ownerUID string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name, "namespace": "app", "uid": uid},
+		}}
+
+		if ownerName != "" {
+			obj.SetOwnerReferences([]metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: ownerKind, Name: ownerName, UID: types.UID(ownerUID)},
+			})
+		}
+
+		return obj
+	}
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{
+			newObject("apps/v1", "ReplicaSet", "web-1", "rs-1-uid", "Deployment", "web", "web-uid"),
+			newObject("apps/v1", "ReplicaSet", "web-2", "rs-2-uid", "Deployment", "web", "web-uid"),
+			newObject("apps/v1", "ReplicaSet", "db-1", "rs-3-uid", "Deployment", "db", "db-uid"),
+			newObject("v1", "Pod", "web-1-a", "pod-1-uid", "ReplicaSet", "web-1", "rs-1-uid"),
+			newObject("v1", "Pod", "web-2-a", "pod-2-uid", "ReplicaSet", "web-2", "rs-2-uid"),
+			newObject("v1", "Pod", "db-1-a", "pod-3-uid", "ReplicaSet", "db-1", "rs-3-uid"),
+			newObject("v1", "Pod", "standalone", "pod-4-uid", "", "", ""),
+		},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    error
+	}{
+		"owner UID": {
+			inputTmpl: `value: '{{ range (getObjectsByOwner "apps/v1" "ReplicaSet" "app" "web-uid").items }}` +
+				`{{ .metadata.name }},{{ end }}'`,
+			expectedResult: `{"value":"web-1,web-2,"}`,
+		},
+		"owner name": {
+			inputTmpl: `value: '{{ range (getObjectsByOwner "v1" "Pod" "app" "db-1").items }}` +
+				`{{ .metadata.name }},{{ end }}'`,
+			expectedResult: `{"value":"db-1-a,"}`,
+		},
+		"Pods of a Deployment": {
+			inputTmpl: `value: '{{ $rsNames := list }}` +
+				`{{ range (getObjectsByOwner "apps/v1" "ReplicaSet" "app" "web").items }}` +
+				`{{ $rsNames = append $rsNames .metadata.name }}{{ end }}` +
+				`{{ range (getObjectsByOwner "v1" "Pod" "app" $rsNames).items }}{{ .metadata.name }},{{ end }}'`,
+			expectedResult: `{"value":"web-1-a,web-2-a,"}`,
+		},
+		"no owned objects": {
+			inputTmpl:      `value: '{{ len (getObjectsByOwner "v1" "Pod" "app" "other").items }}'`,
+			expectedResult: `{"value":"0"}`,
+		},
+		"invalid owners": {
+			inputTmpl:   `value: '{{ getObjectsByOwner "v1" "Pod" "app" (dict "a" "b") }}'`,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{EnabledFunctionGroups: []string{"dicts", "lists"}},
+			)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected an error matching %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestClusterScopedLookupRestrictedErrorFields(t *testing.T) {
+	t.Parallel()
+
+	storageClass := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "storage.k8s.io/v1",
+		"kind":       "StorageClass",
+		"metadata":   map[string]interface{}{"name": "standard"},
+	}}
+
+	resolver, err := NewFakeResolver([]unstructured.Unstructured{storageClass}, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate(
+		[]byte(`value: '{{ (lookup "storage.k8s.io/v1" "StorageClass" "" "standard").metadata.name }}'`),
+		nil,
+		&ResolveOptions{LookupNamespace: "app"},
+	)
+
+	var restrictedErr ClusterScopedLookupRestrictedError
+	if !errors.As(err, &restrictedErr) {
+		t.Fatalf("Expected ClusterScopedLookupRestrictedError error but got %v", err)
+	}
+
+	expected := ClusterScopedLookupRestrictedError{Group: "storage.k8s.io", Kind: "StorageClass", Name: "standard"}
+	if restrictedErr != expected {
+		t.Fatalf("Expected %#v but got %#v", expected, restrictedErr)
+	}
+}
+
+func TestDefaultFromList(t *testing.T) {
+	t.Parallel()
+
+	newObj := func(name string, annotations map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name, "annotations": annotations},
+		}
+	}
+
+	standard := newObj("standard", map[string]interface{}{"storageclass.kubernetes.io/is-default-class": "true"})
+	fast := newObj("fast", map[string]interface{}{"storageclass.kubernetes.io/is-default-class": "false"})
+	nginx := newObj("nginx", map[string]interface{}{"ingressclass.kubernetes.io/is-default-class": "true"})
+
+	testcases := map[string]struct {
+		items        []interface{}
+		expectedName string
+		expectedErr  error
+	}{
+		"no items":         {nil, "", nil},
+		"no default":       {[]interface{}{fast}, "", nil},
+		"one default":      {[]interface{}{fast, standard}, "standard", nil},
+		"ingress class":    {[]interface{}{nginx}, "nginx", nil},
+		"multiple default": {[]interface{}{standard, fast, nginx}, "", ErrMultipleDefaults},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val, err := defaultFromList(test.items)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			name, _, _ := unstructured.NestedString(val, "metadata", "name")
+			if name != test.expectedName {
+				t.Fatalf("expected the default %q but got %q", test.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestLookupTrimManagedFields(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	val, err := resolver.lookup(&ResolveOptions{}, "v1", "ConfigMap", "testns", "testconfigmap")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, found, _ := unstructured.NestedSlice(val, "metadata", "managedFields"); !found {
+		t.Fatal("Expected the managedFields to be returned by default")
+	}
+
+	val, err = resolver.lookup(
+		&ResolveOptions{TrimManagedFields: true}, "v1", "ConfigMap", "testns", "testconfigmap",
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if _, found, _ := unstructured.NestedSlice(val, "metadata", "managedFields"); found {
+		t.Fatal("Expected the managedFields to be trimmed")
+	}
+
+	list, err := resolver.lookup(&ResolveOptions{TrimManagedFields: true}, "v1", "ConfigMap", "testns", "", "env=a")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	items, _, _ := unstructured.NestedSlice(list, "items")
+	if len(items) != 1 {
+		t.Fatalf("Expected one item but got %d", len(items))
+	}
+
+	item, _ := items[0].(map[string]interface{})
+	if _, found, _ := unstructured.NestedSlice(item, "metadata", "managedFields"); found {
+		t.Fatal("Expected the managedFields to be trimmed in the list")
+	}
+}
+
+func TestTrimManagedFields(t *testing.T) {
+	t.Parallel()
+
+	newObj := func() map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name": "my-obj",
+				"managedFields": []interface{}{
+					map[string]interface{}{"manager": "kubectl", "operation": "Apply"},
+					map[string]interface{}{"manager": "my-operator", "operation": "Update"},
+				},
+			},
+		}
+	}
+
+	testcases := map[string]struct {
+		preserved        []string
+		expectedManagers []string
+	}{
+		"blanket trim":         {nil, nil},
+		"preserve one":         {[]string{"my-operator"}, []string{"my-operator"}},
+		"preserve all":         {[]string{"kubectl", "my-operator"}, []string{"kubectl", "my-operator"}},
+		"preserve nonexistent": {[]string{"other"}, nil},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			obj := newObj()
+			trimManagedFields(obj, test.preserved)
+
+			managedFields, found, _ := unstructured.NestedSlice(obj, "metadata", "managedFields")
+			if len(test.expectedManagers) == 0 {
+				if found {
+					t.Fatalf("Expected no managedFields but got %v", managedFields)
+				}
+
+				return
+			}
+
+			managers := []string{}
+
+			for _, entry := range managedFields {
+				manager, _, _ := unstructured.NestedString(entry.(map[string]interface{}), "manager")
+				managers = append(managers, manager)
+			}
+
+			if !slices.Equal(managers, test.expectedManagers) {
+				t.Fatalf("Expected the managers %v but got %v", test.expectedManagers, managers)
+			}
+		})
+	}
+}
+
+func TestLookupAny(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		candidates     interface{}
+		inputName      string
+		expectedErr    error
+		expectedExists bool
+	}{
+		"first candidate":       {[]interface{}{"v1", "example.com/v1"}, "testconfigmap", nil, true},
+		"fallback candidate":    {[]interface{}{"example.com/v1", "v1"}, "testconfigmap", nil, true},
+		"string slice":          {[]string{"example.com/v1", "v1"}, "testconfigmap", nil, true},
+		"not found":             {[]interface{}{"example.com/v1", "v1"}, "idontexist", nil, false},
+		"no installed resource": {[]interface{}{"example.com/v1"}, "testconfigmap", ErrMissingAPIResource, false},
+		"no candidates":         {[]interface{}{}, "testconfigmap", ErrInvalidInput, false},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := resolver.lookupAny(&ResolveOptions{}, test.candidates, "ConfigMap", "testns", test.inputName)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected err: %v got err: %v", test.expectedErr, err)
+			}
+
+			if test.expectedExists != (len(val) != 0) {
+				t.Fatalf("expected the object to exist: %v, got: %v", test.expectedExists, val)
+			}
+		})
+	}
+}
+
+func TestLookupAggregatedAPI(t *testing.T) {
+	t.Parallel()
+
+	// Simulate an API server with a metrics.k8s.io aggregated API that is available and a custom.metrics.k8s.io
+	// aggregated API whose backing server is down.
+	responses := map[string]string{
+		"/apis/metrics.k8s.io/v1beta1": `{"kind": "APIResourceList", "apiVersion": "v1", ` +
+			`"groupVersion": "metrics.k8s.io/v1beta1", "resources": [` +
+			`{"name": "nodes", "singularName": "", "namespaced": false, "kind": "NodeMetrics", "verbs": ["get"]},` +
+			`{"name": "pods", "singularName": "", "namespaced": true, "kind": "PodMetrics", "verbs": ["get"]}]}`,
+		"/apis/metrics.k8s.io/v1beta1/nodes/node1": `{"kind": "NodeMetrics", ` +
+			`"apiVersion": "metrics.k8s.io/v1beta1", "metadata": {"name": "node1"}, "usage": {"cpu": "100m"}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			if strings.HasPrefix(r.URL.Path, "/apis/metrics.k8s.io/") {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	resolver, err := NewResolver(&rest.Config{Host: server.URL}, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	result, err := resolver.lookup(&ResolveOptions{}, "metrics.k8s.io/v1beta1", "NodeMetrics", "", "node1")
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	cpu, _, _ := unstructured.NestedString(result, "usage", "cpu")
+	if cpu != "100m" {
+		t.Fatalf("Expected the CPU usage of 100m but got: %v", result)
+	}
+
+	// A missing object on an available aggregated API is not an error
+	result, err = resolver.lookup(&ResolveOptions{}, "metrics.k8s.io/v1beta1", "PodMetrics", "default", "pod1")
+	if err != nil || len(result) != 0 {
+		t.Fatalf("Expected an empty result and no error but got: %v, %v", result, err)
+	}
+
+	_, err = resolver.lookup(&ResolveOptions{}, "custom.metrics.k8s.io/v1beta1", "MetricValueList", "default", "")
+	if !errors.Is(err, ErrAPIUnavailable) {
+		t.Fatalf("Expected ErrAPIUnavailable but got: %v", err)
+	}
+}
+
+func TestExistingNames(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		apiVersion  string
+		kind        string
+		names       interface{}
+		expected    []string
+		expectedErr error
+	}{
+		"some exist": {
+			"v1", "ConfigMap", []interface{}{"testcm-envb", "idontexist", "testconfigmap"},
+			[]string{"testcm-envb", "testconfigmap"}, nil,
+		},
+		"none exist":  {"v1", "ConfigMap", []string{"idontexist"}, []string{}, nil},
+		"empty names": {"v1", "ConfigMap", []string{}, []string{}, nil},
+		"invalid names": {
+			"v1", "ConfigMap", map[string]string{"a": "b"}, nil, ErrInvalidInput,
+		},
+		"missing API": {
+			"v1", "UnknownKind", []string{"testconfigmap"}, nil, ErrMissingAPIResource,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			names, err := resolver.existingNames(&ResolveOptions{}, test.apiVersion, test.kind, "testns", test.names)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No error was expected: %v", err)
+			}
+
+			if !slices.Equal(names, test.expected) {
+				t.Fatalf("Expected the names %v but got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestIntersectNames(t *testing.T) {
+	t.Parallel()
+
+	items := []interface{}{
+		map[string]interface{}{"metadata": map[string]interface{}{"name": "c"}},
+		map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}},
+		map[string]interface{}{"metadata": map[string]interface{}{"name": "b"}},
+	}
+
+	names := intersectNames(items, []string{"c", "z", "a", "c"})
+
+	if !slices.Equal(names, []string{"a", "c"}) {
+		t.Fatalf("Expected the names [a c] but got %v", names)
+	}
+}
+
+func TestResourceFor(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		apiVersion  string
+		kind        string
+		expected    string
+		expectedErr error
+	}{
+		"core":        {"v1", "ConfigMap", "configmaps", nil},
+		"group":       {"networking.k8s.io/v1", "NetworkPolicy", "networkpolicies", nil},
+		"missing API": {"v1", "UnknownKind", "", ErrMissingAPIResource},
+	}
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resource, err := resolver.resourceFor(test.apiVersion, test.kind)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+
+			if resource != test.expected {
+				t.Fatalf("Expected the resource %s but got %s", test.expected, resource)
+			}
+		})
+	}
+}
+
+func TestLookupWithFieldSelector(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	result, err := resolver.lookup(
+		&ResolveOptions{}, "v1", "ConfigMap", "testns", "", "", "metadata.name=testconfigmap",
+	)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	items, _, _ := unstructured.NestedSlice(result, "items")
+	if len(items) != 1 {
+		t.Fatalf("Expected one ConfigMap but got %d", len(items))
+	}
+
+	_, err = resolver.lookup(&ResolveOptions{}, "v1", "ConfigMap", "testns", "", "", "metadata.name")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
+
+func TestSplitSelectors(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input          []string
+		expectedLabels []string
+		expectedFields []string
+	}{
+		"none":         {nil, nil, nil},
+		"labels":       {[]string{"a=b", "c=d"}, []string{"a=b", "c=d"}, nil},
+		"empty labels": {[]string{""}, nil, []string{}},
+		"fields":       {[]string{"", "status.phase=Running"}, nil, []string{"status.phase=Running"}},
+		"labels, fields": {
+			[]string{"a=b", "", "spec.x=y", "spec.z=w"}, []string{"a=b"}, []string{"spec.x=y", "spec.z=w"},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			labelSelector, fieldSelector := splitSelectors(test.input)
+
+			if !slices.Equal(labelSelector, test.expectedLabels) || !slices.Equal(fieldSelector, test.expectedFields) {
+				t.Fatalf(
+					"Expected %v and %v but got %v and %v",
+					test.expectedLabels, test.expectedFields, labelSelector, fieldSelector,
+				)
+			}
+		})
+	}
+}
+
+func TestFilterByFieldSelector(t *testing.T) {
+	t.Parallel()
+
+	newObject := func(name string, phase string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetName(name)
+
+		if phase != "" {
+			_ = unstructured.SetNestedField(obj.Object, phase, "status", "phase")
+		}
+
+		return obj
+	}
+
+	objects := []unstructured.Unstructured{newObject("a", "Running"), newObject("b", "Pending"), newObject("c", "")}
+
+	testcases := map[string]struct {
+		selector string
+		expected []string
+	}{
+		"empty":     {"", []string{"a", "b", "c"}},
+		"equals":    {"status.phase=Running", []string{"a"}},
+		"not equal": {"status.phase!=Running", []string{"b", "c"}},
+		"multiple":  {"status.phase!=Running,metadata.name!=c", []string{"b"}},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := fields.ParseSelector(test.selector)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			names := []string{}
+			for _, obj := range filterByFieldSelector(objects, selector) {
+				names = append(names, obj.GetName())
+			}
+
+			if !slices.Equal(names, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		options           ResolveOptions
+		kind              string
+		namespaceSelector string
+		expectedCount     int
+		expectedErr       error
+	}{
+		"namespace selector": {
+			kind: "ConfigMap", namespaceSelector: "kubernetes.io/metadata.name=testns", expectedCount: 3,
+		},
+		"no matching namespaces": {
+			kind: "ConfigMap", namespaceSelector: "kubernetes.io/metadata.name=idontexist", expectedCount: 0,
+		},
+		"restricted without a namespace selector": {
+			options: ResolveOptions{LookupNamespace: "testns"}, kind: "ConfigMap", expectedCount: 3,
+		},
+		"restricted with an allowed namespace selector": {
+			options: ResolveOptions{
+				LookupNamespaces:       []string{"testns", "other"},
+				ClusterScopedAllowList: []ClusterScopedObjectIdentifier{{Kind: "Namespace", Name: "*"}},
+			},
+			kind:              "ConfigMap",
+			namespaceSelector: "kubernetes.io/metadata.name",
+			expectedCount:     3,
+		},
+		"restricted with a namespace selector": {
+			options:           ResolveOptions{LookupNamespace: "testns"},
+			kind:              "ConfigMap",
+			namespaceSelector: "kubernetes.io/metadata.name=testns",
+			expectedErr:       ClusterScopedLookupRestrictedError{Kind: "Namespace"},
+		},
+		"cluster-scoped kind": {
+			kind: "Namespace", expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			result, err := resolver.lookupAll(&test.options, "v1", test.kind, test.namespaceSelector, "app=test")
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No error was expected: %v", err)
+			}
+
+			items, _, _ := unstructured.NestedSlice(result, "items")
+			if len(items) != test.expectedCount {
+				t.Fatalf("Expected %d objects but got %d", test.expectedCount, len(items))
+			}
+		})
+	}
+}
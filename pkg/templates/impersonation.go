@@ -0,0 +1,100 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ServiceAccountImpersonation returns the impersonation of the service account for ResolveOptions.Impersonate, which
+// is the user name and groups that the Kubernetes API server authenticates the service account as.
+func ServiceAccountImpersonation(namespace string, name string) *rest.ImpersonationConfig {
+	return &rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace},
+	}
+}
+
+// impersonatedClients are the Kubernetes clients of the identities impersonated with ResolveOptions.Impersonate,
+// keyed by impersonationKey. The clients are created on first use and kept for the lifetime of the resolver.
+type impersonatedClients struct {
+	lock    sync.Mutex
+	clients map[string]*impersonatedClient
+}
+
+type impersonatedClient struct {
+	dynamicClient *dynamic.DynamicClient
+	kubeClient    kubernetes.Interface
+}
+
+// impersonationKey returns a string identifying the impersonated identity. An empty string is returned when there is no
+// impersonation.
+func impersonationKey(impersonate *rest.ImpersonationConfig) string {
+	if impersonate == nil {
+		return ""
+	}
+
+	// The map keys of Extra are sorted by json.Marshal, and the struct can't fail to be marshaled
+	key, _ := json.Marshal(impersonate)
+
+	return string(key)
+}
+
+// clientsFor returns the Kubernetes clients of the lookups of the options, which impersonate ResolveOptions.Impersonate
+// if it's set.
+func (t *TemplateResolver) clientsFor(options *ResolveOptions) (*dynamic.DynamicClient, kubernetes.Interface, error) {
+	if options == nil || options.Impersonate == nil {
+		return t.dynamicClient, t.kubeClient, nil
+	}
+
+	key := impersonationKey(options.Impersonate)
+
+	t.impersonatedClients.lock.Lock()
+	defer t.impersonatedClients.lock.Unlock()
+
+	client, ok := t.impersonatedClients.clients[key]
+	if !ok {
+		var err error
+
+		client, err = newImpersonatedClient(t.kubeConfig, *options.Impersonate)
+		if err != nil {
+			return nil, nil, fmt.Errorf(
+				"failed to create the Kubernetes client to impersonate %s: %w", options.Impersonate.UserName, err,
+			)
+		}
+
+		t.impersonatedClients.clients[key] = client
+	}
+
+	return client.dynamicClient, client.kubeClient, nil
+}
+
+func newImpersonatedClient(
+	kubeConfig *rest.Config, impersonate rest.ImpersonationConfig,
+) (*impersonatedClient, error) {
+	kubeConfig = rest.CopyConfig(kubeConfig)
+	kubeConfig.Impersonate = impersonate
+
+	httpClient, err := rest.HTTPClientFor(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(kubeConfig, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeClient, err := kubernetes.NewForConfigAndClient(kubeConfig, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &impersonatedClient{dynamicClient: dynamicClient, kubeClient: kubeClient}, nil
+}
@@ -0,0 +1,119 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// secretDenyingTransport forbids the impersonated users from reading Secrets and otherwise serves the requests from the
+// wrapped fake API server.
+type secretDenyingTransport struct {
+	server http.RoundTripper
+}
+
+func (s secretDenyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	user := req.Header.Get("Impersonate-User")
+	if user == "" || !strings.Contains(req.URL.Path, "/secrets") {
+		return s.server.RoundTrip(req)
+	}
+
+	status := apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", nil).Status()
+
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Content-Type", "application/json")
+	recorder.WriteHeader(http.StatusForbidden)
+
+	if err := json.NewEncoder(recorder).Encode(&status); err != nil {
+		return nil, err
+	}
+
+	resp := recorder.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+func TestResolveTemplateImpersonation(t *testing.T) {
+	t.Parallel()
+
+	server, err := newFakeAPIServer([]unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "app-config", "namespace": "app"},
+			"data":       map[string]interface{}{"replicas": "3"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app-secret", "namespace": "app"},
+			"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+		}},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Keep the lookups across calls to check that the identities don't share the cache entries
+	resolver, err := NewResolver(
+		&rest.Config{Host: fakeAPIServerHost, Transport: secretDenyingTransport{server}},
+		Config{InputIsYAML: true, LookupCacheTTL: time.Minute},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	secretTmpl := []byte(`password: '{{ fromSecret "app" "app-secret" "password" }}'`)
+
+	_, err = resolver.ResolveTemplate(secretTmpl, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected the Secret lookup without impersonation to succeed but got: %v", err)
+	}
+
+	options := &ResolveOptions{Impersonate: ServiceAccountImpersonation("tenant", "policies")}
+
+	_, err = resolver.ResolveTemplate(secretTmpl, nil, options)
+	if !apierrors.IsForbidden(err) {
+		t.Fatalf("Expected a Forbidden error for the impersonated Secret lookup but got: %v", err)
+	}
+
+	result, err := resolver.ResolveTemplate(
+		[]byte(`replicas: '{{ fromConfigMap "app" "app-config" "replicas" }}'`), nil, options,
+	)
+	if err != nil {
+		t.Fatalf("Expected the impersonated ConfigMap lookup to succeed but got: %v", err)
+	}
+
+	if string(result.ResolvedJSON) != `{"replicas":"3"}` {
+		t.Fatalf("Unexpected resolved JSON: %s", result.ResolvedJSON)
+	}
+
+	if len(resolver.impersonatedClients.clients) != 1 {
+		t.Fatalf("Expected a single impersonated client but got %d", len(resolver.impersonatedClients.clients))
+	}
+}
+
+func TestServiceAccountImpersonation(t *testing.T) {
+	t.Parallel()
+
+	expected := &rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:tenant:policies",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:tenant"},
+	}
+
+	if actual := ServiceAccountImpersonation("tenant", "policies"); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Expected %v but got %v", expected, actual)
+	}
+}
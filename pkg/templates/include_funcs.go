@@ -0,0 +1,95 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxIncludeDepth is the maximum number of nested include calls, which protects against named templates that include
+// each other in a cycle.
+const maxIncludeDepth = 20
+
+var (
+	ErrTemplateLibraryNotSet = errors.New(
+		"the include template function is only available when TemplateLibraryConfigMap is set",
+	)
+	ErrNamedTemplateNotFound = errors.New("the named template is not in the template library")
+	ErrMaxIncludeDepth       = errors.New("the named templates are included too many times in a row")
+)
+
+func (t *TemplateResolver) includeHelper(
+	options *ResolveOptions, funcMap template.FuncMap,
+) func(string, interface{}) (string, error) {
+	return func(name string, data interface{}) (string, error) {
+		return t.include(options, funcMap, name, data)
+	}
+}
+
+// include resolves the named template stored in the template library ConfigMap with the input data as the context and
+// returns the result. The named template has the same template functions as the calling template, so it may include
+// other named templates.
+func (t *TemplateResolver) include(
+	options *ResolveOptions, funcMap template.FuncMap, name string, data interface{},
+) (string, error) {
+	t.logger(options).V(2).Info("include", "name", name)
+
+	library := options.TemplateLibraryConfigMap
+	if library.Name == "" || library.Namespace == "" {
+		return "", ErrTemplateLibraryNotSet
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%w: the name must be specified", ErrInvalidInput)
+	}
+
+	if options.state != nil {
+		if options.state.includeDepth >= maxIncludeDepth {
+			return "", fmt.Errorf("%w: the maximum is %d", ErrMaxIncludeDepth, maxIncludeDepth)
+		}
+
+		options.state.includeDepth++
+		defer func() { options.state.includeDepth-- }()
+	}
+
+	// The template library is set by the caller, so it's not restricted by the lookup namespaces, the allowed kinds, or
+	// the access review, and it's looked up with the resolver's credentials
+	libraryOptions := *options
+	libraryOptions.LookupNamespace = ""
+	libraryOptions.LookupNamespaces = nil
+	libraryOptions.AllowedGVKs = nil
+	libraryOptions.LookupAccessReview = nil
+	libraryOptions.Impersonate = nil
+
+	configMap, err := t.getOrList(&libraryOptions, "v1", "ConfigMap", library.Namespace, library.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed getting the template library ConfigMap %s: %w", library, err)
+	}
+
+	namedTemplate, found, _ := unstructured.NestedString(configMap, "data", name)
+	if !found {
+		return "", fmt.Errorf("%w: %s is not a key in the ConfigMap %s", ErrNamedTemplateNotFound, name, library)
+	}
+
+	tmpl, err := t.parseTemplate(name, namedTemplate, funcMap, options)
+	if err != nil {
+		return "", fmt.Errorf(
+			"failed to parse the named template %s: %w",
+			name, classifyError(ErrParseFailed, deniedFunctionError(err, options.DeniedFunctions)),
+		)
+	}
+
+	var buf bytes.Buffer
+
+	err = tmpl.Execute(options.limitWriter(&buf), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the named template %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
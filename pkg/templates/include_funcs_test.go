@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestInclude(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "library", "namespace": "templates"},
+			"data": map[string]interface{}{
+				"greeting": "Hello {{ . }}",
+				"nested":   `{{ include "greeting" . }}!`,
+				"loop":     `{{ include "loop" . }}`,
+			},
+		}},
+	}
+
+	library := types.NamespacedName{Namespace: "templates", Name: "library"}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		options        ResolveOptions
+		expectedResult string
+		expectedErr    error
+	}{
+		"include": {
+			inputTmpl:      `value: '{{ include "greeting" "cluster1" }}'`,
+			options:        ResolveOptions{TemplateLibraryConfigMap: library},
+			expectedResult: "value: Hello cluster1",
+		},
+		"nested include": {
+			inputTmpl:      `value: '{{ include "nested" "cluster1" }}'`,
+			options:        ResolveOptions{TemplateLibraryConfigMap: library},
+			expectedResult: "value: Hello cluster1!",
+		},
+		"include with a restricted lookup namespace": {
+			inputTmpl: `value: '{{ include "greeting" "cluster1" }}'`,
+			options: ResolveOptions{
+				TemplateLibraryConfigMap: library, LookupNamespace: "app",
+			},
+			expectedResult: "value: Hello cluster1",
+		},
+		"template library not set": {
+			inputTmpl:   `value: '{{ include "greeting" "cluster1" }}'`,
+			expectedErr: ErrTemplateLibraryNotSet,
+		},
+		"named template not found": {
+			inputTmpl:   `value: '{{ include "not-found" "cluster1" }}'`,
+			options:     ResolveOptions{TemplateLibraryConfigMap: library},
+			expectedErr: ErrNamedTemplateNotFound,
+		},
+		"include cycle": {
+			inputTmpl:   `value: '{{ include "loop" "cluster1" }}'`,
+			options:     ResolveOptions{TemplateLibraryConfigMap: library},
+			expectedErr: ErrMaxIncludeDepth,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &test.options)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(val) != test.expectedResult+"\n" {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, string(val))
+			}
+
+			if len(result.ReferencedObjects) != 1 || result.ReferencedObjects[0].Name != "library" {
+				t.Fatalf("Expected the template library to be referenced but got: %v", result.ReferencedObjects)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+func (t *TemplateResolver) jqHelper(options *ResolveOptions) func(string, interface{}) (interface{}, error) {
+	return func(query string, input interface{}) (interface{}, error) {
+		return t.jq(options, query, input)
+	}
+}
+
+// jq runs the jq query on the input, such as the result of a lookup, so that complex selection and transformation
+// logic can be expressed in a single template function call. If the query outputs a single value, that value is
+// returned. Otherwise, a list of the output values is returned, which is empty if the query had no output. Wrap the
+// query in brackets (e.g. `[.items[] | .metadata.name]`) to always get a list. The query stops if the context of the
+// ResolveTemplateWithContext call is canceled.
+func (t *TemplateResolver) jq(options *ResolveOptions, query string, input interface{}) (interface{}, error) {
+	parsedQuery, err := gojq.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq query %s is invalid: %w", ErrInvalidInput, query, err)
+	}
+
+	code, err := gojq.Compile(parsedQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq query %s is invalid: %w", ErrInvalidInput, query, err)
+	}
+
+	// gojq only accepts JSON compatible types, so convert the input such as an int64 in a looked up object
+	jsonInput, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq input is not JSON compatible: %w", ErrInvalidInput, err)
+	}
+
+	var normalizedInput interface{}
+
+	err = json.Unmarshal(jsonInput, &normalizedInput)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the jq input is not JSON compatible: %w", ErrInvalidInput, err)
+	}
+
+	outputs := []interface{}{}
+
+	iter := code.RunWithContext(options.apiContext(), normalizedInput)
+
+	for {
+		output, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := output.(error); ok {
+			return nil, fmt.Errorf("failed to run the jq query %s: %w", query, err)
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	if len(outputs) == 1 {
+		return outputs[0], nil
+	}
+
+	return outputs, nil
+}
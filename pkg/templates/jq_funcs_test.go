@@ -0,0 +1,159 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestJQ(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{}
+
+	podList := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "a"},
+				"spec":     map[string]interface{}{"priority": int64(10)},
+				"status":   map[string]interface{}{"phase": "Running"},
+			},
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "b"},
+				"spec":     map[string]interface{}{"priority": int64(5)},
+				"status":   map[string]interface{}{"phase": "Pending"},
+			},
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "c"},
+				"spec":     map[string]interface{}{"priority": int64(1)},
+				"status":   map[string]interface{}{"phase": "Running"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		query    string
+		input    interface{}
+		expected interface{}
+	}{
+		"multiple outputs": {
+			query:    `.items[] | select(.status.phase == "Running") | .metadata.name`,
+			input:    podList,
+			expected: []interface{}{"a", "c"},
+		},
+		"single output": {
+			query:    `.items[] | select(.status.phase == "Pending") | .metadata.name`,
+			input:    podList,
+			expected: "b",
+		},
+		"no output": {
+			query:    `.items[] | select(.status.phase == "Failed") | .metadata.name`,
+			input:    podList,
+			expected: []interface{}{},
+		},
+		"always a list": {
+			query:    `[.items[] | select(.status.phase == "Pending") | .metadata.name]`,
+			input:    podList,
+			expected: []interface{}{"b"},
+		},
+		"int64 input": {
+			query:    `[.items[].spec.priority] | add`,
+			input:    podList,
+			expected: float64(16),
+		},
+		"object output": {
+			query:    `.items | map({(.metadata.name): .status.phase}) | add`,
+			input:    podList,
+			expected: map[string]interface{}{"a": "Running", "b": "Pending", "c": "Running"},
+		},
+		"map[string]string input": {
+			query:    `.key`,
+			input:    map[string]string{"key": "value"},
+			expected: "value",
+		},
+		"nil input": {
+			query:    `.missing`,
+			input:    nil,
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.jq(&ResolveOptions{}, test.query, test.input)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Fatalf("Expected %#v but got %#v", test.expected, result)
+			}
+		})
+	}
+
+	_, err := resolver.jq(&ResolveOptions{}, `.items[`, podList)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+
+	_, err = resolver.jq(&ResolveOptions{}, `.items | undefinedfunc`, podList)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+
+	_, err = resolver.jq(&ResolveOptions{}, `.items + 1`, podList)
+	if err == nil {
+		t.Fatal("Expected a runtime error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = resolver.jq(&ResolveOptions{state: &resolveState{ctx: ctx}}, `repeat(.)`, podList)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but got %v", err)
+	}
+}
+
+func TestJQTemplate(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(name, phase string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+			"status":     map[string]interface{}{"phase": phase},
+		}}
+	}
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{newPod("a", "Running"), newPod("b", "Pending"), newPod("c", "Running")},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `running: '{{ range lookup "v1" "Pod" "app" "" | jq ".items[] | select(.status.phase==\"Running\") | ` +
+		`.metadata.name" }}{{ . }},{{ end }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"running":"a,c,"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("Expected %s but got %s", expected, result.ResolvedJSON)
+	}
+}
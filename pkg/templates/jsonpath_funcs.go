@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// jsonPath returns the values in the input object that match the Kubernetes JSONPath expression (e.g.
+// `.spec.containers[*].image`). The surrounding braces of the expression are optional. Missing keys along the path are
+// not an error, so this is safer than long chains of index calls on the result of a lookup.
+//
+// If the expression can match multiple values, such as with a wildcard, a slice, a filter, or a recursive descent, a
+// list of the matched values is returned, which is empty if nothing matched. Otherwise, the single matched value is
+// returned, which is nil if nothing matched.
+func jsonPath(path string, obj interface{}) (interface{}, error) {
+	if !strings.Contains(path, "{") {
+		path = "{" + path + "}"
+	}
+
+	parser, err := jsonpath.Parse("jsonpath", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the JSONPath expression %s is invalid: %w", ErrInvalidInput, path, err)
+	}
+
+	query := jsonpath.New("jsonpath").AllowMissingKeys(true)
+
+	err = query.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the JSONPath expression %s is invalid: %w", ErrInvalidInput, path, err)
+	}
+
+	results, err := query.FindResults(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate the JSONPath expression %s: %w", path, err)
+	}
+
+	values := []interface{}{}
+
+	for _, result := range results {
+		for _, value := range result {
+			if value.IsValid() && value.CanInterface() {
+				values = append(values, value.Interface())
+			}
+		}
+	}
+
+	if isMultiValueJSONPath(parser.Root) || len(values) > 1 {
+		return values, nil
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return values[0], nil
+}
+
+// isMultiValueJSONPath returns whether the parsed JSONPath expression can match more than one value.
+func isMultiValueJSONPath(node jsonpath.Node) bool {
+	switch typedNode := node.(type) {
+	case *jsonpath.ListNode:
+		for _, child := range typedNode.Nodes {
+			if isMultiValueJSONPath(child) {
+				return true
+			}
+		}
+	case *jsonpath.ArrayNode:
+		// A single index like [0] has a derived end index
+		return !typedNode.Params[0].Known || !typedNode.Params[1].Derived
+	case *jsonpath.WildcardNode, *jsonpath.RecursiveNode, *jsonpath.FilterNode, *jsonpath.UnionNode:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestJSONPath(t *testing.T) {
+	t.Parallel()
+
+	pod := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "pod", "labels": map[string]interface{}{"app": "web"}},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1", "ports": []interface{}{int64(8080)}},
+				map[string]interface{}{"name": "sidecar", "image": "sidecar:2"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		path     string
+		expected interface{}
+	}{
+		"single value":           {".metadata.name", "pod"},
+		"braces":                 {"{.metadata.labels.app}", "web"},
+		"map value":              {".metadata.labels", map[string]interface{}{"app": "web"}},
+		"index":                  {".spec.containers[1].image", "sidecar:2"},
+		"wildcard":               {".spec.containers[*].image", []interface{}{"app:1", "sidecar:2"}},
+		"filter":                 {`.spec.containers[?(@.name=="app")].image`, []interface{}{"app:1"}},
+		"slice":                  {".spec.containers[0:1].name", []interface{}{"app"}},
+		"recursive":              {"..image", []interface{}{"app:1", "sidecar:2"}},
+		"non-string value":       {".spec.containers[0].ports[0]", int64(8080)},
+		"missing key":            {".status.phase", nil},
+		"missing intermediate":   {".status.conditions[0].type", nil},
+		"missing with wildcard":  {".status.conditions[*].type", []interface{}{}},
+		"multiple expressions":   {"{.metadata.name}{.metadata.labels.app}", []interface{}{"pod", "web"}},
+		"wildcard single result": {".spec.containers[?(@.name==\"sidecar\")].name", []interface{}{"sidecar"}},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := jsonPath(test.path, pod)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Fatalf("Expected %#v but got %#v", test.expected, result)
+			}
+		})
+	}
+
+	_, err := jsonPath(".spec.containers[", pod)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestJSONPathTemplate(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "app"},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:1"},
+					map[string]interface{}{"name": "sidecar", "image": "sidecar:2"},
+				},
+			},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `images: '{{ range jsonpath ".spec.containers[*].image" (lookup "v1" "Pod" "app" "web") }}{{ . }},{{ end }}'
+phase: '{{ jsonpath ".status.phase" (lookup "v1" "Pod" "app" "web") | default "Unknown" }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"images":"app:1,sidecar:2,","phase":"Unknown"}`
+	if string(result.ResolvedJSON) != expected {
+		t.Fatalf("Expected %s but got %s", expected, result.ResolvedJSON)
+	}
+}
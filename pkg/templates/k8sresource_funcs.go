@@ -9,7 +9,6 @@ import (
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/klog"
 )
 
 func (t *TemplateResolver) fromSecretHelper(
@@ -24,22 +23,100 @@ func (t *TemplateResolver) fromSecretHelper(
 func (t *TemplateResolver) fromSecret(
 	options *ResolveOptions, namespace string, name string, key string,
 ) (string, error) {
-	klog.V(2).Infof("fromSecret for namespace: %v, name: %v, key:%v", namespace, name, key)
+	t.logger(options).V(2).Info("fromSecret", "namespace", namespace, "name", name, "key", key)
 
-	if name == "" || (options.LookupNamespace == "" && namespace == "") || key == "" {
-		return "", fmt.Errorf("%w: namespace, name, and key must be specified", ErrInvalidInput)
+	keyVal, _, err := t.getSecretValue(options, namespace, name, key)
+
+	return keyVal, err
+}
+
+// getSecretValue returns the base64 encoded value of the key in the given Secret and whether the key was found. A
+// Secret that is not found is treated as not having the key.
+func (t *TemplateResolver) getSecretValue(
+	options *ResolveOptions, namespace string, name string, key string,
+) (string, bool, error) {
+	if name == "" || (len(options.lookupNamespaces()) == 0 && namespace == "") || key == "" {
+		return "", false, fmt.Errorf("%w: namespace, name, and key must be specified", ErrInvalidInput)
 	}
 
 	secret, err := t.getOrList(options, "v1", "Secret", namespace, name)
 	if err != nil {
-		return "", fmt.Errorf("failed to get the secret %s from %s: %w", name, namespace, err)
+		return "", false, fmt.Errorf("failed to get the secret %s from %s: %w", name, namespace, err)
+	}
+
+	keyVal, found, _ := unstructured.NestedString(secret, "data", key)
+
+	return keyVal, found, nil
+}
+
+func (t *TemplateResolver) fromSecretBinaryHelper(
+	options *ResolveOptions,
+) func(string, string, string) (string, error) {
+	return func(namespace string, name string, key string) (string, error) {
+		return t.fromSecretBinary(options, namespace, name, key)
+	}
+}
+
+// fromSecretBinary retrieves the decoded value of the key in the given Secret, namespace. This is useful as the input
+// of other template functions such as sha256sum. The value is never encrypted.
+func (t *TemplateResolver) fromSecretBinary(
+	options *ResolveOptions, namespace string, name string, key string,
+) (string, error) {
+	t.logger(options).V(2).Info("fromSecretBinary", "namespace", namespace, "name", name, "key", key)
+
+	keyVal, _, err := t.getSecretValue(options, namespace, name, key)
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(keyVal)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the key %s in the secret %s from %s: %w", key, name, namespace, err)
+	}
+
+	return string(decoded), nil
+}
+
+func (t *TemplateResolver) fromSecretKeyOrDefaultHelper(
+	options *ResolveOptions,
+) func(string, string, string, string) (string, error) {
+	return func(namespace string, name string, key string, defaultVal string) (string, error) {
+		return t.fromSecretKeyOrDefault(options, namespace, name, key, defaultVal)
+	}
+}
+
+// fromSecretKeyOrDefault retrieves the value of the key in the given Secret, namespace like fromSecret, but returns the
+// default value as is if the Secret or key is not found.
+func (t *TemplateResolver) fromSecretKeyOrDefault(
+	options *ResolveOptions, namespace string, name string, key string, defaultVal string,
+) (string, error) {
+	t.logger(options).V(2).Info("fromSecretKeyOrDefault", "namespace", namespace, "name", name, "key", key)
+
+	keyVal, found, err := t.getSecretValue(options, namespace, name, key)
+	if err != nil {
+		return "", err
 	}
 
-	keyVal, _, _ := unstructured.NestedString(secret, "data", key)
+	if !found {
+		return defaultVal, nil
+	}
 
 	return keyVal, nil
 }
 
+func (t *TemplateResolver) fromSecretKeyOrDefaultProtectedHelper(
+	options *ResolveOptions,
+) func(string, string, string, string) (string, error) {
+	return func(namespace string, name string, key string, defaultVal string) (string, error) {
+		value, err := t.fromSecretKeyOrDefault(options, namespace, name, key, defaultVal)
+		if err != nil {
+			return "", err
+		}
+
+		return t.protect(options, value)
+	}
+}
+
 func (t *TemplateResolver) fromSecretProtectedHelper(
 	options *ResolveOptions,
 ) func(string, string, string) (string, error) {
@@ -60,13 +137,34 @@ func (t *TemplateResolver) fromSecretProtected(
 	return t.protect(options, value)
 }
 
+func (t *TemplateResolver) fromSecretEncryptedHelper(
+	options *ResolveOptions,
+) func(string, string, string) (string, error) {
+	return func(namespace string, secretName string, key string) (string, error) {
+		return t.fromSecretEncrypted(options, namespace, secretName, key)
+	}
+}
+
+// fromSecretEncrypted wraps fromSecret and always encrypts the output value using the "protect" method so that the
+// value never appears in plaintext in the resolved template. Unlike fromSecret, an error wrapping
+// ErrEncryptionNotEnabled is returned if encryption is not enabled.
+func (t *TemplateResolver) fromSecretEncrypted(
+	options *ResolveOptions, namespace string, secretName string, key string,
+) (string, error) {
+	if !options.EncryptionEnabled {
+		return "", fmt.Errorf("%w: fromSecretEncrypted", ErrEncryptionNotEnabled)
+	}
+
+	return t.fromSecretProtected(options, namespace, secretName, key)
+}
+
 // copies all data in the given Secret, namespace.
 func (t *TemplateResolver) copySecretDataBase(
 	options *ResolveOptions, namespace string, name string,
 ) (map[string]interface{}, error) {
-	klog.V(2).Infof("copySecretDataBase for namespace: %v, name: %v", namespace, name)
+	t.logger(options).V(2).Info("copySecretDataBase", "namespace", namespace, "name", name)
 
-	if name == "" || (options.LookupNamespace == "" && namespace == "") {
+	if name == "" || (len(options.lookupNamespaces()) == 0 && namespace == "") {
 		return nil, fmt.Errorf("%w: namespace and name must be specified", ErrInvalidInput)
 	}
 
@@ -90,7 +188,7 @@ func (t *TemplateResolver) copySecretDataHelper(options *ResolveOptions) func(st
 func (t *TemplateResolver) copySecretData(
 	options *ResolveOptions, namespace string, secretname string,
 ) (string, error) {
-	klog.V(2).Infof("copySecretData for namespace: %v, secretname: %v", namespace, secretname)
+	t.logger(options).V(2).Info("copySecretData", "namespace", namespace, "name", secretname)
 
 	data, err := t.copySecretDataBase(options, namespace, secretname)
 	if err != nil {
@@ -149,9 +247,9 @@ func (t *TemplateResolver) fromConfigMapHelper(
 func (t *TemplateResolver) fromConfigMap(
 	options *ResolveOptions, namespace string, name string, key string,
 ) (string, error) {
-	klog.V(2).Infof("fromConfigMap for namespace: %s, name: %s, key: %s", namespace, name, key)
+	t.logger(options).V(2).Info("fromConfigMap", "namespace", namespace, "name", name, "key", key)
 
-	if name == "" || (options.LookupNamespace == "" && namespace == "") || key == "" {
+	if name == "" || (len(options.lookupNamespaces()) == 0 && namespace == "") || key == "" {
 		return "", fmt.Errorf("%w: namespace, name, and key must be specified", ErrInvalidInput)
 	}
 
@@ -167,6 +265,61 @@ func (t *TemplateResolver) fromConfigMap(
 	return keyVal, nil
 }
 
+func (t *TemplateResolver) fromConfigMapsHelper(
+	options *ResolveOptions,
+) func(string, string, string) (map[string]interface{}, error) {
+	return func(namespace string, labelSelector string, key string) (map[string]interface{}, error) {
+		return t.fromConfigMaps(options, namespace, labelSelector, key)
+	}
+}
+
+// fromConfigMaps lists the ConfigMaps in the namespace that match the label selector and returns a map of the
+// ConfigMap names to the values of the key. ConfigMaps without the key are omitted. An empty label selector matches all
+// ConfigMaps in the namespace.
+func (t *TemplateResolver) fromConfigMaps(
+	options *ResolveOptions, namespace string, labelSelector string, key string,
+) (map[string]interface{}, error) {
+	t.logger(options).V(2).Info(
+		"fromConfigMaps", "namespace", namespace, "labelSelector", labelSelector, "key", key,
+	)
+
+	if (len(options.lookupNamespaces()) == 0 && namespace == "") || key == "" {
+		return nil, fmt.Errorf("%w: namespace and key must be specified", ErrInvalidInput)
+	}
+
+	var selectors []string
+	if labelSelector != "" {
+		selectors = append(selectors, labelSelector)
+	}
+
+	list, err := t.getOrList(options, "v1", "ConfigMap", namespace, "", selectors...)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed listing the ConfigMaps from %s with the label selector %q: %w", namespace, labelSelector, err,
+		)
+	}
+
+	values := map[string]interface{}{}
+
+	items, _, _ := unstructured.NestedSlice(list, "items")
+	for _, item := range items {
+		configmap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		keyVal, found, _ := unstructured.NestedString(configmap, "data", key)
+		if !found {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(configmap, "metadata", "name")
+		values[name] = keyVal
+	}
+
+	return values, nil
+}
+
 func (t *TemplateResolver) copyConfigMapDataHelper(options *ResolveOptions) func(string, string) (string, error) {
 	return func(namespace string, name string) (string, error) {
 		return t.copyConfigMapData(options, namespace, name)
@@ -177,9 +330,9 @@ func (t *TemplateResolver) copyConfigMapDataHelper(options *ResolveOptions) func
 func (t *TemplateResolver) copyConfigMapData(
 	options *ResolveOptions, namespace string, name string,
 ) (string, error) {
-	klog.V(2).Infof("copyConfigMapData for namespace: %s, name: %s", namespace, name)
+	t.logger(options).V(2).Info("copyConfigMapData", "namespace", namespace, "name", name)
 
-	if name == "" || (options.LookupNamespace == "" && namespace == "") {
+	if name == "" || (len(options.lookupNamespaces()) == 0 && namespace == "") {
 		return "", fmt.Errorf("%w: namespace and name must be specified", ErrInvalidInput)
 	}
 
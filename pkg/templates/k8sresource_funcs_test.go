@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestFromSecret(t *testing.T) {
@@ -181,6 +183,86 @@ func TestFromConfigMap(t *testing.T) {
 	}
 }
 
+func TestFromConfigMaps(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{}
+
+	for name, labels := range map[string]string{"team-a": "config", "team-b": "config", "team-c": "other"} {
+		objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": name, "namespace": "app", "labels": map[string]interface{}{"app": labels},
+			},
+			"data": map[string]interface{}{"owner": name + "-owner"},
+		}})
+	}
+
+	objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name": "no-key", "namespace": "app", "labels": map[string]interface{}{"app": "config"},
+		},
+		"data": map[string]interface{}{"other": "value"},
+	}})
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    error
+	}{
+		"label selector": {
+			inputTmpl: `value: '{{ range $name, $owner := fromConfigMaps "app" "app=config" "owner" }}` +
+				`{{ $name }}={{ $owner }},{{ end }}'`,
+			expectedResult: `{"value":"team-a=team-a-owner,team-b=team-b-owner,"}`,
+		},
+		"empty label selector": {
+			inputTmpl:      `value: '{{ len (fromConfigMaps "app" "" "owner") }}'`,
+			expectedResult: `{"value":"3"}`,
+		},
+		"no matches": {
+			inputTmpl:      `value: '{{ len (fromConfigMaps "other" "app=config" "owner") }}'`,
+			expectedResult: `{"value":"0"}`,
+		},
+		"missing key": {
+			inputTmpl:   `value: '{{ fromConfigMaps "app" "app=config" "" }}'`,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected an error matching %v but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expectedResult {
+				t.Fatalf("Expected %s but got %s", test.expectedResult, result.ResolvedJSON)
+			}
+		})
+	}
+}
+
 func TestCopySecretData(t *testing.T) {
 	t.Parallel()
 
@@ -391,6 +473,109 @@ func TestCopySecretDataProtected(t *testing.T) {
 	}
 }
 
+func TestFromSecretEncrypted(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.fromSecretEncrypted(&ResolveOptions{}, "testns", "testsecret", "secretkey1")
+	if !errors.Is(err, ErrEncryptionNotEnabled) {
+		t.Fatalf("Expected ErrEncryptionNotEnabled but got: %v", err)
+	}
+
+	val, err := resolver.fromSecretEncrypted(
+		&ResolveOptions{
+			EncryptionConfig: EncryptionConfig{
+				AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+				EncryptionEnabled:    true,
+				InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+			},
+		},
+		"testns", "testsecret", "secretkey1",
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := "$ocm_encrypted:c6PNhsEfbM9NRUqeJ+HbcECCyVdFnRbLdd+n8r1fS9M="
+	if val != expected {
+		t.Fatalf("expected : %s , to equal : %s", expected, val)
+	}
+}
+
+func TestFromSecretBinary(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	val, err := resolver.fromSecretBinary(&ResolveOptions{}, "testns", "testsecret", "secretkey1")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if val != "secretkey1Val" {
+		t.Fatalf("expected : secretkey1Val , got : %s", val)
+	}
+
+	val, err = resolver.fromSecretBinary(&ResolveOptions{}, "testns", "testsecret", "not-a-key")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if val != "" {
+		t.Fatalf("expected an empty string, got : %s", val)
+	}
+
+	_, err = resolver.fromSecretBinary(&ResolveOptions{}, "testns", "", "secretkey1")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
+
+func TestFromSecretKeyOrDefault(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		inputName      string
+		inputKey       string
+		expectedResult string
+	}{
+		"key exists":        {"testsecret", "secretkey1", base64encode("secretkey1Val")},
+		"key doesn't exist": {"testsecret", "not-a-key", "default"},
+		"secret not found":  {"not-a-secret", "secretkey1", "default"},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := resolver.fromSecretKeyOrDefault(
+				&ResolveOptions{}, "testns", test.inputName, test.inputKey, "default",
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if val != test.expectedResult {
+				t.Fatalf("expected : %s , got : %s", test.expectedResult, val)
+			}
+		})
+	}
+}
+
 func TestCopyConfigMapData(t *testing.T) {
 	t.Parallel()
 
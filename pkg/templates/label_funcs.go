@@ -0,0 +1,51 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// labelValueHashLength is the number of hexadecimal characters of the hash suffix added to truncated label values.
+const labelValueHashLength = 8
+
+var invalidLabelValueChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// toLabelValue converts the input string to a valid Kubernetes label value. Invalid characters are replaced with
+// dashes and leading and trailing characters that are not alphanumeric are removed. If the result is longer than 63
+// characters, it is truncated and suffixed with a hash of the input string so that distinct inputs remain distinct.
+// The output is deterministic so that the same input always produces the same label value.
+func toLabelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+
+	sanitized := trimNonAlphanumeric(invalidLabelValueChars.ReplaceAllString(value, "-"))
+
+	if len(sanitized) <= validation.LabelValueMaxLength {
+		return sanitized
+	}
+
+	hash := sha256.Sum256([]byte(value))
+	hashSuffix := hex.EncodeToString(hash[:])[:labelValueHashLength]
+
+	// Leave room for the dash and the hash suffix
+	truncated := trimNonAlphanumeric(sanitized[:validation.LabelValueMaxLength-labelValueHashLength-1])
+	if truncated == "" {
+		return hashSuffix
+	}
+
+	return truncated + "-" + hashSuffix
+}
+
+// trimNonAlphanumeric removes leading and trailing characters that are not alphanumeric.
+func trimNonAlphanumeric(value string) string {
+	return strings.TrimFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestToLabelValue(t *testing.T) {
+	t.Parallel()
+
+	longValue := strings.Repeat("a", 70)
+
+	testcases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"valid":                  {"my-app_1.0", "my-app_1.0"},
+		"empty":                  {"", ""},
+		"invalid characters":     {"my app/v1", "my-app-v1"},
+		"invalid edges":          {"-_my-app._", "my-app"},
+		"only invalid":           {"///", ""},
+		"truncated":              {longValue, strings.Repeat("a", 54) + "-" + "6bd5e503"},
+		"truncated invalid edge": {strings.Repeat("a", 53) + "." + longValue, strings.Repeat("a", 53) + "-0e79289b"},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			val := toLabelValue(test.input)
+
+			if errs := validation.IsValidLabelValue(val); len(errs) != 0 {
+				t.Fatalf("The label value %q is invalid: %v", val, errs)
+			}
+
+			if val != test.expected {
+				t.Fatalf("expected : %q , got : %q", test.expected, val)
+			}
+
+			if toLabelValue(test.input) != val {
+				t.Fatal("The label value is not deterministic")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog"
+)
+
+// defaultLogger is the logger of the resolvers without Config.Logger and of the package functions, which writes to klog
+// so that the verbosity is controlled by the klog flags (e.g. -v).
+var defaultLogger = logr.New(&klogSink{})
+
+// klogSink is a logr.LogSink that writes the messages and the key and value pairs to klog. The logr verbosity levels
+// are the klog verbosity levels.
+type klogSink struct {
+	name      string
+	values    []interface{}
+	callDepth int
+}
+
+func (k *klogSink) Init(info logr.RuntimeInfo) {
+	k.callDepth = info.CallDepth
+}
+
+func (k *klogSink) Enabled(level int) bool {
+	return bool(klog.V(klog.Level(level)))
+}
+
+func (k *klogSink) Info(_ int, msg string, keysAndValues ...interface{}) {
+	klog.InfoDepth(k.callDepth+1, k.format(msg, keysAndValues))
+}
+
+func (k *klogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorDepth(k.callDepth+1, k.format(msg, append([]interface{}{"err", err}, keysAndValues...)))
+}
+
+func (k *klogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	sink := *k
+	sink.values = append(append([]interface{}{}, k.values...), keysAndValues...)
+
+	return &sink
+}
+
+func (k *klogSink) WithName(name string) logr.LogSink {
+	sink := *k
+
+	if sink.name == "" {
+		sink.name = name
+	} else {
+		sink.name += "/" + name
+	}
+
+	return &sink
+}
+
+func (k *klogSink) WithCallDepth(depth int) logr.LogSink {
+	sink := *k
+	sink.callDepth += depth
+
+	return &sink
+}
+
+// format returns the message prefixed with the logger name and followed by the key and value pairs of the logger and
+// of the message, such as `lookup: getting the object kind="ConfigMap" name="settings"`.
+func (k *klogSink) format(msg string, keysAndValues []interface{}) string {
+	var builder strings.Builder
+
+	if k.name != "" {
+		builder.WriteString(k.name + ": ")
+	}
+
+	builder.WriteString(msg)
+
+	allKeysAndValues := append(append([]interface{}{}, k.values...), keysAndValues...)
+
+	for i := 0; i < len(allKeysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(allKeysAndValues) {
+			value = allKeysAndValues[i+1]
+		}
+
+		switch typedValue := value.(type) {
+		case string:
+			fmt.Fprintf(&builder, " %v=%q", allKeysAndValues[i], typedValue)
+		case error:
+			fmt.Fprintf(&builder, " %v=%q", allKeysAndValues[i], typedValue.Error())
+		default:
+			fmt.Fprintf(&builder, " %v=%+v", allKeysAndValues[i], typedValue)
+		}
+	}
+
+	return builder.String()
+}
+
+// contextLogger returns the logger in the input context or the logger of the resolver if the context has none.
+func (t *TemplateResolver) contextLogger(ctx context.Context) logr.Logger {
+	if log, err := logr.FromContext(ctx); err == nil {
+		return log
+	}
+
+	return t.log
+}
+
+// logger returns the logger of the ResolveTemplate call of the input options or the logger of the resolver if the
+// options are not from a ResolveTemplate call.
+func (t *TemplateResolver) logger(options *ResolveOptions) logr.Logger {
+	if options != nil && options.state != nil && options.state.log.GetSink() != nil {
+		return options.state.log
+	}
+
+	return t.log
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestKlogSinkFormat(t *testing.T) {
+	t.Parallel()
+
+	sink := (&klogSink{}).WithName("templates").WithName("lookup").WithValues("policy", "my-policy")
+
+	testcases := map[string]struct {
+		msg            string
+		keysAndValues  []interface{}
+		expectedOutput string
+	}{
+		"no_values": {
+			"Resolving", nil, `templates/lookup: Resolving policy="my-policy"`,
+		},
+		"values": {
+			"lookup",
+			[]interface{}{"kind", "ConfigMap", "count", 2, "err", errors.New("not found")},
+			`templates/lookup: lookup policy="my-policy" kind="ConfigMap" count=2 err="not found"`,
+		},
+		"missing_value": {
+			"lookup", []interface{}{"kind"}, `templates/lookup: lookup policy="my-policy" kind="(MISSING)"`,
+		},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			output := sink.(*klogSink).format(test.msg, test.keysAndValues)
+			if output != test.expectedOutput {
+				t.Fatalf("Expected %q but got %q", test.expectedOutput, output)
+			}
+		})
+	}
+}
+
+// newCaptureLogger returns a logger with the verbosity of 2 and a function that returns the captured log lines.
+func newCaptureLogger() (logr.Logger, func() []string) {
+	var lock sync.Mutex
+	var lines []string
+
+	logger := funcr.New(func(prefix, args string) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 2})
+
+	return logger, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]string{}, lines...)
+	}
+}
+
+func TestConfigLogger(t *testing.T) {
+	t.Parallel()
+
+	configLogger, configLines := newCaptureLogger()
+	contextLogger, contextLines := newCaptureLogger()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "settings", "namespace": "app"},
+			"data":       map[string]interface{}{"host": "db.example.com"},
+		}}},
+		Config{InputIsYAML: true, Logger: configLogger},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := []byte(`data: '{{ fromConfigMap "app" "settings" "host" }}'`)
+
+	_, err = resolver.ResolveTemplate(tmpl, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `"msg"="fromConfigMap" "namespace"="app" "name"="settings" "key"="host"`
+
+	if !slices.ContainsFunc(configLines(), func(line string) bool { return strings.Contains(line, expected) }) {
+		t.Fatalf("Expected the Config.Logger to log %s but got %v", expected, configLines())
+	}
+
+	ctx := logr.NewContext(context.Background(), contextLogger.WithValues("policy", "my-policy"))
+
+	configLineCount := len(configLines())
+
+	_, err = resolver.ResolveTemplateWithContext(ctx, tmpl, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected = `"msg"="fromConfigMap" "policy"="my-policy"`
+
+	if !slices.ContainsFunc(contextLines(), func(line string) bool { return strings.Contains(line, expected) }) {
+		t.Fatalf("Expected the context logger to log %s but got %v", expected, contextLines())
+	}
+
+	if len(configLines()) != configLineCount {
+		t.Fatalf("Expected the Config.Logger to not be used but got %v", configLines()[configLineCount:])
+	}
+}
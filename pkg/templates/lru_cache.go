@@ -0,0 +1,64 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a cache keyed by strings, such as content hashes, that evicts the least recently used entries when the
+// number of entries exceeds maxEntries. It's safe for concurrent use.
+type lruCache[V any] struct {
+	lock       sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+type lruCacheEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUCache[V any](maxEntries uint) *lruCache[V] {
+	return &lruCache[V]{maxEntries: int(maxEntries), order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the value of the key and marks it as the most recently used.
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		var empty V
+
+		return empty, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return element.Value.(*lruCacheEntry[V]).value, true
+}
+
+// set stores the value of the key and evicts the least recently used entries beyond maxEntries.
+func (c *lruCache[V]) set(key string, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruCacheEntry[V]).value = value
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruCacheEntry[V]{key: key, value: value})
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruCacheEntry[V]).key)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+
+	cache := newLRUCache[int](2)
+
+	cache.set("a", 1)
+	cache.set("b", 2)
+
+	// Use "a" so that "b" is the least recently used
+	if value, ok := cache.get("a"); !ok || value != 1 {
+		t.Fatalf("Expected the entry a to be 1 but got %d", value)
+	}
+
+	cache.set("c", 3)
+
+	if _, ok := cache.get("b"); ok {
+		t.Fatalf("Expected the entry b to be evicted")
+	}
+
+	cache.set("a", 4)
+
+	for key, expected := range map[string]int{"a": 4, "c": 3} {
+		if value, ok := cache.get(key); !ok || value != expected {
+			t.Fatalf("Expected the entry %s to be %d but got %d", key, expected, value)
+		}
+	}
+}
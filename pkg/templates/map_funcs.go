@@ -0,0 +1,286 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// keyValuePair is a map entry returned by the sortedPairs template function.
+type keyValuePair struct {
+	Key   string
+	Value interface{}
+}
+
+// sortedPairs returns the entries of the input map as a slice of key/value pairs sorted lexicographically by key. This
+// allows templates to range over a map with a stable index (e.g. `{{ range $i, $pair := sortedPairs $m }}`). Any map
+// with string keys is accepted, such as the data of a ConfigMap returned by lookup.
+func sortedPairs(m interface{}) ([]keyValuePair, error) {
+	if m == nil {
+		return []keyValuePair{}, nil
+	}
+
+	mValue := reflect.ValueOf(m)
+	if mValue.Kind() != reflect.Map || mValue.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("%w: sortedPairs requires a map with string keys, got %T", ErrInvalidInput, m)
+	}
+
+	pairs := make([]keyValuePair, 0, mValue.Len())
+
+	iter := mValue.MapRange()
+	for iter.Next() {
+		pairs = append(pairs, keyValuePair{Key: iter.Key().String(), Value: iter.Value().Interface()})
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+
+	return pairs, nil
+}
+
+// deepMerge returns a new map with the keys of the input maps, where the values of later maps take precedence. Nested
+// maps are merged recursively and all other values, including lists and zero values like false, replace the earlier
+// value. This allows overlaying environment-specific overrides onto defaults (e.g. `deepMerge $defaults $overrides`).
+// Unlike the sprig merge and mergeOverwrite functions, the input maps aren't modified. Any map with string keys is
+// accepted and nil maps are skipped.
+func deepMerge(maps ...interface{}) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+
+	for i, m := range maps {
+		if m == nil {
+			continue
+		}
+
+		converted, ok := toStringKeyMap(m)
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w: deepMerge requires maps with string keys, got %T at argument %d", ErrInvalidInput, m, i+1,
+			)
+		}
+
+		mergeInto(merged, converted)
+	}
+
+	return merged, nil
+}
+
+// mergeInto recursively merges src into dst. The nested maps of dst are copied before they are modified, so that the
+// maps of the deepMerge arguments aren't modified.
+func mergeInto(dst, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcMap, srcIsMap := toStringKeyMap(srcValue)
+		dstMap, dstIsMap := toStringKeyMap(dst[key])
+
+		if !srcIsMap || !dstIsMap {
+			if srcIsMap {
+				copied := map[string]interface{}{}
+				mergeInto(copied, srcMap)
+				srcValue = copied
+			}
+
+			dst[key] = srcValue
+
+			continue
+		}
+
+		copied := map[string]interface{}{}
+		mergeInto(copied, dstMap)
+		mergeInto(copied, srcMap)
+		dst[key] = copied
+	}
+}
+
+// toStringKeyMap converts a map with string keys, such as a map[string]string, to a map[string]interface{}.
+func toStringKeyMap(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	converted := make(map[string]interface{}, value.Len())
+
+	iter := value.MapRange()
+	for iter.Next() {
+		converted[iter.Key().String()] = iter.Value().Interface()
+	}
+
+	return converted, true
+}
+
+// dig returns the nested value at the input keys of the last argument or the default value in the second to last
+// argument if a key is missing or the value is nil (e.g. `{{ dig "spec" "replicas" 1 $obj }}`). This replaces the sprig
+// dig function to also accept maps other than map[string]interface{}, such as the data of a ConfigMap, and numeric keys
+// to index lists, and to return an error instead of panicking on invalid arguments.
+func dig(args ...interface{}) (interface{}, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf(
+			"%w: dig requires at least one key, a default value, and a map, got %d arguments",
+			ErrInvalidInput, len(args),
+		)
+	}
+
+	defaultValue := args[len(args)-2]
+	current := args[len(args)-1]
+
+	if current == nil {
+		return defaultValue, nil
+	}
+
+	if _, ok := toStringKeyMap(current); !ok {
+		return nil, fmt.Errorf("%w: dig requires a map with string keys, got %T", ErrInvalidInput, current)
+	}
+
+	for _, keyArg := range args[:len(args)-2] {
+		key, err := cast.ToStringE(keyArg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: the dig key %v is not a string", ErrInvalidInput, keyArg)
+		}
+
+		var ok bool
+
+		current, ok = childValue(current, key)
+		if !ok {
+			return defaultValue, nil
+		}
+	}
+
+	if current == nil {
+		return defaultValue, nil
+	}
+
+	return current, nil
+}
+
+// childValue returns the value at the key of the map with string keys or at the numeric key of the list.
+func childValue(parent interface{}, key string) (interface{}, bool) {
+	if m, ok := toStringKeyMap(parent); ok {
+		value, ok := m[key]
+
+		return value, ok
+	}
+
+	if list, ok := parent.([]interface{}); ok {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, false
+		}
+
+		return list[i], true
+	}
+
+	return nil, false
+}
+
+// setPath returns a copy of the input map with the value set at the dot separated path (e.g.
+// `{{ setPath $obj "metadata.labels.env" "prod" }}`). Missing maps along the path are created and numeric path
+// segments index existing lists. A literal dot in a key is escaped with a backslash, such as
+// `metadata.labels.app\.kubernetes\.io/name`. Only the maps and lists along the path are copied, so the input map,
+// which may be a cached lookup result, isn't modified.
+func setPath(obj interface{}, path string, value interface{}) (interface{}, error) {
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+
+	if _, ok := toStringKeyMap(obj); !ok {
+		return nil, fmt.Errorf("%w: setPath requires a map with string keys, got %T", ErrInvalidInput, obj)
+	}
+
+	keys := splitEscapedPath(path)
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("%w: the path %s has an empty key", ErrInvalidInput, path)
+		}
+	}
+
+	return setPathCopy(obj, keys, value, "")
+}
+
+func setPathCopy(parent interface{}, keys []string, value interface{}, parentPath string) (interface{}, error) {
+	key := keys[0]
+	keyPath := joinFieldPath(parentPath, key)
+
+	if parent == nil {
+		parent = map[string]interface{}{}
+	}
+
+	if m, ok := toStringKeyMap(parent); ok {
+		copied := make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			copied[k] = v
+		}
+
+		if len(keys) == 1 {
+			copied[key] = value
+
+			return copied, nil
+		}
+
+		child, err := setPathCopy(m[key], keys[1:], value, keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		copied[key] = child
+
+		return copied, nil
+	}
+
+	if list, ok := parent.([]interface{}); ok {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(list) {
+			return nil, fmt.Errorf("%w: %s is not a valid index of the list at %s", ErrInvalidInput, key, parentPath)
+		}
+
+		copied := make([]interface{}, len(list))
+		copy(copied, list)
+
+		if len(keys) == 1 {
+			copied[i] = value
+
+			return copied, nil
+		}
+
+		child, err := setPathCopy(list[i], keys[1:], value, keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		copied[i] = child
+
+		return copied, nil
+	}
+
+	return nil, fmt.Errorf("%w: the value at %s is a %T and not a map or a list", ErrInvalidInput, parentPath, parent)
+}
+
+// splitEscapedPath splits the path on the dots that aren't escaped with a backslash.
+func splitEscapedPath(path string) []string {
+	keys := []string{}
+
+	var key strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+
+	return append(keys, key.String())
+}
@@ -0,0 +1,301 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSortedPairs(t *testing.T) {
+	t.Parallel()
+
+	pairs, err := sortedPairs(map[string]interface{}{"c": 3, "a": "1", "b": nil})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectedKeys := []string{"a", "b", "c"}
+
+	if len(pairs) != len(expectedKeys) {
+		t.Fatalf("Expected %d pairs but got %d", len(expectedKeys), len(pairs))
+	}
+
+	for i, key := range expectedKeys {
+		if pairs[i].Key != key {
+			t.Fatalf("Expected the key %s at index %d but got %s", key, i, pairs[i].Key)
+		}
+	}
+
+	if pairs[2].Value != 3 {
+		t.Fatalf("Expected the value 3 but got %v", pairs[2].Value)
+	}
+
+	pairs, err = sortedPairs(map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if len(pairs) != 1 || pairs[0].Key != "key" || pairs[0].Value != "value" {
+		t.Fatalf("Unexpected pairs: %v", pairs)
+	}
+
+	pairs, err = sortedPairs(nil)
+	if err != nil || len(pairs) != 0 {
+		t.Fatalf("Expected no pairs and no error but got %v and %v", pairs, err)
+	}
+
+	_, err = sortedPairs([]string{"a"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestSortedPairsTemplate(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `data: '{{ range $i, $pair := sortedPairs (fromJson "{\"b\":2,\"a\":1}") }}` +
+			`{{ if $i }},{{ end }}{{ $pair.Key }}={{ $pair.Value }}{{ end }}'`,
+		expectedResult: "data: a=1,b=2",
+	})
+}
+
+func TestDeepMerge(t *testing.T) {
+	t.Parallel()
+
+	defaults := map[string]interface{}{
+		"replicas": 1,
+		"debug":    true,
+		"image":    map[string]interface{}{"repository": "app", "tag": "1.0"},
+		"ports":    []interface{}{80, 443},
+	}
+	overrides := map[string]interface{}{
+		"debug": false,
+		"image": map[string]interface{}{"tag": "2.0"},
+		"ports": []interface{}{8080},
+		"env":   map[string]interface{}{"LEVEL": "info"},
+	}
+
+	merged, err := deepMerge(defaults, nil, overrides, map[string]string{"replicas": "3"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"replicas": "3",
+		"debug":    false,
+		"image":    map[string]interface{}{"repository": "app", "tag": "2.0"},
+		"ports":    []interface{}{8080},
+		"env":      map[string]interface{}{"LEVEL": "info"},
+	}
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("Expected %v but got %v", expected, merged)
+	}
+
+	// The input maps must not be modified
+	if defaults["image"].(map[string]interface{})["tag"] != "1.0" || defaults["debug"] != true {
+		t.Fatalf("The defaults were modified: %v", defaults)
+	}
+
+	merged["env"].(map[string]interface{})["LEVEL"] = "debug"
+
+	if overrides["env"].(map[string]interface{})["LEVEL"] != "info" {
+		t.Fatalf("The overrides share a nested map with the result: %v", overrides)
+	}
+
+	merged, err = deepMerge()
+	if err != nil || len(merged) != 0 {
+		t.Fatalf("Expected an empty map and no error but got %v and %v", merged, err)
+	}
+
+	_, err = deepMerge(defaults, []string{"a"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestDeepMergeTemplate(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `data: '{{ $merged := deepMerge (fromJson "{\"a\":{\"b\":1,\"c\":true}}") ` +
+			`(fromJson "{\"a\":{\"c\":false}}") }}{{ $merged.a.b }},{{ $merged.a.c }}'`,
+		expectedResult: "data: 1,false",
+	})
+}
+
+func TestDig(t *testing.T) {
+	t.Parallel()
+
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas":   int64(3),
+			"paused":     nil,
+			"containers": []interface{}{map[string]interface{}{"name": "app"}},
+		},
+		"data": map[string]string{"key": "value"},
+	}
+
+	tests := map[string]struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		"nested value":       {[]interface{}{"spec", "replicas", 1, obj}, int64(3)},
+		"missing key":        {[]interface{}{"spec", "missing", 1, obj}, 1},
+		"missing parent":     {[]interface{}{"status", "phase", "Unknown", obj}, "Unknown"},
+		"nil value":          {[]interface{}{"spec", "paused", false, obj}, false},
+		"non-map parent":     {[]interface{}{"spec", "replicas", "x", "default", obj}, "default"},
+		"list index":         {[]interface{}{"spec", "containers", 0, "name", "", obj}, "app"},
+		"list out of range":  {[]interface{}{"spec", "containers", "1", "name", "none", obj}, "none"},
+		"map[string]string":  {[]interface{}{"data", "key", "", obj}, "value"},
+		"nil map":            {[]interface{}{"spec", "default", nil}, "default"},
+		"nested map default": {[]interface{}{"spec", map[string]interface{}{}, obj}, obj["spec"]},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := dig(test.args...)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, result)
+			}
+		})
+	}
+
+	_, err := dig("default", obj)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for too few arguments but got %v", err)
+	}
+
+	_, err = dig("key", "default", "not a map")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for a non-map but got %v", err)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	t.Parallel()
+
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "app", "labels": map[string]interface{}{"tier": "web"}},
+		"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app"}}},
+		"data":     map[string]string{"key": "value"},
+	}
+
+	tests := map[string]struct {
+		path     string
+		value    interface{}
+		check    []interface{}
+		expected interface{}
+	}{
+		"existing map": {
+			path:     "metadata.labels.env",
+			value:    "prod",
+			check:    []interface{}{"metadata", "labels", "env"},
+			expected: "prod",
+		},
+		"missing maps": {
+			path:     "metadata.annotations.owner",
+			value:    "team",
+			check:    []interface{}{"metadata", "annotations", "owner"},
+			expected: "team",
+		},
+		"escaped dot": {
+			path:     `metadata.labels.app\.kubernetes\.io/name`,
+			value:    "app",
+			check:    []interface{}{"metadata", "labels", "app.kubernetes.io/name"},
+			expected: "app",
+		},
+		"list index": {
+			path:     "spec.containers.0.image",
+			value:    "app:1",
+			check:    []interface{}{"spec", "containers", "0", "image"},
+			expected: "app:1",
+		},
+		"map[string]string": {
+			path:     "data.other",
+			value:    "x",
+			check:    []interface{}{"data", "other"},
+			expected: "x",
+		},
+		"replace": {
+			path:     "metadata",
+			value:    "replaced",
+			check:    []interface{}{"metadata"},
+			expected: "replaced",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := setPath(obj, test.path, test.value)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			actual, err := dig(append(test.check, nil, result)...)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, actual)
+			}
+
+			// The input map must not be modified
+			if _, ok := obj["metadata"].(map[string]interface{})["annotations"]; ok {
+				t.Fatal("The input map was modified")
+			}
+
+			if len(obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{})) != 1 {
+				t.Fatal("The input labels were modified")
+			}
+
+			containers := obj["spec"].(map[string]interface{})["containers"].([]interface{})
+			if len(containers[0].(map[string]interface{})) != 1 {
+				t.Fatal("The input list was modified")
+			}
+		})
+	}
+
+	result, err := setPath(nil, "a.b", 1)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !reflect.DeepEqual(result, map[string]interface{}{"a": map[string]interface{}{"b": 1}}) {
+		t.Fatalf("Unexpected result: %v", result)
+	}
+
+	for _, path := range []string{"metadata.name.first", "spec.containers.1.name", "spec.containers.x", "a..b"} {
+		_, err = setPath(obj, path, "value")
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for the path %s but got %v", path, err)
+		}
+	}
+}
+
+func TestDigAndSetPathTemplate(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `data: '{{ $obj := fromJson "{\"metadata\":{\"name\":\"app\"}}" }}` +
+			`{{ $obj = setPath $obj "metadata.labels.env" "prod" }}` +
+			`{{ dig "metadata" "labels" "env" "none" $obj }},{{ dig "spec" "replicas" 1 $obj }}'`,
+		expectedResult: "data: prod,1",
+	})
+}
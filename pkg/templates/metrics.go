@@ -0,0 +1,239 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const metricsNamespace = "go_template_utils"
+
+// resolverMetrics are the Prometheus metrics of a TemplateResolver. All methods are no-ops on a nil receiver so that
+// metrics are optional.
+type resolverMetrics struct {
+	resolutions        *prometheus.CounterVec
+	resolutionDuration prometheus.Histogram
+	resolutionErrors   *prometheus.CounterVec
+	lookups            *prometheus.CounterVec
+	tempCallCache      *prometheus.CounterVec
+	resultCache        *prometheus.CounterVec
+	templateCache      *prometheus.CounterVec
+}
+
+// newResolverMetrics creates the metrics and registers them with the input registerer. If the metrics are already
+// registered, such as by another TemplateResolver, the registered metrics are reused.
+func newResolverMetrics(registerer prometheus.Registerer) (*resolverMetrics, error) {
+	metrics := &resolverMetrics{
+		resolutions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "resolutions_total",
+				Help:      "The number of template resolutions by result (success or error).",
+			},
+			[]string{"result"},
+		),
+		resolutionDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "resolution_duration_seconds",
+				Help:      "The duration of template resolutions in seconds.",
+				Buckets:   prometheus.DefBuckets,
+			},
+		),
+		resolutionErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "resolution_errors_total",
+				Help:      "The number of failed template resolutions by error type.",
+			},
+			[]string{"type"},
+		),
+		lookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "lookups_total",
+				Help:      "The number of lookups made by template functions by group, version, and kind.",
+			},
+			[]string{"group", "version", "kind"},
+		),
+		tempCallCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "temp_call_cache_requests_total",
+				Help:      "The number of temporary call cache requests by result (hit or miss).",
+			},
+			[]string{"result"},
+		),
+		resultCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "result_cache_requests_total",
+				Help:      "The number of result cache requests by result (hit or miss).",
+			},
+			[]string{"result"},
+		),
+		templateCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "template_cache_requests_total",
+				Help:      "The number of parsed template cache requests by result (hit or miss).",
+			},
+			[]string{"result"},
+		),
+	}
+
+	var err error
+
+	metrics.resolutions, err = registerCollector(registerer, metrics.resolutions)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.resolutionDuration, err = registerCollector(registerer, metrics.resolutionDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.resolutionErrors, err = registerCollector(registerer, metrics.resolutionErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.lookups, err = registerCollector(registerer, metrics.lookups)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.tempCallCache, err = registerCollector(registerer, metrics.tempCallCache)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.resultCache, err = registerCollector(registerer, metrics.resultCache)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.templateCache, err = registerCollector(registerer, metrics.templateCache)
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// registerCollector registers the collector and returns it. If an equivalent collector is already registered, the
+// registered collector is returned instead.
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector, nil
+	}
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+
+	return collector, err
+}
+
+func (m *resolverMetrics) observeResolution(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.resolutionDuration.Observe(time.Since(start).Seconds())
+
+	if err == nil {
+		m.resolutions.WithLabelValues("success").Inc()
+
+		return
+	}
+
+	m.resolutions.WithLabelValues("error").Inc()
+	m.resolutionErrors.WithLabelValues(resolutionErrorType(err)).Inc()
+}
+
+func (m *resolverMetrics) observeLookup(gvk schema.GroupVersionKind) {
+	if m == nil {
+		return
+	}
+
+	m.lookups.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Inc()
+}
+
+func (m *resolverMetrics) observeTempCallCache(hit bool) {
+	if m == nil {
+		return
+	}
+
+	if hit {
+		m.tempCallCache.WithLabelValues("hit").Inc()
+	} else {
+		m.tempCallCache.WithLabelValues("miss").Inc()
+	}
+}
+
+func (m *resolverMetrics) observeResultCache(hit bool) {
+	if m == nil {
+		return
+	}
+
+	if hit {
+		m.resultCache.WithLabelValues("hit").Inc()
+	} else {
+		m.resultCache.WithLabelValues("miss").Inc()
+	}
+}
+
+func (m *resolverMetrics) observeTemplateCache(hit bool) {
+	if m == nil {
+		return
+	}
+
+	if hit {
+		m.templateCache.WithLabelValues("hit").Inc()
+	} else {
+		m.templateCache.WithLabelValues("miss").Inc()
+	}
+}
+
+// resolutionErrorType returns a low cardinality description of the error for the resolution_errors_total metric.
+func resolutionErrorType(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingAPIResource):
+		return "missing_api_resource"
+	case errors.Is(err, ErrAPIUnavailable):
+		return "api_unavailable"
+	case errors.Is(err, ErrRestrictedNamespace), errors.Is(err, ErrClusterScopedLookupRestricted),
+		errors.Is(err, ErrKindLookupRestricted), errors.Is(err, ErrLookupAccessDenied):
+		return "restricted"
+	case errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return "unauthorized"
+	case errors.Is(err, ErrContextTransformerFailed):
+		return "context_transformer"
+	case errors.Is(err, ErrLookupQuotaExceeded):
+		return "lookup_quota_exceeded"
+	case errors.Is(err, ErrExecutionTimeout), errors.Is(err, ErrExecutionStepsExceeded):
+		return "execution_limit"
+	case errors.Is(err, ErrParseFailed):
+		return "parse"
+	case errors.Is(err, ErrEncryptionFailed), errors.Is(err, ErrDecryptionFailed):
+		return "encryption"
+	case errors.Is(err, ErrWatcherFailed):
+		return "watcher"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResolverMetrics(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+
+	resolver, err := NewResolver(k8sConfig, Config{MetricsRegisterer: registry})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// A second resolver with the same registerer must reuse the registered metrics.
+	resolver2, err := NewResolver(k8sConfig, Config{MetricsRegisterer: registry})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if resolver.metrics.resolutions != resolver2.metrics.resolutions {
+		t.Fatalf("Expected the resolvers to share the registered metrics")
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`value: '{{ "a" }}'`), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver2.ResolveTemplate(
+		[]byte(`value: '{{ "a" }}'`),
+		nil,
+		&ResolveOptions{CustomFunctions: map[string]interface{}{"not-valid": func() string { return "" }}},
+	)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	if value := testutil.ToFloat64(resolver.metrics.resolutions.WithLabelValues("success")); value != 1 {
+		t.Fatalf("Expected 1 successful resolution but got %v", value)
+	}
+
+	if value := testutil.ToFloat64(resolver.metrics.resolutions.WithLabelValues("error")); value != 1 {
+		t.Fatalf("Expected 1 failed resolution but got %v", value)
+	}
+
+	if value := testutil.ToFloat64(resolver.metrics.resolutionErrors.WithLabelValues("invalid_input")); value != 1 {
+		t.Fatalf("Expected 1 invalid_input error but got %v", value)
+	}
+
+	if count := testutil.CollectAndCount(registry, metricsNamespace+"_resolution_duration_seconds"); count != 1 {
+		t.Fatalf("Expected the resolution duration histogram to be registered but got %d metrics", count)
+	}
+
+	resolver.metrics.observeLookup(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	resolver.metrics.observeTempCallCache(true)
+	resolver.metrics.observeTempCallCache(false)
+	resolver.metrics.observeTempCallCache(false)
+
+	if value := testutil.ToFloat64(resolver.metrics.lookups.WithLabelValues("", "v1", "ConfigMap")); value != 1 {
+		t.Fatalf("Expected 1 ConfigMap lookup but got %v", value)
+	}
+
+	if value := testutil.ToFloat64(resolver.metrics.tempCallCache.WithLabelValues("miss")); value != 2 {
+		t.Fatalf("Expected 2 cache misses but got %v", value)
+	}
+}
+
+func TestResolverMetricsNil(t *testing.T) {
+	t.Parallel()
+
+	var metrics *resolverMetrics
+
+	// None of these should panic when metrics are disabled.
+	metrics.observeResolution(time.Now(), errors.New("some error"))
+	metrics.observeLookup(schema.GroupVersionKind{Version: "v1", Kind: "Secret"})
+	metrics.observeTempCallCache(true)
+}
+
+func TestResolutionErrorType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err      error
+		expected string
+	}{
+		"missing API resource": {fmt.Errorf("%w: Foo", ErrMissingAPIResource), "missing_api_resource"},
+		"API unavailable":      {fmt.Errorf("%w: bar", ErrAPIUnavailable), "api_unavailable"},
+		"restricted namespace": {fmt.Errorf("%w: baz", ErrRestrictedNamespace), "restricted"},
+		"invalid input":        {fmt.Errorf("template: %w", ErrInvalidInput), "invalid_input"},
+		"lookup quota":         {fmt.Errorf("%w: 1", ErrLookupQuotaExceeded), "lookup_quota_exceeded"},
+		"cluster-scoped":       {ClusterScopedLookupRestrictedError{Kind: "Node", Name: "foo"}, "restricted"},
+		"parse":                {classifyError(ErrParseFailed, errors.New("unexpected EOF")), "parse"},
+		"decryption":           {fmt.Errorf("decryption failed: %w", ErrDecryptionFailed), "encryption"},
+		"watcher":              {classifyError(ErrWatcherFailed, errors.New("watch failed")), "watcher"},
+		"execution timeout":    {fmt.Errorf("%w: 1s", ErrExecutionTimeout), "execution_limit"},
+		"other":                {errors.New("something else"), "other"},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := resolutionErrorType(test.err); actual != test.expected {
+				t.Fatalf("Expected %s but got %s", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+var ErrMaxPassesExceeded = errors.New("the template still has template actions after the maximum number of passes")
+
+// resolveNestedTemplates resolves the template actions in the output of the first resolution pass, such as template
+// fragments returned by fromConfigMap, until no template actions remain. The input and returned templates are YAML.
+// An error wrapping ErrMaxPassesExceeded is returned if template actions remain after options.MaxPasses passes, which
+// also protects against templates that resolve to themselves.
+func (t *TemplateResolver) resolveNestedTemplates(
+	resolved []byte, funcMap template.FuncMap, ctx interface{}, options *ResolveOptions,
+) ([]byte, error) {
+	for pass := uint(2); t.hasTemplateOutsideVerbatim(options, resolved); pass++ {
+		if pass > options.MaxPasses {
+			return nil, fmt.Errorf("%w: options.MaxPasses is %d", ErrMaxPassesExceeded, options.MaxPasses)
+		}
+
+		// Normalize the output of the previous pass like the input template so that the quoting of the nested
+		// templates is consistent before the data type processing
+		resolvedJSON, err := yamlToJSON(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the output of pass %d to JSON: %w", pass-1, err)
+		}
+
+		templateYAML, err := JSONToYAML(resolvedJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the output of pass %d to YAML: %w", pass-1, err)
+		}
+
+		templateStr, verbatimBlocks := t.processTemplate(options, string(templateYAML))
+
+		t.logger(options).V(2).Info(
+			"Resolving the nested templates", "pass", pass, "template", options.state.redact(options, templateStr),
+		)
+
+		tmpl, err := t.parseTemplate("tmpl", templateStr, funcMap, options)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to parse the template in pass %d: %w", pass,
+				classifyError(
+					ErrParseFailed, newTemplateError(templateStr, deniedFunctionError(err, options.DeniedFunctions)),
+				),
+			)
+		}
+
+		var buf bytes.Buffer
+
+		err = tmpl.Execute(options.limitWriter(&buf), ctx)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to resolve the template in pass %d: %w", pass, newTemplateError(templateStr, err),
+			)
+		}
+
+		resolved = restoreVerbatimBlocks(buf.Bytes(), verbatimBlocks)
+	}
+
+	return resolved, nil
+}
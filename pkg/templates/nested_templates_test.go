@@ -0,0 +1,74 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveTemplateMaxPasses(t *testing.T) {
+	t.Parallel()
+
+	ctx := struct{ ClusterName string }{ClusterName: "cluster1"}
+	// Each template outputs the template of the previous level
+	nestedOnce := `value: '{{ printf "%s .ClusterName %s" "{{" "}}" }}'`
+	nestedTwice := `value: '{{ printf "%s printf \"%%s .ClusterName %%s\" \"{{\" \"}}\" %s" "{{" "}}" }}'`
+
+	testcases := map[string]resolveTestCase{
+		"single pass by default": {
+			inputTmpl:      nestedOnce,
+			ctx:            ctx,
+			expectedResult: "value: '{{ .ClusterName }}'",
+		},
+		"single pass": {
+			inputTmpl:      nestedOnce,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 1},
+			expectedResult: "value: '{{ .ClusterName }}'",
+		},
+		"nested template": {
+			inputTmpl:      nestedOnce,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 2},
+			expectedResult: "value: cluster1",
+		},
+		"no nested template": {
+			inputTmpl:      `value: '{{ .ClusterName }}'`,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 2},
+			expectedResult: "value: cluster1",
+		},
+		"twice nested template": {
+			inputTmpl:      nestedTwice,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 3},
+			expectedResult: "value: cluster1",
+		},
+		"twice nested template exceeds max passes": {
+			inputTmpl:      nestedTwice,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 2},
+			expectedErr:    ErrMaxPassesExceeded,
+		},
+		"nested template error": {
+			inputTmpl:      `value: '{{ printf "%s notAFunction %s" "{{" "}}" }}'`,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MaxPasses: 2},
+			expectedErr: errors.New(
+				`failed to resolve the nested templates of ` +
+					`{"value":"{{ printf \"%s notAFunction %s\" \"{{\" \"}}\" }}"}: ` +
+					`failed to parse the template in pass 2: template: tmpl:1: function "notAFunction" not defined`,
+			),
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
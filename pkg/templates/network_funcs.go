@@ -0,0 +1,161 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// cidrhost returns the IP address of the host number in the input CIDR, such as `{{ cidrhost "10.0.0.0/24" 5 }}` =>
+// `10.0.0.5`. A negative host number counts back from the end of the range, so -1 is the last address. This matches
+// the Terraform function of the same name.
+func cidrhost(cidr string, hostnum interface{}) (string, error) {
+	prefix, err := parsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	num, err := cast.ToInt64E(hostnum)
+	if err != nil {
+		return "", fmt.Errorf("%w: the host number %v is not an integer", ErrInvalidInput, hostnum)
+	}
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+	offset := big.NewInt(num)
+
+	if num < 0 {
+		offset.Add(offset, size)
+	}
+
+	if offset.Sign() < 0 || offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("%w: the prefix %s has no host number %d", ErrInvalidInput, cidr, num)
+	}
+
+	return addToAddr(prefix.Addr(), offset).String(), nil
+}
+
+// cidrsubnet returns the subnet of the input CIDR with the prefix length extended by newbits and the network number
+// netnum, such as `{{ cidrsubnet "10.0.0.0/16" 8 2 }}` => `10.0.2.0/24`. This matches the Terraform function of the
+// same name.
+func cidrsubnet(cidr string, newbits interface{}, netnum interface{}) (string, error) {
+	prefix, err := parsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	bits, err := cast.ToIntE(newbits)
+	if err != nil || bits < 0 {
+		return "", fmt.Errorf("%w: the new bits %v is not a non-negative integer", ErrInvalidInput, newbits)
+	}
+
+	num, err := cast.ToInt64E(netnum)
+	if err != nil || num < 0 {
+		return "", fmt.Errorf("%w: the network number %v is not a non-negative integer", ErrInvalidInput, netnum)
+	}
+
+	newPrefixLen := prefix.Bits() + bits
+	if newPrefixLen > prefix.Addr().BitLen() {
+		return "", fmt.Errorf(
+			"%w: extending the prefix %s by %d bits exceeds the address length", ErrInvalidInput, cidr, bits,
+		)
+	}
+
+	if big.NewInt(num).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(bits))) >= 0 {
+		return "", fmt.Errorf("%w: the network number %d doesn't fit in %d bits", ErrInvalidInput, num, bits)
+	}
+
+	hostBits := prefix.Addr().BitLen() - newPrefixLen
+	offset := new(big.Int).Lsh(big.NewInt(num), uint(hostBits))
+
+	return netip.PrefixFrom(addToAddr(prefix.Addr(), offset), newPrefixLen).String(), nil
+}
+
+// cidrContains returns whether the input CIDR contains the IP address or all of the addresses of the CIDR in addr.
+// Addresses of different IP families are never contained.
+func cidrContains(cidr string, addr string) (bool, error) {
+	prefix, err := parsePrefix(cidr)
+	if err != nil {
+		return false, err
+	}
+
+	if strings.Contains(addr, "/") {
+		other, err := parsePrefix(addr)
+		if err != nil {
+			return false, err
+		}
+
+		return other.Bits() >= prefix.Bits() && prefix.Contains(other.Addr()), nil
+	}
+
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s is not a valid IP address", ErrInvalidInput, addr)
+	}
+
+	return prefix.Contains(ip.Unmap()), nil
+}
+
+// ipFamily returns IPv4 or IPv6 for the input IP address or CIDR. IPv4-mapped IPv6 addresses are IPv4.
+func ipFamily(addr string) (string, error) {
+	var ip netip.Addr
+
+	if strings.Contains(addr, "/") {
+		prefix, err := parsePrefix(addr)
+		if err != nil {
+			return "", err
+		}
+
+		ip = prefix.Addr()
+	} else {
+		var err error
+
+		ip, err = netip.ParseAddr(addr)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s is not a valid IP address", ErrInvalidInput, addr)
+		}
+
+		ip = ip.Unmap()
+	}
+
+	if ip.Is4() {
+		return "IPv4", nil
+	}
+
+	return "IPv6", nil
+}
+
+// parsePrefix parses the CIDR and returns the masked prefix, so the host bits of the input address are ignored.
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %s is not a valid CIDR", ErrInvalidInput, cidr)
+	}
+
+	if prefix.Addr().Is4In6() {
+		if prefix.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("%w: %s is not a valid IPv4-mapped CIDR", ErrInvalidInput, cidr)
+		}
+
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+
+	return prefix.Masked(), nil
+}
+
+// addToAddr returns the IP address offset from the input address. The offset must fit in the address range.
+func addToAddr(addr netip.Addr, offset *big.Int) netip.Addr {
+	sum := new(big.Int).Add(new(big.Int).SetBytes(addr.AsSlice()), offset)
+
+	bytes := make([]byte, addr.BitLen()/8)
+	sum.FillBytes(bytes)
+
+	result, _ := netip.AddrFromSlice(bytes)
+
+	return result
+}
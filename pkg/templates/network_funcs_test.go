@@ -0,0 +1,183 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCidrhost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cidr     string
+		hostnum  interface{}
+		expected string
+	}{
+		"IPv4":                 {"10.0.0.0/24", 5, "10.0.0.5"},
+		"IPv4 unmasked":        {"10.0.0.17/24", 5, "10.0.0.5"},
+		"IPv4 carry":           {"10.0.0.0/16", 300, "10.0.1.44"},
+		"IPv4 last":            {"10.0.0.0/24", -1, "10.0.0.255"},
+		"IPv4 float64":         {"192.168.0.0/30", float64(3), "192.168.0.3"},
+		"IPv4 string":          {"192.168.0.0/30", "2", "192.168.0.2"},
+		"IPv6":                 {"fd00::/64", 10, "fd00::a"},
+		"IPv6 last":            {"fd00::/120", -2, "fd00::fe"},
+		"IPv6 large host part": {"2001:db8::/32", int64(1) << 40, "2001:db8::100:0:0"},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := cidrhost(test.cidr, test.hostnum)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Expected %s but got %s", test.expected, result)
+			}
+		})
+	}
+
+	for _, args := range [][]interface{}{
+		{"10.0.0.0/24", 256}, {"10.0.0.0/24", -257}, {"10.0.0.0", 1}, {"10.0.0.0/24", "one"},
+	} {
+		_, err := cidrhost(args[0].(string), args[1])
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %v but got %v", args, err)
+		}
+	}
+}
+
+func TestCidrsubnet(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cidr     string
+		newbits  interface{}
+		netnum   interface{}
+		expected string
+	}{
+		"IPv4":            {"10.0.0.0/16", 8, 2, "10.0.2.0/24"},
+		"IPv4 unmasked":   {"10.0.99.1/16", 8, 0, "10.0.0.0/24"},
+		"IPv4 odd bits":   {"10.1.0.0/16", 4, 15, "10.1.240.0/20"},
+		"IPv4 zero bits":  {"10.0.0.0/16", 0, 0, "10.0.0.0/16"},
+		"IPv6":            {"fd00::/48", 16, 3, "fd00:0:0:3::/64"},
+		"IPv6 large bits": {"2001:db8::/32", 32, 1, "2001:db8:0:1::/64"},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := cidrsubnet(test.cidr, test.newbits, test.netnum)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Expected %s but got %s", test.expected, result)
+			}
+		})
+	}
+
+	for _, args := range [][]interface{}{
+		{"10.0.0.0/16", 8, 256}, {"10.0.0.0/16", 17, 0}, {"10.0.0.0/16", -1, 0}, {"10.0.0.0/16", 8, -1},
+		{"invalid", 8, 0},
+	} {
+		_, err := cidrsubnet(args[0].(string), args[1], args[2])
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %v but got %v", args, err)
+		}
+	}
+}
+
+func TestCidrContains(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cidr     string
+		addr     string
+		expected bool
+	}{
+		"IPv4 contained":        {"10.0.0.0/16", "10.0.255.1", true},
+		"IPv4 not contained":    {"10.0.0.0/16", "10.1.0.1", false},
+		"IPv4 subnet contained": {"10.0.0.0/16", "10.0.4.0/24", true},
+		"IPv4 larger subnet":    {"10.0.0.0/16", "10.0.0.0/8", false},
+		"IPv4 same subnet":      {"10.0.0.0/16", "10.0.0.0/16", true},
+		"IPv6 contained":        {"fd00::/64", "fd00::1234", true},
+		"IPv6 not contained":    {"fd00::/64", "fd01::1", false},
+		"different families":    {"10.0.0.0/8", "fd00::1", false},
+		"IPv4-mapped IPv6":      {"10.0.0.0/8", "::ffff:10.1.2.3", true},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := cidrContains(test.cidr, test.addr)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Expected %t but got %t", test.expected, result)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"10.0.0.0/16", "10.0.0"}, {"10.0.0.0", "10.0.0.1"}, {"10.0.0.0/16", "10.0.0.0/33"},
+	} {
+		_, err := cidrContains(args[0], args[1])
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %v but got %v", args, err)
+		}
+	}
+}
+
+func TestIPFamily(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"10.0.0.1":        "IPv4",
+		"10.0.0.0/8":      "IPv4",
+		"fd00::1":         "IPv6",
+		"fd00::/64":       "IPv6",
+		"::ffff:10.0.0.1": "IPv4",
+	}
+
+	for addr, expected := range tests {
+		result, err := ipFamily(addr)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if result != expected {
+			t.Fatalf("Expected %s for %s but got %s", expected, addr, result)
+		}
+	}
+
+	_, err := ipFamily("not an IP")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestResolveTemplateNetworkFuncs(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `value: '{{ $cidr := "10.128.0.0/14" }}{{ cidrhost (cidrsubnet $cidr 9 3) 1 }},` +
+			`{{ cidrContains $cidr "10.130.0.1" }},{{ ipFamily $cidr }}'`,
+		expectedResult: "value: 10.128.6.1,true,IPv4",
+	})
+}
@@ -0,0 +1,334 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/ini.v1"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var ErrDuplicateKey = errors.New("the input has a duplicate key")
+
+// fromJSONStrict is like the sprig mustFromJson function but returns an error wrapping ErrDuplicateKey if an object in
+// the input has the same key more than once. This also replaces the sprig fromJson and mustFromJson functions when
+// ResolveOptions.StrictParsing is set.
+func fromJSONStrict(str string) (interface{}, error) {
+	return parseJSON(str, true)
+}
+
+// parseJSON decodes the input JSON string. If strict is set, an error wrapping ErrDuplicateKey is returned if an object
+// in the input has the same key more than once. Otherwise, the last value of the key is used.
+func parseJSON(str string, strict bool) (interface{}, error) {
+	if strict {
+		decoder := json.NewDecoder(strings.NewReader(str))
+
+		err := checkJSONDuplicateKeys(decoder, "")
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	}
+
+	var output interface{}
+
+	err := json.Unmarshal([]byte(str), &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the JSON: %w", err)
+	}
+
+	return output, nil
+}
+
+// checkJSONDuplicateKeys reads the next JSON value from the decoder and returns an error wrapping ErrDuplicateKey if an
+// object in the value has the same key more than once. The path is the dot separated path of the value for the error
+// message.
+func checkJSONDuplicateKeys(decoder *json.Decoder, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		keys := map[string]bool{}
+
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			key, _ := keyToken.(string)
+			if keys[key] {
+				return fmt.Errorf("%w: %s", ErrDuplicateKey, joinFieldPath(path, key))
+			}
+
+			keys[key] = true
+
+			if err := checkJSONDuplicateKeys(decoder, joinFieldPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; decoder.More(); i++ {
+			if err := checkJSONDuplicateKeys(decoder, joinFieldPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing delimiter
+	_, err = decoder.Token()
+
+	return err
+}
+
+func (t *TemplateResolver) fromJSONHelper(options *ResolveOptions) func(string) (interface{}, error) {
+	return func(str string) (interface{}, error) {
+		return parseJSON(str, options.StrictParsing)
+	}
+}
+
+// toJSON encodes the input as compact JSON. Unlike the sprig toJson function, an error is returned if the input can't
+// be encoded instead of an empty string, and the HTML characters <, >, and & are not escaped since the output is not
+// embedded in HTML. Map keys are sorted so that the output is stable.
+func toJSON(v interface{}) (string, error) {
+	return encodeJSON(v, "")
+}
+
+// toPrettyJSON is like toJSON but indents the output with two spaces.
+func toPrettyJSON(v interface{}) (string, error) {
+	return encodeJSON(v, "  ")
+}
+
+func encodeJSON(v interface{}, indent string) (string, error) {
+	var buf strings.Builder
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+
+	err := encoder.Encode(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to encode the input as JSON: %w", ErrInvalidInput, err)
+	}
+
+	// Remove the newline added by the encoder
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// toYAMLIndented encodes the input as YAML and indents the lines after the first by the input number of spaces like
+// indent, so that a mapping or sequence lines up with its first line in the document. A `toYamlIndented` placeholder
+// at the end of a pipeline is converted to `toYamlIndented N` by processForYAMLIndent.
+func (t *TemplateResolver) toYAMLIndented(spaces int, v interface{}) (string, error) {
+	encoded, err := encodeJSON(v, "")
+	if err != nil {
+		return "", err
+	}
+
+	yamlBytes, err := JSONToYAML([]byte(encoded))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to encode the input as YAML: %w", ErrInvalidInput, err)
+	}
+
+	return t.indent(spaces, strings.TrimSuffix(string(yamlBytes), "\n")), nil
+}
+
+func (t *TemplateResolver) fromYAMLHelper(options *ResolveOptions) func(string) (interface{}, error) {
+	return func(str string) (interface{}, error) {
+		return parseYAML(str, options.StrictParsing)
+	}
+}
+
+// fromYAMLStrict is like fromYaml but always returns an error wrapping ErrDuplicateKey if a mapping in the input has
+// the same key more than once.
+func fromYAMLStrict(str string) (interface{}, error) {
+	return parseYAML(str, true)
+}
+
+// parseYAML decodes the input YAML string. If strict is set, an error wrapping ErrDuplicateKey is returned if a mapping
+// in the input has the same key more than once. Otherwise, the last value of the key is used.
+func parseYAML(str string, strict bool) (interface{}, error) {
+	var node yaml.Node
+
+	err := yaml.Unmarshal([]byte(str), &node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the YAML: %w", err)
+	}
+
+	return decodeYAMLNode(&node, "", strict)
+}
+
+// decodeYAMLNode converts the YAML node to the same types as parsing JSON, except that integers are kept as integers.
+// The path is the dot separated path of the node for the error message.
+func decodeYAMLNode(node *yaml.Node, path string, strict bool) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+
+		return decodeYAMLNode(node.Content[0], path, strict)
+	case yaml.AliasNode:
+		return decodeYAMLNode(node.Alias, path, strict)
+	case yaml.SequenceNode:
+		output := make([]interface{}, 0, len(node.Content))
+
+		for i, item := range node.Content {
+			value, err := decodeYAMLNode(item, joinFieldPath(path, strconv.Itoa(i)), strict)
+			if err != nil {
+				return nil, err
+			}
+
+			output = append(output, value)
+		}
+
+		return output, nil
+	case yaml.MappingNode:
+		output := map[string]interface{}{}
+		// Keys from merge keys (i.e. <<) don't override explicit keys and are not duplicates
+		merged := map[string]interface{}{}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+
+			if keyNode.Tag == "!!merge" {
+				value, err := decodeYAMLNode(node.Content[i+1], path, strict)
+				if err != nil {
+					return nil, err
+				}
+
+				mergeYAMLValue(merged, value)
+
+				continue
+			}
+
+			var key interface{}
+
+			if err := keyNode.Decode(&key); err != nil {
+				return nil, fmt.Errorf("failed to parse the YAML: %w", err)
+			}
+
+			keyPath := joinFieldPath(path, fmt.Sprint(key))
+
+			if _, ok := output[fmt.Sprint(key)]; ok && strict {
+				return nil, fmt.Errorf("%w: %s at line %d", ErrDuplicateKey, keyPath, keyNode.Line)
+			}
+
+			value, err := decodeYAMLNode(node.Content[i+1], keyPath, strict)
+			if err != nil {
+				return nil, err
+			}
+
+			output[fmt.Sprint(key)] = value
+		}
+
+		for key, value := range merged {
+			if _, ok := output[key]; !ok {
+				output[key] = value
+			}
+		}
+
+		return output, nil
+	default:
+		var output interface{}
+
+		if err := node.Decode(&output); err != nil {
+			return nil, fmt.Errorf("failed to parse the YAML: %w", err)
+		}
+
+		return output, nil
+	}
+}
+
+// mergeYAMLValue adds the keys of the value of a YAML merge key to merged. The value is either a map or a list of maps
+// where the earlier maps take precedence.
+func mergeYAMLValue(merged map[string]interface{}, value interface{}) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typedValue {
+			if _, ok := merged[key]; !ok {
+				merged[key] = item
+			}
+		}
+	case []interface{}:
+		for _, item := range typedValue {
+			mergeYAMLValue(merged, item)
+		}
+	}
+}
+
+// fromTOML decodes the input TOML document. Integers are kept as int64 and dates and times are decoded as time.Time
+// values or the local date and time types of the TOML library.
+func fromTOML(str string) (map[string]interface{}, error) {
+	output := map[string]interface{}{}
+
+	err := toml.Unmarshal([]byte(str), &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the TOML: %w", err)
+	}
+
+	return output, nil
+}
+
+// toTOML encodes the input map as a TOML document, such as a document from fromTOML with a modified setting. Nested
+// tables are not indented.
+func toTOML(v interface{}) (string, error) {
+	if reflect.ValueOf(v).Kind() != reflect.Map {
+		return "", fmt.Errorf("%w: toTOML requires a map, got %T", ErrInvalidInput, v)
+	}
+
+	var buf strings.Builder
+
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+
+	err := encoder.Encode(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to encode the input as TOML: %w", ErrInvalidInput, err)
+	}
+
+	return buf.String(), nil
+}
+
+// fromINI decodes the input INI document as a map of the section names to maps of the keys to the string values. The
+// keys before the first section are in the DEFAULT section, which is omitted if it has no keys.
+func fromINI(str string) (map[string]interface{}, error) {
+	file, err := ini.Load([]byte(str))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the INI: %w", err)
+	}
+
+	output := map[string]interface{}{}
+
+	for _, section := range file.Sections() {
+		keys := section.Keys()
+
+		if section.Name() == ini.DefaultSection && len(keys) == 0 {
+			continue
+		}
+
+		values := make(map[string]interface{}, len(keys))
+
+		for _, key := range keys {
+			values[key.Name()] = key.Value()
+		}
+
+		output[section.Name()] = values
+	}
+
+	return output, nil
+}
@@ -0,0 +1,377 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input       string
+		strict      bool
+		expected    interface{}
+		expectedErr string
+	}{
+		"lenient duplicate key": {
+			input:    `{"a": 1, "a": 2}`,
+			expected: map[string]interface{}{"a": float64(2)},
+		},
+		"strict duplicate key": {
+			input:       `{"a": 1, "a": 2}`,
+			strict:      true,
+			expectedErr: "the input has a duplicate key: a",
+		},
+		"strict nested duplicate key": {
+			input:       `{"a": [{"b": 1}, {"b": 1, "c": 2, "c": 3}]}`,
+			strict:      true,
+			expectedErr: "the input has a duplicate key: a.1.c",
+		},
+		"strict same key in different objects": {
+			input:  `{"a": {"b": 1}, "c": {"b": 2}}`,
+			strict: true,
+			expected: map[string]interface{}{
+				"a": map[string]interface{}{"b": float64(1)},
+				"c": map[string]interface{}{"b": float64(2)},
+			},
+		},
+		"strict list": {
+			input:    `[1, "two"]`,
+			strict:   true,
+			expected: []interface{}{float64(1), "two"},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := parseJSON(test.input, test.strict)
+			if test.expectedErr != "" {
+				if !errors.Is(err, ErrDuplicateKey) || err.Error() != test.expectedErr {
+					t.Fatalf("Expected the error %s but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No error was expected: %v", err)
+			}
+
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input       string
+		strict      bool
+		expected    interface{}
+		expectedErr string
+	}{
+		"lenient duplicate key": {
+			input:    "a: 1\na: 2",
+			expected: map[string]interface{}{"a": 2},
+		},
+		"strict duplicate key": {
+			input:       "a: 1\na: 2",
+			strict:      true,
+			expectedErr: "the input has a duplicate key: a at line 2",
+		},
+		"strict nested duplicate key": {
+			input:       "a:\n  - b: 1\n    b: 2",
+			strict:      true,
+			expectedErr: "the input has a duplicate key: a.0.b at line 3",
+		},
+		"strict merge key": {
+			input:  "base: &base\n  a: 1\n  b: 2\nchild:\n  <<: *base\n  b: 3",
+			strict: true,
+			expected: map[string]interface{}{
+				"base":  map[string]interface{}{"a": 1, "b": 2},
+				"child": map[string]interface{}{"a": 1, "b": 3},
+			},
+		},
+		"empty": {
+			input: "",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := parseYAML(test.input, test.strict)
+			if test.expectedErr != "" {
+				if !errors.Is(err, ErrDuplicateKey) || err.Error() != test.expectedErr {
+					t.Fatalf("Expected the error %s but got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No error was expected: %v", err)
+			}
+
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input          interface{}
+		expected       string
+		expectedPretty string
+	}{
+		"map": {
+			input:          map[string]interface{}{"b": int64(1), "a": []interface{}{"x", nil}},
+			expected:       `{"a":["x",null],"b":1}`,
+			expectedPretty: "{\n  \"a\": [\n    \"x\",\n    null\n  ],\n  \"b\": 1\n}",
+		},
+		"HTML characters": {
+			input:          map[string]string{"arg": "--filter=a<b&&c>d"},
+			expected:       `{"arg":"--filter=a<b&&c>d"}`,
+			expectedPretty: "{\n  \"arg\": \"--filter=a<b&&c>d\"\n}",
+		},
+		"string with quotes and newlines": {
+			input:          "say \"hi\"\n",
+			expected:       `"say \"hi\"\n"`,
+			expectedPretty: `"say \"hi\"\n"`,
+		},
+		"nil": {
+			input:          nil,
+			expected:       "null",
+			expectedPretty: "null",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := toJSON(test.input)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if output != test.expected {
+				t.Fatalf("Expected %s but got %s", test.expected, output)
+			}
+
+			output, err = toPrettyJSON(test.input)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if output != test.expectedPretty {
+				t.Fatalf("Expected %s but got %s", test.expectedPretty, output)
+			}
+		})
+	}
+
+	_, err := toJSON(map[string]interface{}{"invalid": make(chan int)})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestTOML(t *testing.T) {
+	t.Parallel()
+
+	doc := `title = "app"
+
+[server]
+port = 8080
+hosts = ["a", "b"]
+
+[server.tls]
+enabled = true
+`
+
+	output, err := fromTOML(doc)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"title": "app",
+		"server": map[string]interface{}{
+			"port":  int64(8080),
+			"hosts": []interface{}{"a", "b"},
+			"tls":   map[string]interface{}{"enabled": true},
+		},
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("Expected %v but got %v", expected, output)
+	}
+
+	output["server"].(map[string]interface{})["port"] = int64(9090)
+
+	encoded, err := toTOML(output)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectedTOML := `title = "app"
+
+[server]
+hosts = ["a", "b"]
+port = 9090
+[server.tls]
+enabled = true
+`
+
+	if encoded != expectedTOML {
+		t.Fatalf("Expected:\n%s\nbut got:\n%s", expectedTOML, encoded)
+	}
+
+	_, err = fromTOML("title = ")
+	if err == nil {
+		t.Fatal("Expected an error for invalid TOML")
+	}
+
+	_, err = toTOML([]string{"a"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got %v", err)
+	}
+}
+
+func TestFromINI(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input    string
+		expected map[string]interface{}
+	}{
+		"sections": {
+			input: "; comment\n[database]\nhost = db.example.com\nport: 5432\n\n[cache]\nenabled = true\n",
+			expected: map[string]interface{}{
+				"database": map[string]interface{}{"host": "db.example.com", "port": "5432"},
+				"cache":    map[string]interface{}{"enabled": "true"},
+			},
+		},
+		"default section": {
+			input: "name = app\n[server]\nport = 80\n",
+			expected: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{"name": "app"},
+				"server":  map[string]interface{}{"port": "80"},
+			},
+		},
+		"empty": {
+			input:    "",
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := fromINI(test.input)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Fatalf("Expected %v but got %v", test.expected, output)
+			}
+		})
+	}
+
+	_, err := fromINI("[unclosed\n")
+	if err == nil {
+		t.Fatal("Expected an error for invalid INI")
+	}
+}
+
+func TestResolveTemplateStrictParsing(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"fromJson lenient": {
+			inputTmpl:      `value: '{{ (fromJson "{\"a\": 1, \"a\": 2}").a }}'`,
+			expectedResult: "value: \"2\"",
+		},
+		"fromJson strict": {
+			inputTmpl:      `value: '{{ (fromJson "{\"a\": 1, \"a\": 2}").a }}'`,
+			resolveOptions: ResolveOptions{StrictParsing: true},
+			expectedErr:    ErrDuplicateKey,
+		},
+		"mustFromJson strict": {
+			inputTmpl:      `value: '{{ (mustFromJson "{\"a\": 1, \"a\": 2}").a }}'`,
+			resolveOptions: ResolveOptions{StrictParsing: true},
+			expectedErr:    ErrDuplicateKey,
+		},
+		"fromJsonStrict": {
+			inputTmpl:   `value: '{{ (fromJsonStrict "{\"a\": 1, \"a\": 2}").a }}'`,
+			expectedErr: ErrDuplicateKey,
+		},
+		"fromJSON lenient": {
+			inputTmpl:      `value: '{{ (fromJSON "{\"a\": 1, \"a\": 2}").a }}'`,
+			expectedResult: "value: \"2\"",
+		},
+		"fromJSON strict": {
+			inputTmpl:      `value: '{{ (fromJSON "{\"a\": 1, \"a\": 2}").a }}'`,
+			resolveOptions: ResolveOptions{StrictParsing: true},
+			expectedErr:    ErrDuplicateKey,
+		},
+		"toJSON round trip": {
+			inputTmpl:      `value: '{{ fromJSON "{\"b\": [1, \"<x>\"], \"a\": true}" | toJSON }}'`,
+			expectedResult: `value: '{"a":true,"b":[1,"<x>"]}'`,
+		},
+		"fromTOML": {
+			inputTmpl:      `value: '{{ (fromTOML "[server]\nport = 8080").server.port }}'`,
+			expectedResult: "value: \"8080\"",
+		},
+		"fromINI": {
+			inputTmpl:      `value: '{{ (fromINI "[server]\nport = 8080").server.port }}'`,
+			expectedResult: "value: \"8080\"",
+		},
+		"fromYaml lenient": {
+			inputTmpl:      `value: '{{ (fromYaml "a: 1\na: 2").a }}'`,
+			expectedResult: "value: \"2\"",
+		},
+		"fromYaml strict": {
+			inputTmpl:      `value: '{{ (fromYaml "a: 1\na: 2").a }}'`,
+			resolveOptions: ResolveOptions{StrictParsing: true},
+			expectedErr:    ErrDuplicateKey,
+		},
+		"fromYamlStrict": {
+			inputTmpl:   `value: '{{ (fromYamlStrict "a: 1\na: 2").a }}'`,
+			expectedErr: ErrDuplicateKey,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Prefetch populates the lookup cache with the input queries before resolving templates so that the lookups of the
+// template functions are served from the cache. This is useful when resolving many similar templates that look up
+// objects of the same kinds, since a single list query replaces a query per object.
+//
+// Each query is an object identifier like in TemplateResult.ReferencedObjects. A query with an empty Name lists the
+// objects in the namespace, or in all namespaces if Namespace is empty, that match the label selector in Selector.
+// Both the list query and each listed object are cached. A query with a Name gets the single object.
+//
+// This is only supported when caching is disabled since the watches already serve the lookups from a cache when
+// caching is enabled. Note that the built-in temporary call cache is cleared at the end of each ResolveTemplate call,
+// so set Config.LookupCacheTTL or Config.LookupCacheMaxEntries to keep the prefetched objects for more than the next
+// call.
+func (t *TemplateResolver) Prefetch(ctx context.Context, queries []client.ObjectIdentifier) error {
+	if t.dynamicWatcher != nil {
+		return fmt.Errorf("%w: Prefetch is not supported when caching is enabled", ErrInvalidInput)
+	}
+
+	for _, query := range queries {
+		err := t.prefetch(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to prefetch %s: %w", query, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TemplateResolver) prefetch(ctx context.Context, query client.ObjectIdentifier) error {
+	gvk := schema.GroupVersionKind{Group: query.Group, Version: query.Version, Kind: query.Kind}
+
+	if gvk.Version == "" || gvk.Kind == "" {
+		return fmt.Errorf("%w: the version and kind are required", ErrInvalidInput)
+	}
+
+	selector, err := labels.Parse(query.Selector)
+	if err != nil {
+		return fmt.Errorf("%w: the label selector is invalid: %w", ErrInvalidInput, err)
+	}
+
+	scopedGVRObj, err := t.getScopedGVR(gvk)
+	if err != nil {
+		return err
+	}
+
+	namespace := query.Namespace
+	if !scopedGVRObj.Namespaced {
+		namespace = ""
+	}
+
+	var dynamicClientRes dynamic.ResourceInterface
+
+	if namespace != "" {
+		dynamicClientRes = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(namespace)
+	} else {
+		dynamicClientRes = t.dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+	}
+
+	queryID := client.ObjectIdentifier{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: namespace,
+		Name:      query.Name,
+	}
+
+	if query.Name != "" {
+		obj, err := dynamicClientRes.Get(ctx, query.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				if !t.config.DisableNotFoundCache {
+					t.lookupCache.Set(queryID, []unstructured.Unstructured{})
+				}
+
+				return nil
+			}
+
+			return wrapAPIUnavailableError(gvk, err)
+		}
+
+		t.lookupCache.Set(queryID, []unstructured.Unstructured{*obj})
+
+		return nil
+	}
+
+	list, err := dynamicClientRes.List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return wrapAPIUnavailableError(gvk, err)
+	}
+
+	t.contextLogger(ctx).V(2).Info("Prefetched the objects", "count", len(list.Items), "query", query)
+
+	queryID.Selector = selector.String()
+	t.lookupCache.Set(queryID, list.Items)
+
+	for _, obj := range list.Items {
+		objID := client.ObjectIdentifier{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+
+		t.lookupCache.Set(objID, []unstructured.Unstructured{obj})
+	}
+
+	return nil
+}
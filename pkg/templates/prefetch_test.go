@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPrefetch(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{}
+
+	for _, name := range []string{"cm1", "cm2", "cm3"} {
+		objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": name, "namespace": "app", "labels": map[string]interface{}{"app": "web"},
+			},
+			"data": map[string]interface{}{"key": name},
+		}})
+	}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true, LookupCacheTTL: time.Minute})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = resolver.Prefetch(context.TODO(), []client.ObjectIdentifier{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "app"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "app", Selector: "app=web"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "not-found"},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `value: '{{ range (list "cm1" "cm2" "cm3") }}{{ fromConfigMap "app" . "key" }}{{ end }}-` +
+		`{{ len (lookup "v1" "ConfigMap" "app" "" "app=web").items }}-` +
+		`{{ empty (lookup "v1" "ConfigMap" "app" "not-found") }}'`
+
+	// The lookups would exceed the maximum if they weren't served from the prefetched cache
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{MaxLookups: 1})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if string(result.ResolvedJSON) != `{"value":"cm1cm2cm3-3-true"}` {
+		t.Fatalf("Unexpected result: %s", result.ResolvedJSON)
+	}
+}
+
+func TestPrefetchInvalid(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := map[string]struct {
+		query       client.ObjectIdentifier
+		expectedErr error
+	}{
+		"missing kind": {
+			query:       client.ObjectIdentifier{Version: "v1"},
+			expectedErr: ErrInvalidInput,
+		},
+		"invalid selector": {
+			query:       client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Selector: "app in"},
+			expectedErr: ErrInvalidInput,
+		},
+		"missing API resource": {
+			query:       client.ObjectIdentifier{Version: "v1", Kind: "NotAResource"},
+			expectedErr: ErrMissingAPIResource,
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			err := resolver.Prefetch(context.TODO(), []client.ObjectIdentifier{test.query})
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// uncacheableFunctions are the template functions with an output that doesn't only depend on the template, the
+// context, the options, and the looked up objects, such as the current time or random values. A resolution that calls
+// any of them isn't stored in the result cache.
+var uncacheableFunctions = []string{
+	"ago", "apiResourceExists", "canLookup", "certExpiryDays", "env", "expandenv", "fromVault", "genCA", "genCAWithKey",
+	"genPrivateKey", "genSelfSignedCert", "genSelfSignedCertWithKey", "genSignedCert", "genSignedCertWithKey",
+	"htpasswd", "kubeVersion", "lookupSubresource", "now", "randAlpha", "randAlphaNum", "randAscii", "randBytes",
+	"randInt", "randNumeric", "resolveID", "resourceFor", "shuffle", "uuidv4",
+}
+
+// resultCacheEntry is the cached result of a successful ResolveTemplate call when Config.ResultCacheMaxEntries is
+// set. An entry is keyed by a hash of the raw template, the context, and the options, and it's only valid while the
+// objects returned by the lookups of the resolution are unchanged, which is checked by repeating the lookups.
+type resultCacheEntry struct {
+	result  TemplateResult
+	lookups []lookupRecord
+}
+
+// lookupRecord is a lookup of a ResolveTemplate call and the versions of the returned objects. See
+// ResolveOptions.recordLookup.
+type lookupRecord struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+	selectors  []string
+	// unrestricted is set when the lookup was made without the lookup restrictions of the options, such as the lookup
+	// of the template library ConfigMap.
+	unrestricted bool
+	versions     string
+}
+
+// resultCacheKey returns the result cache key of the ResolveTemplate call. False is returned if the result cache is
+// disabled or the resolution can't be cached, which is the case when the options have custom functions, context
+// transformers, or an encryption provider, since their behavior is unknown, or when the context can't be hashed.
+func (t *TemplateResolver) resultCacheKey(tmplRaw []byte, tmplContext interface{}, options *ResolveOptions) (
+	string, bool,
+) {
+	if t.resultCache == nil || len(options.CustomFunctions) != 0 || len(options.ContextTransformers) != 0 ||
+		options.Provider != nil {
+		return "", false
+	}
+
+	contextJSON, err := json.Marshal(tmplContext)
+	if err != nil {
+		return "", false
+	}
+
+	hashedOptions := *options
+	hashedOptions.state = nil
+
+	optionsJSON, err := json.Marshal(hashedOptions)
+	if err != nil {
+		return "", false
+	}
+
+	hash := sha256.New()
+
+	for _, part := range [][]byte{tmplRaw, contextJSON, optionsJSON} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// cachedResult returns the cached result of the key if the looked up objects are unchanged. The lookups are repeated
+// with the input options so that they are referenced by the ResolveTemplate call like in an uncached resolution.
+func (t *TemplateResolver) cachedResult(options *ResolveOptions, key string) (TemplateResult, bool) {
+	entry, ok := t.resultCache.get(key)
+	if !ok {
+		t.metrics.observeResultCache(false)
+
+		return TemplateResult{}, false
+	}
+
+	for _, lookup := range entry.lookups {
+		lookupOptions := options
+		if lookup.unrestricted {
+			lookupOptions = unrestrictedOptions(options)
+		}
+
+		result, err := t.getOrList(
+			lookupOptions, lookup.apiVersion, lookup.kind, lookup.namespace, lookup.name, lookup.selectors...,
+		)
+		if (err != nil && !apierrors.IsNotFound(err)) || objectVersions(result) != lookup.versions {
+			t.metrics.observeResultCache(false)
+
+			return TemplateResult{}, false
+		}
+	}
+
+	t.metrics.observeResultCache(true)
+	t.logger(options).V(2).Info("Using the cached result since the looked up objects are unchanged")
+
+	result := entry.result
+	result.ResolvedJSON = slices.Clone(result.ResolvedJSON)
+	result.SensitiveObjects = slices.Clone(result.SensitiveObjects)
+	result.Warnings = slices.Clone(result.Warnings)
+
+	return result, true
+}
+
+// cacheResult stores the result of the ResolveTemplate call in the result cache unless the template called one of
+// uncacheableFunctions, generated a value, or had a lookup fail with an error other than not found.
+func (t *TemplateResolver) cacheResult(options *ResolveOptions, key string, result TemplateResult) {
+	if options.state.uncacheable || len(options.state.generatedValues) != 0 {
+		return
+	}
+
+	result.CacheCleanUp = nil
+	result.ResolvedJSON = slices.Clone(result.ResolvedJSON)
+
+	t.resultCache.set(key, resultCacheEntry{result: result, lookups: options.state.lookups})
+}
+
+// recordLookup records the lookup and the versions of the returned objects for the result cache. A lookup that failed
+// with an error other than not found makes the ResolveTemplate call uncacheable. This is a no-op if the result cache is
+// disabled.
+func (o *ResolveOptions) recordLookup(
+	apiVersion, kind, namespace, name string, selectors []string, result map[string]interface{}, err error,
+) {
+	if o.state == nil || !o.state.recordLookups {
+		return
+	}
+
+	if err != nil && !apierrors.IsNotFound(err) {
+		o.state.uncacheable = true
+
+		return
+	}
+
+	o.state.lookups = append(o.state.lookups, lookupRecord{
+		apiVersion:   apiVersion,
+		kind:         kind,
+		namespace:    namespace,
+		name:         name,
+		selectors:    selectors,
+		unrestricted: o.isUnrestricted(),
+		versions:     objectVersions(result),
+	})
+}
+
+// isUnrestricted returns whether the options have none of the lookup restrictions removed by unrestrictedOptions.
+func (o *ResolveOptions) isUnrestricted() bool {
+	return o.LookupNamespace == "" && len(o.LookupNamespaces) == 0 && len(o.AllowedGVKs) == 0 &&
+		o.LookupAccessReview == nil && o.Impersonate == nil
+}
+
+// unrestrictedOptions returns a copy of the options without the lookup restrictions and the impersonation, like the
+// options of the lookup of the template library ConfigMap. The access review of the lookups is also a restriction.
+func unrestrictedOptions(options *ResolveOptions) *ResolveOptions {
+	unrestricted := *options
+	unrestricted.LookupNamespace = ""
+	unrestricted.LookupNamespaces = nil
+	unrestricted.AllowedGVKs = nil
+	unrestricted.LookupAccessReview = nil
+	unrestricted.Impersonate = nil
+
+	return &unrestricted
+}
+
+// objectVersions returns a string identifying the versions of the object or list of objects returned by a lookup. This
+// is the sorted UIDs and resourceVersions of the objects, and the hash of the object content is used if an object has
+// no resourceVersion. An empty string is returned for a lookup that didn't find the object.
+func objectVersions(result map[string]interface{}) string {
+	if result == nil {
+		return ""
+	}
+
+	objects := []interface{}{result}
+
+	if items, found, _ := unstructured.NestedSlice(result, "items"); found {
+		objects = items
+	}
+
+	versions := make([]string, 0, len(objects))
+
+	for _, object := range objects {
+		objectMap, ok := object.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		obj := unstructured.Unstructured{Object: objectMap}
+
+		version := obj.GetResourceVersion()
+		if version == "" {
+			objectJSON, _ := json.Marshal(objectMap)
+			hash := sha256.Sum256(objectJSON)
+			version = hex.EncodeToString(hash[:])
+		}
+
+		versions = append(versions, obj.GetNamespace()+"/"+obj.GetName()+"/"+string(obj.GetUID())+"/"+version)
+	}
+
+	sort.Strings(versions)
+
+	return strings.Join(versions, ",")
+}
+
+// markUncacheableFunctions wraps the uncacheableFunctions in the input function map so that calling one makes the
+// ResolveTemplate call uncacheable.
+func (s *resolveState) markUncacheableFunctions(funcMap map[string]interface{}) {
+	for _, name := range uncacheableFunctions {
+		fn, ok := funcMap[name]
+		if !ok {
+			continue
+		}
+
+		funcMap[name] = wrapFunc(fn, func() { s.uncacheable = true })
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+func TestResultCache(t *testing.T) {
+	t.Parallel()
+
+	server, err := newFakeAPIServer([]unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name": "settings", "namespace": "app", "resourceVersion": "1",
+			},
+			"data": map[string]interface{}{"replicas": "3"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	registry := prometheus.NewRegistry()
+
+	resolver, err := NewResolver(
+		&rest.Config{Host: fakeAPIServerHost, Transport: server},
+		Config{InputIsYAML: true, ResultCacheMaxEntries: 10, MetricsRegisterer: registry},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	type tmplContext struct {
+		Name string
+	}
+
+	resolve := func(tmpl string, name string, expected string) TemplateResult {
+		t.Helper()
+
+		result, err := resolver.ResolveTemplate([]byte(tmpl), tmplContext{Name: name}, nil)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if string(result.ResolvedJSON) != expected {
+			t.Fatalf("Expected the resolved JSON %s but got %s", expected, result.ResolvedJSON)
+		}
+
+		return result
+	}
+
+	expectHits := func(hits float64, misses float64) {
+		t.Helper()
+
+		if value := testutil.ToFloat64(resolver.metrics.resultCache.WithLabelValues("hit")); value != hits {
+			t.Fatalf("Expected %v result cache hits but got %v", hits, value)
+		}
+
+		if value := testutil.ToFloat64(resolver.metrics.resultCache.WithLabelValues("miss")); value != misses {
+			t.Fatalf("Expected %v result cache misses but got %v", misses, value)
+		}
+	}
+
+	tmpl := `value: '{{ .Name }}-{{ fromConfigMap "app" "settings" "replicas" }}'`
+
+	first := resolve(tmpl, "web", `{"value":"web-3"}`)
+	expectHits(0, 1)
+
+	second := resolve(tmpl, "web", `{"value":"web-3"}`)
+	expectHits(1, 1)
+
+	if second.ResolveID == first.ResolveID {
+		t.Fatalf("Expected the cached result to have the resolve ID of its ResolveTemplate call")
+	}
+
+	if len(second.ReferencedObjects) != 1 || second.ReferencedObjects[0].Name != "settings" {
+		t.Fatalf("Expected the cached result to reference the ConfigMap but got %v", second.ReferencedObjects)
+	}
+
+	// A different context is a different cache entry
+	resolve(tmpl, "db", `{"value":"db-3"}`)
+	expectHits(1, 2)
+
+	// A changed object invalidates the cached result
+	configMaps := server.resources[schema.GroupVersion{Version: "v1"}]["configmaps"]
+	configMaps.objects[0].Object["data"] = map[string]interface{}{"replicas": "5"}
+	configMaps.objects[0].SetResourceVersion("2")
+
+	stale := resolve(tmpl, "web", `{"value":"web-5"}`)
+	expectHits(1, 3)
+
+	if stale.LookupStats.Lookups != 1 {
+		t.Fatalf("Expected the repeated lookups of the stale result to not be counted but got %+v", stale.LookupStats)
+	}
+
+	resolve(tmpl, "web", `{"value":"web-5"}`)
+	expectHits(2, 3)
+
+	// A template with a function with a varying output is not cached
+	resolveIDTmpl := `value: '{{ .Name }}-{{ len resolveID }}'`
+
+	resolve(resolveIDTmpl, "web", `{"value":"web-36"}`)
+	resolve(resolveIDTmpl, "web", `{"value":"web-36"}`)
+	expectHits(2, 5)
+
+	// The salt of htpasswd is random
+	htpasswdTmpl := `value: '{{ .Name }}-{{ empty (htpasswd "user" "password") }}'`
+
+	resolve(htpasswdTmpl, "web", `{"value":"web-false"}`)
+	resolve(htpasswdTmpl, "web", `{"value":"web-false"}`)
+	expectHits(2, 7)
+}
+
+func TestObjectVersions(t *testing.T) {
+	t.Parallel()
+
+	withVersion := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "a", "namespace": "ns", "uid": "1", "resourceVersion": "10"},
+	}
+
+	if versions := objectVersions(withVersion); versions != "ns/a/1/10" {
+		t.Fatalf("Unexpected versions: %s", versions)
+	}
+
+	list := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "b", "resourceVersion": "2"}},
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "a", "resourceVersion": "1"}},
+		},
+	}
+
+	if versions := objectVersions(list); versions != "/a//1,/b//2" {
+		t.Fatalf("Unexpected list versions: %s", versions)
+	}
+
+	withoutVersion := map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}, "data": "x"}
+	changed := map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}, "data": "y"}
+
+	if objectVersions(withoutVersion) == objectVersions(changed) {
+		t.Fatalf("Expected the objects without a resourceVersion to be compared by content")
+	}
+
+	if objectVersions(nil) != "" {
+		t.Fatalf("Expected no versions for a lookup that found nothing")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// semverCompare returns whether the input version satisfies the semantic version constraint, such as
+// `{{ semverCompare ">=1.25.0" $version }}`. This replaces the sprig function so that the Kubernetes versions of
+// managed distributions, such as v1.27.8-eks-2d98532 or v1.28.3+k3s2, can be compared. The build metadata is ignored
+// by semantic versioning and the prerelease of the version is ignored unless the constraint has a prerelease, such as
+// `>=1.28.0-0`, since the vendor suffix of a Kubernetes version would otherwise never satisfy the constraint.
+func semverCompare(constraint string, version string) (bool, error) {
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s is not a valid semantic version constraint", ErrInvalidInput, constraint)
+	}
+
+	parsed, err := semver.NewVersion(strings.TrimSpace(version))
+	if err != nil {
+		return false, fmt.Errorf("%w: %s is not a valid semantic version", ErrInvalidInput, version)
+	}
+
+	// A hyphen surrounded by spaces is a range, such as `1.25 - 1.28`, rather than a prerelease
+	if parsed.Prerelease() != "" && !strings.Contains(strings.ReplaceAll(constraint, " - ", " "), "-") {
+		withoutPrerelease, err := parsed.SetPrerelease("")
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+
+		parsed = &withoutPrerelease
+	}
+
+	return constraints.Check(parsed), nil
+}
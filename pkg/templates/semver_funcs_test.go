@@ -0,0 +1,64 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSemverCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		"satisfied":                     {">=1.25.0", "1.27.3", true},
+		"not satisfied":                 {">=1.25.0", "1.24.9", false},
+		"leading v":                     {">=1.25.0", "v1.27.3", true},
+		"build metadata":                {">=1.25.0", "v1.27.3+k3s1", true},
+		"vendor prerelease":             {">=1.25.0", "v1.27.8-eks-2d98532", true},
+		"vendor prerelease too old":     {">=1.25.0", "v1.24.17-eks-2d98532", false},
+		"caret":                         {"^1.2.0", "1.2.3", true},
+		"range":                         {">=1.25.0, <1.28.0", "v1.28.2-gke.1157000", false},
+		"hyphen range":                  {"1.25 - 1.28", "v1.26.5-gke.1200", true},
+		"prerelease constraint":         {">=1.28.0-0", "1.28.0-rc.1", true},
+		"prerelease constraint too old": {">=1.28.0-rc.2", "1.28.0-rc.1", false},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := semverCompare(test.constraint, test.version)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Expected %t but got %t", test.expected, result)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{">=1.25.0", "not a version"}, {"not a constraint", "1.25.0"}} {
+		_, err := semverCompare(args[0], args[1])
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("Expected ErrInvalidInput for %v but got %v", args, err)
+		}
+	}
+}
+
+func TestResolveTemplateSemverFuncs(t *testing.T) {
+	t.Parallel()
+
+	doResolveTest(t, resolveTestCase{
+		inputTmpl: `value: '{{ $version := "v1.27.8-eks-2d98532" }}{{ semverCompare ">=1.25.0" $version }},` +
+			`{{ (semver $version).Minor }}'`,
+		expectedResult: "value: true,27",
+	})
+}
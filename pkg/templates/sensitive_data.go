@@ -0,0 +1,428 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// minTaintSubstringLength is the minimum length of a tainted value for a string containing it to be tainted. Shorter
+// tainted values, such as "1", only taint strings equal to them to avoid tainting most of the resolved objects.
+const minTaintSubstringLength = 4
+
+// taintTracker tracks the values derived from sensitive data in a single ResolveTemplate call. The sources of sensitive
+// data, such as the data of looked up Secrets, add their values and the template functions called with a tainted
+// argument taint their output (see taintFuncMap). The resolved objects containing a tainted value are then reported
+// as sensitive.
+type taintTracker struct {
+	// lock protects values since the decryption adds values from multiple Goroutines.
+	lock   sync.RWMutex
+	values map[string]struct{}
+}
+
+// add taints the input string values. Encrypted values and empty strings are never tainted.
+func (tt *taintTracker) add(values ...string) {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	for _, value := range values {
+		if value == "" || strings.HasPrefix(value, protectedPrefix) {
+			continue
+		}
+
+		if tt.values == nil {
+			tt.values = map[string]struct{}{}
+		}
+
+		tt.values[value] = struct{}{}
+	}
+}
+
+// addBase64 taints the input base64 encoded value and its decoded value, such as a value in the data of a Secret.
+func (tt *taintTracker) addBase64(value string) {
+	tt.add(value)
+
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		tt.add(string(decoded))
+	}
+}
+
+// addSecretData taints the values in the data and stringData of the input Secret or list of Secrets.
+func (tt *taintTracker) addSecretData(result map[string]interface{}) {
+	secrets := []interface{}{result}
+
+	if items, found, _ := unstructured.NestedSlice(result, "items"); found {
+		secrets = items
+	}
+
+	for _, secret := range secrets {
+		secretMap, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		data, _, _ := unstructured.NestedStringMap(secretMap, "data")
+		for _, value := range data {
+			tt.addBase64(value)
+		}
+
+		stringData, _, _ := unstructured.NestedStringMap(secretMap, "stringData")
+		for _, value := range stringData {
+			tt.add(value)
+		}
+	}
+}
+
+// isTainted returns whether the input string is or contains a tainted value.
+func (tt *taintTracker) isTainted(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+
+	if _, ok := tt.values[value]; ok {
+		return true
+	}
+
+	for taintedValue := range tt.values {
+		if len(taintedValue) >= minTaintSubstringLength && strings.Contains(value, taintedValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEmpty returns whether no values are tainted.
+func (tt *taintTracker) isEmpty() bool {
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+
+	return len(tt.values) == 0
+}
+
+// containsTaint returns whether any string in the input value, including the strings nested in maps and slices, is
+// tainted.
+func (tt *taintTracker) containsTaint(value reflect.Value) bool {
+	found := false
+
+	walkStrings(value, func(s string) bool {
+		found = tt.isTainted(s)
+
+		return !found
+	})
+
+	return found
+}
+
+// addAll taints every string in the input value, including the strings nested in maps and slices.
+func (tt *taintTracker) addAll(value reflect.Value) {
+	walkStrings(value, func(s string) bool {
+		tt.add(s)
+
+		return true
+	})
+}
+
+// walkStrings calls visit with every string in the input value, including the map keys and the strings nested in maps,
+// slices, and pointers, until visit returns false. It returns false if the walk was stopped.
+func walkStrings(value reflect.Value, visit func(string) bool) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return visit(value.String())
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return true
+		}
+
+		return walkStrings(value.Elem(), visit)
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			return visit(string(value.Bytes()))
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			if !walkStrings(value.Index(i), visit) {
+				return false
+			}
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			if !walkStrings(iter.Key(), visit) || !walkStrings(iter.Value(), visit) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// taintFuncMap wraps the template functions in the input function map so that the output of a call with a tainted
+// argument is tainted, such as `{{ fromSecret "ns" "name" "key" | base64dec }}`. The wrappers have the same function
+// signatures, so the template argument checks are unchanged.
+func (tt *taintTracker) taintFuncMap(funcMap map[string]interface{}) {
+	for name, fn := range funcMap {
+		fnValue := reflect.ValueOf(fn)
+		if fnValue.Kind() != reflect.Func || fnValue.Type().NumOut() == 0 {
+			continue
+		}
+
+		funcMap[name] = reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+			var results []reflect.Value
+
+			if fnValue.Type().IsVariadic() {
+				results = fnValue.CallSlice(args)
+			} else {
+				results = fnValue.Call(args)
+			}
+
+			if tt.isEmpty() {
+				return results
+			}
+
+			for _, arg := range args {
+				if tt.containsTaint(arg) {
+					tt.addAll(results[0])
+
+					break
+				}
+			}
+
+			return results
+		}).Interface()
+	}
+}
+
+// sensitiveObjects returns whether each resolved object contains a tainted value. The resolved JSON is either a single
+// object, which returns one entry, or a list of objects. Nil is returned if the resolved JSON is invalid.
+func (tt *taintTracker) sensitiveObjects(resolvedJSON []byte) []bool {
+	var resolved interface{}
+
+	if err := json.Unmarshal(resolvedJSON, &resolved); err != nil {
+		return nil
+	}
+
+	objects, isList := resolved.([]interface{})
+	if !isList {
+		objects = []interface{}{resolved}
+	}
+
+	sensitive := make([]bool, len(objects))
+
+	if tt.isEmpty() {
+		return sensitive
+	}
+
+	for i, object := range objects {
+		sensitive[i] = tt.containsTaint(reflect.ValueOf(object))
+	}
+
+	return sensitive
+}
+
+// setSensitiveData sets HasSensitiveData and SensitiveObjects of the input result with the resolved JSON and then
+// applies options.SecretDataCheck. When the check fails, the resolved JSON is cleared and the joined errors are
+// returned.
+func (s *resolveState) setSensitiveData(options *ResolveOptions, result *TemplateResult) error {
+	result.SensitiveObjects = s.taint.sensitiveObjects(result.ResolvedJSON)
+	result.HasSensitiveData = s.hasSensitiveData || slices.Contains(result.SensitiveObjects, true)
+
+	if options.SecretDataCheck == "" {
+		return nil
+	}
+
+	secretDataErrs := s.checkSecretData(result)
+
+	if options.SecretDataCheck == SecretDataCheckFail && len(secretDataErrs) > 0 {
+		result.ResolvedJSON = nil
+		result.SensitiveObjects = nil
+
+		return errors.Join(secretDataErrs...)
+	}
+
+	result.Warnings = append(result.Warnings, secretDataErrs...)
+
+	return nil
+}
+
+// SecretDataCheck is the mode of ResolveOptions.SecretDataCheck.
+type SecretDataCheck string
+
+const (
+	// SecretDataCheckWarn sets a warning in TemplateResult.Warnings for each resolved object that isn't a Secret but
+	// contains a value derived from sensitive data.
+	SecretDataCheckWarn SecretDataCheck = "warn"
+	// SecretDataCheckFail fails the resolution with the errors that SecretDataCheckWarn sets as warnings.
+	SecretDataCheckFail SecretDataCheck = "fail"
+)
+
+var ErrSecretDataInNonSecret = errors.New("sensitive data is in an object that is not a Secret")
+
+// checkSecretData returns an error wrapping ErrSecretDataInNonSecret for each sensitive resolved object in the input
+// result, set by setSensitiveData, that has a kind other than Secret. The resolved objects without a kind are skipped.
+func (s *resolveState) checkSecretData(result *TemplateResult) []error {
+	if !slices.Contains(result.SensitiveObjects, true) {
+		return nil
+	}
+
+	var resolved interface{}
+
+	if err := json.Unmarshal(result.ResolvedJSON, &resolved); err != nil {
+		return nil
+	}
+
+	objects, isList := resolved.([]interface{})
+	if !isList {
+		objects = []interface{}{resolved}
+	}
+
+	var secretDataErrs []error
+
+	for i, object := range objects {
+		if i >= len(result.SensitiveObjects) || !result.SensitiveObjects[i] {
+			continue
+		}
+
+		objectMap, ok := object.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		obj := unstructured.Unstructured{Object: objectMap}
+		if obj.GetKind() == "" || (obj.GetAPIVersion() == "v1" && obj.GetKind() == "Secret") {
+			continue
+		}
+
+		name := obj.GetName()
+		if obj.GetNamespace() != "" {
+			name = obj.GetNamespace() + "/" + name
+		}
+
+		err := fmt.Errorf(
+			"%w: the %s %s has sensitive data at %s", ErrSecretDataInNonSecret, obj.GetKind(), name,
+			strings.Join(s.taint.taintedPaths(objectMap, ""), ", "),
+		)
+
+		if isList {
+			err = fmt.Errorf("index %d: %w", i, err)
+		}
+
+		secretDataErrs = append(secretDataErrs, err)
+	}
+
+	return secretDataErrs
+}
+
+// taintedPaths returns the sorted dot separated paths of the tainted strings in the input value from JSON, such as
+// data.password. List indexes are numeric path segments.
+func (tt *taintTracker) taintedPaths(value interface{}, path string) []string {
+	var paths []string
+
+	switch typedValue := value.(type) {
+	case string:
+		if tt.isTainted(typedValue) {
+			paths = append(paths, path)
+		}
+	case map[string]interface{}:
+		for key, nested := range typedValue {
+			paths = append(paths, tt.taintedPaths(nested, joinFieldPath(path, key))...)
+		}
+	case []interface{}:
+		for i, nested := range typedValue {
+			paths = append(paths, tt.taintedPaths(nested, joinFieldPath(path, strconv.Itoa(i)))...)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// redactedValue replaces the values derived from sensitive data when ResolveOptions.RedactSensitive is set.
+const redactedValue = "[REDACTED]"
+
+// redact returns the input string with the tainted values replaced with redactedValue when options.RedactSensitive is
+// set. The tainted values shorter than minTaintSubstringLength are only redacted if they are the whole string.
+func (s *resolveState) redact(options *ResolveOptions, value string) string {
+	if s == nil || options == nil || !options.RedactSensitive {
+		return value
+	}
+
+	return s.taint.redact(value)
+}
+
+// redactError returns the input error with its message redacted when options.RedactSensitive is set. The returned error
+// still wraps the input error so that errors.Is and errors.As work, and the Template of a wrapped TemplateError is
+// redacted too.
+func (s *resolveState) redactError(options *ResolveOptions, err error) error {
+	if s == nil || options == nil || !options.RedactSensitive || s.taint.isEmpty() {
+		return err
+	}
+
+	var templateErr *TemplateError
+	if errors.As(err, &templateErr) {
+		templateErr.Template = s.taint.redact(templateErr.Template)
+	}
+
+	return &redactedError{msg: s.taint.redact(err.Error()), err: err}
+}
+
+func (tt *taintTracker) redact(value string) string {
+	if value == "" {
+		return value
+	}
+
+	tt.lock.RLock()
+	defer tt.lock.RUnlock()
+
+	if _, ok := tt.values[value]; ok {
+		return redactedValue
+	}
+
+	taintedValues := make([]string, 0, len(tt.values))
+
+	for taintedValue := range tt.values {
+		if len(taintedValue) >= minTaintSubstringLength {
+			taintedValues = append(taintedValues, taintedValue)
+		}
+	}
+
+	// Replace the longest values first so that a value containing another tainted value is fully redacted
+	sort.Slice(taintedValues, func(i, j int) bool {
+		return len(taintedValues[i]) > len(taintedValues[j])
+	})
+
+	for _, taintedValue := range taintedValues {
+		value = strings.ReplaceAll(value, taintedValue, redactedValue)
+	}
+
+	return value
+}
+
+// redactedError is an error with a redacted message that wraps the original error.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,320 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSensitiveObjects(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]interface{}{"name": "creds", "namespace": "app"},
+				// s3cr3t-password and 1
+				"data": map[string]interface{}{"password": "czNjcjN0LXBhc3N3b3Jk", "replicas": "MQ=="},
+			}},
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "settings", "namespace": "app"},
+				"data":       map[string]interface{}{"host": "db.example.com"},
+			}},
+		},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl         string
+		expectedSensitive []bool
+		expectedHas       bool
+	}{
+		"single_object": {
+			`data: '{{ fromSecret "app" "creds" "password" }}'`, []bool{true}, true,
+		},
+		"no_sensitive_data": {
+			`data: '{{ fromConfigMap "app" "settings" "host" }}'`, []bool{false}, false,
+		},
+		"secret_not_found": {
+			`data: '{{ empty (lookup "v1" "Secret" "app" "not-found") }}'`, []bool{false}, false,
+		},
+		"secret_metadata": {
+			`data: '{{ (lookup "v1" "Secret" "app" "creds").metadata.name }}'`, []bool{false}, true,
+		},
+		"list": {
+			`- data: '{{ fromConfigMap "app" "settings" "host" }}'
+- data: '{{ fromSecret "app" "creds" "password" | base64dec }}'
+- data: 'prefix-{{ fromSecret "app" "creds" "password" | base64dec | upper }}'
+- data: '{{ (lookup "v1" "Secret" "app" "creds").data.password }}'
+- data: '{{ fromSecret "app" "creds" "password" | base64dec | sha256sum }}'
+- replicas: 1`,
+			[]bool{false, true, true, true, true, false},
+			true,
+		},
+		"short_value": {
+			`- data: '{{ fromSecret "app" "creds" "replicas" | base64dec }}'
+- data: '{{ fromConfigMap "app" "settings" "host" }}-1'`,
+			[]bool{true, false},
+			true,
+		},
+		"generated_value": {
+			`- data: '{{ genRandomString 16 "app" "new-creds" "password" | base64dec }}'
+- data: 'static'`,
+			[]bool{true, false},
+			true,
+		},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !slices.Equal(result.SensitiveObjects, test.expectedSensitive) {
+				t.Fatalf("Expected SensitiveObjects %v but got %v", test.expectedSensitive, result.SensitiveObjects)
+			}
+
+			if result.HasSensitiveData != test.expectedHas {
+				t.Fatalf("Expected HasSensitiveData to be %v", test.expectedHas)
+			}
+		})
+	}
+}
+
+func TestSensitiveObjectsProtected(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encryptionConfig := EncryptionConfig{
+		AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+		DecryptionEnabled:    true,
+		EncryptionEnabled:    true,
+		InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+	}
+
+	protected, err := resolver.ResolveTemplate(
+		[]byte(`data: '{{ "Raleigh" | protect }}'`), nil, &ResolveOptions{EncryptionConfig: encryptionConfig},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !slices.Equal(protected.SensitiveObjects, []bool{false}) {
+		t.Fatalf("Expected the protected value to not be sensitive but got %v", protected.SensitiveObjects)
+	}
+
+	tmpl := `- data: '$ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w=='
+- data: 'Durham'`
+
+	decrypted, err := resolver.ResolveTemplate(
+		[]byte(tmpl), nil, &ResolveOptions{EncryptionConfig: encryptionConfig},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !slices.Equal(decrypted.SensitiveObjects, []bool{true, false}) {
+		t.Fatalf("Expected the decrypted value to be sensitive but got %v", decrypted.SensitiveObjects)
+	}
+}
+
+func TestSecretDataCheck(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "creds", "namespace": "app"},
+			"data":       map[string]interface{}{"password": "czNjcjN0LXBhc3N3b3Jk"},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: copy
+    namespace: app
+  data:
+    password: '{{ fromSecret "app" "creds" "password" }}'
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: app
+  data:
+    url: 'postgres://admin:{{ fromSecret "app" "creds" "password" | base64dec }}@db'
+    password: '{{ fromSecret "app" "creds" "password" | base64dec }}'
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: other
+    namespace: app
+  data:
+    key: value
+- data: '{{ fromSecret "app" "creds" "password" | base64dec }}'`
+
+	expectedErr := "index 1: sensitive data is in an object that is not a Secret: the ConfigMap app/settings has " +
+		"sensitive data at data.password, data.url"
+
+	testcases := map[string]struct {
+		check            SecretDataCheck
+		expectedWarnings []string
+		expectedErr      string
+	}{
+		"disabled": {"", nil, ""},
+		"warn":     {SecretDataCheckWarn, []string{expectedErr}, ""},
+		"fail":     {SecretDataCheckFail, nil, expectedErr},
+		"invalid": {
+			"ignore", nil, "the input is invalid: options.SecretDataCheck must be warn or fail but got ignore",
+		},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{SecretDataCheck: test.check})
+			if test.expectedErr != "" {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Fatalf("Expected the error %q but got %v", test.expectedErr, err)
+				}
+
+				if result.ResolvedJSON != nil {
+					t.Fatal("Expected no resolved JSON when the check fails")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			warnings := make([]string, 0, len(result.Warnings))
+
+			for _, warning := range result.Warnings {
+				if !errors.Is(warning, ErrSecretDataInNonSecret) {
+					t.Fatalf("Expected the warning to wrap ErrSecretDataInNonSecret: %v", warning)
+				}
+
+				warnings = append(warnings, warning.Error())
+			}
+
+			if !slices.Equal(warnings, test.expectedWarnings) {
+				t.Fatalf("Expected the warnings %v but got %v", test.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestRedactSensitive(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "creds", "namespace": "app"},
+			"data":       map[string]interface{}{"password": "czNjcjN0LXBhc3N3b3Jk"},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encryptionConfig := EncryptionConfig{
+		AESKey:               bytes.Repeat([]byte{byte('A')}, 256/8),
+		DecryptionEnabled:    true,
+		InitializationVector: bytes.Repeat([]byte{byte('I')}, IVSize),
+	}
+
+	customFunctions := map[string]interface{}{
+		"check": func(value string) (string, error) { return "", fmt.Errorf("the value %s is invalid", value) },
+	}
+
+	tmpl := `city: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==
+data: '{{ fromSecret "app" "creds" "password" | base64dec | check }}'`
+
+	testcases := map[string]struct {
+		redact           bool
+		expectedErr      string
+		expectedTemplate string
+	}{
+		"redacted": {
+			true,
+			"the value [REDACTED] is invalid",
+			"city: [REDACTED]\n",
+		},
+		"not_redacted": {
+			false,
+			"the value s3cr3t-password is invalid",
+			"city: Raleigh\n",
+		},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := resolver.ResolveTemplate([]byte(tmpl), nil, &ResolveOptions{
+				CustomFunctions:  customFunctions,
+				EncryptionConfig: encryptionConfig,
+				RedactSensitive:  test.redact,
+			})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			if !strings.HasSuffix(err.Error(), test.expectedErr) {
+				t.Fatalf("Expected the error to end with %q but got %q", test.expectedErr, err.Error())
+			}
+
+			if test.redact && (strings.Contains(err.Error(), "s3cr3t") || strings.Contains(err.Error(), "Raleigh")) {
+				t.Fatalf("Expected the sensitive data to be redacted but got %q", err.Error())
+			}
+
+			var templateErr *TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Fatalf("Expected a TemplateError but got %v", err)
+			}
+
+			if !strings.HasPrefix(templateErr.Template, test.expectedTemplate) {
+				t.Fatalf("Expected the template prefix %q but got %q", test.expectedTemplate, templateErr.Template)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// objectSnapshot pins the objects returned by the lookups of a ResolveTemplate call when caching is enabled. The watch
+// cache may be updated while the template is resolved, so without it, looking up the same object twice, such as with
+// lookup and then fromConfigMap, could return two versions of the object. Each object is pinned to the first version
+// seen in the resolution, whether it was returned by a get or a list query, and a repeated list query returns the same
+// objects.
+type objectSnapshot struct {
+	// objects are the pinned objects keyed by their identifier without a selector. A nil object means it was not found.
+	objects map[client.ObjectIdentifier]*unstructured.Unstructured
+	// lists are the pinned results of the list queries.
+	lists map[client.ObjectIdentifier][]unstructured.Unstructured
+}
+
+// objectKey returns the key of the object in objects, which is its identifier without the selector.
+func objectKey(objID client.ObjectIdentifier, namespace string, name string) client.ObjectIdentifier {
+	return client.ObjectIdentifier{
+		Group: objID.Group, Version: objID.Version, Kind: objID.Kind, Namespace: namespace, Name: name,
+	}
+}
+
+// get returns the pinned object of the get query and whether it's pinned. The object is nil if it was not found.
+func (s *objectSnapshot) get(queryID client.ObjectIdentifier) (*unstructured.Unstructured, bool) {
+	obj, pinned := s.objects[objectKey(queryID, queryID.Namespace, queryID.Name)]
+
+	return obj, pinned
+}
+
+// pinGet pins the result of the get query, which is nil if the object was not found, unless a version of the object
+// is already pinned. The pinned object is returned.
+func (s *objectSnapshot) pinGet(
+	queryID client.ObjectIdentifier, obj *unstructured.Unstructured,
+) *unstructured.Unstructured {
+	key := objectKey(queryID, queryID.Namespace, queryID.Name)
+
+	if pinnedObj, pinned := s.objects[key]; pinned {
+		return pinnedObj
+	}
+
+	if s.objects == nil {
+		s.objects = map[client.ObjectIdentifier]*unstructured.Unstructured{}
+	}
+
+	s.objects[key] = obj
+
+	return obj
+}
+
+// pinList pins the result of the list query unless the query was already made, in which case the pinned result is
+// returned. The objects in the result that are already pinned, such as from a get query, are replaced with the pinned
+// versions, and the others are pinned.
+func (s *objectSnapshot) pinList(
+	queryID client.ObjectIdentifier, items []unstructured.Unstructured,
+) []unstructured.Unstructured {
+	if pinnedItems, pinned := s.lists[queryID]; pinned {
+		return pinnedItems
+	}
+
+	if s.objects == nil {
+		s.objects = map[client.ObjectIdentifier]*unstructured.Unstructured{}
+	}
+
+	pinnedItems := make([]unstructured.Unstructured, 0, len(items))
+
+	for i := range items {
+		key := objectKey(queryID, items[i].GetNamespace(), items[i].GetName())
+
+		pinnedObj, pinned := s.objects[key]
+		if !pinned {
+			pinnedObj = &items[i]
+			s.objects[key] = pinnedObj
+		}
+
+		// An object that was not found by an earlier get query is left out of the list
+		if pinnedObj != nil {
+			pinnedItems = append(pinnedItems, *pinnedObj)
+		}
+	}
+
+	if s.lists == nil {
+		s.lists = map[client.ObjectIdentifier][]unstructured.Unstructured{}
+	}
+
+	s.lists[queryID] = pinnedItems
+
+	return pinnedItems
+}
@@ -0,0 +1,169 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// changingWatcher is a client.DynamicWatcher that returns a new version of the settings ConfigMap on every query to
+// simulate updates to the watch cache during a resolution.
+type changingWatcher struct {
+	client.DynamicWatcher
+	lock    sync.Mutex
+	version int
+}
+
+func (w *changingWatcher) configMap() *unstructured.Unstructured {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.version++
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name": "settings", "namespace": "app", "resourceVersion": strconv.Itoa(w.version),
+		},
+		"data": map[string]interface{}{"version": strconv.Itoa(w.version)},
+	}}
+}
+
+func (w *changingWatcher) Get(
+	_ client.ObjectIdentifier, _ schema.GroupVersionKind, _ string, _ string,
+) (*unstructured.Unstructured, error) {
+	return w.configMap(), nil
+}
+
+func (w *changingWatcher) List(
+	_ client.ObjectIdentifier, _ schema.GroupVersionKind, _ string, _ labels.Selector,
+) ([]unstructured.Unstructured, error) {
+	return []unstructured.Unstructured{*w.configMap()}, nil
+}
+
+func (w *changingWatcher) GVKToGVR(gvk schema.GroupVersionKind) (client.ScopedGVR, error) {
+	return client.ScopedGVR{
+		GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		Namespaced:           true,
+	}, nil
+}
+
+func (w *changingWatcher) StartQueryBatch(_ client.ObjectIdentifier) error {
+	return nil
+}
+
+func (w *changingWatcher) EndQueryBatch(_ client.ObjectIdentifier) error {
+	return nil
+}
+
+func TestObjectSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		inputTmpl string
+		expected  string
+	}{
+		"get queries": {
+			inputTmpl: `a: '{{ fromConfigMap "app" "settings" "version" }}'
+b: '{{ (lookup "v1" "ConfigMap" "app" "settings").data.version }}'`,
+			expected: `{"a":"1","b":"1"}`,
+		},
+		"list queries": {
+			inputTmpl: `a: '{{ (index (lookup "v1" "ConfigMap" "app" "").items 0).data.version }}'
+b: '{{ (index (lookup "v1" "ConfigMap" "app" "").items 0).data.version }}'`,
+			expected: `{"a":"1","b":"1"}`,
+		},
+		"get then list queries": {
+			inputTmpl: `a: '{{ fromConfigMap "app" "settings" "version" }}'
+b: '{{ (index (lookup "v1" "ConfigMap" "app" "" "env").items 0).data.version }}'`,
+			expected: `{"a":"1","b":"1"}`,
+		},
+		"list then get queries": {
+			inputTmpl: `a: '{{ (index (lookup "v1" "ConfigMap" "app" "").items 0).data.version }}'
+b: '{{ fromConfigMap "app" "settings" "version" }}'`,
+			expected: `{"a":"1","b":"1"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			resolver.dynamicWatcher = &changingWatcher{}
+
+			options := &ResolveOptions{Watcher: &client.ObjectIdentifier{
+				Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "policy",
+			}}
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) != test.expected {
+				t.Fatalf("Expected %s but got %s", test.expected, result.ResolvedJSON)
+			}
+
+			if stats := result.LookupStats; stats.Lookups != 2 || stats.CacheHits != 2 || stats.APICalls != 0 {
+				t.Fatalf("Expected 2 lookups served from the watch cache but got %+v", stats)
+			}
+
+			// A new resolution sees the latest version
+			result, err = resolver.ResolveTemplate([]byte(test.inputTmpl), nil, options)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if string(result.ResolvedJSON) == test.expected {
+				t.Fatalf("Expected a new version of the ConfigMap but got %s", result.ResolvedJSON)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateMaxLookupsWithCaching(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	resolver.dynamicWatcher = &changingWatcher{}
+
+	options := &ResolveOptions{
+		MaxLookups: 1,
+		Watcher:    &client.ObjectIdentifier{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "policy"},
+	}
+
+	tmpl := `value: '{{ range (list 1 2 3) }}{{ fromConfigMap "app" "settings" "version" }}{{ end }}'`
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, options)
+	if err != nil {
+		t.Fatalf("Expected the repeated lookups to be counted once but got: %v", err)
+	}
+
+	tmpl = `a: '{{ fromConfigMap "app" "settings" "version" }}'
+b: '{{ (lookup "v1" "ConfigMap" "app" "" "env").items | len }}'`
+
+	_, err = resolver.ResolveTemplate([]byte(tmpl), nil, options)
+	if !errors.Is(err, ErrLookupQuotaExceeded) {
+		t.Fatalf("Expected ErrLookupQuotaExceeded for the distinct lookups but got: %v", err)
+	}
+}
@@ -4,6 +4,8 @@
 package templates
 
 import (
+	"fmt"
+
 	sprig "github.com/Masterminds/sprig/v3"
 )
 
@@ -13,6 +15,7 @@ var (
 	// ExportedSprigFunctions lists all of the functions from sprig that will be exposed
 	exportedSprigFunctions = []string{
 		"add",
+		"adler32sum",
 		"append",
 		"cat",
 		"concat",
@@ -45,6 +48,8 @@ var (
 		"round",
 		"semver",
 		"semverCompare",
+		"sha1sum",
+		"sha256sum",
 		"slice",
 		"split",
 		"splitn",
@@ -60,8 +65,201 @@ var (
 		"untilStep",
 		"upper",
 	}
+
+	// sprigFunctionGroups are the optional groups of sprig functions that can be enabled with
+	// ResolveOptions.EnabledFunctionGroups, keyed by group name. Only deterministic functions are included, and the
+	// functions that modify their input map are replaced by copyingSprigFunctions so that they have no side effects.
+	sprigFunctionGroups = map[string][]string{
+		"dicts": {
+			"deepCopy",
+			"dict",
+			"get",
+			"hasKey",
+			"keys",
+			"merge",
+			"mergeOverwrite",
+			"omit",
+			"pick",
+			"pluck",
+			"set",
+			"unset",
+			"values",
+		},
+		"encoding": {
+			"b32dec",
+			"b32enc",
+			"b64dec",
+			"b64enc",
+			"toJson",
+			"toPrettyJson",
+		},
+		"lists": {
+			"compact",
+			"first",
+			"initial",
+			"last",
+			"mustCompact",
+			"mustFirst",
+			"mustInitial",
+			"mustLast",
+			"mustReverse",
+			"mustRest",
+			"mustUniq",
+			"mustWithout",
+			"rest",
+			"reverse",
+			"sortAlpha",
+			"uniq",
+			"without",
+		},
+		"logic": {
+			"all",
+			"any",
+			"coalesce",
+			"fail",
+		},
+		"math": {
+			"add1",
+			"addf",
+			"ceil",
+			"divf",
+			"floor",
+			"max",
+			"maxf",
+			"min",
+			"minf",
+			"mod",
+			"mulf",
+			"subf",
+		},
+		"regex": {
+			"mustRegexFind",
+			"mustRegexFindAll",
+			"mustRegexMatch",
+			"mustRegexReplaceAll",
+			"mustRegexReplaceAllLiteral",
+			"mustRegexSplit",
+			"regexFind",
+			"regexFindAll",
+			"regexMatch",
+			"regexQuoteMeta",
+			"regexReplaceAll",
+			"regexReplaceAllLiteral",
+			"regexSplit",
+		},
+		"strings": {
+			"abbrev",
+			"abbrevboth",
+			"camelcase",
+			"initials",
+			"kebabcase",
+			"nospace",
+			"plural",
+			"repeat",
+			"snakecase",
+			"squote",
+			"swapcase",
+			"title",
+			"toString",
+			"toStrings",
+			"trimPrefix",
+			"trimSuffix",
+			"untitle",
+			"wrap",
+			"wrapWith",
+		},
+	}
 )
 
+// copyingSprigFunctions replace the sprig functions that modify their input map with functions that return a modified
+// copy instead, since the input map may be a cached lookup result shared with other templates and ResolveTemplate
+// calls.
+var copyingSprigFunctions = map[string]interface{}{
+	"set": func(d map[string]interface{}, key string, value interface{}) map[string]interface{} {
+		copied := shallowCopyMap(d)
+		copied[key] = value
+
+		return copied
+	},
+	"unset": func(d map[string]interface{}, key string) map[string]interface{} {
+		copied := shallowCopyMap(d)
+		delete(copied, key)
+
+		return copied
+	},
+	"merge":          copyingMerge("merge"),
+	"mergeOverwrite": copyingMerge("mergeOverwrite"),
+}
+
+// copyingMerge returns the sprig merge function with the input name wrapped to merge into a deep copy of the
+// destination map, since the nested maps of the destination are merged in place.
+func copyingMerge(funcName string) func(map[string]interface{}, ...map[string]interface{}) interface{} {
+	//nolint:forcetypeassert
+	merge := sprigFuncMap[funcName].(func(map[string]interface{}, ...map[string]interface{}) interface{})
+
+	return func(dst map[string]interface{}, srcs ...map[string]interface{}) interface{} {
+		copied, _ := deepCopyValue(dst).(map[string]interface{})
+
+		return merge(copied, srcs...)
+	}
+}
+
+func shallowCopyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+// deepCopyValue copies the nested maps and lists of the input value. Other values are returned as is.
+func deepCopyValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if typed == nil {
+			return map[string]interface{}{}
+		}
+
+		copied := make(map[string]interface{}, len(typed))
+		for key, value := range typed {
+			copied[key] = deepCopyValue(value)
+		}
+
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for i, value := range typed {
+			copied[i] = deepCopyValue(value)
+		}
+
+		return copied
+	default:
+		return v
+	}
+}
+
 func getSprigFunc(funcName string) (result interface{}) {
+	if copyingFunc, ok := copyingSprigFunctions[funcName]; ok {
+		return copyingFunc
+	}
+
 	return sprigFuncMap[funcName]
 }
+
+// addSprigFunctionGroups adds the sprig functions of the input groups to the input function map. An error wrapping
+// ErrInvalidInput is returned if a group is unknown.
+func addSprigFunctionGroups(funcMap map[string]interface{}, groups []string) error {
+	for _, group := range groups {
+		funcNames, ok := sprigFunctionGroups[group]
+		if !ok {
+			return fmt.Errorf("%w: the function group %s is unknown", ErrInvalidInput, group)
+		}
+
+		for _, funcName := range funcNames {
+			funcMap[funcName] = getSprigFunc(funcName)
+		}
+	}
+
+	return nil
+}
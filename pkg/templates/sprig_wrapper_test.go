@@ -4,6 +4,7 @@
 package templates
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -288,3 +289,105 @@ data:
 		}
 	}
 }
+
+func TestSprigFunctionGroups(t *testing.T) {
+	t.Parallel()
+
+	for group, funcNames := range sprigFunctionGroups {
+		for _, funcName := range funcNames {
+			if _, ok := sprigFuncMap[funcName]; !ok {
+				t.Fatalf("The function %s in the group %s is not a sprig function", funcName, group)
+			}
+		}
+	}
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	funcMap, err := resolver.buildFuncMap(&ResolveOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// The groups must not override the default template functions
+	for group, funcNames := range sprigFunctionGroups {
+		for _, funcName := range funcNames {
+			if _, ok := funcMap[funcName]; ok {
+				t.Fatalf("The function %s in the group %s is already a default template function", funcName, group)
+			}
+		}
+	}
+}
+
+func TestResolveTemplateEnabledFunctionGroups(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]resolveTestCase{
+		"regex": {
+			inputTmpl:      `value: '{{ regexReplaceAll "-[0-9]+$" "cluster-123" "" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"regex"}},
+			expectedResult: "value: cluster",
+		},
+		"strings and lists": {
+			inputTmpl:      `value: '{{ list "b" "a" "b" | uniq | sortAlpha | join " " | title }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"lists", "strings"}},
+			expectedResult: "value: A B",
+		},
+		"dicts": {
+			inputTmpl:      `value: '{{ get (dict "key" "value") "key" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"dicts"}},
+			expectedResult: "value: value",
+		},
+		"dicts set returns a copy": {
+			inputTmpl: `value: '{{ $d := dict "key" "value" }}{{ $_ := set $d "key" "new" }}` +
+				`{{ $_ := unset $d "key" }}{{ $d.key }} {{ (set $d "key" "new").key }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"dicts"}},
+			expectedResult: "value: value new",
+		},
+		"dicts merge returns a copy": {
+			inputTmpl: `value: '{{ $d := dict "a" (dict "b" "1") }}` +
+				`{{ $m := mergeOverwrite $d (dict "a" (dict "b" "2")) }}{{ $_ := merge $d (dict "c" "3") }}` +
+				`{{ $d.a.b }} {{ $m.a.b }} {{ hasKey $d "c" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"dicts"}},
+			expectedResult: "value: 1 2 false",
+		},
+		"encoding": {
+			inputTmpl:      `value: '{{ b64enc "value" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"encoding"}},
+			expectedResult: "value: dmFsdWU=",
+		},
+		"math": {
+			inputTmpl:      `value: '{{ max 1 3 2 }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"math"}},
+			expectedResult: `value: "3"`,
+		},
+		"logic": {
+			inputTmpl:      `value: '{{ coalesce "" "value" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"logic"}},
+			expectedResult: "value: value",
+		},
+		"group not enabled": {
+			inputTmpl: `value: '{{ regexReplaceAll "-[0-9]+$" "cluster-123" "" }}'`,
+			expectedErr: errors.New(
+				`failed to parse the template JSON string {"value":"{{ regexReplaceAll \"-[0-9]+$\" \"cluster-123\" ` +
+					`\"\" }}"}: template: tmpl:1: function "regexReplaceAll" not defined`,
+			),
+		},
+		"unknown group": {
+			inputTmpl:      `value: '{{ "value" }}'`,
+			resolveOptions: ResolveOptions{EnabledFunctionGroups: []string{"network"}},
+			expectedErr:    ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var ErrMissingSubresource = errors.New("the subresource is not registered for the API resource")
+
+func (t *TemplateResolver) lookupSubresourceHelper(
+	options *ResolveOptions,
+) func(string, string, string, string, string) (map[string]interface{}, error) {
+	return func(
+		apiVersion string, kind string, namespace string, name string, subresource string,
+	) (map[string]interface{}, error) {
+		return t.lookupSubresource(options, apiVersion, kind, namespace, name, subresource)
+	}
+}
+
+// lookupSubresource is like lookup but reads the input subresource (e.g. status or scale) of the object. If
+// subresource is empty, this is the same as a lookup of a single object. An error wrapping ErrMissingSubresource is
+// returned if the subresource is not registered for the kind. Subresource queries are always sent to the Kubernetes API
+// since they can't be watched or cached, so an error wrapping ErrInvalidInput is returned when caching is enabled. The
+// object of the subresource is recorded in the ReferencedObjects of the ResolveTemplate call.
+func (t *TemplateResolver) lookupSubresource(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, name string, subresource string,
+) (map[string]interface{}, error) {
+	t.logger(options).V(2).Info(
+		"lookupSubresource", "apiVersion", apiVersion, "kind", kind, "namespace", namespace, "name", name,
+		"subresource", subresource,
+	)
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: the name is required for a subresource lookup", ErrInvalidInput)
+	}
+
+	if subresource == "" {
+		return t.lookup(options, apiVersion, kind, namespace, name)
+	}
+
+	if t.dynamicWatcher != nil {
+		return nil, fmt.Errorf("%w: lookupSubresource is not supported when caching is enabled", ErrInvalidInput)
+	}
+
+	if options == nil {
+		options = &ResolveOptions{}
+	}
+
+	result, err := t.getSubresource(options, apiVersion, kind, namespace, name, subresource)
+
+	// lookups don't fail on errors
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil || result == nil || !options.TrimManagedFields {
+		return result, err
+	}
+
+	trimManagedFields(result, options.PreservedFieldManagers)
+
+	return result, nil
+}
+
+// getSubresource returns the subresource of the object from the Kubernetes API. The query is recorded like the lookups
+// of getOrListRaw.
+func (t *TemplateResolver) getSubresource(
+	options *ResolveOptions, apiVersion string, kind string, namespace string, name string, subresource string,
+) (object map[string]interface{}, err error) {
+	if apiVersion == "" || kind == "" {
+		return nil, errors.New("the apiVersion and kind are required")
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	gvk := gv.WithKind(kind)
+
+	if err := checkAllowedGVK(options, gvk); err != nil {
+		return nil, err
+	}
+
+	scopedGVRObj, err := t.getScopedGVR(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	if !scopedGVRObj.Namespaced && len(options.lookupNamespaces()) != 0 {
+		rsrcIdentifier := ClusterScopedObjectIdentifier{
+			Group: scopedGVRObj.Group,
+			Kind:  kind,
+			Name:  name,
+		}
+		if !isClusterScopedAllowed(options, rsrcIdentifier) {
+			return nil, ClusterScopedLookupRestrictedError{Group: scopedGVRObj.Group, Kind: kind, Name: name}
+		}
+	}
+
+	var ns string
+
+	// The namespace is not relevant to cluster-scoped resources unless it was explicitly provided
+	if scopedGVRObj.Namespaced || namespace != "" {
+		ns, err = t.getNamespace(namespace, options.lookupNamespaces()...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resources, err := t.kubeClient.Discovery().ServerResourcesForGroupVersion(apiVersion)
+	if err != nil {
+		return nil, wrapAPIUnavailableError(gvk, err)
+	}
+
+	subresourceName := scopedGVRObj.Resource + "/" + subresource
+	registered := false
+
+	for _, apiResource := range resources.APIResources {
+		if apiResource.Name == subresourceName {
+			registered = true
+
+			break
+		}
+	}
+
+	if !registered {
+		return nil, fmt.Errorf("%w: %s, Kind=%s: %s", ErrMissingSubresource, apiVersion, kind, subresource)
+	}
+
+	if err := t.reviewLookup(options, scopedGVRObj.GroupVersionResource, ns, name, subresource); err != nil {
+		return nil, err
+	}
+
+	queryID := client.ObjectIdentifier{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: ns,
+		Name:      name,
+	}
+
+	options.addReferencedObject(queryID)
+
+	defer func() {
+		if isMissingObject(object, err) {
+			options.addMissingObject(queryID)
+		}
+	}()
+	t.metrics.observeLookup(gvk)
+
+	stats := options.lookupStats()
+	stats.Lookups++
+
+	dynamicClient, _, err := t.clientsFor(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var dynamicClientRes dynamic.ResourceInterface
+
+	if scopedGVRObj.Namespaced {
+		if ns == "" {
+			return nil, ErrMissingNamespace
+		}
+
+		dynamicClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource).Namespace(ns)
+	} else {
+		dynamicClientRes = dynamicClient.Resource(scopedGVRObj.GroupVersionResource)
+	}
+
+	if err := options.countLookup(); err != nil {
+		return nil, err
+	}
+
+	stats.APICalls++
+
+	result, err := dynamicClientRes.Get(options.apiContext(), name, metav1.GetOptions{}, subresource)
+	if err != nil {
+		return nil, wrapAPIUnavailableError(gvk, err)
+	}
+
+	stats.addBytesFetched(result.UnstructuredContent())
+
+	return result.UnstructuredContent(), nil
+}
@@ -0,0 +1,116 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+func TestLookupSubresource(t *testing.T) {
+	t.Parallel()
+
+	responses := map[string]string{
+		"/apis/apps/v1": `{"kind": "APIResourceList", "apiVersion": "v1", "groupVersion": "apps/v1", "resources": [` +
+			`{"name": "deployments", "singularName": "", "namespaced": true, "kind": "Deployment", "verbs": ["get"]},` +
+			`{"name": "deployments/scale", "singularName": "", "namespaced": true, "group": "autoscaling", ` +
+			`"version": "v1", "kind": "Scale", "verbs": ["get"]}]}`,
+		"/apis/apps/v1/namespaces/default/deployments/web/scale": `{"kind": "Scale", "apiVersion": "autoscaling/v1", ` +
+			`"metadata": {"name": "web", "namespace": "default"}, "spec": {"replicas": 3}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	resolver, err := NewResolver(&rest.Config{Host: server.URL}, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	result, err := resolver.lookupSubresource(&ResolveOptions{}, "apps/v1", "Deployment", "default", "web", "scale")
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	replicas, _, _ := unstructured.NestedInt64(result, "spec", "replicas")
+	if replicas != 3 {
+		t.Fatalf("Expected the scale subresource with 3 replicas but got: %v", result)
+	}
+
+	result, err = resolver.lookupSubresource(&ResolveOptions{}, "apps/v1", "Deployment", "default", "db", "scale")
+	if err != nil || result != nil {
+		t.Fatalf("Expected an empty result and no error but got: %v, %v", result, err)
+	}
+
+	_, err = resolver.lookupSubresource(&ResolveOptions{}, "apps/v1", "Deployment", "default", "web", "status")
+	if !errors.Is(err, ErrMissingSubresource) {
+		t.Fatalf("Expected ErrMissingSubresource but got: %v", err)
+	}
+
+	_, err = resolver.lookupSubresource(&ResolveOptions{}, "apps/v1", "Deployment", "default", "", "scale")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+
+	_, err = resolver.lookupSubresource(
+		&ResolveOptions{LookupNamespace: "other"}, "apps/v1", "Deployment", "default", "web", "scale",
+	)
+	if !errors.Is(err, ErrRestrictedNamespace) {
+		t.Fatalf("Expected ErrRestrictedNamespace but got: %v", err)
+	}
+
+	tmplResult, err := resolver.ResolveTemplate(
+		[]byte(`replicas: '{{ (lookupSubresource "apps/v1" "Deployment" "default" "web" "scale").spec.replicas }}'`),
+		nil,
+		&ResolveOptions{},
+	)
+	if err != nil {
+		t.Fatalf("No error was expected: %v", err)
+	}
+
+	expectedRefs := []client.ObjectIdentifier{
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "default", Name: "web"},
+	}
+	if !reflect.DeepEqual(tmplResult.ReferencedObjects, expectedRefs) {
+		t.Fatalf("Expected the referenced objects %v but got: %v", expectedRefs, tmplResult.ReferencedObjects)
+	}
+
+	if tmplResult.LookupStats.Lookups != 1 || tmplResult.LookupStats.APICalls != 1 {
+		t.Fatalf("Expected 1 lookup and 1 API call but got: %+v", tmplResult.LookupStats)
+	}
+}
+
+func TestLookupSubresourceCaching(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Simulate NewResolverWithCaching, which has no client for direct Kubernetes API requests
+	resolver.dynamicWatcher = &changingWatcher{}
+	resolver.dynamicClient = nil
+
+	_, err = resolver.lookupSubresource(&ResolveOptions{}, "apps/v1", "Deployment", "default", "web", "scale")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+}
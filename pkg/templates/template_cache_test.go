@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTemplateCache(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		nil,
+		Config{InputIsYAML: true, TemplateCacheMaxEntries: 10, MetricsRegisterer: prometheus.NewRegistry()},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectHits := func(hits float64, misses float64) {
+		t.Helper()
+
+		if value := testutil.ToFloat64(resolver.metrics.templateCache.WithLabelValues("hit")); value != hits {
+			t.Fatalf("Expected %v template cache hits but got %v", hits, value)
+		}
+
+		if value := testutil.ToFloat64(resolver.metrics.templateCache.WithLabelValues("miss")); value != misses {
+			t.Fatalf("Expected %v template cache misses but got %v", misses, value)
+		}
+	}
+
+	tmpl := []byte(`value: '{{ resolveID }}'`)
+
+	// The template functions of a cached template must be those of the ResolveTemplate call
+	for i := 0; i < 2; i++ {
+		result, err := resolver.ResolveTemplate(tmpl, nil, nil)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if expected := `{"value":"` + result.ResolveID + `"}`; string(result.ResolvedJSON) != expected {
+			t.Fatalf("Expected %s but got %s", expected, result.ResolvedJSON)
+		}
+	}
+
+	expectHits(1, 1)
+
+	// Different template functions are a different cache entry since they affect parsing
+	options := &ResolveOptions{CustomFunctions: map[string]interface{}{"custom": func() string { return "a" }}}
+
+	_, err = resolver.ResolveTemplate(tmpl, nil, options)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectHits(1, 2)
+
+	// A function that is denied after the template was cached still fails to parse
+	_, err = resolver.ResolveTemplate(tmpl, nil, &ResolveOptions{DeniedFunctions: []string{"resolveID"}})
+	if err == nil {
+		t.Fatalf("Expected an error for the denied function")
+	}
+
+	expectHits(1, 3)
+
+	result, err := resolver.ResolveTemplate([]byte(`value: '{{ "a" }}'`), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if string(result.ResolvedJSON) != `{"value":"a"}` {
+		t.Fatalf(`Expected {"value":"a"} but got %s`, result.ResolvedJSON)
+	}
+
+	expectHits(1, 4)
+}
@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateErrorRegex captures the line and the optional column of the failing action from a text/template error.
+// For example, "template: tmpl:2:16: executing ..." or "template: tmpl:1: function ...".
+var templateErrorRegex = regexp.MustCompile(`template: tmpl:(\d+)(?::(\d+))?:`)
+
+// TemplateError is a template parsing or execution error with the position of the failing action in the template.
+// Use errors.As to get it from the error returned by ResolveTemplate.
+//
+// - Template is the YAML template that was parsed. If Config.InputIsYAML is not set, this is the input JSON converted
+// to YAML. The template may also have minor modifications from the processing of special functions (e.g. toLiteral).
+//
+// - Offset is the byte offset of the failing action in Template.
+//
+// - Line is the 1-based line of the failing action in Template.
+//
+// - Column is the 1-based byte column of the failing action in the line. This is 0 when only the line is known, such
+// as for some parsing errors.
+//
+// - Err is the underlying text/template error.
+type TemplateError struct {
+	Template string
+	Offset   int
+	Line     int
+	Column   int
+	Err      error
+}
+
+func (e *TemplateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Context returns the lines of the template surrounding the failing action, with up to contextLines lines before and
+// after it. Each line is prefixed with its line number, and a marker is added under the failing action when the
+// column is known.
+func (e *TemplateError) Context(contextLines int) string {
+	lines := strings.Split(e.Template, "\n")
+
+	if e.Line < 1 || e.Line > len(lines) {
+		return ""
+	}
+
+	start := e.Line - contextLines
+	if start < 1 {
+		start = 1
+	}
+
+	end := e.Line + contextLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	width := len(strconv.Itoa(end))
+
+	var builder strings.Builder
+
+	for lineNum := start; lineNum <= end; lineNum++ {
+		fmt.Fprintf(&builder, "%*d | %s\n", width, lineNum, lines[lineNum-1])
+
+		if lineNum == e.Line && e.Column > 0 {
+			fmt.Fprintf(&builder, "%s | %s^\n", strings.Repeat(" ", width), strings.Repeat(" ", e.Column-1))
+		}
+	}
+
+	return builder.String()
+}
+
+// newTemplateError wraps the input text/template error in a TemplateError with the position of the failing action in
+// the input template. The input error is returned as is if it doesn't have a position.
+func newTemplateError(templateStr string, err error) error {
+	match := templateErrorRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+
+	line, _ := strconv.Atoi(match[1])
+
+	lineOffset := 0
+
+	for i := 1; i < line; i++ {
+		newLineIndex := strings.IndexByte(templateStr[lineOffset:], '\n')
+		if newLineIndex == -1 {
+			return err
+		}
+
+		lineOffset += newLineIndex + 1
+	}
+
+	tmplErr := &TemplateError{Template: templateStr, Offset: lineOffset, Line: line, Err: err}
+
+	// text/template reports a 0-based byte column
+	if match[2] != "" {
+		column, _ := strconv.Atoi(match[2])
+		tmplErr.Column = column + 1
+		tmplErr.Offset += column
+	}
+
+	return tmplErr
+}
+
+// classifiedError is an error that also matches a sentinel error (e.g. ErrParseFailed) with errors.Is without changing
+// the error message.
+type classifiedError struct {
+	err      error
+	sentinel error
+}
+
+func (e classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e classifiedError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+// classifyError wraps the input error so that it also matches the input sentinel error with errors.Is. The input error
+// is returned as is if it's nil or already matches the sentinel error.
+func classifyError(sentinel error, err error) error {
+	if err == nil || errors.Is(err, sentinel) {
+		return err
+	}
+
+	return classifiedError{err: err, sentinel: sentinel}
+}
@@ -0,0 +1,170 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestResolveTemplateTemplateError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		inputTmpl       string
+		expectedLine    int
+		expectedColumn  int
+		expectedOffset  int
+		expectedContext string
+	}{
+		"execution error": {
+			inputTmpl:      "first: value\nsecond: '{{ \"\" | required \"must be set\" }}'\nthird: value",
+			expectedLine:   2,
+			expectedColumn: 18,
+			expectedOffset: 30,
+			expectedContext: "1 | first: value\n" +
+				"2 | second: '{{ \"\" | required \"must be set\" }}'\n" +
+				"  |                  ^\n" +
+				"3 | third: value\n",
+		},
+		"parse error": {
+			inputTmpl:      "first: value\nsecond: value\nthird: '{{ notAFunction }}'",
+			expectedLine:   3,
+			expectedColumn: 0,
+			expectedOffset: 27,
+			expectedContext: "2 | second: value\n" +
+				"3 | third: '{{ notAFunction }}'\n",
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			_, err = resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+
+			var tmplErr *TemplateError
+			if !errors.As(err, &tmplErr) {
+				t.Fatalf("Expected a TemplateError but got: %v", err)
+			}
+
+			if tmplErr.Line != test.expectedLine {
+				t.Fatalf("Expected line %d but got %d", test.expectedLine, tmplErr.Line)
+			}
+
+			if tmplErr.Column != test.expectedColumn {
+				t.Fatalf("Expected column %d but got %d", test.expectedColumn, tmplErr.Column)
+			}
+
+			if tmplErr.Offset != test.expectedOffset {
+				t.Fatalf("Expected offset %d but got %d", test.expectedOffset, tmplErr.Offset)
+			}
+
+			if context := tmplErr.Context(1); context != test.expectedContext {
+				t.Fatalf("Expected the context:\n%s\nbut got:\n%s", test.expectedContext, context)
+			}
+		})
+	}
+}
+
+func TestNewTemplateErrorNoPosition(t *testing.T) {
+	t.Parallel()
+
+	inputErr := errors.New("some error")
+
+	if err := newTemplateError("value: test", inputErr); err != inputErr { //nolint:errorlint
+		t.Fatalf("Expected the input error to be returned as is but got: %v", err)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	inputErr := &TemplateError{Err: errors.New("template: tmpl:1: unexpected EOF")}
+
+	err := classifyError(ErrParseFailed, inputErr)
+
+	if !errors.Is(err, ErrParseFailed) {
+		t.Fatalf("Expected the error to match ErrParseFailed but got: %v", err)
+	}
+
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) || tmplErr != inputErr {
+		t.Fatalf("Expected the error to wrap the input error but got: %v", err)
+	}
+
+	if err.Error() != inputErr.Error() {
+		t.Fatalf("Expected the error message to be unchanged but got: %s", err.Error())
+	}
+
+	if classifyError(ErrParseFailed, nil) != nil {
+		t.Fatal("Expected a nil error to be returned as is")
+	}
+}
+
+func TestResolveTemplateTypedErrors(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	aesKey := bytes.Repeat([]byte{byte('A')}, 256/8)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+
+	tests := map[string]struct {
+		inputTmpl   string
+		options     ResolveOptions
+		expectedErr error
+	}{
+		"parse failure": {
+			inputTmpl:   `value: '{{ printf "%s" }'`,
+			expectedErr: ErrParseFailed,
+		},
+		"cluster-scoped lookup restricted": {
+			inputTmpl:   `value: '{{ (lookup "v1" "Namespace" "" "default").metadata.name }}'`,
+			options:     ResolveOptions{LookupNamespace: "app"},
+			expectedErr: ErrClusterScopedLookupRestricted,
+		},
+		"decryption failure": {
+			inputTmpl: `value: '$ocm_encrypted:Zm9vYmFy'`,
+			options: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: aesKey, DecryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedErr: ErrDecryptionFailed,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &test.options)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected an error matching %v but got: %v", test.expectedErr, err)
+			}
+		})
+	}
+
+	// The encryption configuration is validated before resolving, so call protect directly with an invalid AES key
+	_, err = resolver.protect(
+		&ResolveOptions{EncryptionConfig: EncryptionConfig{AESKey: []byte("too-short"), InitializationVector: iv}},
+		"foo",
+	)
+	if !errors.Is(err, ErrEncryptionFailed) {
+		t.Fatalf("Expected an error matching ErrEncryptionFailed but got: %v", err)
+	}
+}
@@ -7,26 +7,37 @@ import (
 	"bytes"
 	"context"
 	"crypto/aes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cast"
 	"github.com/stolostron/kubernetes-dependency-watches/client"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slices"
 	yaml "gopkg.in/yaml.v3"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
@@ -35,7 +46,12 @@ const (
 	defaultStopDelim  = "}}"
 	IVSize            = 16 // Size in bytes
 	protectedPrefix   = "$ocm_encrypted:"
-	yamlIndentation   = 2
+	// gcmPrefix follows protectedPrefix in values encrypted with AES-GCM. Values encrypted with AES-CBC have no
+	// algorithm prefix for backwards compatibility.
+	gcmPrefix = "gcm:"
+	// objectPrefix follows protectedPrefix in values from the protectObject template function.
+	objectPrefix    = "obj:"
+	yamlIndentation = 2
 )
 
 var (
@@ -45,11 +61,14 @@ var (
 	ErrIVNotSet              = errors.New("initialization vector must be set to use this encryption mode")
 	ErrInvalidIV             = errors.New("initialization vector must be 128 bits")
 	ErrInvalidPKCS7Padding   = errors.New("invalid PCKS7 padding")
+	ErrInvalidAlgorithm      = errors.New("the encryption algorithm is not supported")
+	ErrGCMAuthFailed         = errors.New("the encrypted value failed the AES-GCM authentication")
 	ErrMissingAPIResource    = errors.New("one or more API resources are not installed on the API server")
 	ErrProtectNotEnabled     = errors.New("the protect template function is not enabled in this mode")
 	ErrNewLinesNotAllowed    = errors.New("new lines are not allowed in the string passed to the toLiteral function")
 	ErrInvalidContextType    = errors.New(
-		"the input context must be a struct, with either string fields or map[string]string fields",
+		"the input context must be a struct, with either string fields, map[string]string fields, or " +
+			"map[string]interface{} fields",
 	)
 	ErrMissingNamespace = errors.New(
 		"the lookup of a single namespaced resource must have a namespace specified",
@@ -59,6 +78,23 @@ var (
 	ErrCacheDisabled            = client.ErrCacheDisabled
 	ErrNoCacheEntry             = client.ErrNoCacheEntry
 	ErrContextTransformerFailed = errors.New("the context transformer failed")
+	ErrMultipleDefaults         = errors.New("multiple objects are marked as the default")
+	ErrEncryptionNotEnabled     = errors.New("encryption must be enabled to use this template function")
+	ErrRequiredValue            = errors.New("a required value is empty")
+	ErrLookupQuotaExceeded      = errors.New("the template resolution exceeded the maximum number of lookups")
+	ErrAPIUnavailable           = errors.New(
+		"the API server serving the API resource is unavailable, check the status of its APIService",
+	)
+	// ErrClusterScopedLookupRestricted is matched by ClusterScopedLookupRestrictedError.
+	ErrClusterScopedLookupRestricted = errors.New("the lookup of a cluster-scoped resource is not allowed")
+	// ErrKindLookupRestricted is matched by KindLookupRestrictedError.
+	ErrKindLookupRestricted = errors.New("the lookup of the kind is not allowed")
+	ErrParseFailed          = errors.New("the template failed to parse")
+	ErrEncryptionFailed     = errors.New("the value failed to be encrypted")
+	ErrDecryptionFailed     = errors.New("the encrypted value failed to be decrypted")
+	ErrWatcherFailed        = errors.New("the watch or cache of the looked up objects failed")
+	// ErrLookupAccessDenied is matched by LookupAccessDeniedError.
+	ErrLookupAccessDenied = errors.New("the lookup is not permitted by the access review")
 )
 
 // Config is a struct containing configuration for the API.
@@ -82,6 +118,76 @@ var (
 // - MissingAPIResourceCacheTTL can be set if you want to temporarily cache an API resource is missing to avoid
 // duplicate API queries when a CRD is missing. By default, this will not be cached. Note that this only affects
 // when caching is enabled.
+//
+// - MetricsRegisterer is an optional Prometheus registerer (e.g. the controller-runtime metrics.Registry) to register
+// metrics about template resolution with. This includes the number, duration, and errors of resolutions, the number of
+// lookups by kind, and the temporary call cache hits and misses. Resolvers may share a registerer.
+//
+// - Clock is the function used by time-dependent template functions (e.g. now) to get the current time. This defaults
+// to time.Now and is useful for deterministic output in tests.
+//
+// - QPS and Burst override the client-side throttling of the input rest.Config for the Kubernetes clients of the
+// resolver, which includes the API queries of the template functions. The values of the rest.Config are used when
+// these are 0.
+//
+// - RateLimiter is an optional client-side rate limiter for the Kubernetes clients of the resolver, which overrides QPS
+// and Burst. Resolvers may share a rate limiter (e.g. flowcontrol.NewTokenBucketRateLimiter) to bound the combined API
+// pressure of the template lookups in a process.
+//
+// - Impersonate overrides the impersonation of the input rest.Config for the Kubernetes clients of the resolver, so
+// that the template lookups are authorized as the impersonated user and groups, such as the service account of the
+// policy controller. A lookup that the identity isn't allowed to do then fails with a Forbidden error. The
+// impersonation of the rest.Config is used when this is empty.
+//
+// - LookupCache is an optional cache of the lookups to use instead of the built-in temporary call cache when caching
+// is disabled, such as one backed by a shared informer cache or shared across processes. Unlike the built-in cache,
+// it's not cleared after each ResolveTemplate call, so the implementation is responsible for expiring the entries. This
+// is ignored by NewResolverWithCaching.
+//
+// - LookupCacheTTL and LookupCacheMaxEntries keep the lookups in the built-in cache across ResolveTemplate calls when
+// caching is disabled, rather than clearing the cache after each call. An entry expires LookupCacheTTL after it was
+// cached, and the least recently used entries are evicted when there are more than LookupCacheMaxEntries entries. A
+// value of 0 means no expiration or no bound respectively. These cannot be set with LookupCache and are ignored by
+// NewResolverWithCaching.
+//
+// - NotFoundCacheTTL overrides LookupCacheTTL for the lookups of single objects that were not found, so that an object
+// created after the lookup is seen sooner. This only applies when LookupCacheTTL or LookupCacheMaxEntries is set.
+//
+// - DiscoveryCacheTTL is how long the API resource of a kind found with the API discovery is cached across
+// ResolveTemplate calls. This defaults to 10 minutes and a negative value disables the cache. A kind whose API resource
+// is not found is not cached, and the API discovery is retried once before failing with ErrMissingAPIResource, so a
+// newly installed CRD can be looked up right away. A cached API resource is removed when a lookup of it indicates that
+// it's no longer installed.
+//
+// - DisableNotFoundCache disables caching the lookups of single objects that were not found when caching is disabled,
+// so that the next lookup of the object queries the API again, including within the same ResolveTemplate call.
+//
+// - ResultCacheMaxEntries enables the caching of the results of successful ResolveTemplate calls, up to the input
+// number of results with the least recently used evicted first. A result is keyed by a hash of the raw template, the
+// context, and the options, and it's returned without executing the template again while the objects returned by its
+// lookups have the same resourceVersions, which is checked by repeating the lookups. This is most effective with
+// caching enabled, where the lookups are served from the watch cache. Resolutions with ResolveOptions.CustomFunctions,
+// ResolveOptions.ContextTransformers, or an encryption provider, and the resolutions calling template functions with a
+// varying output, such as `now`, `resolveID`, `canLookup`, `fromVault`, or the random and key generation functions, are
+// not cached. The default of 0 disables the result cache.
+//
+// - TemplateCacheMaxEntries enables the caching of the parsed templates, up to the input number of templates with the
+// least recently used evicted first. This avoids parsing the same template text again in every ResolveTemplate call,
+// such as when many objects have the same template. The default of 0 disables the template cache.
+//
+// - Vault is the configuration of the HashiCorp Vault server of the fromVault template function, which fails with
+// ErrVaultNotConfigured if this is not set.
+//
+// - Logger is the logger of the resolver, such as the controller-runtime logger. The logger in the context of
+// ResolveTemplateWithContext (see logr.NewContext) is used instead when set, so that the logs of a resolution can
+// include values such as the name and namespace of the policy being reconciled. The verbosity levels are those of klog,
+// with level 2 logging each template function call. This defaults to a logger that writes to klog.
+//
+// - TracerProvider is an optional OpenTelemetry tracer provider to record spans of template resolution with. Each
+// ResolveTemplate call has a span, a child of the span in its context if any, and each Kubernetes object lookup of the
+// template functions has a child span with the group, version, kind, namespace, and name of the query and whether it
+// was a temporary call cache hit. The cache hit attribute is not set when caching is enabled since the lookups are then
+// always served from the watch cache.
 type Config struct {
 	AdditionalIndentation      uint
 	DisabledFunctions          []string
@@ -89,6 +195,23 @@ type Config struct {
 	StopDelim                  string
 	InputIsYAML                bool
 	MissingAPIResourceCacheTTL time.Duration
+	MetricsRegisterer          prometheus.Registerer
+	Clock                      func() time.Time
+	QPS                        float32
+	Burst                      int
+	RateLimiter                flowcontrol.RateLimiter
+	Impersonate                rest.ImpersonationConfig
+	LookupCache                LookupCache
+	LookupCacheTTL             time.Duration
+	LookupCacheMaxEntries      uint
+	NotFoundCacheTTL           time.Duration
+	DisableNotFoundCache       bool
+	DiscoveryCacheTTL          time.Duration
+	ResultCacheMaxEntries      uint
+	TemplateCacheMaxEntries    uint
+	Vault                      *VaultConfig
+	Logger                     logr.Logger
+	TracerProvider             trace.TracerProvider
 }
 
 // ResolveOptions is a struct containing configuration for calling ResolveTemplate.
@@ -97,10 +220,34 @@ type Config struct {
 // query API. This is useful if you want to add information about a Kubernetes object in the context and be notified
 // when the object changes.
 //
+// - AggregateErrors continues resolving the other templated fields when a template fails to resolve so that all
+// failing templates are reported at once. The returned error joins an error per failing field, which includes the
+// template. This applies when each template is contained in a single string field and is ignored when
+// ResolveInDependencyOrder is set.
+//
+// - AllowedGVKs restricts the template functions that look up objects (e.g. lookup and fromConfigMap) to the listed
+// kinds, such as to only allow a tenant's templates to look up ConfigMaps and Secrets. Each field may be the wildcard
+// value `*` to match any value. An error wrapping ErrKindLookupRestricted is returned when another kind is looked up,
+// and canLookup returns false for it. The default of an empty list allows all kinds. This doesn't apply to the
+// TemplateLibraryConfigMap.
+//
 // - ClusterScopedAllowList is a list of cluster-scoped object identifiers (group, kind, name) which
-// are allowed to be used in "lookup" calls even when LookupNamespace is set. A wildcard value `*`
-// may be used in any or all of the fields. The default behavior when LookupNamespace is set is to
-// deny all cluster-scoped lookups.
+// are allowed to be used in "lookup" calls even when LookupNamespace or LookupNamespaces is set. A wildcard value `*`
+// may be used in any or all of the fields, and a value ending with `*` matches a prefix (e.g. `kube-*`). The default
+// behavior when LookupNamespace or LookupNamespaces is set is to deny all cluster-scoped lookups.
+//
+// - ClusterScopedDenyList is a list of cluster-scoped object identifiers, in the same format as ClusterScopedAllowList,
+// which are denied even if they are on the ClusterScopedAllowList. For example, an allow entry of all Nodes and a deny
+// entry of the Nodes named `control-plane-*` allow looking up all Nodes except the control plane ones. The denied
+// objects are also removed from the results of list queries. This only applies when LookupNamespace or
+// LookupNamespaces is set.
+//
+// - CustomFunctions is a map of additional template functions to make available, keyed by function name. An error
+// wrapping ErrCustomFunctionCollision is returned if a name is already used by a built-in template function. The
+// functions must follow the requirements of the text/template package.
+//
+// - DeniedFunctions is a list of template function names to remove for this call, such as "lookup" or "fromSecret"
+// for templates from a less trusted source. An error wrapping ErrDeniedFunction is returned if the template uses one.
 //
 // - EncryptionConfig is the configuration for template encryption/decryption functionality.
 //
@@ -108,21 +255,206 @@ type Config struct {
 // The caller must call the CacheCleanUp function returned from ResolveTemplate when done. This is useful if you are
 // splitting up calls to ResolveTemplate for a single template owner object.
 //
+// - EnabledFunctionGroups is a list of the optional groups of sprig template functions to make available in addition to
+// the default template functions. The groups are "dicts", "encoding", "lists", "logic", "math", "regex", and
+// "strings". An error wrapping ErrInvalidInput is returned if a group is unknown.
+//
+// - LookupAccessReview gates each lookup of the template functions (e.g. lookup and fromSecret) on an access review,
+// which is a SelfSubjectAccessReview, or a SubjectAccessReview of the subject if one is set, of the "get" verb for a
+// single object or the "list" verb for a list query. An error wrapping ErrLookupAccessDenied is returned when a lookup
+// isn't permitted. This enforces least privilege per tenant without a Kubernetes client per tenant, including when
+// caching is enabled and the lookups are served from the shared watch cache. The access reviews are cached for the
+// ResolveTemplate call. This doesn't apply to the TemplateLibraryConfigMap.
+//
 // - LookupNamespace is the namespace to restrict "lookup" template functions (e.g. fromConfigMap)
 // to. If this is not set (i.e. an empty string), then all namespaces can be used.
 //
+// - ExecutionTimeout is the maximum duration of the ResolveTemplate call, which also applies to the Kubernetes API
+// queries of the template functions. Since the template execution can't be interrupted, the deadline is checked at each
+// template function call and each write of the template output, and an error wrapping ErrExecutionTimeout is returned
+// when it has passed. The default of 0 means there is no timeout.
+//
+// - Impersonate is the identity to impersonate for the Kubernetes API queries of the template functions (e.g. lookup,
+// canLookup, and the ValidateAgainstCluster dry-run), such as the user of a tenant or the service account returned by
+// ServiceAccountImpersonation, so that the templates can only read what that identity can read. A lookup that the
+// identity isn't allowed to do fails with a Forbidden error. The resolver keeps a Kubernetes client per impersonated
+// identity, and the temporary call cache entries aren't shared between identities. The resolver's credentials must be
+// allowed to impersonate the identity. This doesn't apply to the TemplateLibraryConfigMap, and it cannot be set when
+// caching is enabled since the watch cache is shared by all identities.
+//
+// - LookupNamespaces is a list of namespaces to restrict "lookup" template functions to in addition to
+// LookupNamespace. When more than one namespace is allowed in total, the namespace argument of the "lookup"
+// template functions is required. The ClusterScopedAllowList applies when either field is set.
+//
+// - MaxConcurrency is the maximum number of templates that ResolveTemplates resolves concurrently. This defaults to 10
+// and is ignored by ResolveTemplate.
+//
+// - MaxLookups is the maximum number of Kubernetes API queries that the template functions (e.g. lookup and
+// canLookup) may make in the ResolveTemplate call, including the access reviews of LookupAccessReview. Lookups served
+// from the temporary call cache are not counted, and when caching is enabled, only the first lookup of each object or
+// list in the ResolveTemplate call is counted since the repeated lookups are served from the objects pinned for the
+// call. An error wrapping ErrLookupQuotaExceeded is returned when a lookup would exceed it. The default of 0 means
+// there is no maximum.
+//
+// - MaxExecutionSteps is the maximum number of template function calls, `range` iterations, and writes of the template
+// output in the ResolveTemplate call, across all passes and named templates. This aborts runaway templates, such as a
+// `range` over a huge list or deeply nested loops, with an error wrapping ErrExecutionStepsExceeded. Note that each
+// action and each text between actions is a write, so a loop iteration is usually multiple steps. The default of 0
+// means there is no maximum.
+//
+// - MaxPasses is the maximum number of times the template is resolved when the output of a pass has template actions,
+// such as when a template fragment is stored in a ConfigMap and returned by fromConfigMap. Each pass has the same
+// context and template functions. An error wrapping ErrMaxPassesExceeded is returned if the output still has template
+// actions after the last pass. The default of 0 and a value of 1 resolve the template once, and the output is returned
+// as is. This is ignored when ResolveInDependencyOrder is set.
+//
+// - MissingKey is the text/template "missingkey" option, which controls the behavior when a template indexes a map
+// with a key that doesn't exist, such as `{{ (lookup "v1" "ConfigMap" "ns" "name").data.missing }}`. The supported
+// values are "default" and "invalid" to render `<no value>`, "zero" to render the zero value, and "error" to fail the
+// resolution. An empty string is the same as "default".
+//
+// - PreservedFieldManagers is a list of field manager names whose metadata.managedFields entries are kept in lookup
+// results when TrimManagedFields is set. If this is empty, all metadata.managedFields entries are removed.
+//
+// - RedactSensitive replaces the values derived from sensitive data with `[REDACTED]` in the log messages of the
+// resolution and in the returned error messages, since these may include the template after decryption or the values
+// passed to the template functions. See TemplateResult.SensitiveObjects for what is derived from sensitive data. The
+// TemplateError.Template of a returned error is also redacted, but the error messages of the other wrapped errors are
+// not.
+//
+// - ResolveInDependencyOrder resolves each templated string field individually instead of the whole document at once.
+// A field can reference the resolved value of another field with the `fieldValue` template function and a literal dot
+// separated path (e.g. `{{ fieldValue "spec.name" }}`), which determines the order of resolution. Each template must be
+// fully contained in a single string field in this mode. An error wrapping ErrFieldDependencyCycle is returned if the
+// fields reference each other in a cycle.
+//
+// - SecretDataCheck checks for the resolved objects that are not Secrets but contain a value derived from sensitive
+// data, such as a Secret value copied to a ConfigMap, which is a common mistake. With SecretDataCheckWarn, an error
+// wrapping ErrSecretDataInNonSecret is set in TemplateResult.Warnings for each of these objects. With
+// SecretDataCheckFail, the resolution fails with these errors instead. The resolved objects without a kind are not
+// checked. See TemplateResult.SensitiveObjects for what is derived from sensitive data. The default of an empty string
+// disables the check.
+//
+// - StartDelim and StopDelim override Config.StartDelim and Config.StopDelim for this call, such as `[[` and `]]` or
+// multi-character delimiters like `<%=` and `%>` for templates of objects that already use `{{ }}` for another
+// templating system, such as Prometheus rules or Grafana dashboards. Both must be set together, or an error wrapping
+// ErrInvalidInput is returned.
+//
+// - StrictParsing causes the `fromJson`, `mustFromJson`, and `fromYaml` template functions to return an error wrapping
+// ErrDuplicateKey if the input has the same key more than once in an object. By default, the last value of the key is
+// used. Note that the input template itself is always rejected if it has duplicate keys. The `fromJsonStrict` and
+// `fromYamlStrict` template functions are always strict.
+//
+// - TemplateLibraryConfigMap is the ConfigMap of named templates used by the `include` template function, where each
+// data key is the name of a template (e.g. `{{ include "common.labels" . }}`). The ConfigMap is looked up like any
+// other object, so it's watched when caching is enabled, but it's not restricted by LookupNamespace or
+// LookupNamespaces.
+//
+// - TempCallCacheMaxEntries bounds the number of entries in the temporary cache of lookup results used during the
+// ResolveTemplate call when caching is disabled. When the bound is exceeded, the least recently used entry is evicted,
+// which causes a subsequent lookup of it to query the API again. The default of 0 means the cache is unbounded.
+//
+// - TrimManagedFields removes the server-side apply metadata.managedFields from the objects returned by lookups. This
+// is useful when the looked up objects are embedded in the resolved template. See PreservedFieldManagers to keep the
+// entries of specific field managers.
+//
+// - ValidateAgainstCluster validates the resolved objects with a server-side dry-run apply after a successful
+// resolution. The resolved template must be a Kubernetes object or a list of Kubernetes objects. The rejected objects
+// are set in TemplateResult.ValidationErrors rather than failing the resolution. This cannot be set when caching is
+// enabled since the resolver has no client for direct Kubernetes API requests. See ValidateWithDryRun.
+//
 // - Watcher is the Kubernetes object that includes the templates. This is only used when caching is enabled.
 type ResolveOptions struct {
 	ContextTransformers []func(
 		queryAPI CachingQueryAPI, context interface{},
 	) (transformedContext interface{}, err error)
+	AggregateErrors        bool
+	AllowedGVKs            []schema.GroupVersionKind
 	ClusterScopedAllowList []ClusterScopedObjectIdentifier
+	ClusterScopedDenyList  []ClusterScopedObjectIdentifier
+	CustomFunctions        map[string]interface{}
+	DeniedFunctions        []string
 	EncryptionConfig
-	DisableAutoCacheCleanUp bool
-	LookupNamespace         string
-	Watcher                 *client.ObjectIdentifier
+	DisableAutoCacheCleanUp  bool
+	EnabledFunctionGroups    []string
+	ExecutionTimeout         time.Duration
+	Impersonate              *rest.ImpersonationConfig
+	LookupAccessReview       *LookupAccessReview
+	LookupNamespace          string
+	LookupNamespaces         []string
+	MaxConcurrency           uint
+	MaxExecutionSteps        uint
+	MaxLookups               uint
+	MaxPasses                uint
+	MissingKey               string
+	PreservedFieldManagers   []string
+	RedactSensitive          bool
+	ResolveInDependencyOrder bool
+	SecretDataCheck          SecretDataCheck
+	StartDelim               string
+	StopDelim                string
+	StrictParsing            bool
+	TemplateLibraryConfigMap types.NamespacedName
+	TempCallCacheMaxEntries  uint
+	TrimManagedFields        bool
+	ValidateAgainstCluster   bool
+	Watcher                  *client.ObjectIdentifier
+	// state is the internal state of a single ResolveTemplate call. It's only set on the copy of the options made by
+	// ResolveTemplate.
+	state *resolveState
+	// inBatch is set by ResolveTemplates so that the temporary call cache is shared by the templates of the batch.
+	inBatch bool
+}
+
+// resolveState is the internal state of a single ResolveTemplate call.
+type resolveState struct {
+	// tempCallCacheLRU tracks the temporary call cache entries when ResolveOptions.TempCallCacheMaxEntries is set.
+	tempCallCacheLRU *cacheLRU
+	// log is the logger of the ResolveTemplate call.
+	log logr.Logger
+	// hasSensitiveData is set when a Secret is read.
+	hasSensitiveData bool
+	// taint tracks the values derived from sensitive data to report the sensitive resolved objects.
+	taint taintTracker
+	// decryptedWithPreviousKey is set when an encrypted value is decrypted with a key other than the current key.
+	decryptedWithPreviousKey bool
+	// resolveID is the unique ID of the ResolveTemplate call returned by the resolveID template function.
+	resolveID string
+	// ctx is the context of the ResolveTemplateWithContext call used for Kubernetes API calls.
+	ctx context.Context
+	// referencedObjects are the unique object and list queries of the lookups.
+	referencedObjects []client.ObjectIdentifier
+	// missingObjects are the unique object and list queries of the lookups that returned no objects.
+	missingObjects []client.ObjectIdentifier
+	// includeDepth is the number of nested include template function calls being resolved.
+	includeDepth int
+	// lookupCount is the number of lookups counted against ResolveOptions.MaxLookups.
+	lookupCount uint
+	// deadline is the end of ResolveOptions.ExecutionTimeout.
+	deadline time.Time
+	// executionSteps is the number of steps counted against ResolveOptions.MaxExecutionSteps.
+	executionSteps uint
+	// recordLookups is set when the result of the ResolveTemplate call may be stored in the result cache.
+	recordLookups bool
+	// lookups are the lookups of the ResolveTemplate call recorded for the result cache.
+	lookups []lookupRecord
+	// uncacheable is set when the result of the ResolveTemplate call must not be stored in the result cache.
+	uncacheable bool
+	// lookupStats are the statistics of the lookups returned in TemplateResult.LookupStats.
+	lookupStats LookupStats
+	// snapshot pins the looked up objects to their first version seen in the call when caching is enabled.
+	snapshot objectSnapshot
+	// accessReviews are the statuses of the access reviews of ResolveOptions.LookupAccessReview keyed by the resource
+	// attributes of the lookups.
+	accessReviews map[authorizationv1.ResourceAttributes]authorizationv1.SubjectAccessReviewStatus
+	// generatedValues are the values generated by genRandomString and genUUID keyed by the Secret namespace, name, and
+	// key, so that repeated calls for the same key return the same value.
+	generatedValues map[string]string
 }
 
+// ClusterScopedObjectIdentifier identifies cluster-scoped objects in ResolveOptions.ClusterScopedAllowList and
+// ResolveOptions.ClusterScopedDenyList. Each field may be the wildcard value `*` to match any value or end with `*` to
+// match a prefix, such as `kube-*`.
 type ClusterScopedObjectIdentifier struct {
 	Group string
 	Kind  string
@@ -136,28 +468,148 @@ type ClusterScopedObjectIdentifier struct {
 //
 // - AESKeyFallback is an AES key to try if the decryption fails using AESKey.
 //
+// - Algorithm is the EncryptionAlgorithm of the "protect" template function, which defaults to AES-CBC. Values
+// encrypted with either algorithm are decrypted regardless of this setting, since the algorithm is part of the
+// encrypted value.
+//
 // - DecryptionConcurrency is the concurrency (i.e. number of Goroutines) limit when decrypting encrypted strings. Not
 // setting this value is the equivalent of setting this to 1, which means no concurrency.
 //
 // - DecryptionEnabled enables automatic decrypting of encrypted strings. AESKey and InitializationVector must also be
-// set if this is enabled.
+// set if this is enabled, unless Provider is set.
 //
 // - EncryptionEnabled enables the "protect" template function and "fromSecret" returns encrypted content. AESKey and
-// InitializationVector must also be set if this is enabled.
+// InitializationVector must also be set if this is enabled, unless Provider is set.
 //
 // - InitializationVector is the initialization vector (IV) used in the AES-CBC encryption/decryption. Note that it must
 // be equal to the AES block size which is always 128 bits (16 bytes). This value must be random but does not need to be
 // private. Its purpose is to make the same plaintext value, when encrypted with the same AES key, appear unique. When
 // performing decryption, the IV must be the same as it was for the encryption of the data. Note that all values
 // encrypted in the template will use this same IV, which means that duplicate plaintext values that are encrypted will
-// yield the same encrypted value in the template.
+// yield the same encrypted value in the template. With AES-GCM, the IV is only used to derive the nonce.
+//
+// - PreviousAESKeys are the AES keys that were previously used for the encryption, which are tried in order after
+// AESKey and AESKeyFallback when decrypting. This allows the AES key to be rotated without failing the decryption of
+// values encrypted with an earlier key. TemplateResult.DecryptedWithPreviousKey is set when a value is decrypted with
+// one of these or AESKeyFallback, which means that the value should be encrypted again with AESKey.
+//
+// - Provider is the EncryptionProvider that encrypts and decrypts the values instead of the AES key, such as one backed
+// by an external key management service. AESKey, AESKeyFallback, Algorithm, InitializationVector, and PreviousAESKeys
+// are not used when this is set.
 type EncryptionConfig struct {
 	AESKey                []byte
 	AESKeyFallback        []byte
+	Algorithm             EncryptionAlgorithm
 	DecryptionConcurrency uint8
 	DecryptionEnabled     bool
 	EncryptionEnabled     bool
 	InitializationVector  []byte
+	PreviousAESKeys       [][]byte
+	Provider              EncryptionProvider
+}
+
+// EncryptionAlgorithm is the algorithm of the "protect" template function.
+type EncryptionAlgorithm string
+
+const (
+	// EncryptionAlgorithmAESCBC is AES-CBC with PKCS #7 padding, which is the default.
+	EncryptionAlgorithmAESCBC EncryptionAlgorithm = "AES-CBC"
+	// EncryptionAlgorithmAESGCM is the AES-GCM authenticated encryption, so a modified encrypted value fails to decrypt
+	// rather than decrypting to a different value. The nonce is derived from the plaintext value, so like AES-CBC, the
+	// same plaintext value yields the same encrypted value and the resolved template is stable.
+	EncryptionAlgorithmAESGCM EncryptionAlgorithm = "AES-GCM"
+)
+
+// tempCallCacheLRU returns the LRU tracker of the temporary call cache for the ResolveTemplate call. This is nil if the
+// cache is unbounded or the options are not from a ResolveTemplate call.
+func (o *ResolveOptions) tempCallCacheLRU() *cacheLRU {
+	if o.state == nil {
+		return nil
+	}
+
+	return o.state.tempCallCacheLRU
+}
+
+// lookupNamespaces returns the namespaces that "lookup" template functions are restricted to. An empty list means
+// there are no restrictions.
+func (o *ResolveOptions) lookupNamespaces() []string {
+	namespaces := make([]string, 0, len(o.LookupNamespaces)+1)
+
+	for _, namespace := range append([]string{o.LookupNamespace}, o.LookupNamespaces...) {
+		if namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces
+}
+
+// addReferencedObject records the object or list query of a lookup in the ResolveTemplate call if it's not already
+// recorded. This is a no-op if the options are not from a ResolveTemplate call.
+func (o *ResolveOptions) addReferencedObject(objID client.ObjectIdentifier) {
+	if o.state == nil || slices.Contains(o.state.referencedObjects, objID) {
+		return
+	}
+
+	o.state.referencedObjects = append(o.state.referencedObjects, objID)
+}
+
+// addMissingObject records the object or list query of a lookup that returned no objects in the ResolveTemplate call if
+// it's not already recorded. This is a no-op if the options are not from a ResolveTemplate call.
+func (o *ResolveOptions) addMissingObject(objID client.ObjectIdentifier) {
+	if o.state == nil || slices.Contains(o.state.missingObjects, objID) {
+		return
+	}
+
+	o.state.missingObjects = append(o.state.missingObjects, objID)
+}
+
+// apiContext returns the context to use for Kubernetes API calls in the ResolveTemplate call. This is context.TODO()
+// if the options are not from a ResolveTemplate call.
+func (o *ResolveOptions) apiContext() context.Context {
+	if o.state == nil || o.state.ctx == nil {
+		return context.TODO()
+	}
+
+	return o.state.ctx
+}
+
+// lookupStats returns the statistics of the lookups of the ResolveTemplate call to update. Statistics that are
+// discarded are returned if the options are not from a ResolveTemplate call.
+func (o *ResolveOptions) lookupStats() *LookupStats {
+	if o.state == nil {
+		return &LookupStats{}
+	}
+
+	return &o.state.lookupStats
+}
+
+// countLookup counts a Kubernetes API query of a template function against MaxLookups. An error wrapping
+// ErrLookupQuotaExceeded is returned if the query would exceed MaxLookups, in which case it must not be made.
+func (o *ResolveOptions) countLookup() error {
+	if o.MaxLookups == 0 || o.state == nil {
+		return nil
+	}
+
+	if o.state.lookupCount >= o.MaxLookups {
+		return fmt.Errorf("%w: options.MaxLookups is %d", ErrLookupQuotaExceeded, o.MaxLookups)
+	}
+
+	o.state.lookupCount++
+
+	return nil
+}
+
+// resolveIDHelper returns the resolveID template function, which returns the same unique ID for every call within a
+// ResolveTemplate call. This is empty if the options are not from a ResolveTemplate call.
+func (t *TemplateResolver) resolveIDHelper(options *ResolveOptions) func() string {
+	return func() string {
+		if options.state == nil {
+			return ""
+		}
+
+		return options.state.resolveID
+	}
 }
 
 // TemplateResolver is the API for processing templates. It's better to use the NewResolver function
@@ -166,6 +618,7 @@ type TemplateResolver struct {
 	config Config
 	// Used when caching is disabled.
 	dynamicClient *dynamic.DynamicClient
+	kubeClient    kubernetes.Interface
 	kubeConfig    *rest.Config
 	// Used when instantiated with NewResolverWithCaching. This will create watches and the cache will get
 	// automatically updated.
@@ -173,13 +626,108 @@ type TemplateResolver struct {
 	// If caching is disabled, this will act as a temporary cache for objects during the execution of the
 	// ResolveTemplate call.
 	tempCallCache client.ObjectCache
+	// The cache of the lookups when caching is disabled. This is Config.LookupCache if set and tempCallCache otherwise.
+	lookupCache LookupCache
+	// The cache of the GVK to GVR conversions across ResolveTemplate calls in both caching modes.
+	discoveryCache *discoveryCache
+	// Set when Config.MetricsRegisterer is set.
+	metrics *resolverMetrics
+	// Set when Config.Vault is set.
+	vault *vaultClient
+	// log is Config.Logger or defaultLogger.
+	log logr.Logger
+	// Set when Config.TracerProvider is set.
+	tracer trace.Tracer
+	// Set when Config.ResultCacheMaxEntries is set.
+	resultCache *lruCache[resultCacheEntry]
+	// Set when Config.TemplateCacheMaxEntries is set.
+	templateCache *lruCache[*template.Template]
+	// The clients of the identities impersonated with ResolveOptions.Impersonate.
+	impersonatedClients *impersonatedClients
 }
 
 type CacheCleanUpFunc func() error
 
+// TemplateResult is the result of a ResolveTemplate call.
+//
+// - ResolvedJSON is the resolved template as JSON.
+//
+// - CacheCleanUp is set when ResolveOptions.DisableAutoCacheCleanUp is set in caching mode. See ResolveOptions.
+//
+// - HasSensitiveData is true when the template looked up a Secret, which means the resolved template may contain
+// sensitive data, or when a resolved object contains a value derived from sensitive data.
+//
+// - SensitiveObjects reports whether each resolved object contains a value derived from sensitive data, such as the
+// data of a Secret, a Vault secret, a generated value, or a decrypted value, so that a controller can suppress the
+// diffs and logging of only those objects. Values stay derived from sensitive data when passed through template
+// functions, such as `{{ fromSecret "ns" "name" "key" | base64dec }}`. There is one entry if the resolved template is
+// a single object or one entry per object if it's a list. Values encrypted with the "protect" template function are
+// not sensitive.
+//
+// - DecryptedWithPreviousKey is true when an encrypted value in the template was decrypted with a previous key, such as
+// EncryptionConfig.AESKeyFallback, rather than the current key. The controller should encrypt the template values again
+// with the current key.
+//
+// - ResolveID is the unique ID of the ResolveTemplate call, which is the value returned by the resolveID template
+// function.
+//
+// - ReferencedObjects are the unique object and list queries of the lookups in the template in the order they were
+// first made, including queries of objects that were not found. A list query has an empty Name and its Selector is the
+// label selector, which is empty if all objects are listed.
+//
+// - ValidationErrors are the errors of the resolved objects that failed the server-side dry-run validation when
+// ResolveOptions.ValidateAgainstCluster is set. Each error wraps ErrDryRunFailed or ErrInvalidInput.
+//
+// - Warnings are the issues of the resolved template that don't fail the resolution, such as the errors wrapping
+// ErrSecretDataInNonSecret when ResolveOptions.SecretDataCheck is SecretDataCheckWarn.
+//
+// - LookupStats are the statistics of the lookups of the template, which show how expensive the template is to resolve.
+// These are also set when the resolution fails.
+//
+// - MissingObjects are the queries in ReferencedObjects that returned no objects, which is an object that was not found
+// or a list query with no matching objects, such as to check that all the objects a template depends on exist. These
+// are also set when the resolution fails.
 type TemplateResult struct {
-	ResolvedJSON []byte
-	CacheCleanUp CacheCleanUpFunc
+	ResolvedJSON             []byte
+	CacheCleanUp             CacheCleanUpFunc
+	HasSensitiveData         bool
+	SensitiveObjects         []bool
+	DecryptedWithPreviousKey bool
+	ResolveID                string
+	ReferencedObjects        []client.ObjectIdentifier
+	ValidationErrors         []error
+	Warnings                 []error
+	LookupStats              LookupStats
+	MissingObjects           []client.ObjectIdentifier
+}
+
+// LookupStats are the statistics of the object and list queries of the template functions (e.g. lookup and
+// fromConfigMap) in a ResolveTemplate call.
+//
+// - Lookups is the number of queries, including those of objects that were not found.
+//
+// - CacheHits is the number of queries served from a cache. When caching is disabled, this is the temporary call cache
+// or Config.LookupCache. When caching is enabled, all queries are served from the watch cache and are cache hits,
+// although the first query of an object or list starts a watch.
+//
+// - APICalls is the number of queries made to the Kubernetes API.
+//
+// - BytesFetched is the approximate size in bytes of the objects returned by the queries made to the Kubernetes API,
+// which is the size of their JSON encoding.
+type LookupStats struct {
+	Lookups      uint
+	CacheHits    uint
+	APICalls     uint
+	BytesFetched uint64
+}
+
+// addBytesFetched adds the size of the JSON encoding of the input object or list from the Kubernetes API to
+// BytesFetched.
+func (l *LookupStats) addBytesFetched(content map[string]interface{}) {
+	contentJSON, err := json.Marshal(content)
+	if err == nil {
+		l.BytesFetched += uint64(len(contentJSON))
+	}
 }
 
 // NewResolver creates a new TemplateResolver instance, which is the API for processing templates.
@@ -198,7 +746,14 @@ func NewResolver(kubeConfig *rest.Config, config Config) (*TemplateResolver, err
 		config.StopDelim = defaultStopDelim
 	}
 
-	klog.V(2).Infof("Using the delimiters of %s and %s", config.StartDelim, config.StopDelim)
+	log := config.Logger
+	if log.GetSink() == nil {
+		log = defaultLogger
+	}
+
+	log.V(2).Info("Using the delimiters", "startDelim", config.StartDelim, "stopDelim", config.StopDelim)
+
+	kubeConfig = overrideConfig(kubeConfig, config)
 
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeConfig)
 	if err != nil {
@@ -216,11 +771,126 @@ func NewResolver(kubeConfig *rest.Config, config Config) (*TemplateResolver, err
 		return nil, err
 	}
 
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	now := config.Clock
+	if now == nil {
+		now = time.Now
+	}
+
+	var lookupCache LookupCache = objectLookupCache{cache: tempCallCache}
+
+	if config.LookupCacheTTL != 0 || config.LookupCacheMaxEntries != 0 {
+		if config.LookupCache != nil {
+			return nil, fmt.Errorf(
+				"%w: the configurations LookupCacheTTL and LookupCacheMaxEntries cannot be set with LookupCache",
+				ErrInvalidInput,
+			)
+		}
+
+		if config.LookupCacheTTL < 0 || config.NotFoundCacheTTL < 0 {
+			return nil, fmt.Errorf(
+				"%w: the configurations LookupCacheTTL and NotFoundCacheTTL cannot be negative", ErrInvalidInput,
+			)
+		}
+
+		lookupCache = newExpiringLookupCache(
+			config.LookupCacheTTL, config.NotFoundCacheTTL, config.LookupCacheMaxEntries, now, log,
+		)
+	} else if config.LookupCache != nil {
+		lookupCache = config.LookupCache
+	}
+
+	var metrics *resolverMetrics
+
+	if config.MetricsRegisterer != nil {
+		metrics, err = newResolverMetrics(config.MetricsRegisterer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register the metrics: %w", err)
+		}
+	}
+
+	var tracer trace.Tracer
+
+	if config.TracerProvider != nil {
+		tracer = config.TracerProvider.Tracer(tracerName)
+	}
+
+	var resultCache *lruCache[resultCacheEntry]
+
+	if config.ResultCacheMaxEntries > 0 {
+		resultCache = newLRUCache[resultCacheEntry](config.ResultCacheMaxEntries)
+	}
+
+	var templateCache *lruCache[*template.Template]
+
+	if config.TemplateCacheMaxEntries > 0 {
+		templateCache = newLRUCache[*template.Template](config.TemplateCacheMaxEntries)
+	}
+
+	var vault *vaultClient
+
+	if config.Vault != nil {
+		vault, err = newVaultClient(*config.Vault, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &TemplateResolver{
-		config: config, dynamicClient: dynamicClient, kubeConfig: kubeConfig, tempCallCache: tempCallCache,
+		config:         config,
+		dynamicClient:  dynamicClient,
+		kubeClient:     kubeClient,
+		kubeConfig:     kubeConfig,
+		tempCallCache:  tempCallCache,
+		lookupCache:    lookupCache,
+		discoveryCache: newDiscoveryCache(discoveryClient, config.DiscoveryCacheTTL, now, log),
+		metrics:        metrics,
+		vault:          vault,
+		log:            log,
+		tracer:         tracer,
+		resultCache:    resultCache,
+		templateCache:  templateCache,
+		impersonatedClients: &impersonatedClients{
+			clients: map[string]*impersonatedClient{},
+		},
 	}, nil
 }
 
+// overrideConfig returns a copy of the input rest.Config with the client-side throttling and impersonation overrides of
+// the input Config applied. The input rest.Config is returned as is if there are no overrides.
+func overrideConfig(kubeConfig *rest.Config, config Config) *rest.Config {
+	impersonate := config.Impersonate.UserName != "" || config.Impersonate.UID != "" ||
+		len(config.Impersonate.Groups) != 0 || len(config.Impersonate.Extra) != 0
+
+	if config.QPS == 0 && config.Burst == 0 && config.RateLimiter == nil && !impersonate {
+		return kubeConfig
+	}
+
+	kubeConfig = rest.CopyConfig(kubeConfig)
+
+	if config.QPS != 0 {
+		kubeConfig.QPS = config.QPS
+	}
+
+	if config.Burst != 0 {
+		kubeConfig.Burst = config.Burst
+	}
+
+	if config.RateLimiter != nil {
+		kubeConfig.RateLimiter = config.RateLimiter
+	}
+
+	if impersonate {
+		kubeConfig.Impersonate = config.Impersonate
+	}
+
+	return kubeConfig
+}
+
 // NewResolverWithCaching creates a new caching TemplateResolver instance, which is the API for processing templates.
 //
 // The caching works by adding watches to the objects and list queries used in the templates. A controller-runtime
@@ -245,7 +915,7 @@ func NewResolverWithCaching(
 
 	reconciler, channel := client.NewControllerRuntimeSource()
 	dynamicWatcher, err := client.New(
-		kubeConfig,
+		resolver.kubeConfig,
 		reconciler,
 		&client.Options{
 			DisableInitialReconcile: true,
@@ -265,6 +935,7 @@ func NewResolverWithCaching(
 	resolver.dynamicWatcher = dynamicWatcher
 	resolver.dynamicClient = nil
 	resolver.tempCallCache = nil
+	resolver.lookupCache = nil
 
 	return resolver, channel, err
 }
@@ -278,8 +949,7 @@ func HasTemplate(template []byte, startDelim string, checkForEncrypted bool) boo
 	}
 
 	templateStr := string(template)
-	klog.V(2).Infof("HasTemplate template str:  %v", templateStr)
-	klog.V(2).Infof("Checking for the start delimiter:  %s", startDelim)
+	defaultLogger.V(2).Info("HasTemplate", "template", templateStr, "startDelim", startDelim)
 
 	hasTemplate := false
 	if strings.Contains(templateStr, startDelim) {
@@ -288,7 +958,7 @@ func HasTemplate(template []byte, startDelim string, checkForEncrypted bool) boo
 		hasTemplate = true
 	}
 
-	klog.V(2).Infof("hasTemplate: %v", hasTemplate)
+	defaultLogger.V(2).Info("HasTemplate result", "hasTemplate", hasTemplate)
 
 	return hasTemplate
 }
@@ -305,19 +975,22 @@ func UsesEncryption(template []byte, startDelim string, stopDelim string) bool {
 	}
 
 	templateStr := string(template)
-	klog.V(2).Infof("usesEncryption template str:  %v", templateStr)
-	klog.V(2).Infof("Checking for encryption functions")
+	defaultLogger.V(2).Info("UsesEncryption", "template", templateStr)
 
 	// Check for encryption template functions:
 	// {{ fromSecret ... }}
+	// {{ fromSecretEncrypted ... }}
+	// {{ fromSecretKeyOrDefault ... }}
 	// {{ copySecretData ... }}
 	// {{ ... | protect }}
 	d1 := regexp.QuoteMeta(startDelim)
 	d2 := regexp.QuoteMeta(stopDelim)
-	re := regexp.MustCompile(d1 + `(\s*fromSecret\s+.*|\s*copySecretData\s+.*|.*\|\s*protect\s*)` + d2)
+	re := regexp.MustCompile(
+		d1 + `(\s*fromSecret(?:Encrypted|KeyOrDefault)?\s+.*|\s*copySecretData\s+.*|.*\|\s*protect\s*)` + d2,
+	)
 	usesEncryption := re.MatchString(templateStr)
 
-	klog.V(2).Infof("usesEncryption: %v", usesEncryption)
+	defaultLogger.V(2).Info("UsesEncryption result", "usesEncryption", usesEncryption)
 
 	return usesEncryption
 }
@@ -348,8 +1021,12 @@ func getValidContext(context interface{}) (ctx interface{}, _ error) {
 		case reflect.String:
 			// good
 		case reflect.Map:
-			// check if it's map[string]string
-			if f.Type.Elem().Kind() != reflect.String || f.Type.Key().Kind() != reflect.String {
+			// check if it's map[string]string or map[string]interface{}, such as of values from a YAML file
+			if f.Type.Key().Kind() != reflect.String {
+				return nil, ErrInvalidContextType
+			}
+
+			if f.Type.Elem().Kind() != reflect.String && f.Type.Elem().Kind() != reflect.Interface {
 				return nil, ErrInvalidContextType
 			}
 		default:
@@ -360,17 +1037,28 @@ func getValidContext(context interface{}) (ctx interface{}, _ error) {
 	return context, nil
 }
 
+// now returns the current time from the configured clock.
+func (t *TemplateResolver) now() time.Time {
+	if t.config.Clock == nil {
+		return time.Now()
+	}
+
+	return t.config.Clock()
+}
+
 // SetInputIsYAML sets the resolver's inputIsYAML configuration value.
 func (t *TemplateResolver) SetInputIsYAML(inputIsYAML bool) {
-	klog.V(2).Infof("Setting InputIsYAML to %t", inputIsYAML)
+	t.log.V(2).Info("Setting InputIsYAML", "inputIsYAML", inputIsYAML)
 
 	t.config.InputIsYAML = inputIsYAML
 }
 
 // validateEncryptionConfig validates an EncryptionConfig struct to ensure that if encryption
 // and/or decryption are enabled that the AES Key and Initialization Vector are valid.
-func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
-	if encryptionConfig.EncryptionEnabled || encryptionConfig.DecryptionEnabled {
+func validateEncryptionConfig(encryptionConfig EncryptionConfig, log logr.Logger) error {
+	if encryptionConfig.Provider != nil && (encryptionConfig.EncryptionEnabled || encryptionConfig.DecryptionEnabled) {
+		log.V(2).Info("Template encryption uses the provider", "keyID", encryptionConfig.Provider.KeyID())
+	} else if encryptionConfig.EncryptionEnabled || encryptionConfig.DecryptionEnabled {
 		// Ensure AES Key is set
 		if encryptionConfig.AESKey == nil {
 			return ErrAESKeyNotSet
@@ -389,6 +1077,13 @@ func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
 			}
 		}
 
+		for i, previousKey := range encryptionConfig.PreviousAESKeys {
+			_, err = aes.NewCipher(previousKey)
+			if err != nil {
+				return fmt.Errorf("%w: the previous AES key at index %d: %w", ErrInvalidAESKey, i, err)
+			}
+		}
+
 		// Ensure Initialization Vector is set
 		if encryptionConfig.InitializationVector == nil {
 			return ErrIVNotSet
@@ -399,15 +1094,21 @@ func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
 			return ErrInvalidIV
 		}
 
+		switch encryptionConfig.Algorithm {
+		case "", EncryptionAlgorithmAESCBC, EncryptionAlgorithmAESGCM:
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidAlgorithm, encryptionConfig.Algorithm)
+		}
+
 		if encryptionConfig.EncryptionEnabled {
-			klog.V(2).Info("Template encryption is enabled")
+			log.V(2).Info("Template encryption is enabled")
 		}
 
 		if encryptionConfig.DecryptionEnabled {
-			klog.V(2).Info("Template decryption is enabled")
+			log.V(2).Info("Template decryption is enabled")
 		}
 	} else {
-		klog.V(2).Info("Template encryption and decryption is disabled")
+		log.V(2).Info("Template encryption and decryption is disabled")
 	}
 
 	return nil
@@ -416,8 +1117,10 @@ func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
 // ResolveTemplate accepts a map marshaled as JSON or YAML. It also accepts a struct
 // with string fields that will be made available when the template is processed.
 // For example, if the argument is `struct{ClusterName string}{"cluster1"}`,
-// the value `cluster1` would be available with `{{ .ClusterName }}`. This can
-// also be `nil` if no fields should be made available.
+// the value `cluster1` would be available with `{{ .ClusterName }}`. The fields
+// can also be `map[string]string` or `map[string]interface{}`, such as of nested
+// values with `{{ .Values.app.name }}`. This can also be `nil` if no fields
+// should be made available.
 //
 // ResolveTemplate will process any template strings in the map and return the processed map. The
 // ErrMissingAPIResource is returned when one or more "lookup" calls referenced an API resource
@@ -426,23 +1129,88 @@ func validateEncryptionConfig(encryptionConfig EncryptionConfig) error {
 // The input options contains options for template resolution. The options.Watcher field is an ObjectIdentifier that is
 // used in caching mode and the controller-runtime integration. Set this to nil when not in caching mode. When in
 // caching mode, watches are automatically garbage collected when a new call to ResolveTemplate no longer specifies an
-// object or list query it used to.
+// object or list query it used to. The looked up objects are consistent within a ResolveTemplate call in caching mode:
+// an object is always returned at the first version seen in the call, even if the watch cache is updated meanwhile.
 //
 // This method is only concurrency safe when caching is enabled. When caching is disabled, a local cache of objects
 // is stored just for the ResolveTemplate execution to avoid duplicate API queries. If running this method concurrently
 // with caching disabled, you may get some items from the temporary cache while others will be from API queries.
+//
+// This is the same as calling ResolveTemplateWithContext with context.Background().
 func (t *TemplateResolver) ResolveTemplate(
-	tmplRaw []byte, context interface{}, options *ResolveOptions,
+	tmplRaw []byte, tmplContext interface{}, options *ResolveOptions,
 ) (TemplateResult, error) {
-	klog.V(2).Infof("ResolveTemplate for: %v", string(tmplRaw))
+	return t.ResolveTemplateWithContext(context.Background(), tmplRaw, tmplContext, options)
+}
+
+// ResolveTemplateWithContext is the same as ResolveTemplate except that the input resolveCtx is used for the
+// Kubernetes API queries of the template functions (e.g. lookup and fromConfigMap). This allows the caller to cancel
+// the template resolution or set a deadline on it. Note that the queries served by the cache in caching mode are not
+// affected by resolveCtx.
+func (t *TemplateResolver) ResolveTemplateWithContext(
+	resolveCtx context.Context, tmplRaw []byte, tmplContext interface{}, options *ResolveOptions,
+) (TemplateResult, error) {
+	start := time.Now()
 
 	if options == nil {
 		options = &ResolveOptions{}
 	}
 
-	var resolvedResult TemplateResult
+	// Copy the options so that the internal state of this call is not shared with the caller or other calls
+	optionsCopy := *options
+	options = &optionsCopy
+
+	var deadline time.Time
+
+	if options.ExecutionTimeout > 0 {
+		deadline = start.Add(options.ExecutionTimeout)
+
+		var cancel context.CancelFunc
+
+		resolveCtx, cancel = context.WithDeadline(resolveCtx, deadline)
+		defer cancel()
+	}
+
+	resolveID := uuid.NewString()
+	resolveCtx, span := t.startSpan(resolveCtx, "ResolveTemplate", attrResolveID.String(resolveID))
+	options.state = &resolveState{
+		resolveID: resolveID, ctx: resolveCtx, log: t.contextLogger(resolveCtx), deadline: deadline,
+	}
+
+	result, err := t.resolveTemplate(resolveCtx, tmplRaw, tmplContext, options)
+	result.LookupStats = options.state.lookupStats
+	result.MissingObjects = options.state.missingObjects
+
+	if err != nil {
+		err = options.state.redactError(options, err)
+	}
+
+	if err == nil && options.ValidateAgainstCluster {
+		result.ValidationErrors = t.validateResolvedObjects(resolveCtx, options, result.ResolvedJSON)
+	}
+
+	t.metrics.observeResolution(start, err)
+	endSpan(span, err)
+
+	return result, err
+}
+
+func (t *TemplateResolver) resolveTemplate(
+	resolveCtx context.Context, tmplRaw []byte, context interface{}, options *ResolveOptions,
+) (TemplateResult, error) {
+	t.logger(options).V(2).Info("ResolveTemplate", "template", string(tmplRaw))
+
+	if options.TempCallCacheMaxEntries > 0 {
+		options.state.tempCallCacheLRU = newCacheLRU(int(options.TempCallCacheMaxEntries))
+	}
+
+	resolvedResult := TemplateResult{ResolveID: options.state.resolveID}
+
+	if err := resolveCtx.Err(); err != nil {
+		return resolvedResult, err
+	}
 
-	err := validateEncryptionConfig(options.EncryptionConfig)
+	err := validateEncryptionConfig(options.EncryptionConfig, t.logger(options))
 	if err != nil {
 		return resolvedResult, fmt.Errorf("error validating EncryptionConfig: %w", err)
 	}
@@ -454,6 +1222,20 @@ func (t *TemplateResolver) ResolveTemplate(
 				ErrInvalidInput,
 			)
 		}
+
+		if options.Impersonate != nil {
+			return resolvedResult, fmt.Errorf(
+				"%w: options.Impersonate cannot be set if caching is enabled",
+				ErrInvalidInput,
+			)
+		}
+
+		if options.ValidateAgainstCluster {
+			return resolvedResult, fmt.Errorf(
+				"%w: options.ValidateAgainstCluster cannot be set if caching is enabled",
+				ErrInvalidInput,
+			)
+		}
 	} else if len(options.ContextTransformers) != 0 {
 		return resolvedResult, fmt.Errorf(
 			"%w: options.ContextTransformers cannot be set if caching is disabled",
@@ -466,44 +1248,36 @@ func (t *TemplateResolver) ResolveTemplate(
 		return resolvedResult, err
 	}
 
-	// Build Map of supported template functions
-	funcMap := template.FuncMap{
-		"copyConfigMapData": t.copyConfigMapDataHelper(options),
-		"copySecretData":    t.copySecretDataHelper(options),
-		"fromSecret":        t.fromSecretHelper(options),
-		"fromConfigMap":     t.fromConfigMapHelper(options),
-		"fromClusterClaim":  t.fromClusterClaimHelper(options),
-		"lookup":            t.lookupHelper(options),
-		"base64enc":         base64encode,
-		"base64dec":         base64decode,
-		"autoindent":        autoindent,
-		"indent":            t.indent,
-		"atoi":              atoi,
-		"toInt":             toInt,
-		"toBool":            toBool,
-		"toLiteral":         toLiteral,
+	if (options.StartDelim == "") != (options.StopDelim == "") {
+		return resolvedResult, fmt.Errorf(
+			"%w: options.StartDelim and options.StopDelim cannot be set independently", ErrInvalidInput,
+		)
 	}
 
-	// Add all the functions from sprig we will support
-	for _, fname := range exportedSprigFunctions {
-		funcMap[fname] = getSprigFunc(fname)
+	switch options.MissingKey {
+	case "", "default", "invalid", "zero", "error":
+	default:
+		return resolvedResult, fmt.Errorf(
+			"%w: options.MissingKey must be default, invalid, zero, or error but got %s", ErrInvalidInput,
+			options.MissingKey,
+		)
 	}
 
-	if options.EncryptionEnabled {
-		funcMap["fromSecret"] = t.fromSecretProtectedHelper(options)
-		funcMap["protect"] = t.protectHelper(options)
-		funcMap["copySecretData"] = t.copySecretDataProtectedHelper(options)
-	} else {
-		// In other encryption modes, return a readable error if the protect template function is accidentally used.
-		funcMap["protect"] = func(s string) (string, error) { return "", ErrProtectNotEnabled }
+	switch options.SecretDataCheck {
+	case "", SecretDataCheckWarn, SecretDataCheckFail:
+	default:
+		return resolvedResult, fmt.Errorf(
+			"%w: options.SecretDataCheck must be %s or %s but got %s", ErrInvalidInput, SecretDataCheckWarn,
+			SecretDataCheckFail, options.SecretDataCheck,
+		)
 	}
 
-	for _, funcName := range t.config.DisabledFunctions {
-		delete(funcMap, funcName)
+	funcMap, err := t.buildFuncMap(options)
+	if err != nil {
+		return resolvedResult, err
 	}
 
-	// create template processor and Initialize function map
-	tmpl := template.New("tmpl").Delims(t.config.StartDelim, t.config.StopDelim).Funcs(funcMap)
+	var tmpl *template.Template
 
 	// convert the JSON to YAML if necessary
 	var templateStr string
@@ -519,7 +1293,7 @@ func (t *TemplateResolver) ResolveTemplate(
 		templateStr = string(tmplRaw)
 	}
 
-	klog.V(2).Infof("Initial template str to resolve : %v ", templateStr)
+	t.logger(options).V(2).Info("Initial template to resolve", "template", templateStr)
 
 	if options.DecryptionEnabled {
 		templateStr, err = t.processEncryptedStrs(options, templateStr)
@@ -528,29 +1302,40 @@ func (t *TemplateResolver) ResolveTemplate(
 		}
 	}
 
-	// processForDataTypes handles scenarios where quotes need to be removed for
-	// special data types or cases where multiple values are returned
-	templateStr = t.processForDataTypes(templateStr)
-
-	// convert `autoindent` placeholders to `indent N`
-	if strings.Contains(templateStr, "autoindent") {
-		templateStr = t.processForAutoIndent(templateStr)
+	if err := t.validateVerbatimBlocks(options, templateStr); err != nil {
+		return resolvedResult, err
 	}
 
-	tmpl, err = tmpl.Parse(templateStr)
-	if err != nil {
-		tmplRawStr := string(tmplRaw)
-		klog.Errorf(
-			"error parsing template string %v,\n template str %v,\n error: %v", tmplRawStr, templateStr, err,
-		)
+	// Keep the template before the data type processing so that its fields can be resolved individually
+	unprocessedTemplateStr := templateStr
+
+	var verbatimBlocks []string
+
+	// In dependency order mode, each field is processed and parsed individually after the context is finalized.
+	if !options.ResolveInDependencyOrder {
+		templateStr, verbatimBlocks = t.processTemplate(options, templateStr)
+
+		tmpl, err = t.parseTemplate("tmpl", templateStr, funcMap, options)
+		if err != nil {
+			tmplRawStr := string(tmplRaw)
+			t.logger(options).Error(
+				errors.New(options.state.redact(options, err.Error())), "error parsing the template",
+				"rawTemplate", tmplRawStr, "template", options.state.redact(options, templateStr),
+			)
 
-		return resolvedResult, fmt.Errorf("failed to parse the template JSON string %v: %w", tmplRawStr, err)
+			err = newTemplateError(templateStr, deniedFunctionError(err, options.DeniedFunctions))
+
+			return resolvedResult, fmt.Errorf(
+				"failed to parse the template JSON string %v: %w", tmplRawStr, classifyError(ErrParseFailed, err),
+			)
+		}
 	}
 
 	var buf bytes.Buffer
 
-	// If the dynamic watcher caching style is disabled, clear the cache after resolving the template.
-	if t.tempCallCache != nil {
+	// If the dynamic watcher caching style is disabled, clear the cache after resolving the template. ResolveTemplates
+	// clears it after resolving the whole batch instead.
+	if t.tempCallCache != nil && !options.inBatch {
 		defer t.tempCallCache.Clear()
 	}
 
@@ -560,7 +1345,7 @@ func (t *TemplateResolver) ResolveTemplate(
 		err := t.dynamicWatcher.StartQueryBatch(watcher)
 		if err != nil {
 			if !errors.Is(err, client.ErrQueryBatchInProgress) {
-				return resolvedResult, err
+				return resolvedResult, classifyError(ErrWatcherFailed, err)
 			}
 
 			if !options.DisableAutoCacheCleanUp {
@@ -578,7 +1363,7 @@ func (t *TemplateResolver) ResolveTemplate(
 			defer func() {
 				err := t.dynamicWatcher.EndQueryBatch(watcher)
 				if err != nil && !errors.Is(err, client.ErrQueryBatchNotStarted) {
-					klog.Errorf("failed to end the query batch for %s: %v", watcher, err)
+					t.logger(options).Error(err, "failed to end the query batch", "watcher", watcher)
 				}
 			}()
 		}
@@ -597,27 +1382,321 @@ func (t *TemplateResolver) ResolveTemplate(
 		}
 	}
 
-	err = tmpl.Execute(&buf, ctx)
+	resultCacheKey, cacheable := t.resultCacheKey(tmplRaw, context, options)
+	if cacheable {
+		lookupCount := options.state.lookupCount
+		lookupStats := options.state.lookupStats
+		referencedObjects := options.state.referencedObjects
+		missingObjects := options.state.missingObjects
+
+		if cachedResult, ok := t.cachedResult(options, resultCacheKey); ok {
+			cachedResult.ResolveID = resolvedResult.ResolveID
+			cachedResult.CacheCleanUp = resolvedResult.CacheCleanUp
+			cachedResult.ReferencedObjects = options.state.referencedObjects
+
+			return cachedResult, nil
+		}
+
+		// Discard the state of the repeated lookups of the stale cached result
+		options.state.lookupCount = lookupCount
+		options.state.lookupStats = lookupStats
+		options.state.referencedObjects = referencedObjects
+		options.state.missingObjects = missingObjects
+		options.state.lookups = nil
+		options.state.uncacheable = false
+		options.state.recordLookups = true
+	}
+
+	if options.ResolveInDependencyOrder {
+		resolvedJSON, err := t.resolveInDependencyOrder(templateStr, funcMap, ctx, options)
+		if err != nil {
+			return resolvedResult, err
+		}
+
+		resolvedResult.ResolvedJSON = t.stripVerbatimMarkers(options, resolvedJSON)
+
+		resolvedResult.DecryptedWithPreviousKey = options.state.decryptedWithPreviousKey
+		resolvedResult.ReferencedObjects = options.state.referencedObjects
+
+		return resolvedResult, t.completeResult(options, resultCacheKey, &resolvedResult)
+	}
+
+	err = tmpl.Execute(options.limitWriter(&buf), ctx)
 
 	if err != nil {
 		tmplRawStr := string(tmplRaw)
-		klog.Errorf("error resolving the template %v,\n template str %v,\n error: %v", tmplRawStr, templateStr, err)
+		t.logger(options).Error(
+			errors.New(options.state.redact(options, err.Error())), "error resolving the template",
+			"rawTemplate", tmplRawStr, "template", options.state.redact(options, templateStr),
+		)
+
+		if options.AggregateErrors {
+			err = t.aggregateFieldErrors(
+				unprocessedTemplateStr, funcMap, ctx, options, newTemplateError(templateStr, err),
+			)
+		} else {
+			err = newTemplateError(templateStr, err)
+		}
 
 		return resolvedResult, fmt.Errorf("failed to resolve the template %v: %w", tmplRawStr, err)
 	}
 
-	resolvedTemplateStr := buf.String()
-	klog.V(3).Infof("resolved template str: %v ", resolvedTemplateStr)
-	// unmarshall before returning
+	resolvedYAML := restoreVerbatimBlocks(buf.Bytes(), verbatimBlocks)
+
+	resolvedTemplateStr := string(resolvedYAML)
+	t.logger(options).V(3).Info("Resolved the template", "template", options.state.redact(options, resolvedTemplateStr))
 
-	resolvedTemplateBytes, err := yamlToJSON(buf.Bytes())
+	if options.MaxPasses > 1 {
+		resolvedYAML, err = t.resolveNestedTemplates(resolvedYAML, funcMap, ctx, options)
+		if err != nil {
+			return resolvedResult, fmt.Errorf("failed to resolve the nested templates of %v: %w", string(tmplRaw), err)
+		}
+	}
+
+	// unmarshall before returning
+	resolvedTemplateBytes, err := yamlToJSON(resolvedYAML)
 	if err != nil {
 		return resolvedResult, fmt.Errorf("failed to convert the resolved template to JSON: %w", err)
 	}
 
-	resolvedResult.ResolvedJSON = resolvedTemplateBytes
+	resolvedResult.ResolvedJSON = t.stripVerbatimMarkers(options, resolvedTemplateBytes)
+	resolvedResult.DecryptedWithPreviousKey = options.state.decryptedWithPreviousKey
+	resolvedResult.ReferencedObjects = options.state.referencedObjects
 
-	return resolvedResult, nil
+	return resolvedResult, t.completeResult(options, resultCacheKey, &resolvedResult)
+}
+
+// completeResult sets the sensitive data of the resolved result (see setSensitiveData) and then stores it in the result
+// cache if the lookups were recorded for it.
+func (t *TemplateResolver) completeResult(
+	options *ResolveOptions, resultCacheKey string, result *TemplateResult,
+) error {
+	if err := options.state.setSensitiveData(options, result); err != nil {
+		return err
+	}
+
+	if options.state.recordLookups {
+		t.cacheResult(options, resultCacheKey, *result)
+	}
+
+	return nil
+}
+
+// delims returns the delimiters of the ResolveTemplate call, which are ResolveOptions.StartDelim and
+// ResolveOptions.StopDelim if set and otherwise Config.StartDelim and Config.StopDelim.
+func (t *TemplateResolver) delims(options *ResolveOptions) (startDelim string, stopDelim string) {
+	if options != nil && options.StartDelim != "" {
+		return options.StartDelim, options.StopDelim
+	}
+
+	return t.config.StartDelim, t.config.StopDelim
+}
+
+// newTemplate returns a new template with the delimiters of the ResolveTemplate call, the input template functions,
+// and the template options set in the input options.
+func (t *TemplateResolver) newTemplate(
+	name string, funcMap template.FuncMap, options *ResolveOptions,
+) *template.Template {
+	tmpl := template.New(name).Delims(t.delims(options)).Funcs(funcMap)
+
+	if options.MissingKey != "" {
+		tmpl = tmpl.Option("missingkey=" + options.MissingKey)
+	}
+
+	return tmpl
+}
+
+// parseTemplate returns the template parsed from the input text with newTemplate. When Config.TemplateCacheMaxEntries
+// is set, the parsed templates are cached by a hash of the name, the text, the delimiters, the missingkey option, and
+// the names of the template functions, which are what parsing depends on. A cached template is cloned with the input
+// template functions since the functions are bound to the options of a ResolveTemplate call.
+func (t *TemplateResolver) parseTemplate(
+	name string, text string, funcMap template.FuncMap, options *ResolveOptions,
+) (*template.Template, error) {
+	if t.templateCache == nil {
+		tmpl, err := t.newTemplate(name, funcMap, options).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+
+		return options.limitRanges(tmpl), nil
+	}
+
+	funcNames := make([]string, 0, len(funcMap))
+	for funcName := range funcMap {
+		funcNames = append(funcNames, funcName)
+	}
+
+	sort.Strings(funcNames)
+
+	hash := sha256.New()
+
+	startDelim, stopDelim := t.delims(options)
+
+	for _, part := range []string{
+		name, text, startDelim, stopDelim, options.MissingKey, strings.Join(funcNames, ","),
+	} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	if cached, ok := t.templateCache.get(key); ok {
+		t.metrics.observeTemplateCache(true)
+
+		tmpl, err := cached.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		return options.limitRanges(tmpl.Funcs(funcMap)), nil
+	}
+
+	t.metrics.observeTemplateCache(false)
+
+	tmpl, err := t.newTemplate(name, funcMap, options).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache a clone so that the cached template is never executed or modified by the caller
+	cached, err := tmpl.Clone()
+	if err == nil {
+		t.templateCache.set(key, cached)
+	}
+
+	return options.limitRanges(tmpl), nil
+}
+
+// buildFuncMap returns the template functions available to the templates with the input options.
+func (t *TemplateResolver) buildFuncMap(options *ResolveOptions) (template.FuncMap, error) {
+	funcMap := template.FuncMap{
+		"copyConfigMapData":         t.copyConfigMapDataHelper(options),
+		"copySecretData":            t.copySecretDataHelper(options),
+		"fromSecret":                t.fromSecretHelper(options),
+		"fromSecretEncrypted":       t.fromSecretEncryptedHelper(options),
+		"fromSecretBinary":          t.fromSecretBinaryHelper(options),
+		"fromSecretKeyOrDefault":    t.fromSecretKeyOrDefaultHelper(options),
+		"fromConfigMap":             t.fromConfigMapHelper(options),
+		"fromConfigMaps":            t.fromConfigMapsHelper(options),
+		"clusterClaims":             t.clusterClaimsHelper(options),
+		"fromClusterClaim":          t.fromClusterClaimHelper(options),
+		"fromClusterClaimOrDefault": t.fromClusterClaimOrDefaultHelper(options),
+		"fromVault":                 t.fromVaultHelper(options),
+		"getNodes":                  t.getNodesHelper(options),
+		"lookup":                    t.lookupHelper(options),
+		"lookupAny":                 t.lookupAnyHelper(options),
+		"lookupAll":                 t.lookupAllHelper(options),
+		"getDefault":                t.getDefaultHelper(options),
+		"getObjectsByOwner":         t.getObjectsByOwnerHelper(options),
+		"existingNames":             t.existingNamesHelper(options),
+		"lookupSubresource":         t.lookupSubresourceHelper(options),
+		"resourceFor":               t.resourceFor,
+		"apiResourceExists":         t.apiResourceExists,
+		"kubeVersion":               t.kubeVersion,
+		"canLookup":                 t.canLookupHelper(options),
+		"base64enc":                 base64encode,
+		"base64dec":                 base64decode,
+		"autoindent":                autoindent,
+		"indent":                    t.indent,
+		"atoi":                      atoi,
+		"toInt":                     toInt,
+		"toBool":                    toBool,
+		"toLiteral":                 toLiteral,
+		"required":                  required,
+		"toLabelValue":              toLabelValue,
+		"sortedPairs":               sortedPairs,
+		"deepMerge":                 deepMerge,
+		"setPath":                   setPath,
+		"jsonpath":                  jsonPath,
+		"jq":                        t.jqHelper(options),
+		"cel":                       t.celHelper(options),
+		"resolveID":                 t.resolveIDHelper(options),
+		"genRandomString":           t.genRandomStringHelper(options),
+		"genUUID":                   t.genUUIDHelper(options),
+		"fromJsonStrict":            fromJSONStrict,
+		"fromJSON":                  t.fromJSONHelper(options),
+		"toJSON":                    toJSON,
+		"toPrettyJSON":              toPrettyJSON,
+		"toYamlIndented":            t.toYAMLIndented,
+		"hmacSha256":                hmacSha256,
+		"certDecode":                certDecode,
+		"certExpiryDays":            t.certExpiryDays,
+		"cidrhost":                  cidrhost,
+		"cidrsubnet":                cidrsubnet,
+		"cidrContains":              cidrContains,
+		"ipFamily":                  ipFamily,
+		"fromTOML":                  fromTOML,
+		"toTOML":                    toTOML,
+		"fromINI":                   fromINI,
+		"fromYaml":                  t.fromYAMLHelper(options),
+		"fromYamlStrict":            fromYAMLStrict,
+		// This is overridden when options.ResolveInDependencyOrder is set.
+		"fieldValue": func(string) (interface{}, error) { return nil, ErrFieldValueNotAvailable },
+	}
+
+	// Add all the functions from sprig we will support
+	for _, fname := range exportedSprigFunctions {
+		funcMap[fname] = getSprigFunc(fname)
+	}
+
+	err := addSprigFunctionGroups(funcMap, options.EnabledFunctionGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the configured clock rather than the sprig function which always uses time.Now
+	funcMap["now"] = t.now
+	// Use the dig function that accepts any map with string keys rather than the sprig function which panics
+	funcMap["dig"] = dig
+	// Use the semverCompare function that accepts the vendor suffixes of Kubernetes versions
+	funcMap["semverCompare"] = semverCompare
+
+	// The named templates have the same template functions as the calling template
+	funcMap["include"] = t.includeHelper(options, funcMap)
+
+	if options.StrictParsing {
+		funcMap["fromJson"] = fromJSONStrict
+		funcMap["mustFromJson"] = fromJSONStrict
+	}
+
+	if options.EncryptionEnabled {
+		funcMap["fromSecret"] = t.fromSecretProtectedHelper(options)
+		funcMap["fromSecretKeyOrDefault"] = t.fromSecretKeyOrDefaultProtectedHelper(options)
+		funcMap["protect"] = t.protectHelper(options)
+		funcMap["protectObject"] = t.protectObjectHelper(options)
+		funcMap["copySecretData"] = t.copySecretDataProtectedHelper(options)
+	} else {
+		// In other encryption modes, return a readable error if the protect template function is accidentally used.
+		funcMap["protect"] = func(s string) (string, error) { return "", ErrProtectNotEnabled }
+		funcMap["protectObject"] = func(v interface{}) (string, error) { return "", ErrProtectNotEnabled }
+	}
+
+	err = addCustomFunctions(funcMap, options.CustomFunctions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, funcName := range t.config.DisabledFunctions {
+		delete(funcMap, funcName)
+	}
+
+	removeDeniedFunctions(funcMap, options.DeniedFunctions)
+
+	if options.hasExecutionLimits() {
+		options.limitFuncMap(funcMap)
+	}
+
+	if t.resultCache != nil && options.state != nil {
+		options.state.markUncacheableFunctions(funcMap)
+	}
+
+	if options.state != nil {
+		options.state.taint.taintFuncMap(funcMap)
+	}
+
+	return funcMap, nil
 }
 
 // UncacheWatcher will clear the watcher from the cache and remove all associated API watches.
@@ -660,8 +1739,31 @@ func (t *TemplateResolver) GetWatchCount() uint {
 	return 0
 }
 
+// processTemplate processes the special template functions of the whole template before it's parsed. The verbatim
+// blocks are replaced with placeholders so that their content is left untouched, the quotes around the data type
+// functions are removed, and the autoindent and toYamlIndented placeholders are converted. The replaced verbatim
+// blocks are returned for restoreVerbatimBlocks.
+func (t *TemplateResolver) processTemplate(options *ResolveOptions, templateStr string) (string, []string) {
+	templateStr, verbatimBlocks := t.protectVerbatimBlocks(options, templateStr)
+
+	// processForDataTypes handles scenarios where quotes need to be removed for
+	// special data types or cases where multiple values are returned
+	templateStr = t.processForDataTypes(options, templateStr)
+
+	// convert `autoindent` placeholders to `indent N`
+	if strings.Contains(templateStr, "autoindent") {
+		templateStr = t.processForAutoIndent(options, templateStr)
+	}
+
+	if strings.Contains(templateStr, "toYamlIndented") {
+		templateStr = t.processForYAMLIndent(options, templateStr)
+	}
+
+	return templateStr, verbatimBlocks
+}
+
 //nolint:wsl
-func (t *TemplateResolver) processForDataTypes(str string) string {
+func (t *TemplateResolver) processForDataTypes(options *ResolveOptions, str string) string {
 	// The idea is to remove the quotes enclosing the template if it has toBool, toInt, or toLiteral.
 	// Quotes around the resolved template forces the value to be a string so removal of these quotes allows YAML to
 	// process the datatype correctly.
@@ -671,47 +1773,55 @@ func (t *TemplateResolver) processForDataTypes(str string) string {
 	// ex-1 key : '{{ "6" | toInt }}'  .. is replaced with  key : {{ "6" | toInt }}
 	// ex-2 key : |
 	//						'{{ "true" | toBool }}' .. is replaced with key : {{ "true" | toBool }}
+	// ex-3 - '{{ $obj | toLiteral }}'  .. is replaced with  - {{ $obj | toLiteral }}
 
 	// NOTES : on testing it was found that
 	// outer quotes around key-values are always single quotes
 	// even if the user input is with  double quotes , the yaml processed and saved with single quotes
 
-	d1 := regexp.QuoteMeta(t.config.StartDelim)
-	d2 := regexp.QuoteMeta(t.config.StopDelim)
+	startDelim, stopDelim := t.delims(options)
+	d1 := regexp.QuoteMeta(startDelim)
+	d2 := regexp.QuoteMeta(stopDelim)
 	//nolint: lll
 	expression := `:\s+(?:[\|>]-?\s+)?(?:'?\s*)(` + d1 + `(?:.*\|\s*(?:toInt|toBool|toLiteral)|(?:.*(?:copyConfigMapData|copySecretData))).*` + d2 + `)(?:\s*'?)`
 	re := regexp.MustCompile(expression)
-	klog.V(2).Infof("\n Pattern: %v\n", re.String())
+	// Only toLiteral is processed in list items, such as to splice a whole object in a list
+	listItemRe := regexp.MustCompile(`(?m)^([ \t]*-)\s+(?:'?\s*)(` + d1 + `.*\|\s*toLiteral.*` + d2 + `)(?:\s*'?)`)
+	t.logger(options).V(2).Info(
+		"Processing the data types", "pattern", re.String(), "listItemPattern", listItemRe.String(),
+	)
 
-	submatchall := re.FindAllStringSubmatch(str, -1)
+	submatchall := append(re.FindAllStringSubmatch(str, -1), listItemRe.FindAllStringSubmatch(str, -1)...)
 	if submatchall == nil {
 		return str
 	}
-	klog.V(2).Infof("\n All Submatches:\n%v", submatchall)
+	t.logger(options).V(2).Info("Found the data type submatches", "submatches", submatchall)
 
 	processeddata := re.ReplaceAllString(str, ": $1")
-	klog.V(2).Infof("\n processed data :\n%v", processeddata)
+	processeddata = listItemRe.ReplaceAllString(processeddata, "$1 $2")
+	t.logger(options).V(2).Info("Processed the data types", "processed", processeddata)
 
 	return processeddata
 }
 
 // processForAutoIndent converts any `autoindent` placeholders into `indent N` in the string.
 // The processed input string is returned.
-func (t *TemplateResolver) processForAutoIndent(str string) string {
-	d1 := regexp.QuoteMeta(t.config.StartDelim)
-	d2 := regexp.QuoteMeta(t.config.StopDelim)
+func (t *TemplateResolver) processForAutoIndent(options *ResolveOptions, str string) string {
+	startDelim, stopDelim := t.delims(options)
+	d1 := regexp.QuoteMeta(startDelim)
+	d2 := regexp.QuoteMeta(stopDelim)
 	// Detect any templates that contain `autoindent` and capture the spaces before it.
 	// Later on, the amount of spaces will dictate the conversion of `autoindent` to `indent`.
 	// This is not a very strict regex as occasionally, a user will make a mistake such as
 	// `config: '{{ "hello\nworld" | autoindent }}'`. In that event, `autoindent` will change to
 	// `indent 1`, but `indent` properly handles this.
 	re := regexp.MustCompile(`( *)(?:'|")?(` + d1 + `.*\| *autoindent *` + d2 + `)`)
-	klog.V(2).Infof("\n Pattern: %v\n", re.String())
+	t.logger(options).V(2).Info("Processing the autoindent placeholders", "pattern", re.String())
 
 	submatches := re.FindAllStringSubmatch(str, -1)
 	processed := str
 
-	klog.V(2).Infof("\n All Submatches:\n%v", submatches)
+	t.logger(options).V(2).Info("Found the autoindent submatches", "submatches", submatches)
 
 	for _, submatch := range submatches {
 		numSpaces := len(submatch[1]) - int(t.config.AdditionalIndentation)
@@ -720,7 +1830,45 @@ func (t *TemplateResolver) processForAutoIndent(str string) string {
 		processed = strings.Replace(processed, matchStr, newMatchStr, 1)
 	}
 
-	klog.V(2).Infof("\n processed data :\n%v", processed)
+	t.logger(options).V(2).Info("Processed the autoindent placeholders", "processed", processed)
+
+	return processed
+}
+
+// processForYAMLIndent converts any `toYamlIndented` placeholders at the end of the pipeline of a template that is the
+// whole value of its line into `toYamlIndented N`, where N is the column of the template, such as after the dash of a
+// list item. If the template is the value of a mapping key, it's moved to the next line and indented under the key
+// since a YAML mapping or sequence can't start on the line of its key. The processed input string is returned.
+func (t *TemplateResolver) processForYAMLIndent(options *ResolveOptions, str string) string {
+	startDelim, stopDelim := t.delims(options)
+	d1 := regexp.QuoteMeta(startDelim)
+	d2 := regexp.QuoteMeta(stopDelim)
+	// Capture the indentation and list item dashes, the optional mapping key, and the template without its quotes
+	re := regexp.MustCompile(
+		`(?m)^( *(?:- +)*)(?:([^\s'"#-][^\n]*?:)[ \t]+)?['"]?(` +
+			d1 + `[^\n]*?\| *toYamlIndented *` + d2 + `)['"]?[ \t]*$`,
+	)
+	t.logger(options).V(2).Info("Processing the toYamlIndented placeholders", "pattern", re.String())
+
+	processed := re.ReplaceAllStringFunc(str, func(match string) string {
+		submatch := re.FindStringSubmatch(match)
+		prefix, key, tmpl := submatch[1], submatch[2], submatch[3]
+
+		column := len(prefix)
+		line := prefix
+
+		if key != "" {
+			column += yamlIndentation
+			line += key + "\n" + strings.Repeat(" ", column)
+		}
+
+		numSpaces := column - int(t.config.AdditionalIndentation)
+		i := strings.LastIndex(tmpl, "toYamlIndented")
+
+		return line + tmpl[:i] + fmt.Sprintf("toYamlIndented %d", numSpaces) + tmpl[i+len("toYamlIndented"):]
+	})
+
+	t.logger(options).V(2).Info("Processed the toYamlIndented placeholders", "processed", processed)
 
 	return processed
 }
@@ -793,13 +1941,43 @@ func toBool(a string) bool {
 }
 
 // toLiteral just returns the input string as it is, however, this template function will be used to detect when
-// to remove quotes around the template string after the template is processed.
-func toLiteral(a string) (string, error) {
-	if strings.Contains(a, "\n") {
+// to remove quotes around the template string after the template is processed. Other values, such as the maps and
+// slices of lookups, are encoded as single line JSON so that they are spliced in the document as YAML flow nodes.
+func toLiteral(a interface{}) (string, error) {
+	str, ok := a.(string)
+	if !ok {
+		literal, err := json.Marshal(a)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode the value as a literal: %w", err)
+		}
+
+		return string(literal), nil
+	}
+
+	if strings.Contains(str, "\n") {
 		return "", ErrNewLinesNotAllowed
 	}
 
-	return a, nil
+	return str, nil
+}
+
+// required returns the input value if it's set and otherwise returns an error wrapping ErrRequiredValue with the
+// input message. A value is not set if it's nil, an empty string, or an empty map or slice, such as the result of a
+// lookup of an object that doesn't exist.
+func required(msg string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRequiredValue, msg)
+	}
+
+	switch reflectValue := reflect.ValueOf(value); reflectValue.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice:
+		if reflectValue.Len() == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrRequiredValue, msg)
+		}
+	default:
+	}
+
+	return value, nil
 }
 
 // CachingQueryAPI is a limited query API that will cache results. This is used with ContextTransformers.
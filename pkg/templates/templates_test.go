@@ -6,16 +6,22 @@ package templates
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stolostron/kubernetes-dependency-watches/client"
 	yaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 func TestNewResolver(t *testing.T) {
@@ -135,7 +141,7 @@ func TestValidateEncryptionFailures(t *testing.T) {
 		testName := fmt.Sprintf("expectedErr=%s", test.expectedErr)
 		t.Run(testName, func(t *testing.T) {
 			t.Parallel()
-			err := validateEncryptionConfig(test.resolveOptions.EncryptionConfig)
+			err := validateEncryptionConfig(test.resolveOptions.EncryptionConfig, defaultLogger)
 			if err == nil {
 				t.Fatal("No error was provided")
 			}
@@ -494,6 +500,27 @@ func TestResolveTemplateDefaultConfig(t *testing.T) {
 			inputTmpl:      `param: '{{ fromConfigMap "testns" "testconfigmap" "ingressSources" | toLiteral }}'`,
 			expectedResult: "param:\n  - 10.10.10.10\n  - 1.1.1.1",
 		},
+		"toLiteral_map": {
+			inputTmpl: `data: '{{ (lookup "v1" "ConfigMap" "testns" "testconfigmap").data | toLiteral }}'`,
+			expectedResult: "data:\n  cmkey1: cmkey1Val\n  cmkey2: cmkey2Val\n" +
+				"  ingressSources: '[10.10.10.10, 1.1.1.1]'",
+		},
+		"toLiteral_list_item": {
+			inputTmpl: "params:\n  - '{{ (lookup \"v1\" \"ConfigMap\" \"testns\" \"testconfigmap\").data " +
+				"| toLiteral }}'\n  - '{{ \"2\" | toInt }}'",
+			expectedResult: "params:\n  - cmkey1: cmkey1Val\n    cmkey2: cmkey2Val\n" +
+				"    ingressSources: '[10.10.10.10, 1.1.1.1]'\n  - \"2\"",
+		},
+		"required_fromConfigMap": {
+			inputTmpl: `param: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1" | ` +
+				`required "cmkey1 must be set" }}'`,
+			expectedResult: "param: cmkey1Val",
+		},
+		"required_lookup": {
+			inputTmpl: `param: '{{ (lookup "v1" "ConfigMap" "testns" "testconfigmap" | ` +
+				`required "testconfigmap must exist").metadata.name }}'`,
+			expectedResult: "param: testconfigmap",
+		},
 		"base64enc": {
 			inputTmpl:      `config1: '{{ "testdata" | base64enc  }}'`,
 			expectedResult: "config1: dGVzdGRhdGE=",
@@ -557,6 +584,22 @@ func TestResolveTemplateErrors(t *testing.T) {
 			inputTmpl:   `param: '{{ "something\n  with\n  new\n lines\n" | toLiteral }}'`,
 			expectedErr: ErrNewLinesNotAllowed,
 		},
+		"required_empty_string": {
+			inputTmpl:   `param: '{{ "" | required "the param must be set" }}'`,
+			expectedErr: ErrRequiredValue,
+		},
+		"required_missing_key": {
+			inputTmpl:   `param: '{{ fromConfigMap "testns" "testconfigmap" "not-a-key" | required "missing key" }}'`,
+			expectedErr: ErrRequiredValue,
+		},
+		"required_missing_object": {
+			inputTmpl:   `param: '{{ lookup "v1" "ConfigMap" "testns" "not-a-configmap" | required "missing" }}'`,
+			expectedErr: ErrRequiredValue,
+		},
+		"required_nil": {
+			inputTmpl:   `param: '{{ required "the param must be set" nil }}'`,
+			expectedErr: ErrRequiredValue,
+		},
 		"undefined_function": {
 			inputTmpl: `test: '{{ blah "asdf"  }}'`,
 			expectedErr: errors.New(
@@ -692,6 +735,140 @@ func TestResolveTemplateWithConfig(t *testing.T) {
 	}
 }
 
+func TestResolveTemplateOptionsDelims(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		startDelim     string
+		stopDelim      string
+		expectedResult string
+		expectedErr    error
+	}{
+		"multi-character delimiters": {
+			inputTmpl:      `value: '<%= "testdata" | base64enc %>'` + "\n" + `expr: '{{ $labels.instance }} is down'`,
+			startDelim:     "<%=",
+			stopDelim:      "%>",
+			expectedResult: "expr: '{{ $labels.instance }} is down'\nvalue: dGVzdGRhdGE=",
+		},
+		"data types": {
+			inputTmpl:      `replicas: '[[ "3" | toInt ]]'`,
+			startDelim:     "[[",
+			stopDelim:      "]]",
+			expectedResult: "replicas: 3",
+		},
+		"autoindent": {
+			inputTmpl:      "config: |\n  [[ " + `"hello\nworld\n"` + " | autoindent ]]\n",
+			startDelim:     "[[",
+			stopDelim:      "]]",
+			expectedResult: "config: |\n  hello\n  world",
+		},
+		"configured delimiters": {
+			inputTmpl:      `value: '{{ "testdata" | base64enc }}'`,
+			expectedResult: "value: dGVzdGRhdGE=",
+		},
+		"start delimiter only": {
+			inputTmpl:   `value: '<%= "testdata" %>'`,
+			startDelim:  "<%=",
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{StartDelim: test.startDelim, StopDelim: test.stopDelim},
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if strings.TrimSuffix(string(val), "\n") != test.expectedResult {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, val)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateYAMLIndent(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{InputIsYAML: true})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	container := `fromJSON "{\"name\":\"web\",\"ports\":[80,443]}"`
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+	}{
+		"list item": {
+			inputTmpl:      "spec:\n  containers:\n    - '{{ " + container + " | toYamlIndented }}'\n",
+			expectedResult: "spec:\n  containers:\n    - name: web\n      ports:\n        - 80\n        - 443",
+		},
+		"mapping value": {
+			inputTmpl:      "spec:\n  container: '{{ " + container + " | toYamlIndented }}'\n",
+			expectedResult: "spec:\n  container:\n    name: web\n    ports:\n      - 80\n      - 443",
+		},
+		"mapping value in a list item": {
+			inputTmpl: "containers:\n  - container: '{{ " + container + " | toYamlIndented }}'\n" +
+				"    image: nginx\n",
+			expectedResult: "containers:\n  - container:\n      name: web\n      ports:\n        - 80\n" +
+				"        - 443\n    image: nginx",
+		},
+		"own line": {
+			inputTmpl:      "spec:\n  {{ " + container + " | toYamlIndented }}\n",
+			expectedResult: "spec:\n  name: web\n  ports:\n    - 80\n    - 443",
+		},
+		"scalar": {
+			inputTmpl:      `name: '{{ "web" | toYamlIndented }}'`,
+			expectedResult: "name: web",
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if strings.TrimSuffix(string(val), "\n") != test.expectedResult {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, val)
+			}
+		})
+	}
+}
+
 func TestSetInputIsYAML(t *testing.T) {
 	t.Parallel()
 
@@ -742,6 +919,14 @@ func TestResolveTemplateWithContext(t *testing.T) {
 			ctx:            struct{ Foo map[string]string }{Foo: map[string]string{"greeting": "hello"}},
 			expectedResult: "value: hello",
 		},
+		"nested_values": {
+			inputTmpl: `value: '{{ .Values.app.name }}-{{ .Values.replicas }}'`,
+			ctx: struct{ Values map[string]interface{} }{Values: map[string]interface{}{
+				"app":      map[string]interface{}{"name": "web"},
+				"replicas": 3,
+			}},
+			expectedResult: "value: web-3",
+		},
 	}
 
 	for testName, test := range testcases {
@@ -890,6 +1075,57 @@ func TestResolveTemplateWithCrypto(t *testing.T) {
 			resolveOptions: decrypt,
 			expectedErr:    ErrInvalidPKCS7Padding,
 		},
+		"encrypt_protect_gcm": {
+			inputTmpl: `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey:               key,
+					Algorithm:            EncryptionAlgorithmAESGCM,
+					EncryptionEnabled:    true,
+					InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: $ocm_encrypted:gcm:XVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg=",
+		},
+		"decrypt_gcm": {
+			inputTmpl: "value: $ocm_encrypted:gcm:XVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg=\n" +
+				"value2: $ocm_encrypted:Eud/p3S7TvuP03S9fuNV+w==",
+			resolveOptions: decrypt,
+			expectedResult: "value: Raleigh\nvalue2: Raleigh",
+		},
+		"decrypt_gcm_fallback": {
+			inputTmpl: "value: $ocm_encrypted:gcm:XVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg=",
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: otherKey, AESKeyFallback: key, DecryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedResult: "value: Raleigh",
+		},
+		"decrypt_fails_gcm_modified": {
+			inputTmpl:      "value: $ocm_encrypted:gcm:YVkILZyOnj72YnYXdew4ZM5dLzRpC8IeBcl6uy+thiDiFVg=",
+			resolveOptions: decrypt,
+			expectedErr:    ErrGCMAuthFailed,
+		},
+		"decrypt_fails_gcm_too_short": {
+			inputTmpl:      "value: $ocm_encrypted:gcm:Zm9vYmFy",
+			resolveOptions: decrypt,
+			expectedErr:    ErrGCMAuthFailed,
+		},
+		"encrypt_fails_invalid_algorithm": {
+			inputTmpl: `value: '{{ "Raleigh" | protect }}'`,
+			resolveOptions: ResolveOptions{
+				EncryptionConfig: EncryptionConfig{
+					AESKey: key, Algorithm: "DES", EncryptionEnabled: true, InitializationVector: iv,
+				},
+			},
+			expectedErr: ErrInvalidAlgorithm,
+		},
+		"encrypt_fails_notfullblocks": {
+			inputTmpl:      "value: $ocm_encrypted:Zm9vYmFy",
+			resolveOptions: decrypt,
+			expectedErr:    ErrInvalidPKCS7Padding,
+		},
 	}
 
 	for testName, test := range testcases {
@@ -903,6 +1139,84 @@ func TestResolveTemplateWithCrypto(t *testing.T) {
 	}
 }
 
+func TestProtectObject(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "creds", "namespace": "app"},
+			"data":       map[string]interface{}{"password": "aHVudGVyMg==", "username": "YWRtaW4="},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	key := bytes.Repeat([]byte{byte('A')}, 256/8)
+	iv := bytes.Repeat([]byte{byte('I')}, IVSize)
+
+	encrypted, err := resolver.ResolveTemplate(
+		[]byte(`data: '{{ (lookup "v1" "Secret" "app" "creds").data | protectObject }}'`),
+		nil,
+		&ResolveOptions{
+			EncryptionConfig: EncryptionConfig{AESKey: key, EncryptionEnabled: true, InitializationVector: iv},
+		},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	encryptedObj := map[string]string{}
+	if err := json.Unmarshal(encrypted.ResolvedJSON, &encryptedObj); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !strings.HasPrefix(encryptedObj["data"], protectedPrefix+objectPrefix) ||
+		strings.Contains(encryptedObj["data"], "password") {
+		t.Fatalf("Unexpected encrypted value: %s", encryptedObj["data"])
+	}
+
+	encryptedYAML, err := JSONToYAML(encrypted.ResolvedJSON)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	decrypted, err := resolver.ResolveTemplate(
+		encryptedYAML,
+		nil,
+		&ResolveOptions{
+			EncryptionConfig: EncryptionConfig{AESKey: key, DecryptionEnabled: true, InitializationVector: iv},
+		},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := `{"data":{"password":"aHVudGVyMg==","username":"YWRtaW4="}}`
+	if string(decrypted.ResolvedJSON) != expected {
+		t.Fatalf("Expected %s but got %s", expected, decrypted.ResolvedJSON)
+	}
+
+	_, err = resolver.ResolveTemplate(
+		[]byte(`data: '{{ protectObject "not an object" }}'`),
+		nil,
+		&ResolveOptions{
+			EncryptionConfig: EncryptionConfig{AESKey: key, EncryptionEnabled: true, InitializationVector: iv},
+		},
+	)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput but got: %v", err)
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`data: '{{ protectObject (list "a") }}'`), nil, nil)
+	if !errors.Is(err, ErrProtectNotEnabled) {
+		t.Fatalf("Expected ErrProtectNotEnabled but got: %v", err)
+	}
+}
+
 func TestHasTemplate(t *testing.T) {
 	t.Parallel()
 
@@ -931,6 +1245,397 @@ func TestHasTemplate(t *testing.T) {
 	}
 }
 
+func TestResolveTemplateHasSensitiveData(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		inputTmpl string
+		expected  bool
+	}{
+		"fromSecret":    {`data: '{{ fromSecret "testns" "testsecret" "secretkey1" }}'`, true},
+		"lookup secret": {`data: '{{ (lookup "v1" "Secret" "testns" "testsecret").metadata.name }}'`, true},
+		"fromConfigMap": {`data: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1" }}'`, false},
+		"no lookups":    {`data: '{{ "Raleigh" }}'`, false},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			tmplStr, err := yamlToJSON([]byte(test.inputTmpl))
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			resolver, err := NewResolver(k8sConfig, Config{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			tmplResult, err := resolver.ResolveTemplate(tmplStr, nil, &ResolveOptions{})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if tmplResult.HasSensitiveData != test.expected {
+				t.Fatalf("Expected HasSensitiveData to be %v", test.expected)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateResolveID(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStr := []byte(`{"a": "{{ resolveID }}", "b": "{{ resolveID }}"}`)
+	resolveIDs := make([]string, 0, 2)
+
+	for i := 0; i < 2; i++ {
+		tmplResult, err := resolver.ResolveTemplate(tmplStr, nil, &ResolveOptions{})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		resolved := map[string]string{}
+
+		err = json.Unmarshal(tmplResult.ResolvedJSON, &resolved)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if resolved["a"] == "" || resolved["a"] != resolved["b"] || resolved["a"] != tmplResult.ResolveID {
+			t.Fatalf("Expected the same resolve ID for every call but got %v and %s", resolved, tmplResult.ResolveID)
+		}
+
+		resolveIDs = append(resolveIDs, tmplResult.ResolveID)
+	}
+
+	if resolveIDs[0] == resolveIDs[1] {
+		t.Fatalf("Expected a different resolve ID for each ResolveTemplate call but got %v", resolveIDs)
+	}
+}
+
+func TestResolveTemplateClock(t *testing.T) {
+	t.Parallel()
+
+	clock := func() time.Time { return time.Date(2023, time.October, 31, 12, 30, 0, 0, time.UTC) }
+
+	testcases := map[string]resolveTestCase{
+		"now": {
+			inputTmpl:      `value: '{{ (now.UTC).Format "2006-01-02T15:04" }}'`,
+			config:         Config{Clock: clock},
+			expectedResult: "value: 2023-10-31T12:30",
+		},
+		"now unix": {
+			inputTmpl:      `value: '{{ now.Unix }}'`,
+			config:         Config{Clock: clock},
+			expectedResult: `value: "1698755400"`,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestResolveTemplateMissingKey(t *testing.T) {
+	t.Parallel()
+
+	tmpl := `value: '{{ .Labels.missing }}'`
+	ctx := struct{ Labels map[string]string }{Labels: map[string]string{"key": "value"}}
+
+	testcases := map[string]resolveTestCase{
+		"unset": {
+			inputTmpl:      tmpl,
+			ctx:            ctx,
+			expectedResult: "value: <no value>",
+		},
+		"default": {
+			inputTmpl:      tmpl,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MissingKey: "default"},
+			expectedResult: "value: <no value>",
+		},
+		"zero": {
+			inputTmpl:      tmpl,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MissingKey: "zero"},
+			expectedResult: `value: ""`,
+		},
+		"error": {
+			inputTmpl:      tmpl,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MissingKey: "error"},
+			expectedErr: errors.New(
+				`failed to resolve the template {"value":"{{ .Labels.missing }}"}: template: tmpl:1:18: ` +
+					`executing "tmpl" at <.Labels.missing>: map has no entry for key "missing"`,
+			),
+		},
+		"invalid option": {
+			inputTmpl:      tmpl,
+			ctx:            ctx,
+			resolveOptions: ResolveOptions{MissingKey: "ignore"},
+			expectedErr:    ErrInvalidInput,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			doResolveTest(t, test)
+		})
+	}
+}
+
+func TestResolveTemplateMaxLookups(t *testing.T) {
+	t.Parallel()
+
+	objects := []unstructured.Unstructured{}
+
+	for _, name := range []string{"cm1", "cm2", "cm3"} {
+		objects = append(objects, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+			"data":       map[string]interface{}{"key": name},
+		}})
+	}
+
+	distinctLookups := `value: '{{ range (list "cm1" "cm2" "cm3") }}{{ fromConfigMap "app" . "key" }}{{ end }}'`
+
+	testcases := map[string]struct {
+		inputTmpl   string
+		maxLookups  uint
+		expectedErr error
+	}{
+		"no maximum": {
+			inputTmpl: distinctLookups,
+		},
+		"within the maximum": {
+			inputTmpl:  distinctLookups,
+			maxLookups: 3,
+		},
+		"exceeds the maximum": {
+			inputTmpl:   distinctLookups,
+			maxLookups:  2,
+			expectedErr: ErrLookupQuotaExceeded,
+		},
+		"cached lookups are not counted": {
+			inputTmpl:  `value: '{{ range (list "cm1" "cm1" "cm1") }}{{ fromConfigMap "app" . "key" }}{{ end }}'`,
+			maxLookups: 1,
+		},
+		"canLookup is counted": {
+			inputTmpl:   `value: '{{ fromConfigMap "app" "cm1" "key" }}{{ canLookup "v1" "ConfigMap" "app" "get" }}'`,
+			maxLookups:  1,
+			expectedErr: ErrLookupQuotaExceeded,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			_, err = resolver.ResolveTemplate(
+				[]byte(test.inputTmpl), nil, &ResolveOptions{MaxLookups: test.maxLookups},
+			)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNewResolverRateLimits(t *testing.T) {
+	t.Parallel()
+
+	kubeConfig := &rest.Config{Host: k8sConfig.Host, QPS: 5, Burst: 10}
+	rateLimiter := flowcontrol.NewTokenBucketRateLimiter(100, 200)
+
+	tests := map[string]struct {
+		config              Config
+		expectedQPS         float32
+		expectedBurst       int
+		expectedRateLimiter flowcontrol.RateLimiter
+	}{
+		"no overrides": {
+			expectedQPS:   5,
+			expectedBurst: 10,
+		},
+		"QPS and burst": {
+			config:        Config{QPS: 50, Burst: 100},
+			expectedQPS:   50,
+			expectedBurst: 100,
+		},
+		"rate limiter": {
+			config:              Config{RateLimiter: rateLimiter},
+			expectedQPS:         5,
+			expectedBurst:       10,
+			expectedRateLimiter: rateLimiter,
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(kubeConfig, test.config)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if resolver.kubeConfig.QPS != test.expectedQPS || resolver.kubeConfig.Burst != test.expectedBurst {
+				t.Fatalf(
+					"Expected a QPS of %v and a burst of %d but got %v and %d",
+					test.expectedQPS, test.expectedBurst, resolver.kubeConfig.QPS, resolver.kubeConfig.Burst,
+				)
+			}
+
+			if resolver.kubeConfig.RateLimiter != test.expectedRateLimiter {
+				t.Fatalf("Expected the rate limiter to be %v", test.expectedRateLimiter)
+			}
+
+			// The input rest.Config must not be modified
+			if kubeConfig.QPS != 5 || kubeConfig.Burst != 10 || kubeConfig.RateLimiter != nil {
+				t.Fatalf("Expected the input rest.Config to not be modified but got: %v", kubeConfig)
+			}
+		})
+	}
+}
+
+func TestNewResolverImpersonation(t *testing.T) {
+	t.Parallel()
+
+	kubeConfig := &rest.Config{Host: k8sConfig.Host, Impersonate: rest.ImpersonationConfig{UserName: "admin"}}
+
+	tests := map[string]struct {
+		config   Config
+		expected rest.ImpersonationConfig
+	}{
+		"no override": {
+			expected: rest.ImpersonationConfig{UserName: "admin"},
+		},
+		"user and groups": {
+			config: Config{
+				Impersonate: rest.ImpersonationConfig{
+					UserName: "system:serviceaccount:open-cluster-management-agent-addon:config-policy-controller",
+					Groups:   []string{"system:serviceaccounts"},
+				},
+			},
+			expected: rest.ImpersonationConfig{
+				UserName: "system:serviceaccount:open-cluster-management-agent-addon:config-policy-controller",
+				Groups:   []string{"system:serviceaccounts"},
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(kubeConfig, test.config)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !reflect.DeepEqual(resolver.kubeConfig.Impersonate, test.expected) {
+				t.Fatalf("Expected the impersonation %v but got %v", test.expected, resolver.kubeConfig.Impersonate)
+			}
+
+			// The input rest.Config must not be modified
+			if kubeConfig.Impersonate.UserName != "admin" || len(kubeConfig.Impersonate.Groups) != 0 {
+				t.Fatalf("Expected the input rest.Config to not be modified but got: %v", kubeConfig)
+			}
+		})
+	}
+}
+
+func TestResolveTemplateWithContextCancel(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStr := []byte(`{"data": "{{ fromConfigMap \"testns\" \"testconfigmap\" \"cmkey1\" }}"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = resolver.ResolveTemplateWithContext(ctx, tmplStr, nil, &ResolveOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but got: %v", err)
+	}
+
+	tmplResult, err := resolver.ResolveTemplateWithContext(context.Background(), tmplStr, nil, &ResolveOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if string(tmplResult.ResolvedJSON) != `{"data":"cmkey1Val"}` {
+		t.Fatalf("Unexpected resolved template: %s", tmplResult.ResolvedJSON)
+	}
+}
+
+func TestResolveTemplateReferencedObjects(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewResolver(k8sConfig, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplStr, err := yamlToJSON([]byte(`
+a: '{{ fromConfigMap "testns" "testconfigmap" "cmkey1" }}'
+b: '{{ fromConfigMap "testns" "testconfigmap" "cmkey2" }}'
+c: '{{ (lookup "v1" "ConfigMap" "testns" "idontexist").data }}'
+d: '{{ len (lookup "v1" "ConfigMap" "testns" "" "env=a").items }}'`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmplResult, err := resolver.ResolveTemplate(tmplStr, nil, &ResolveOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// The fields are resolved in alphabetical order since the input is converted from JSON
+	expected := []client.ObjectIdentifier{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "testconfigmap"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Name: "idontexist"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "testns", Selector: "env=a"},
+	}
+
+	if !reflect.DeepEqual(tmplResult.ReferencedObjects, expected) {
+		t.Fatalf("Expected the referenced objects %v but got %v", expected, tmplResult.ReferencedObjects)
+	}
+}
+
 func TestUsesEncryption(t *testing.T) {
 	t.Parallel()
 
@@ -957,6 +1662,9 @@ func TestUsesEncryption(t *testing.T) {
 		{" I am a {{hub sample hub}}  template ", "{{hub", "hub}}", false},
 		{" I am a {{hub fromSecret test-secret hub}}  template ", "{{hub", "hub}}", true},
 		{" I am a {{hub test-secret | protect hub}}  template ", "{{hub", "hub}}", true},
+		{" I am a {{ fromSecretEncrypted test-secret }}  encrypted template ", "{{", "}}", true},
+		{" I am a {{ fromSecretKeyOrDefault test-secret }}  encrypted template ", "{{", "}}", true},
+		{" I am a {{ fromSecretBinary test-secret | sha256sum }} template ", "{{", "}}", false},
 	}
 
 	for _, test := range testcases {
@@ -1063,6 +1771,21 @@ func TestProcessForDataTypes(t *testing.T) {
 		     key2 : {{with fromConfigMap "namespace" "name" "key" }} {{ . | toInt }} {{ else }} 2 {{ end }} }}
 		     key3 : {{ "blah" | toBool }}`,
 		},
+		{
+			`list:
+  - '{{ $obj | toLiteral }}'
+  - '{{ "6" | toInt }}'
+  - '{{ "true" | toBool }}'
+  - '{{ copyConfigMapData "namespace" "name" }}'
+  - key: '{{ "6" | toInt }}'`,
+			config,
+			`list:
+  - {{ $obj | toLiteral }}
+  - '{{ "6" | toInt }}'
+  - '{{ "true" | toBool }}'
+  - '{{ copyConfigMapData "namespace" "name" }}'
+  - key: {{ "6" | toInt }}`,
+		},
 	}
 
 	for _, test := range testcases {
@@ -1071,7 +1794,7 @@ func TestProcessForDataTypes(t *testing.T) {
 			t.Fatalf(err.Error())
 		}
 
-		val := resolver.processForDataTypes(test.input)
+		val := resolver.processForDataTypes(nil, test.input)
 
 		if val != test.expectedResult {
 			t.Fatalf("expected : %v , got : %v", test.expectedResult, val)
@@ -1124,6 +1847,62 @@ func TestGetNamespace(t *testing.T) {
 	}
 }
 
+func TestGetNamespaceMultiple(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		options           ResolveOptions
+		actualNamespace   string
+		returnedNamespace string
+		expectedError     string
+	}{
+		"allowed": {
+			ResolveOptions{LookupNamespaces: []string{"ns1", "ns2"}}, "ns2", "ns2", "",
+		},
+		"allowed by LookupNamespace": {
+			ResolveOptions{LookupNamespace: "ns1", LookupNamespaces: []string{"ns2"}}, "ns1", "ns1", "",
+		},
+		"single default": {
+			ResolveOptions{LookupNamespaces: []string{"ns1"}}, "", "ns1", "",
+		},
+		"restricted": {
+			ResolveOptions{LookupNamespace: "ns1", LookupNamespaces: []string{"ns2"}}, "ns3", "",
+			"the namespace argument is restricted to ns1, ns2",
+		},
+		"missing namespace": {
+			ResolveOptions{LookupNamespaces: []string{"ns1", "ns2"}}, "", "",
+			"the namespace argument is restricted to ns1, ns2 and a namespace must be specified",
+		},
+	}
+
+	resolver, _ := NewResolver(k8sConfig, Config{})
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			ns, err := resolver.getNamespace(test.actualNamespace, test.options.lookupNamespaces()...)
+			if test.expectedError != "" {
+				if !errors.Is(err, ErrRestrictedNamespace) || err.Error() != test.expectedError {
+					t.Fatalf("expected error: %v, got: %v", test.expectedError, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("No error was expected: %v", err)
+			}
+
+			if ns != test.returnedNamespace {
+				t.Fatalf("expected namespace: %s, got: %s", test.returnedNamespace, ns)
+			}
+		})
+	}
+}
+
 //nolint:nosnakecase
 func ExampleTemplateResolver_ResolveTemplate() {
 	policyYAML := `
@@ -1207,3 +1986,102 @@ spec:
 	// Templates rock!
 	// Y2x1c3RlcjAwMDE=
 }
+
+func TestResolveTemplateLookupStats(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "settings", "namespace": "app"},
+				"data":       map[string]interface{}{"replicas": "3"},
+			}},
+		},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `first: '{{ fromConfigMap "app" "settings" "replicas" }}'
+second: '{{ fromConfigMap "app" "settings" "replicas" }}'
+missing: '{{ (lookup "v1" "ConfigMap" "app" "missing").data }}'
+count: '{{ len (lookup "v1" "ConfigMap" "app" "").items }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats := result.LookupStats
+
+	if stats.Lookups != 4 || stats.CacheHits != 1 || stats.APICalls != 3 {
+		t.Fatalf("Expected 4 lookups, 1 cache hit, and 3 API calls but got %+v", stats)
+	}
+
+	if stats.BytesFetched == 0 {
+		t.Fatalf("Expected the bytes fetched to be set")
+	}
+
+	// The statistics are also set when the resolution fails
+	result, err = resolver.ResolveTemplate(
+		[]byte(`value: '{{ fromConfigMap "app" "settings" "replicas" }}{{ required "a value" "" }}'`), nil, nil,
+	)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	if result.LookupStats.Lookups != 1 || result.LookupStats.APICalls != 1 {
+		t.Fatalf("Expected 1 lookup and 1 API call but got %+v", result.LookupStats)
+	}
+}
+
+func TestResolveTemplateMissingObjects(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "settings", "namespace": "app"},
+				"data":       map[string]interface{}{"replicas": "3"},
+			}},
+		},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tmpl := `found: '{{ fromConfigMap "app" "settings" "replicas" }}'
+missing: '{{ (lookup "v1" "ConfigMap" "app" "missing").data }}'
+missingAgain: '{{ (lookup "v1" "ConfigMap" "app" "missing").data }}'
+empty: '{{ len (lookup "v1" "ConfigMap" "other" "").items }}'
+listed: '{{ len (lookup "v1" "ConfigMap" "app" "").items }}'`
+
+	result, err := resolver.ResolveTemplate([]byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expected := []client.ObjectIdentifier{
+		{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "missing"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "other"},
+	}
+
+	if !reflect.DeepEqual(result.MissingObjects, expected) {
+		t.Fatalf("Expected the missing objects %v but got %v", expected, result.MissingObjects)
+	}
+
+	result, err = resolver.ResolveTemplate([]byte(`found: '{{ fromConfigMap "app" "settings" "replicas" }}'`), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if len(result.MissingObjects) != 0 {
+		t.Fatalf("Expected no missing objects but got %v", result.MissingObjects)
+	}
+}
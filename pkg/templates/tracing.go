@@ -0,0 +1,53 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the OpenTelemetry instrumentation scope of the spans of the TemplateResolver.
+const tracerName = "github.com/stolostron/go-template-utils/v4/pkg/templates"
+
+// The attribute keys of the spans.
+const (
+	attrResolveID     = attribute.Key("template.resolve_id")
+	attrTemplateCount = attribute.Key("template.count")
+	attrGroup         = attribute.Key("k8s.group")
+	attrVersion       = attribute.Key("k8s.version")
+	attrKind          = attribute.Key("k8s.kind")
+	attrNamespace     = attribute.Key("k8s.namespace")
+	attrName          = attribute.Key("k8s.name")
+	attrLabelSelector = attribute.Key("k8s.label_selector")
+	attrCacheHit      = attribute.Key("cache.hit")
+)
+
+// noopTracer is used when Config.TracerProvider is not set so that the spans are not recorded.
+var noopTracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+
+// startSpan starts a span that is a child of the span in the input context, if any, with the tracer of
+// Config.TracerProvider. The returned context contains the new span.
+func (t *TemplateResolver) startSpan(
+	ctx context.Context, name string, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	tracer := t.tracer
+	if tracer == nil {
+		tracer = noopTracer
+	}
+
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan sets the error status on the span if the input error is not nil and then ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,147 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	objects := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "settings", "namespace": "app"},
+			"data":       map[string]interface{}{"replicas": "3"},
+		}},
+	}
+
+	resolver, err := NewFakeResolver(objects, Config{InputIsYAML: true, TracerProvider: provider})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "reconcile")
+
+	tmpl := `data:
+  first: '{{ fromConfigMap "app" "settings" "replicas" }}'
+  second: '{{ fromConfigMap "app" "settings" "replicas" }}'
+  missing: '{{ (lookup "v1" "ConfigMap" "app" "missing").data }}'`
+
+	result, err := resolver.ResolveTemplateWithContext(ctx, []byte(tmpl), nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplateWithContext(
+		ctx, []byte(`value: '{{ fromConfigMap "app" "settings" }}'`), nil, nil,
+	)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	parent.End()
+
+	spans := recorder.Ended()
+
+	var resolveSpans, lookupSpans []sdktrace.ReadOnlySpan
+
+	for _, span := range spans {
+		switch span.Name() {
+		case "ResolveTemplate":
+			resolveSpans = append(resolveSpans, span)
+		case "getOrList":
+			lookupSpans = append(lookupSpans, span)
+		}
+	}
+
+	if len(resolveSpans) != 2 {
+		t.Fatalf("Expected 2 ResolveTemplate spans but got %d", len(resolveSpans))
+	}
+
+	if resolveSpans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("Expected the ResolveTemplate span to be a child of the span in the context")
+	}
+
+	attrs := spanAttributes(resolveSpans[0])
+	if attrs[attrResolveID] != attribute.StringValue(result.ResolveID) {
+		t.Fatalf("Expected the resolve ID %s but got %v", result.ResolveID, attrs[attrResolveID].Emit())
+	}
+
+	if resolveSpans[0].Status().Code == codes.Error {
+		t.Fatalf("Expected the first ResolveTemplate span to not have an error")
+	}
+
+	if resolveSpans[1].Status().Code != codes.Error {
+		t.Fatalf("Expected the second ResolveTemplate span to have an error")
+	}
+
+	if len(lookupSpans) != 3 {
+		t.Fatalf("Expected 3 getOrList spans but got %d", len(lookupSpans))
+	}
+
+	expectedCacheHits := []bool{false, true, false}
+
+	for i, span := range lookupSpans {
+		if span.Parent().SpanID() != resolveSpans[0].SpanContext().SpanID() {
+			t.Fatalf("Expected the getOrList span %d to be a child of the ResolveTemplate span", i)
+		}
+
+		attrs := spanAttributes(span)
+
+		if attrs[attrVersion].AsString() != "v1" || attrs[attrKind].AsString() != "ConfigMap" {
+			t.Fatalf("Expected the getOrList span %d to have the v1 ConfigMap kind but got %v", i, span.Attributes())
+		}
+
+		if attrs[attrNamespace].AsString() != "app" {
+			t.Fatalf("Expected the getOrList span %d to have the app namespace but got %v", i, span.Attributes())
+		}
+
+		if attrs[attrCacheHit].AsBool() != expectedCacheHits[i] {
+			t.Fatalf("Expected the getOrList span %d to have cache.hit=%v", i, expectedCacheHits[i])
+		}
+	}
+
+	if attrs := spanAttributes(lookupSpans[2]); attrs[attrName].AsString() != "missing" {
+		t.Fatalf("Expected the last getOrList span to be for the missing object but got %v", attrs)
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	t.Parallel()
+
+	resolver := TemplateResolver{}
+
+	ctx, span := resolver.startSpan(context.Background(), "test")
+	if span.IsRecording() {
+		t.Fatalf("Expected the span to not be recorded without a TracerProvider")
+	}
+
+	endSpan(span, nil)
+
+	if ctx == nil {
+		t.Fatalf("Expected a context")
+	}
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+
+	return attrs
+}
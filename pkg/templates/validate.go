@@ -0,0 +1,243 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+var ErrInvalidArgumentCount = errors.New("the template function has the wrong number of arguments")
+
+// namespaceArgIndexes are the indexes of the namespace argument of the template functions that are restricted by
+// ResolveOptions.LookupNamespace and ResolveOptions.LookupNamespaces.
+var namespaceArgIndexes = map[string]int{
+	"canLookup":              2,
+	"copyConfigMapData":      0,
+	"copySecretData":         0,
+	"existingNames":          2,
+	"fromConfigMap":          0,
+	"fromConfigMaps":         0,
+	"fromSecret":             0,
+	"fromSecretBinary":       0,
+	"fromSecretEncrypted":    0,
+	"fromSecretKeyOrDefault": 0,
+	"getObjectsByOwner":      2,
+	"lookup":                 2,
+	"lookupAny":              2,
+	"lookupSubresource":      2,
+}
+
+// ValidationIssue is a problem found in a template by Validate.
+//
+// - Line is the 1-based line of the problem in the template. If Config.InputIsYAML is not set, this is relative to
+// the input JSON converted to YAML. The template may also have minor modifications from the processing of special
+// functions (e.g. toYamlIndented).
+//
+// - Column is the 1-based byte column of the problem in the line. This is 0 when only the line is known.
+//
+// - Err describes the problem. It wraps a sentinel error such as ErrInvalidArgumentCount or ErrRestrictedNamespace
+// when applicable.
+type ValidationIssue struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (v ValidationIssue) String() string {
+	if v.Column == 0 {
+		return fmt.Sprintf("line %d: %v", v.Line, v.Err)
+	}
+
+	return fmt.Sprintf("line %d, column %d: %v", v.Line, v.Column, v.Err)
+}
+
+// Validate statically checks the template without executing it, so no lookups are made. This is useful to reject
+// obviously broken templates early, such as in an admission webhook. The template is checked for syntax errors,
+// undefined template functions, template function calls with the wrong number of arguments, and literal namespace
+// arguments outside of the namespaces allowed by the input options. A nil slice is returned if no issues are found.
+//
+// Note that a template that passes validation can still fail to resolve, such as if a looked up object doesn't exist.
+func (t *TemplateResolver) Validate(tmplRaw []byte, options ResolveOptions) []ValidationIssue {
+	funcMap, err := t.buildFuncMap(&options)
+	if err != nil {
+		return []ValidationIssue{{Err: err}}
+	}
+
+	templateStr := string(tmplRaw)
+
+	if !t.config.InputIsYAML {
+		templateYAMLBytes, err := JSONToYAML(tmplRaw)
+		if err != nil {
+			return []ValidationIssue{{Err: fmt.Errorf("failed to convert the template to YAML: %w", err)}}
+		}
+
+		templateStr = string(templateYAMLBytes)
+	}
+
+	if err := t.validateVerbatimBlocks(&options, templateStr); err != nil {
+		return []ValidationIssue{{Err: err}}
+	}
+
+	// Process the template like ResolveTemplate so that the verbatim blocks and the placeholders of the special
+	// template functions, such as toYamlIndented, are validated as they are resolved
+	templateStr, _ = t.processTemplate(&options, templateStr)
+
+	tmpl, err := t.parseTemplate("tmpl", templateStr, funcMap, &options)
+	if err != nil {
+		err = classifyError(ErrParseFailed, deniedFunctionError(err, options.DeniedFunctions))
+		issue := ValidationIssue{Err: err}
+
+		var tmplErr *TemplateError
+		if errors.As(newTemplateError(templateStr, err), &tmplErr) {
+			issue.Line = tmplErr.Line
+			issue.Column = tmplErr.Column
+		}
+
+		return []ValidationIssue{issue}
+	}
+
+	validator := templateValidator{
+		resolver:         t,
+		funcMap:          funcMap,
+		lookupNamespaces: options.lookupNamespaces(),
+		templateStr:      templateStr,
+	}
+
+	for _, definedTmpl := range tmpl.Templates() {
+		if definedTmpl.Tree != nil {
+			validator.walk(definedTmpl.Tree.Root)
+		}
+	}
+
+	return validator.issues
+}
+
+// templateValidator walks the parsed template and records the issues found in the template function calls.
+type templateValidator struct {
+	resolver         *TemplateResolver
+	funcMap          template.FuncMap
+	lookupNamespaces []string
+	templateStr      string
+	issues           []ValidationIssue
+}
+
+func (v *templateValidator) walk(node parse.Node) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+
+		for _, child := range typedNode.Nodes {
+			v.walk(child)
+		}
+	case *parse.ActionNode:
+		v.walk(typedNode.Pipe)
+	case *parse.IfNode:
+		v.walkBranch(&typedNode.BranchNode)
+	case *parse.RangeNode:
+		v.walkBranch(&typedNode.BranchNode)
+	case *parse.WithNode:
+		v.walkBranch(&typedNode.BranchNode)
+	case *parse.TemplateNode:
+		v.walk(typedNode.Pipe)
+	case *parse.ChainNode:
+		v.walk(typedNode.Node)
+	case *parse.PipeNode:
+		if typedNode == nil {
+			return
+		}
+
+		for i, cmd := range typedNode.Cmds {
+			// Every command after the first in a pipeline receives the previous result as its last argument
+			v.checkCommand(cmd, i > 0)
+
+			for _, arg := range cmd.Args {
+				v.walk(arg)
+			}
+		}
+	}
+}
+
+func (v *templateValidator) walkBranch(node *parse.BranchNode) {
+	v.walk(node.Pipe)
+	v.walk(node.List)
+	v.walk(node.ElseList)
+}
+
+// checkCommand checks the number of arguments and the namespace argument of the template function call in the
+// command, if it is one.
+func (v *templateValidator) checkCommand(cmd *parse.CommandNode, piped bool) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+
+	identifier, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return
+	}
+
+	// Functions predefined by text/template are not in the function map and are checked at execution
+	fn, ok := v.funcMap[identifier.Ident]
+	if !ok {
+		return
+	}
+
+	args := cmd.Args[1:]
+
+	argCount := len(args)
+	if piped {
+		argCount++
+	}
+
+	fnType := reflect.TypeOf(fn)
+
+	if fnType.IsVariadic() {
+		if argCount < fnType.NumIn()-1 {
+			v.addIssue(identifier, fmt.Errorf(
+				"%w: %s expects at least %d arguments but got %d",
+				ErrInvalidArgumentCount, identifier.Ident, fnType.NumIn()-1, argCount,
+			))
+		}
+	} else if argCount != fnType.NumIn() {
+		v.addIssue(identifier, fmt.Errorf(
+			"%w: %s expects %d arguments but got %d",
+			ErrInvalidArgumentCount, identifier.Ident, fnType.NumIn(), argCount,
+		))
+	}
+
+	nsIndex, ok := namespaceArgIndexes[identifier.Ident]
+	if !ok || len(v.lookupNamespaces) == 0 || nsIndex >= len(args) {
+		return
+	}
+
+	// Only literal namespaces can be checked without executing the template
+	namespaceNode, ok := args[nsIndex].(*parse.StringNode)
+	if !ok || namespaceNode.Text == "" {
+		return
+	}
+
+	if _, err := v.resolver.getNamespace(namespaceNode.Text, v.lookupNamespaces...); err != nil {
+		v.addIssue(namespaceNode, fmt.Errorf("%s: %w", identifier.Ident, err))
+	}
+}
+
+func (v *templateValidator) addIssue(node parse.Node, err error) {
+	offset := int(node.Position())
+	if offset > len(v.templateStr) {
+		offset = len(v.templateStr)
+	}
+
+	lineStart := strings.LastIndexByte(v.templateStr[:offset], '\n') + 1
+
+	v.issues = append(v.issues, ValidationIssue{
+		Line:   strings.Count(v.templateStr[:offset], "\n") + 1,
+		Column: offset - lineStart + 1,
+		Err:    err,
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	type expectedIssue struct {
+		line   int
+		column int
+		err    error
+	}
+
+	tests := map[string]struct {
+		inputTmpl      string
+		options        ResolveOptions
+		expectedIssues []expectedIssue
+	}{
+		"valid": {
+			inputTmpl: "value: '{{ fromConfigMap \"ns\" \"name\" \"key\" | toInt }}'\n" +
+				"other: '{{ (lookup \"v1\" \"ConfigMap\" \"ns\" \"\" \"app=web\").items | len }}'",
+			options: ResolveOptions{LookupNamespace: "ns"},
+		},
+		"syntax error": {
+			inputTmpl:      "value: '{{ fromConfigMap \"ns\" \"name\" \"key\" '",
+			expectedIssues: []expectedIssue{{line: 1, column: 0}},
+		},
+		"undefined function": {
+			inputTmpl:      "value: test\nother: '{{ notAFunction }}'",
+			expectedIssues: []expectedIssue{{line: 2, column: 0}},
+		},
+		"wrong argument count": {
+			inputTmpl: "value: '{{ fromConfigMap \"ns\" \"name\" }}'\n" +
+				"other: '{{ \"key\" | fromSecret \"ns\" \"name\" \"key\" }}'\n" +
+				"list: '{{ lookup \"v1\" \"ConfigMap\" }}'",
+			expectedIssues: []expectedIssue{
+				{line: 1, column: 12, err: ErrInvalidArgumentCount},
+				{line: 2, column: 20, err: ErrInvalidArgumentCount},
+				{line: 3, column: 11, err: ErrInvalidArgumentCount},
+			},
+		},
+		"restricted namespace": {
+			inputTmpl: "value: '{{ if true }}{{ fromConfigMap \"other\" \"name\" \"key\" }}{{ end }}'\n" +
+				"other: '{{ (lookup \"v1\" \"ConfigMap\" \"other\" \"name\").data }}'\n" +
+				"allowed: '{{ fromSecret \"ns\" \"name\" \"key\" }}'",
+			options: ResolveOptions{LookupNamespace: "ns"},
+			expectedIssues: []expectedIssue{
+				{line: 1, column: 39, err: ErrRestrictedNamespace},
+				{line: 2, column: 37, err: ErrRestrictedNamespace},
+			},
+		},
+		"verbatim block": {
+			inputTmpl: "value: '{{ \"web\" }} {{ verbatim }}{{ $labels.instance }} down{{ endVerbatim }}'",
+		},
+		"verbatim block without end marker": {
+			inputTmpl:      "value: '{{ verbatim }}{{ $labels.instance }} down'",
+			expectedIssues: []expectedIssue{{err: ErrParseFailed}},
+		},
+		"toYamlIndented": {
+			inputTmpl: "spec:\n  containers:\n    - '{{ fromConfigMap \"ns\" \"name\" \"key\" | toYamlIndented }}'\n" +
+				"  template: '{{ fromConfigMap \"ns\" \"name\" \"key\" | fromYaml | toYamlIndented }}'",
+		},
+		"namespace not restricted": {
+			inputTmpl: "value: '{{ fromConfigMap \"other\" \"name\" \"key\" }}'",
+		},
+	}
+
+	for testName, test := range tests {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			resolver, err := NewResolver(k8sConfig, Config{InputIsYAML: true})
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			issues := resolver.Validate([]byte(test.inputTmpl), test.options)
+
+			if len(issues) != len(test.expectedIssues) {
+				t.Fatalf("Expected %d issues but got: %v", len(test.expectedIssues), issues)
+			}
+
+			for i, issue := range issues {
+				expected := test.expectedIssues[i]
+
+				if issue.Line != expected.line || issue.Column != expected.column {
+					t.Fatalf(
+						"Expected the issue at %d:%d but got: %s", expected.line, expected.column, issue.String(),
+					)
+				}
+
+				if expected.err != nil && !errors.Is(issue.Err, expected.err) {
+					t.Fatalf("Expected the issue to wrap %v but got: %v", expected.err, issue.Err)
+				}
+			}
+		})
+	}
+}
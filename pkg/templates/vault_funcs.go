@@ -0,0 +1,289 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVaultKubernetesAuthPath = "kubernetes"
+	defaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// vaultTokenRenewBuffer is how long before the expiration of a Vault token from the Kubernetes auth method a new
+	// token is requested. Half of the lease duration is used instead for shorter leases.
+	vaultTokenRenewBuffer = 30 * time.Second
+)
+
+var (
+	ErrVaultNotConfigured = errors.New("the fromVault template function requires Config.Vault to be set")
+	ErrVaultRequestFailed = errors.New("the Vault request failed")
+)
+
+// VaultConfig is the configuration of the HashiCorp Vault server used by the fromVault template function. Either Token
+// or KubernetesAuthRole must be set.
+//
+// - Address is the address of the Vault server, such as https://vault.example.com:8200.
+//
+// - Namespace is the Vault Enterprise namespace of the requests, which is optional.
+//
+// - Token is the Vault token of the requests.
+//
+// - KubernetesAuthRole is the role of the Vault Kubernetes auth method to log in with using the service account token
+// of the process. The Vault token from the login is reused until shortly before it expires.
+//
+// - KubernetesAuthPath is the mount path of the Vault Kubernetes auth method. This defaults to kubernetes.
+//
+// - ServiceAccountTokenPath is the path of the service account token file used with the Kubernetes auth method. This
+// defaults to the token mounted in Kubernetes pods.
+//
+// - HTTPClient is the HTTP client of the requests, such as one trusting a custom CA. This defaults to
+// http.DefaultClient.
+type VaultConfig struct {
+	Address                 string
+	Namespace               string
+	Token                   string
+	KubernetesAuthRole      string
+	KubernetesAuthPath      string
+	ServiceAccountTokenPath string
+	HTTPClient              *http.Client
+}
+
+// vaultClient reads secrets from Vault with the Vault HTTP API and caches the token of the Kubernetes auth method.
+type vaultClient struct {
+	config VaultConfig
+	now    func() time.Time
+	// lock protects token and tokenExpires.
+	lock  sync.Mutex
+	token string
+	// tokenExpires is when a new token is requested, which is zero if the token doesn't expire.
+	tokenExpires time.Time
+}
+
+func newVaultClient(config VaultConfig, now func() time.Time) (*vaultClient, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("%w: the Vault address must be set", ErrInvalidInput)
+	}
+
+	if config.Token == "" && config.KubernetesAuthRole == "" {
+		return nil, fmt.Errorf("%w: the Vault token or Kubernetes auth role must be set", ErrInvalidInput)
+	}
+
+	if config.KubernetesAuthPath == "" {
+		config.KubernetesAuthPath = defaultVaultKubernetesAuthPath
+	}
+
+	if config.ServiceAccountTokenPath == "" {
+		config.ServiceAccountTokenPath = defaultServiceAccountTokenPath
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	config.Address = strings.TrimSuffix(config.Address, "/")
+
+	return &vaultClient{config: config, now: now}, nil
+}
+
+func (t *TemplateResolver) fromVaultHelper(options *ResolveOptions) func(string, string) (string, error) {
+	return func(path string, key string) (string, error) {
+		value, err := t.fromVault(options, path, key)
+		if err != nil || !options.EncryptionEnabled {
+			return value, err
+		}
+
+		return t.protect(options, value)
+	}
+}
+
+// fromVault returns the value of the key in the Vault secret at the input path, such as
+// `{{ fromVault "secret/data/app" "password" }}` for a KV version 2 secrets engine mounted at secret. An empty string
+// is returned if the key is not in the secret, and an error wrapping ErrVaultRequestFailed is returned if the secret
+// can't be read. An error wrapping ErrVaultNotConfigured is returned if Config.Vault is not set.
+func (t *TemplateResolver) fromVault(options *ResolveOptions, path string, key string) (string, error) {
+	t.logger(options).V(2).Info("fromVault", "path", path, "key", key)
+
+	if t.vault == nil {
+		return "", ErrVaultNotConfigured
+	}
+
+	if path == "" || key == "" {
+		return "", fmt.Errorf("%w: the path and key must be specified", ErrInvalidInput)
+	}
+
+	if options.state != nil {
+		options.state.hasSensitiveData = true
+	}
+
+	data, err := t.vault.read(options.apiContext(), path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok || value == nil {
+		return "", nil
+	}
+
+	strValue, ok := value.(string)
+	if !ok {
+		strValue, err = encodeJSON(value, "")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if options.state != nil {
+		options.state.taint.add(strValue)
+	}
+
+	return strValue, nil
+}
+
+// read returns the data of the Vault secret at the path. The data of a KV version 2 secret is unwrapped from its
+// metadata.
+func (v *vaultClient) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	token, err := v.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	err = v.request(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Vault secret %s: %w", path, err)
+	}
+
+	innerData, hasData := response.Data["data"].(map[string]interface{})
+	_, hasMetadata := response.Data["metadata"].(map[string]interface{})
+
+	if hasData && hasMetadata {
+		return innerData, nil
+	}
+
+	return response.Data, nil
+}
+
+// getToken returns the configured Vault token or the cached token of the Kubernetes auth method, logging in again if
+// the cached token is about to expire.
+func (v *vaultClient) getToken(ctx context.Context) (string, error) {
+	if v.config.Token != "" {
+		return v.config.Token, nil
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.token != "" && (v.tokenExpires.IsZero() || v.now().Before(v.tokenExpires)) {
+		return v.token, nil
+	}
+
+	jwt, err := os.ReadFile(v.config.ServiceAccountTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read the service account token: %w", ErrVaultRequestFailed, err)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role": v.config.KubernetesAuthRole, "jwt": strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+	}
+
+	loginPath := "/v1/auth/" + strings.Trim(v.config.KubernetesAuthPath, "/") + "/login"
+
+	err = v.request(ctx, http.MethodPost, loginPath, "", body, &response)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to Vault with the Kubernetes auth method: %w", err)
+	}
+
+	if response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("%w: the Vault login response has no client token", ErrVaultRequestFailed)
+	}
+
+	v.token = response.Auth.ClientToken
+	v.tokenExpires = time.Time{}
+
+	// A lease duration of 0 is a token that doesn't expire
+	if response.Auth.LeaseDuration > 0 {
+		lease := time.Duration(response.Auth.LeaseDuration) * time.Second
+
+		renewBuffer := vaultTokenRenewBuffer
+		if lease/2 < renewBuffer {
+			renewBuffer = lease / 2
+		}
+
+		v.tokenExpires = v.now().Add(lease - renewBuffer)
+	}
+
+	return v.token, nil
+}
+
+// request sends a request to the Vault API and decodes the JSON response into the result. An error wrapping
+// ErrVaultRequestFailed is returned if the response status is not successful.
+func (v *vaultClient) request(
+	ctx context.Context, method string, path string, token string, body []byte, result interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, method, v.config.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVaultRequestFailed, err)
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVaultRequestFailed, err)
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVaultRequestFailed, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResponse struct {
+			Errors []string `json:"errors"`
+		}
+
+		_ = json.Unmarshal(respBody, &errResponse)
+
+		return fmt.Errorf(
+			"%w: status code %d: %s", ErrVaultRequestFailed, resp.StatusCode, strings.Join(errResponse.Errors, ", "),
+		)
+	}
+
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("%w: invalid response: %w", ErrVaultRequestFailed, err)
+	}
+
+	return nil
+}
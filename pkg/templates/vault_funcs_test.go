@@ -0,0 +1,277 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFakeVaultServer returns a Vault server with a KV version 2 secret at secret/data/app, a KV version 1 secret at
+// kv/app, and the Kubernetes auth method of the test-role role with tokens of the input lease duration in seconds. The
+// returned counter is the number of logins.
+func newFakeVaultServer(t *testing.T, leaseDuration int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var logins int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/kubernetes/login" {
+			body := map[string]string{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+
+			if body["role"] != "test-role" || body["jwt"] != "sa-token" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+
+				return
+			}
+
+			atomic.AddInt32(&logins, 1)
+			_, _ = fmt.Fprintf(w, `{"auth": {"client_token": "k8s-token", "lease_duration": %d}}`, leaseDuration)
+
+			return
+		}
+
+		if token := r.Header.Get("X-Vault-Token"); token != "root-token" && token != "k8s-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			_, _ = w.Write([]byte(
+				`{"data": {"data": {"password": "s3cr3t", "port": 5432}, "metadata": {"version": 2}}}`,
+			))
+		case "/v1/kv/app":
+			_, _ = w.Write([]byte(`{"data": {"password": "kv1-s3cr3t"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &logins
+}
+
+func TestFromVault(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newFakeVaultServer(t, 3600)
+
+	resolver, err := NewFakeResolver(
+		nil, Config{Vault: &VaultConfig{Address: server.URL + "/", Token: "root-token"}},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		expectedResult string
+		expectedErr    error
+	}{
+		"kv2":            {`data: '{{ fromVault "secret/data/app" "password" }}'`, "data: s3cr3t", nil},
+		"kv2_non_string": {`data: '{{ fromVault "secret/data/app" "port" }}'`, "data: \"5432\"", nil},
+		"kv1":            {`data: '{{ fromVault "kv/app" "password" }}'`, "data: kv1-s3cr3t", nil},
+		"missing_key":    {`data: '{{ fromVault "secret/data/app" "user" }}'`, "data: \"\"", nil},
+		"missing_secret": {`data: '{{ fromVault "secret/data/other" "user" }}'`, "", ErrVaultRequestFailed},
+		"missing_path":   {`data: '{{ fromVault "" "user" }}'`, "", ErrInvalidInput},
+		"leading_slash":  {`data: '{{ fromVault "/kv/app" "password" }}'`, "data: kv1-s3cr3t", nil},
+		"piped":          {`data: '{{ fromVault "kv/app" "password" | base64enc }}'`, "data: a3YxLXMzY3IzdA==", nil},
+	}
+
+	for name, test := range testcases {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, nil)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("Expected the error %v but got %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if !result.HasSensitiveData {
+				t.Fatal("Expected the result to have sensitive data")
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if strings.TrimSuffix(string(val), "\n") != test.expectedResult {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, string(val))
+			}
+		})
+	}
+}
+
+func TestFromVaultKubernetesAuth(t *testing.T) {
+	t.Parallel()
+
+	server, logins := newFakeVaultServer(t, 3600)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	now := time.Now()
+
+	client, err := newVaultClient(
+		VaultConfig{Address: server.URL, KubernetesAuthRole: "test-role", ServiceAccountTokenPath: tokenPath},
+		func() time.Time { return now },
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		data, err := client.read(context.Background(), "secret/data/app")
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if data["password"] != "s3cr3t" {
+			t.Fatalf("Expected the password s3cr3t but got %v", data["password"])
+		}
+	}
+
+	if *logins != 1 {
+		t.Fatalf("Expected the token to be cached but logged in %d times", *logins)
+	}
+
+	now = now.Add(time.Hour)
+
+	if _, err := client.read(context.Background(), "secret/data/app"); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if *logins != 2 {
+		t.Fatalf("Expected a new login after the token expired but logged in %d times", *logins)
+	}
+
+	client.config.KubernetesAuthRole = "other-role"
+	client.token = ""
+
+	if _, err := client.read(context.Background(), "secret/data/app"); !errors.Is(err, ErrVaultRequestFailed) {
+		t.Fatalf("Expected the error %v but got %v", ErrVaultRequestFailed, err)
+	}
+}
+
+func TestFromVaultKubernetesAuthLease(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		leaseDuration int
+		// elapsed are the durations after the first login of the later reads.
+		elapsed        []time.Duration
+		expectedLogins []int32
+	}{
+		"short lease is renewed halfway": {
+			leaseDuration:  20,
+			elapsed:        []time.Duration{9 * time.Second, 10 * time.Second},
+			expectedLogins: []int32{1, 2},
+		},
+		"lease equal to the renew buffer": {
+			leaseDuration:  30,
+			elapsed:        []time.Duration{14 * time.Second, 15 * time.Second},
+			expectedLogins: []int32{1, 2},
+		},
+		"long lease is renewed before the buffer": {
+			leaseDuration:  120,
+			elapsed:        []time.Duration{89 * time.Second, 90 * time.Second},
+			expectedLogins: []int32{1, 2},
+		},
+		"zero lease doesn't expire": {
+			leaseDuration:  0,
+			elapsed:        []time.Duration{time.Second, 365 * 24 * time.Hour},
+			expectedLogins: []int32{1, 1},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server, logins := newFakeVaultServer(t, test.leaseDuration)
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenPath, []byte("sa-token"), 0o600); err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			start := time.Now()
+			now := start
+
+			client, err := newVaultClient(
+				VaultConfig{Address: server.URL, KubernetesAuthRole: "test-role", ServiceAccountTokenPath: tokenPath},
+				func() time.Time { return now },
+			)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if _, err := client.read(context.Background(), "secret/data/app"); err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			for i, elapsed := range test.elapsed {
+				now = start.Add(elapsed)
+
+				if _, err := client.read(context.Background(), "secret/data/app"); err != nil {
+					t.Fatalf(err.Error())
+				}
+
+				if *logins != test.expectedLogins[i] {
+					t.Fatalf("Expected %d logins after %s but got %d", test.expectedLogins[i], elapsed, *logins)
+				}
+			}
+		})
+	}
+}
+
+func TestFromVaultNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(nil, Config{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, err = resolver.ResolveTemplate([]byte(`data: '{{ fromVault "secret/data/app" "password" }}'`), nil, nil)
+	if !errors.Is(err, ErrVaultNotConfigured) {
+		t.Fatalf("Expected the error %v but got %v", ErrVaultNotConfigured, err)
+	}
+
+	_, err = NewFakeResolver(nil, Config{Vault: &VaultConfig{Token: "root-token"}})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected the error %v but got %v", ErrInvalidInput, err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// verbatimPlaceholder is the format of the text replacing the verbatim blocks while the template is resolved. It has no
+// characters that need quoting in YAML and is not a prefix of another placeholder.
+const verbatimPlaceholder = "__go_template_utils_verbatim_%d__"
+
+// verbatimBlockRegex returns the regular expression matching a verbatim block with the input delimiters, such as
+// `{{ verbatim }}{{ .Literal }}{{ endVerbatim }}`, with the content of the block captured.
+func verbatimBlockRegex(startDelim string, stopDelim string) *regexp.Regexp {
+	d1 := regexp.QuoteMeta(startDelim)
+	d2 := regexp.QuoteMeta(stopDelim)
+
+	return regexp.MustCompile(`(?s)` + d1 + `\s*verbatim\s*` + d2 + `(.*?)` + d1 + `\s*endVerbatim\s*` + d2)
+}
+
+// verbatimStartRegex returns the regular expression matching the start marker of a verbatim block with the input
+// delimiters.
+func verbatimStartRegex(startDelim string, stopDelim string) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(startDelim) + `\s*verbatim\s*` + regexp.QuoteMeta(stopDelim))
+}
+
+// protectVerbatimBlocks replaces the verbatim blocks of the input template with placeholders so that their content is
+// not parsed or altered before execution. The replaced blocks, including their markers, are returned so that
+// restoreVerbatimBlocks can put them back in the output.
+func (t *TemplateResolver) protectVerbatimBlocks(options *ResolveOptions, tmpl string) (string, []string) {
+	startDelim, stopDelim := t.delims(options)
+	if !strings.Contains(tmpl, startDelim) {
+		return tmpl, nil
+	}
+
+	var blocks []string
+
+	protected := verbatimBlockRegex(startDelim, stopDelim).ReplaceAllStringFunc(tmpl, func(block string) string {
+		blocks = append(blocks, block)
+
+		return fmt.Sprintf(verbatimPlaceholder, len(blocks)-1)
+	})
+
+	return protected, blocks
+}
+
+// restoreVerbatimBlocks replaces the placeholders in the resolved output with the verbatim blocks returned by
+// protectVerbatimBlocks. The markers are kept so that later passes leave the blocks untouched until
+// stripVerbatimMarkers is called on the final result.
+func restoreVerbatimBlocks(resolved []byte, blocks []string) []byte {
+	if len(blocks) == 0 {
+		return resolved
+	}
+
+	replacements := make([]string, 0, len(blocks)*2)
+	for i, block := range blocks {
+		replacements = append(replacements, fmt.Sprintf(verbatimPlaceholder, i), block)
+	}
+
+	return []byte(strings.NewReplacer(replacements...).Replace(string(resolved)))
+}
+
+// hasTemplateOutsideVerbatim determines if the input template has template actions other than verbatim blocks.
+func (t *TemplateResolver) hasTemplateOutsideVerbatim(options *ResolveOptions, tmpl []byte) bool {
+	startDelim, _ := t.delims(options)
+	protected, _ := t.protectVerbatimBlocks(options, string(tmpl))
+
+	return strings.Contains(protected, startDelim)
+}
+
+// validateVerbatimBlocks returns an error wrapping ErrParseFailed if a verbatim block of the input template has no
+// end marker.
+func (t *TemplateResolver) validateVerbatimBlocks(options *ResolveOptions, tmpl string) error {
+	protected, _ := t.protectVerbatimBlocks(options, tmpl)
+
+	startDelim, stopDelim := t.delims(options)
+	if verbatimStartRegex(startDelim, stopDelim).MatchString(protected) {
+		return fmt.Errorf(
+			"%w: a verbatim block is missing the %sendVerbatim%s end marker", ErrParseFailed, startDelim, stopDelim,
+		)
+	}
+
+	return nil
+}
+
+// stripVerbatimMarkers removes the start and end markers of the verbatim blocks in the resolved JSON, leaving their
+// content as is.
+func (t *TemplateResolver) stripVerbatimMarkers(options *ResolveOptions, resolvedJSON []byte) []byte {
+	startDelim, stopDelim := t.delims(options)
+
+	// The delimiters may have characters that are escaped in JSON strings
+	jsonStartDelim, _ := json.Marshal(startDelim)
+	jsonStopDelim, _ := json.Marshal(stopDelim)
+
+	re := verbatimBlockRegex(
+		strings.Trim(string(jsonStartDelim), `"`), strings.Trim(string(jsonStopDelim), `"`),
+	)
+
+	return re.ReplaceAll(resolvedJSON, []byte("${1}"))
+}
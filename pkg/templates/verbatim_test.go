@@ -0,0 +1,101 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestResolveTemplateVerbatim(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := NewFakeResolver(
+		[]unstructured.Unstructured{{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "nested", "namespace": "app"},
+			"data":       map[string]interface{}{"template": `{{ "nested" | upper }}`},
+		}}},
+		Config{InputIsYAML: true},
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testcases := map[string]struct {
+		inputTmpl      string
+		options        ResolveOptions
+		expectedResult string
+		expectedErr    error
+	}{
+		"verbatim block": {
+			inputTmpl:      `summary: '{{ verbatim }}{{ $labels.instance }} is down{{ endVerbatim }}'`,
+			expectedResult: "summary: '{{ $labels.instance }} is down'",
+		},
+		"verbatim block with templates": {
+			inputTmpl:      `summary: '{{ "web" | upper }}: {{verbatim}}{{ $value | toInt }}{{endVerbatim}}'`,
+			expectedResult: "summary: 'WEB: {{ $value | toInt }}'",
+		},
+		"multiple verbatim blocks": {
+			inputTmpl: "expr: '{{ verbatim }}{{ .A }}{{ endVerbatim }}'\n" +
+				"replicas: '{{ \"3\" | toInt }}'\n" +
+				"summary: |\n  {{ verbatim }}{{ range .Alerts }}\n  {{ .Name }}\n  {{ end }}{{ endVerbatim }}\n",
+			expectedResult: "expr: '{{ .A }}'\nreplicas: 3\n" +
+				"summary: |\n  {{ range .Alerts }}\n  {{ .Name }}\n  {{ end }}",
+		},
+		"nested passes": {
+			inputTmpl: `nested: '{{ fromConfigMap "app" "nested" "template" }}'` + "\n" +
+				`summary: '{{ verbatim }}{{ .Literal }}{{ endVerbatim }}'`,
+			options:        ResolveOptions{MaxPasses: 2},
+			expectedResult: "nested: NESTED\nsummary: '{{ .Literal }}'",
+		},
+		"dependency order": {
+			inputTmpl: "name: '{{ \"web\" | upper }}'\n" +
+				`summary: '{{ fieldValue "name" }} {{ verbatim }}{{ .Literal }}{{ endVerbatim }}'` + "\n" +
+				`untemplated: '{{ verbatim }}{{ .Literal }}{{ endVerbatim }}'`,
+			options:        ResolveOptions{ResolveInDependencyOrder: true},
+			expectedResult: "name: WEB\nsummary: WEB {{ .Literal }}\nuntemplated: '{{ .Literal }}'",
+		},
+		"resolution delimiters": {
+			inputTmpl:      `summary: '[[ verbatim ]]{{ .Literal }} [[ "x" ]][[ endVerbatim ]] [[ "y" ]]'`,
+			options:        ResolveOptions{StartDelim: "[[", StopDelim: "]]"},
+			expectedResult: `summary: '{{ .Literal }} [[ "x" ]] y'`,
+		},
+		"missing end marker": {
+			inputTmpl:   `summary: '{{ verbatim }}{{ $labels.instance }} is down'`,
+			expectedErr: ErrParseFailed,
+		},
+	}
+
+	for testName, test := range testcases {
+		test := test
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			options := test.options
+
+			result, err := resolver.ResolveTemplate([]byte(test.inputTmpl), nil, &options)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected the error %v but got: %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			val, err := JSONToYAML(result.ResolvedJSON)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if strings.TrimSuffix(string(val), "\n") != test.expectedResult {
+				t.Fatalf("Expected %q but got %q", test.expectedResult, val)
+			}
+		})
+	}
+}